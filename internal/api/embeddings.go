@@ -0,0 +1,37 @@
+package api
+
+import "encoding/json"
+
+// EmbeddingsRequest is an OpenAI /v1/embeddings request.
+type EmbeddingsRequest struct {
+	Model string `json:"model"`
+	// Input is a string, an array of strings, an array of token IDs or an
+	// array of token ID arrays; it is passed through undecoded.
+	Input          json.RawMessage `json:"input"`
+	EncodingFormat string          `json:"encoding_format,omitempty"` // "float" or "base64"
+	Dimensions     *int            `json:"dimensions,omitempty"`
+	User           string          `json:"user,omitempty"`
+}
+
+// EmbeddingsResponse is an OpenAI /v1/embeddings response.
+type EmbeddingsResponse struct {
+	Object string           `json:"object"` // "list"
+	Data   []Embedding      `json:"data"`
+	Model  string           `json:"model"`
+	Usage  *EmbeddingsUsage `json:"usage,omitempty"`
+}
+
+// Embedding is one embedding vector of an EmbeddingsResponse.
+type Embedding struct {
+	Object string `json:"object"` // "embedding"
+	Index  int    `json:"index"`
+	// Embedding is an array of floats, or a base64 string when the
+	// request asked for encoding_format "base64".
+	Embedding json.RawMessage `json:"embedding"`
+}
+
+// EmbeddingsUsage is the token usage of an embeddings request.
+type EmbeddingsUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
@@ -3,6 +3,9 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
 // ErrorResponse represents an OpenAI API error response.
@@ -16,6 +19,9 @@ type ErrorDetail struct {
 	Type    string  `json:"type"`
 	Param   *string `json:"param"`
 	Code    *string `json:"code"`
+
+	// Extensions carries fields beyond the OpenAI format (omitted when nil).
+	Extensions any `json:"extensions,omitempty"`
 }
 
 // Common error types
@@ -122,3 +128,110 @@ func WriteUpstreamError(w http.ResponseWriter, err *UpstreamError) {
 		WriteError(w, http.StatusBadGateway, ErrorTypeServer, err.Message, nil, nil)
 	}
 }
+
+// ErrContextLengthExceeded reports a prompt that doesn't fit in the model's
+// context window. Token counts are 0 when the upstream message omits them.
+type ErrContextLengthExceeded struct {
+	Message         string
+	PromptTokens    int
+	ModelLimit      int
+	SuggestedModels []string // models whose context window fits the prompt, smallest first
+}
+
+// Error implements the error interface.
+func (e *ErrContextLengthExceeded) Error() string {
+	return e.Message
+}
+
+// Unwrap exposes the error as a 400 UpstreamError for callers that don't
+// know about this type.
+func (e *ErrContextLengthExceeded) Unwrap() error {
+	return NewUpstreamError(http.StatusBadRequest, e.Message)
+}
+
+// contextLengthPatterns identify context length errors in upstream messages.
+var contextLengthPatterns = []string{
+	"context_length_exceeded",
+	"context length",
+	"context window",
+	"maximum context",
+	"model_max_prompt_tokens_exceeded",
+	"prompt token count",
+	"prompt is too long",
+	"input token count",
+}
+
+// contextLengthFormats extract (prompt, limit) token counts from known
+// upstream messages. Capture groups are named so order can differ.
+var contextLengthFormats = []*regexp.Regexp{
+	// Copilot: "prompt token count of 140000 exceeds the limit of 128000"
+	regexp.MustCompile(`prompt token count of (?P<prompt>\d+) exceeds the limit of (?P<limit>\d+)`),
+	// Anthropic: "prompt is too long: 210000 tokens > 200000 maximum"
+	regexp.MustCompile(`prompt is too long: (?P<prompt>\d+) tokens > (?P<limit>\d+) maximum`),
+	// Gemini: "The input token count (1100000) exceeds the maximum number of tokens allowed (1048576)."
+	regexp.MustCompile(`input token count \((?P<prompt>\d+)\) exceeds the maximum number of tokens allowed \((?P<limit>\d+)\)`),
+	// OpenAI: "maximum context length is 8192 tokens. However, your messages resulted in 9000 tokens"
+	regexp.MustCompile(`maximum context length is (?P<limit>\d+) tokens.*?resulted in (?P<prompt>\d+) tokens`),
+}
+
+// ParseContextLengthExceeded returns an ErrContextLengthExceeded if message
+// describes a context length error, or nil otherwise.
+func ParseContextLengthExceeded(message string) *ErrContextLengthExceeded {
+	lower := strings.ToLower(message)
+	matched := false
+	for _, pattern := range contextLengthPatterns {
+		if strings.Contains(lower, pattern) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil
+	}
+
+	err := &ErrContextLengthExceeded{Message: message}
+	for _, re := range contextLengthFormats {
+		m := re.FindStringSubmatch(lower)
+		if m == nil {
+			continue
+		}
+		err.PromptTokens, _ = strconv.Atoi(m[re.SubexpIndex("prompt")])
+		err.ModelLimit, _ = strconv.Atoi(m[re.SubexpIndex("limit")])
+		break
+	}
+	return err
+}
+
+// WriteContextLengthExceeded writes a 400 context_length_exceeded error with
+// the token counts and suggested models in extensions.
+func WriteContextLengthExceeded(w http.ResponseWriter, err *ErrContextLengthExceeded) {
+	code := "context_length_exceeded"
+	param := "messages"
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+
+	resp := ErrorResponse{
+		Error: ErrorDetail{
+			Message: err.Message,
+			Type:    ErrorTypeInvalidRequest,
+			Code:    &code,
+			Param:   &param,
+			Extensions: map[string]any{
+				"prompt_tokens":    err.PromptTokens,
+				"model_limit":      err.ModelLimit,
+				"suggested_models": nonNilStrings(err.SuggestedModels),
+			},
+		},
+	}
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// nonNilStrings returns s, or an empty slice so it encodes as [] rather than null.
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+package api
+
+import "testing"
+
+func TestParseContextLengthExceeded(t *testing.T) {
+	tests := []struct {
+		name       string
+		message    string
+		wantMatch  bool
+		wantPrompt int
+		wantLimit  int
+	}{
+		{
+			name:       "copilot",
+			message:    "prompt token count of 140000 exceeds the limit of 128000",
+			wantMatch:  true,
+			wantPrompt: 140000,
+			wantLimit:  128000,
+		},
+		{
+			name:       "openai",
+			message:    "This model's maximum context length is 8192 tokens. However, your messages resulted in 9000 tokens.",
+			wantMatch:  true,
+			wantPrompt: 9000,
+			wantLimit:  8192,
+		},
+		{
+			name:       "anthropic",
+			message:    "prompt is too long: 210000 tokens > 200000 maximum",
+			wantMatch:  true,
+			wantPrompt: 210000,
+			wantLimit:  200000,
+		},
+		{
+			name:       "gemini",
+			message:    "The input token count (1100000) exceeds the maximum number of tokens allowed (1048576).",
+			wantMatch:  true,
+			wantPrompt: 1100000,
+			wantLimit:  1048576,
+		},
+		{name: "code without counts", message: "context_length_exceeded", wantMatch: true},
+		{name: "unrelated", message: "temperature must be at most 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ParseContextLengthExceeded(tt.message)
+			if (err != nil) != tt.wantMatch {
+				t.Fatalf("ParseContextLengthExceeded() = %v, want match %v", err, tt.wantMatch)
+			}
+			if err == nil {
+				return
+			}
+			if err.PromptTokens != tt.wantPrompt || err.ModelLimit != tt.wantLimit {
+				t.Errorf("tokens = (%d, %d), want (%d, %d)", err.PromptTokens, err.ModelLimit, tt.wantPrompt, tt.wantLimit)
+			}
+			if err.Message != tt.message {
+				t.Errorf("Message = %q, want the upstream message", err.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// NormalizeFunctions rewrites the legacy function calling fields into their
+// tools equivalents, so providers only ever see the modern format:
+//
+//   - functions becomes tools of type "function"
+//   - function_call ("none", "auto" or {"name": ...}) becomes tool_choice
+//   - assistant messages with function_call get an equivalent tool_calls entry
+//   - "function" role messages become "tool" messages answering that call
+//
+// Legacy messages carry no call IDs, so synthetic IDs are generated and each
+// function result is matched to the most recent unanswered call of the same
+// name. Modern fields already present take precedence and are left as is.
+func (r *ChatCompletionRequest) NormalizeFunctions() error {
+	if len(r.Functions) > 0 && len(r.Tools) == 0 {
+		r.Tools = make([]Tool, len(r.Functions))
+		for i, fn := range r.Functions {
+			r.Tools[i] = Tool{Type: "function", Function: fn}
+		}
+	}
+	r.Functions = nil
+
+	if len(r.FunctionCall) > 0 && len(r.ToolChoice) == 0 {
+		choice, err := functionCallToToolChoice(r.FunctionCall)
+		if err != nil {
+			return err
+		}
+		r.ToolChoice = choice
+	}
+	r.FunctionCall = nil
+
+	pending := make(map[string][]string) // function name -> unanswered call IDs
+	for i := range r.Messages {
+		msg := &r.Messages[i]
+		switch {
+		case msg.Role == "assistant" && msg.FunctionCall != nil:
+			if len(msg.ToolCalls) == 0 {
+				id := fmt.Sprintf("call_legacy_%d", i)
+				msg.ToolCalls = []ToolCall{{
+					ID:       id,
+					Type:     "function",
+					Function: *msg.FunctionCall,
+				}}
+				pending[msg.FunctionCall.Name] = append(pending[msg.FunctionCall.Name], id)
+			}
+			msg.FunctionCall = nil
+		case msg.Role == "function":
+			msg.Role = "tool"
+			if msg.ToolCallID == "" {
+				ids := pending[msg.Name]
+				if len(ids) == 0 {
+					return fmt.Errorf("messages[%d]: function result %q has no matching function_call", i, msg.Name)
+				}
+				msg.ToolCallID = ids[len(ids)-1]
+				pending[msg.Name] = ids[:len(ids)-1]
+			}
+			// Tool messages are identified by their call ID alone
+			msg.Name = ""
+		}
+	}
+	return nil
+}
+
+// functionCallToToolChoice converts a legacy function_call value.
+func functionCallToToolChoice(raw json.RawMessage) (json.RawMessage, error) {
+	var mode string
+	if err := json.Unmarshal(raw, &mode); err == nil {
+		return raw, nil // "none" and "auto" mean the same for tool_choice
+	}
+
+	var named struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Name == "" {
+		return nil, fmt.Errorf("invalid function_call: must be \"none\", \"auto\" or {\"name\": ...}")
+	}
+	return json.Marshal(ToolChoice{Mode: ToolChoiceFunction, Function: named.Name})
+}
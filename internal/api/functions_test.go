@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// normalizedJSON reads a request fixture, normalizes it and returns it as
+// JSON.
+func normalizedJSON(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req ChatCompletionRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	if err := req.NormalizeFunctions(); err != nil {
+		t.Fatalf("%s: NormalizeFunctions() error = %v", path, err)
+	}
+	out, err := json.Marshal(&req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestNormalizeFunctionsMatchesTools(t *testing.T) {
+	legacy := normalizedJSON(t, "testdata/functions/functions.json")
+	modern := normalizedJSON(t, "testdata/functions/tools.json")
+	if legacy != modern {
+		t.Errorf("functions request normalizes to\n%s\nwant the tools request\n%s", legacy, modern)
+	}
+	for _, field := range []string{`"functions"`, `"function_call"`, `"role":"function"`, `"name":"get_weather","content"`} {
+		if strings.Contains(legacy, field) {
+			t.Errorf("normalized request still contains %s", field)
+		}
+	}
+}
+
+func TestNormalizeFunctionCall(t *testing.T) {
+	tests := []struct {
+		name         string
+		functionCall string
+		want         string
+		wantErr      bool
+	}{
+		{name: "none", functionCall: `"none"`, want: `"none"`},
+		{name: "auto", functionCall: `"auto"`, want: `"auto"`},
+		{name: "named", functionCall: `{"name":"get_weather"}`, want: `{"type":"function","function":{"name":"get_weather"}}`},
+		{name: "missing name", functionCall: `{}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := ChatCompletionRequest{FunctionCall: json.RawMessage(tt.functionCall)}
+			err := req.NormalizeFunctions()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("NormalizeFunctions() succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeFunctions() error = %v", err)
+			}
+			if string(req.ToolChoice) != tt.want || req.FunctionCall != nil {
+				t.Errorf("tool_choice = %s, function_call = %s; want %s and none", req.ToolChoice, req.FunctionCall, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeFunctionsUnmatchedResult(t *testing.T) {
+	req := ChatCompletionRequest{Messages: []Message{{Role: "function", Name: "get_weather", Content: json.RawMessage(`"sunny"`)}}}
+	if err := req.NormalizeFunctions(); err == nil || !strings.Contains(err.Error(), "no matching function_call") {
+		t.Errorf("NormalizeFunctions() error = %v, want no matching function_call", err)
+	}
+}
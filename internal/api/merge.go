@@ -0,0 +1,254 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MergeChunks folds a sequence of streaming chunks into the equivalent
+// non-streaming response.
+//
+// The result mirrors what the upstream would have returned for the same
+// request with stream=false: content, refusal and reasoning deltas are
+// concatenated per choice, tool call deltas are reassembled by index (with
+// argument fragments appended in order), the role is taken from whichever
+// chunk carries it (normally the first) and the finish reason from the last
+// chunk that sets it. Usage is taken from the last chunk that includes it.
+func MergeChunks(chunks []ChatCompletionChunk) (*ChatCompletionResponse, error) {
+	if len(chunks) == 0 {
+		return nil, errors.New("no chunks to merge")
+	}
+
+	resp := &ChatCompletionResponse{
+		Object: "chat.completion",
+	}
+
+	choices := make(map[int]*choiceAccum)
+	for i := range chunks {
+		chunk := &chunks[i]
+
+		if chunk.ID != "" {
+			if resp.ID == "" {
+				resp.ID = chunk.ID
+			} else if chunk.ID != resp.ID {
+				return nil, fmt.Errorf("chunk %d belongs to a different completion (id %q, expected %q)", i, chunk.ID, resp.ID)
+			}
+		}
+		if resp.Model == "" {
+			resp.Model = chunk.Model
+		}
+		if resp.Created == 0 {
+			resp.Created = chunk.Created
+		}
+		if resp.SystemFingerprint == "" {
+			resp.SystemFingerprint = chunk.SystemFingerprint
+		}
+		if chunk.Usage != nil {
+			resp.Usage = chunk.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			acc, ok := choices[choice.Index]
+			if !ok {
+				acc = newChoiceAccum()
+				choices[choice.Index] = acc
+			}
+			acc.add(&choice)
+		}
+	}
+
+	indexes := make([]int, 0, len(choices))
+	for idx := range choices {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	resp.Choices = make([]Choice, 0, len(indexes))
+	for _, idx := range indexes {
+		resp.Choices = append(resp.Choices, choices[idx].build(idx))
+	}
+
+	return resp, nil
+}
+
+// choiceAccum accumulates the deltas of a single choice.
+type choiceAccum struct {
+	role             string
+	content          strings.Builder
+	refusal          strings.Builder
+	reasoning        strings.Builder
+	sawReasoning     bool
+	reasoningSummary strings.Builder
+	toolCalls        map[int]*ToolCall
+	finishReason     *string
+	logprobs         *Logprobs
+}
+
+func newChoiceAccum() *choiceAccum {
+	return &choiceAccum{toolCalls: make(map[int]*ToolCall)}
+}
+
+func (a *choiceAccum) add(choice *Choice) {
+	if choice.FinishReason != nil {
+		a.finishReason = choice.FinishReason
+	}
+	if choice.Logprobs != nil {
+		if a.logprobs == nil {
+			a.logprobs = &Logprobs{}
+		}
+		lp := choice.Logprobs
+		a.logprobs.Content = append(a.logprobs.Content, lp.Content...)
+		a.logprobs.Tokens = append(a.logprobs.Tokens, lp.Tokens...)
+		a.logprobs.TokenLogprobs = append(a.logprobs.TokenLogprobs, lp.TokenLogprobs...)
+		a.logprobs.TopLogprobs = append(a.logprobs.TopLogprobs, lp.TopLogprobs...)
+		a.logprobs.TextOffset = append(a.logprobs.TextOffset, lp.TextOffset...)
+	}
+
+	delta := choice.Delta
+	if delta == nil {
+		return
+	}
+	if delta.Role != "" {
+		a.role = delta.Role
+	}
+	a.content.WriteString(delta.Content)
+	a.refusal.WriteString(delta.Refusal)
+	a.reasoningSummary.WriteString(delta.ReasoningSummary)
+	if delta.Reasoning != nil {
+		a.sawReasoning = true
+		for _, rc := range delta.Reasoning.Content {
+			a.reasoning.WriteString(rc.Text)
+		}
+	}
+
+	for pos, tc := range delta.ToolCalls {
+		// Chunks without an explicit index are positional within the delta
+		idx := pos
+		if tc.Index != nil {
+			idx = *tc.Index
+		}
+		acc, ok := a.toolCalls[idx]
+		if !ok {
+			acc = &ToolCall{}
+			a.toolCalls[idx] = acc
+		}
+		if tc.ID != "" {
+			acc.ID = tc.ID
+		}
+		if tc.Type != "" {
+			acc.Type = tc.Type
+		}
+		if tc.Function.Name != "" {
+			acc.Function.Name = tc.Function.Name
+		}
+		acc.Function.Arguments += tc.Function.Arguments
+	}
+}
+
+func (a *choiceAccum) build(index int) Choice {
+	role := a.role
+	if role == "" {
+		role = "assistant"
+	}
+	msg := &Message{
+		Role:             role,
+		Refusal:          a.refusal.String(),
+		ReasoningSummary: a.reasoningSummary.String(),
+	}
+	if a.sawReasoning {
+		msg.Reasoning = &ReasoningOutput{
+			Content: []ReasoningContent{{Type: "text", Text: a.reasoning.String()}},
+		}
+	}
+
+	// Tool calls are emitted in index order and, as in non-streaming
+	// responses, without the streaming-only Index field
+	if len(a.toolCalls) > 0 {
+		idxs := make([]int, 0, len(a.toolCalls))
+		for idx := range a.toolCalls {
+			idxs = append(idxs, idx)
+		}
+		sort.Ints(idxs)
+		for _, idx := range idxs {
+			tc := a.toolCalls[idx]
+			if tc.Type == "" {
+				tc.Type = "function"
+			}
+			msg.ToolCalls = append(msg.ToolCalls, *tc)
+		}
+	}
+
+	// Non-streaming responses use null content for pure tool call turns
+	content := a.content.String()
+	if content != "" || len(msg.ToolCalls) == 0 {
+		msg.SetContentString(content)
+	}
+
+	return Choice{
+		Index:        index,
+		Message:      msg,
+		FinishReason: a.finishReason,
+		Logprobs:     a.logprobs,
+	}
+}
+
+// ResponseToChunks splits a non-streaming response into the streaming chunks
+// a client would have received for it. It is the inverse of MergeChunks and
+// is used to simulate streaming on top of a buffered response.
+//
+// Each choice yields one delta chunk carrying its full content, followed by a
+// single chunk with the finish reasons. When includeUsage is set and the
+// response has usage, a final usage-only chunk with empty choices is appended
+// (matching stream_options.include_usage behavior).
+func ResponseToChunks(resp *ChatCompletionResponse, includeUsage bool) []ChatCompletionChunk {
+	newChunk := func(choices []Choice) ChatCompletionChunk {
+		return ChatCompletionChunk{
+			ID:                resp.ID,
+			Object:            "chat.completion.chunk",
+			Created:           resp.Created,
+			Model:             resp.Model,
+			SystemFingerprint: resp.SystemFingerprint,
+			Choices:           choices,
+		}
+	}
+
+	var chunks []ChatCompletionChunk
+	finish := make([]Choice, 0, len(resp.Choices))
+	for _, choice := range resp.Choices {
+		delta := &Delta{Role: "assistant"}
+		if msg := choice.Message; msg != nil {
+			if msg.Role != "" {
+				delta.Role = msg.Role
+			}
+			delta.Content = msg.GetContentString()
+			delta.Refusal = msg.Refusal
+			delta.Reasoning = msg.Reasoning
+			delta.ReasoningSummary = msg.ReasoningSummary
+			for i, tc := range msg.ToolCalls {
+				tc.Index = &i
+				delta.ToolCalls = append(delta.ToolCalls, tc)
+			}
+		}
+		chunks = append(chunks, newChunk([]Choice{{
+			Index:    choice.Index,
+			Delta:    delta,
+			Logprobs: choice.Logprobs,
+		}}))
+		finish = append(finish, Choice{
+			Index:        choice.Index,
+			Delta:        &Delta{},
+			FinishReason: choice.FinishReason,
+		})
+	}
+	chunks = append(chunks, newChunk(finish))
+
+	if includeUsage && resp.Usage != nil {
+		usageChunk := newChunk([]Choice{})
+		usageChunk.Usage = resp.Usage
+		chunks = append(chunks, usageChunk)
+	}
+
+	return chunks
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readChunks parses the data events of a recorded SSE stream.
+func readChunks(t *testing.T, path string) []ChatCompletionChunk {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var chunks []ChatCompletionChunk
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return chunks
+}
+
+// canonicalJSON re-encodes data through ChatCompletionResponse, so fields the
+// proxy doesn't model (e.g. annotations) and formatting are ignored.
+func canonicalJSON(t *testing.T, data []byte) string {
+	t.Helper()
+	var resp ChatCompletionResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.MarshalIndent(&resp, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// TestMergeChunksGolden merges recorded streams and compares the result to
+// the non-streaming response for the same prompt, in testdata/merge.
+func TestMergeChunksGolden(t *testing.T) {
+	streams, err := filepath.Glob("testdata/merge/*.sse")
+	if err != nil || len(streams) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	for _, stream := range streams {
+		name := strings.TrimSuffix(filepath.Base(stream), ".sse")
+		t.Run(name, func(t *testing.T) {
+			merged, err := MergeChunks(readChunks(t, stream))
+			if err != nil {
+				t.Fatalf("MergeChunks() error = %v", err)
+			}
+			got, err := json.Marshal(merged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden, err := os.ReadFile(strings.TrimSuffix(stream, ".sse") + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := canonicalJSON(t, got), canonicalJSON(t, golden); got != want {
+				t.Errorf("merged response differs from the non-streaming response\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestMergeChunksEdgeCases(t *testing.T) {
+	if _, err := MergeChunks(nil); err == nil {
+		t.Error("MergeChunks(nil) succeeded, want an error")
+	}
+
+	mixed := []ChatCompletionChunk{{ID: "a"}, {ID: "b"}}
+	if _, err := MergeChunks(mixed); err == nil {
+		t.Error("MergeChunks() merged chunks of different completions")
+	}
+
+	// No role in any chunk defaults to assistant
+	resp, err := MergeChunks([]ChatCompletionChunk{{ID: "a", Choices: []Choice{{Delta: &Delta{Content: "hi"}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := resp.Choices[0].Message; msg.Role != "assistant" || !bytes.Equal(msg.Content, []byte(`"hi"`)) {
+		t.Errorf("message = %+v, want assistant saying hi", msg)
+	}
+}
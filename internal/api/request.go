@@ -0,0 +1,35 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ToHTTPRequest builds the POST request that sends req to endpoint as JSON.
+// Content-Type is application/json and Accept follows req.Stream
+// (text/event-stream or application/json); entries in headers are set last
+// and may override either.
+func ToHTTPRequest(ctx context.Context, req *ChatCompletionRequest, endpoint string, headers map[string]string) (*http.Request, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if req.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	} else {
+		httpReq.Header.Set("Accept", "application/json")
+	}
+	for name, value := range headers {
+		httpReq.Header.Set(name, value)
+	}
+	return httpReq, nil
+}
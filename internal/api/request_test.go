@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+type requestTestKey struct{}
+
+func TestToHTTPRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		stream     bool
+		headers    map[string]string
+		wantAccept string
+	}{
+		{name: "non-streaming", wantAccept: "application/json"},
+		{name: "streaming", stream: true, wantAccept: "text/event-stream"},
+		{name: "headers override", stream: true, headers: map[string]string{"Accept": "*/*", "X-Custom": "1"}, wantAccept: "*/*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &ChatCompletionRequest{Model: "gpt-4o", Messages: []Message{UserMessage("hello")}, Stream: tt.stream}
+			ctx := context.WithValue(context.Background(), requestTestKey{}, "marker")
+			httpReq, err := ToHTTPRequest(ctx, req, "https://api.example.com/v1/chat/completions", tt.headers)
+			if err != nil {
+				t.Fatalf("ToHTTPRequest() error = %v", err)
+			}
+
+			if httpReq.Method != http.MethodPost || httpReq.URL.String() != "https://api.example.com/v1/chat/completions" {
+				t.Errorf("request = %s %s, want POST to the endpoint", httpReq.Method, httpReq.URL)
+			}
+			if httpReq.Context() != ctx {
+				t.Error("request does not carry the caller's context")
+			}
+			if got := httpReq.Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if got := httpReq.Header.Get("Accept"); got != tt.wantAccept {
+				t.Errorf("Accept = %q, want %q", got, tt.wantAccept)
+			}
+			for name, value := range tt.headers {
+				if got := httpReq.Header.Get(name); got != value {
+					t.Errorf("%s = %q, want %q", name, got, value)
+				}
+			}
+
+			body, err := io.ReadAll(httpReq.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if httpReq.ContentLength != int64(len(body)) {
+				t.Errorf("ContentLength = %d, want %d", httpReq.ContentLength, len(body))
+			}
+			want, _ := json.Marshal(req)
+			if string(body) != string(want) {
+				t.Errorf("body = %s, want %s", body, want)
+			}
+			// The body can be replayed for retries and redirects
+			if httpReq.GetBody == nil {
+				t.Error("GetBody = nil, want a replayable body")
+			}
+		})
+	}
+}
+
+func TestToHTTPRequestInvalidEndpoint(t *testing.T) {
+	if _, err := ToHTTPRequest(context.Background(), &ChatCompletionRequest{}, "://missing-scheme", nil); err == nil {
+		t.Error("ToHTTPRequest() error = nil, want an error for an invalid endpoint")
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// StrictSchemaViolations checks a function's parameters schema against the
+// subset of JSON Schema that strict mode accepts and returns a description
+// of each violation, or nil if the schema is valid:
+//
+//   - every object schema must set "additionalProperties": false
+//   - "anyOf" may only make a single schema nullable (one non-null branch)
+//
+// Paths in the messages are rooted at "parameters".
+func StrictSchemaViolations(parameters json.RawMessage) []string {
+	if len(parameters) == 0 {
+		return nil
+	}
+	var schema any
+	if err := json.Unmarshal(parameters, &schema); err != nil {
+		return []string{fmt.Sprintf("parameters: invalid JSON: %v", err)}
+	}
+	var violations []string
+	checkStrictSchema("parameters", schema, &violations)
+	return violations
+}
+
+// checkStrictSchema walks schema, appending violations found at path and below.
+func checkStrictSchema(path string, schema any, violations *[]string) {
+	obj, ok := schema.(map[string]any)
+	if !ok {
+		return
+	}
+
+	if isObjectSchema(obj) {
+		if ap, ok := obj["additionalProperties"].(bool); !ok || ap {
+			*violations = append(*violations, fmt.Sprintf("%s: additionalProperties must be false", path))
+		}
+	}
+
+	if anyOf, ok := obj["anyOf"].([]any); ok {
+		nonNull := 0
+		for _, branch := range anyOf {
+			if !isNullSchema(branch) {
+				nonNull++
+			}
+		}
+		if nonNull > 1 {
+			*violations = append(*violations,
+				fmt.Sprintf("%s: anyOf may only combine a single schema with null", path))
+		}
+		for i, branch := range anyOf {
+			checkStrictSchema(fmt.Sprintf("%s.anyOf[%d]", path, i), branch, violations)
+		}
+	}
+
+	for _, key := range []string{"properties", "$defs", "definitions"} {
+		children, ok := obj[key].(map[string]any)
+		if !ok {
+			continue
+		}
+		// Sort for a stable violation order
+		names := make([]string, 0, len(children))
+		for name := range children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			checkStrictSchema(fmt.Sprintf("%s.%s.%s", path, key, name), children[name], violations)
+		}
+	}
+
+	if items, ok := obj["items"]; ok {
+		checkStrictSchema(path+".items", items, violations)
+	}
+}
+
+// isObjectSchema reports whether schema describes an object.
+func isObjectSchema(schema map[string]any) bool {
+	if _, ok := schema["properties"]; ok {
+		return true
+	}
+	switch t := schema["type"].(type) {
+	case string:
+		return t == "object"
+	case []any:
+		for _, v := range t {
+			if v == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isNullSchema reports whether schema only accepts null.
+func isNullSchema(schema any) bool {
+	obj, ok := schema.(map[string]any)
+	return ok && obj["type"] == "null"
+}
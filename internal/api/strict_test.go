@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestStrictSchemaViolations(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   []string
+	}{
+		{name: "empty", schema: ``},
+		{
+			name:   "valid",
+			schema: `{"type":"object","properties":{"city":{"type":"string"}},"required":["city"],"additionalProperties":false}`,
+		},
+		{
+			name:   "nullable anyOf",
+			schema: `{"type":"object","properties":{"unit":{"anyOf":[{"type":"string"},{"type":"null"}]}},"additionalProperties":false}`,
+		},
+		{
+			name:   "missing additionalProperties",
+			schema: `{"type":"object","properties":{"city":{"type":"string"}}}`,
+			want:   []string{"parameters: additionalProperties must be false"},
+		},
+		{
+			name:   "additionalProperties true",
+			schema: `{"type":"object","properties":{},"additionalProperties":true}`,
+			want:   []string{"parameters: additionalProperties must be false"},
+		},
+		{
+			name: "nested object",
+			schema: `{"type":"object","additionalProperties":false,"properties":{` +
+				`"location":{"type":"object","properties":{"lat":{"type":"number"}}},` +
+				`"tags":{"type":"array","items":{"type":["object","null"]}}}}`,
+			want: []string{
+				"parameters.properties.location: additionalProperties must be false",
+				"parameters.properties.tags.items: additionalProperties must be false",
+			},
+		},
+		{
+			name: "anyOf with several schemas",
+			schema: `{"type":"object","additionalProperties":false,"properties":{` +
+				`"id":{"anyOf":[{"type":"string"},{"type":"integer"}]}}}`,
+			want: []string{"parameters.properties.id: anyOf may only combine a single schema with null"},
+		},
+		{
+			name: "definitions",
+			schema: `{"type":"object","additionalProperties":false,"properties":{},` +
+				`"$defs":{"point":{"type":"object","properties":{"x":{"type":"number"}}}}}`,
+			want: []string{"parameters.$defs.point: additionalProperties must be false"},
+		},
+		{
+			name:   "invalid JSON",
+			schema: `{"type":`,
+			want:   []string{"parameters: invalid JSON: unexpected end of JSON input"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StrictSchemaViolations(json.RawMessage(tt.schema))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("StrictSchemaViolations() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Tool choice modes. ToolChoiceFunction forces a call to ToolChoice.Function.
+const (
+	ToolChoiceNone     = "none"
+	ToolChoiceAuto     = "auto"
+	ToolChoiceRequired = "required"
+	ToolChoiceFunction = "function"
+)
+
+// ToolChoice is a parsed tool_choice: "none", "auto", "required", or
+// {"type": "function", "function": {"name": ...}}.
+type ToolChoice struct {
+	Mode     string // one of the ToolChoice* constants
+	Function string // function name, set when Mode is ToolChoiceFunction
+}
+
+var errInvalidToolChoice = errors.New(`invalid tool_choice: expected "none", "auto", "required" or {"type":"function","function":{"name":...}}`)
+
+// ParseToolChoice parses a tool_choice value. It returns nil when raw is
+// empty or null.
+func ParseToolChoice(raw json.RawMessage) (*ToolChoice, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var tc ToolChoice
+	if err := json.Unmarshal(raw, &tc); err != nil {
+		return nil, err
+	}
+	return &tc, nil
+}
+
+// MarshalJSON encodes the choice in OpenAI's format.
+func (tc ToolChoice) MarshalJSON() ([]byte, error) {
+	switch tc.Mode {
+	case ToolChoiceNone, ToolChoiceAuto, ToolChoiceRequired:
+		return json.Marshal(tc.Mode)
+	case ToolChoiceFunction:
+		if tc.Function == "" {
+			return nil, errInvalidToolChoice
+		}
+		return json.Marshal(namedToolChoice{Type: ToolChoiceFunction, Function: functionName{Name: tc.Function}})
+	default:
+		return nil, fmt.Errorf("invalid tool_choice mode %q", tc.Mode)
+	}
+}
+
+// UnmarshalJSON decodes any of the four tool_choice forms.
+func (tc *ToolChoice) UnmarshalJSON(data []byte) error {
+	var mode string
+	if err := json.Unmarshal(data, &mode); err == nil {
+		switch mode {
+		case ToolChoiceNone, ToolChoiceAuto, ToolChoiceRequired:
+			*tc = ToolChoice{Mode: mode}
+			return nil
+		default:
+			return fmt.Errorf("invalid tool_choice %q", mode)
+		}
+	}
+
+	var named namedToolChoice
+	if err := json.Unmarshal(data, &named); err != nil || named.Type != ToolChoiceFunction || named.Function.Name == "" {
+		return errInvalidToolChoice
+	}
+	*tc = ToolChoice{Mode: ToolChoiceFunction, Function: named.Function.Name}
+	return nil
+}
+
+// namedToolChoice is the object form of tool_choice.
+type namedToolChoice struct {
+	Type     string       `json:"type"`
+	Function functionName `json:"function"`
+}
+
+type functionName struct {
+	Name string `json:"name"`
+}
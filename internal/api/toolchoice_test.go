@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToolChoiceRoundTrip(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want ToolChoice
+	}{
+		{raw: `"none"`, want: ToolChoice{Mode: ToolChoiceNone}},
+		{raw: `"auto"`, want: ToolChoice{Mode: ToolChoiceAuto}},
+		{raw: `"required"`, want: ToolChoice{Mode: ToolChoiceRequired}},
+		{raw: `{"type":"function","function":{"name":"get_weather"}}`, want: ToolChoice{Mode: ToolChoiceFunction, Function: "get_weather"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			var got ToolChoice
+			if err := json.Unmarshal([]byte(tt.raw), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, tt.want)
+			}
+
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			assertJSONEqual(t, string(data), tt.raw)
+
+			parsed, err := ParseToolChoice(json.RawMessage(tt.raw))
+			if err != nil || parsed == nil || *parsed != tt.want {
+				t.Errorf("ParseToolChoice() = %+v, %v, want %+v", parsed, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolChoiceInRequest(t *testing.T) {
+	// The request keeps tool_choice raw, so any form passes through verbatim
+	for _, choice := range []string{`"required"`, `{"type":"function","function":{"name":"get_weather"}}`} {
+		in := `{"model":"gpt-4o","messages":[],"tool_choice":` + choice + `}`
+		req, out := roundTrip(t, in)
+		if string(req.ToolChoice) != choice {
+			t.Errorf("ToolChoice = %s, want %s", req.ToolChoice, choice)
+		}
+		assertJSONEqual(t, out, in)
+	}
+}
+
+func TestParseToolChoiceEmpty(t *testing.T) {
+	for _, raw := range []string{"", "null"} {
+		if got, err := ParseToolChoice(json.RawMessage(raw)); got != nil || err != nil {
+			t.Errorf("ParseToolChoice(%q) = %+v, %v, want nil", raw, got, err)
+		}
+	}
+}
+
+func TestToolChoiceInvalid(t *testing.T) {
+	for _, raw := range []string{
+		`"any"`,
+		`"Auto"`,
+		`42`,
+		`{"type":"function"}`,
+		`{"type":"function","function":{}}`,
+		`{"type":"code_interpreter","function":{"name":"run"}}`,
+		`["auto"]`,
+	} {
+		if got, err := ParseToolChoice(json.RawMessage(raw)); err == nil {
+			t.Errorf("ParseToolChoice(%s) = %+v, want an error", raw, got)
+		}
+	}
+
+	for _, tc := range []ToolChoice{{}, {Mode: "any"}, {Mode: ToolChoiceFunction}} {
+		if data, err := json.Marshal(tc); err == nil {
+			t.Errorf("Marshal(%+v) = %s, want an error", tc, data)
+		}
+	}
+}
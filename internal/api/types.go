@@ -5,25 +5,32 @@ import "encoding/json"
 
 // ChatCompletionRequest represents an OpenAI chat completion request.
 type ChatCompletionRequest struct {
-	Model               string          `json:"model"`
-	Messages            []Message       `json:"messages"`
-	Temperature         *float64        `json:"temperature,omitempty"`
-	TopP                *float64        `json:"top_p,omitempty"`
-	N                   *int            `json:"n,omitempty"`
-	Stream              bool            `json:"stream,omitempty"`
-	StreamOptions       *StreamOptions  `json:"stream_options,omitempty"`
-	Stop                json.RawMessage `json:"stop,omitempty"` // string or []string
-	MaxTokens           *int            `json:"max_tokens,omitempty"`
-	MaxCompletionTokens *int            `json:"max_completion_tokens,omitempty"` // Newer replacement for max_tokens
-	PresencePenalty     *float64        `json:"presence_penalty,omitempty"`
-	FrequencyPenalty    *float64        `json:"frequency_penalty,omitempty"`
-	LogitBias           map[string]int  `json:"logit_bias,omitempty"`
-	User                string          `json:"user,omitempty"`
-	Tools               []Tool          `json:"tools,omitempty"`
-	ToolChoice          json.RawMessage `json:"tool_choice,omitempty"` // "none", "auto", "required", or object
-	ParallelToolCalls   *bool           `json:"parallel_tool_calls,omitempty"`
-	ResponseFormat      *ResponseFormat `json:"response_format,omitempty"`
-	Seed                *int            `json:"seed,omitempty"`
+	Model               string             `json:"model"`
+	Messages            []Message          `json:"messages"`
+	Temperature         *float64           `json:"temperature,omitempty"`
+	TopP                *float64           `json:"top_p,omitempty"`
+	N                   *int               `json:"n,omitempty"`
+	Stream              bool               `json:"stream,omitempty"`
+	StreamOptions       *StreamOptions     `json:"stream_options,omitempty"`
+	Stop                json.RawMessage    `json:"stop,omitempty"` // string or []string
+	MaxTokens           *int               `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int               `json:"max_completion_tokens,omitempty"` // Newer replacement for max_tokens
+	PresencePenalty     *float64           `json:"presence_penalty,omitempty"`
+	FrequencyPenalty    *float64           `json:"frequency_penalty,omitempty"`
+	LogitBias           map[string]int     `json:"logit_bias,omitempty"`
+	Logprobs            *bool              `json:"logprobs,omitempty"`
+	TopLogprobs         *int               `json:"top_logprobs,omitempty"` // 0-20, requires logprobs
+	User                string             `json:"user,omitempty"`
+	Tools               []Tool             `json:"tools,omitempty"`
+	ToolChoice          json.RawMessage    `json:"tool_choice,omitempty"`   // "none", "auto", "required", or object
+	Functions           []Function         `json:"functions,omitempty"`     // Deprecated: legacy form of Tools, see NormalizeFunctions
+	FunctionCall        json.RawMessage    `json:"function_call,omitempty"` // Deprecated: legacy form of ToolChoice, see NormalizeFunctions
+	ParallelToolCalls   *bool              `json:"parallel_tool_calls,omitempty"`
+	ResponseFormat      *ResponseFormat    `json:"response_format,omitempty"`
+	Seed                *int               `json:"seed,omitempty"`
+	Modalities          []string           `json:"modalities,omitempty"`     // "text", "audio"
+	AudioConfig         *AudioOutputConfig `json:"audio,omitempty"`          // Required when modalities includes "audio"
+	ToolResources       json.RawMessage    `json:"tool_resources,omitempty"` // Assistants-style tool context, passed through verbatim
 	// OpenAI-specific reasoning parameters (passed through)
 	ReasoningEffort string `json:"reasoning_effort,omitempty"`
 }
@@ -41,15 +48,17 @@ type Message struct {
 	Refusal          string           `json:"refusal,omitempty"` // Model refusal message
 	ToolCalls        []ToolCall       `json:"tool_calls,omitempty"`
 	ToolCallID       string           `json:"tool_call_id,omitempty"`
+	FunctionCall     *FunctionCall    `json:"function_call,omitempty"`     // Deprecated: legacy form of ToolCalls
 	Reasoning        *ReasoningOutput `json:"reasoning,omitempty"`         // For o3 mode
 	ReasoningSummary string           `json:"reasoning_summary,omitempty"` // For legacy mode
 }
 
 // ContentPart represents a part of a multimodal message.
 type ContentPart struct {
-	Type     string    `json:"type"`
-	Text     string    `json:"text,omitempty"`
-	ImageURL *ImageURL `json:"image_url,omitempty"`
+	Type       string        `json:"type"`
+	Text       string        `json:"text,omitempty"`
+	ImageURL   *ImageURL     `json:"image_url,omitempty"`
+	InputAudio *AudioContent `json:"input_audio,omitempty"`
 }
 
 // ImageURL represents an image URL in a message.
@@ -58,6 +67,12 @@ type ImageURL struct {
 	Detail string `json:"detail,omitempty"` // "low", "high", "auto"
 }
 
+// AudioContent represents base64-encoded audio input in a message.
+type AudioContent struct {
+	Data   string `json:"data"`   // Base64-encoded audio
+	Format string `json:"format"` // "wav", "mp3"
+}
+
 // Tool represents a tool/function that can be called.
 type Tool struct {
 	Type     string   `json:"type"` // "function"
@@ -86,9 +101,16 @@ type FunctionCall struct {
 	Arguments string `json:"arguments"`
 }
 
+// AudioOutputConfig specifies the voice and format of audio output.
+type AudioOutputConfig struct {
+	Voice  string `json:"voice"`  // "alloy", "echo", ...
+	Format string `json:"format"` // "wav", "mp3", "flac", "opus", "pcm16"
+}
+
 // ResponseFormat specifies the output format.
 type ResponseFormat struct {
-	Type string `json:"type"` // "text", "json_object", "json_schema"
+	Type       string          `json:"type"`                  // "text", "json_object", "json_schema"
+	JSONSchema json.RawMessage `json:"json_schema,omitempty"` // {name, schema, strict}, for "json_schema"
 }
 
 // ChatCompletionResponse represents an OpenAI chat completion response.
@@ -111,9 +133,16 @@ type Choice struct {
 	Logprobs     *Logprobs `json:"logprobs"`      // Always present (null or object)
 }
 
-// Logprobs represents log probability information for a choice.
+// Logprobs represents log probability information for a choice. Chat
+// completions report per-token entries in Content; upstreams that still use
+// the legacy completions layout fill the parallel Tokens, TokenLogprobs,
+// TopLogprobs and TextOffset slices instead.
 type Logprobs struct {
-	Content []LogprobContent `json:"content,omitempty"`
+	Content       []LogprobContent     `json:"content,omitempty"`
+	Tokens        []string             `json:"tokens,omitempty"`
+	TokenLogprobs []float64            `json:"token_logprobs,omitempty"`
+	TopLogprobs   []map[string]float64 `json:"top_logprobs,omitempty"`
+	TextOffset    []int                `json:"text_offset,omitempty"`
 }
 
 // LogprobContent represents log probability for a token.
@@ -179,6 +208,18 @@ type ChatCompletionChunk struct {
 	SystemFingerprint string   `json:"system_fingerprint,omitempty"`
 }
 
+// HasContent reports whether the chunk carries content or tool call deltas
+// in at least one choice. Role-only, finish-reason-only and usage-only
+// chunks have no content.
+func (c *ChatCompletionChunk) HasContent() bool {
+	for _, choice := range c.Choices {
+		if choice.Delta != nil && (choice.Delta.Content != "" || len(choice.Delta.ToolCalls) > 0) {
+			return true
+		}
+	}
+	return false
+}
+
 // ModelsResponse represents the /v1/models response.
 type ModelsResponse struct {
 	Object string  `json:"object"`
@@ -191,6 +232,13 @@ type Model struct {
 	Object  string `json:"object"`
 	Created int64  `json:"created"`
 	OwnedBy string `json:"owned_by"`
+
+	// ContextWindow is the maximum prompt size in tokens; 0 when unknown.
+	ContextWindow int `json:"context_window,omitempty"`
+
+	// StructuredOutputs reports support for response_format json_schema;
+	// nil when unknown.
+	StructuredOutputs *bool `json:"structured_outputs,omitempty"`
 }
 
 // GetContentString extracts string content from a message.
@@ -235,3 +283,48 @@ func (m *Message) SetContentString(s string) {
 	data, _ := json.Marshal(s)
 	m.Content = data
 }
+
+// SetContentParts sets the content as an array of content parts.
+func (m *Message) SetContentParts(parts []ContentPart) {
+	data, _ := json.Marshal(parts)
+	m.Content = data
+}
+
+// SystemMessage creates a system message with text content.
+func SystemMessage(content string) Message {
+	return textMessage("system", content)
+}
+
+// UserMessage creates a user message with text content.
+func UserMessage(content string) Message {
+	return textMessage("user", content)
+}
+
+// AssistantMessage creates an assistant message with text content.
+func AssistantMessage(content string) Message {
+	return textMessage("assistant", content)
+}
+
+// ToolResultMessage creates a tool message answering the tool call toolCallID.
+func ToolResultMessage(toolCallID, content string) Message {
+	m := textMessage("tool", content)
+	m.ToolCallID = toolCallID
+	return m
+}
+
+// UserMessageWithImage creates a user message with a text part followed by
+// an image part. detail ("low", "high", "auto") may be empty.
+func UserMessageWithImage(text, imageURL, detail string) Message {
+	m := Message{Role: "user"}
+	m.SetContentParts([]ContentPart{
+		{Type: "text", Text: text},
+		{Type: "image_url", ImageURL: &ImageURL{URL: imageURL, Detail: detail}},
+	})
+	return m
+}
+
+func textMessage(role, content string) Message {
+	m := Message{Role: role}
+	m.SetContentString(content)
+	return m
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// roundTrip decodes a chat completion request from in and encodes it again.
+func roundTrip(t *testing.T, in string) (ChatCompletionRequest, string) {
+	t.Helper()
+	var req ChatCompletionRequest
+	if err := json.Unmarshal([]byte(in), &req); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	out, err := json.Marshal(&req)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	return req, string(out)
+}
+
+// assertJSONEqual fails unless got and want encode the same JSON value.
+func assertJSONEqual(t *testing.T, got, want string) {
+	t.Helper()
+	var g, w any
+	if err := json.Unmarshal([]byte(got), &g); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("invalid JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("JSON = %s, want %s", got, want)
+	}
+}
+
+func TestModalitiesRoundTrip(t *testing.T) {
+	in := `{"model":"gpt-4o-audio-preview","messages":[{"role":"user","content":"hi"}],` +
+		`"modalities":["text","audio"],"audio":{"voice":"alloy","format":"wav"}}`
+	req, out := roundTrip(t, in)
+
+	if !slices.Equal(req.Modalities, []string{"text", "audio"}) {
+		t.Errorf("Modalities = %v, want [text audio]", req.Modalities)
+	}
+	if req.AudioConfig == nil || *req.AudioConfig != (AudioOutputConfig{Voice: "alloy", Format: "wav"}) {
+		t.Errorf("AudioConfig = %+v, want alloy/wav", req.AudioConfig)
+	}
+	assertJSONEqual(t, out, in)
+
+	// Both fields are omitted when unset
+	_, out = roundTrip(t, `{"model":"gpt-4o","messages":[]}`)
+	assertJSONEqual(t, out, `{"model":"gpt-4o","messages":[]}`)
+}
+
+func TestToolResourcesRoundTrip(t *testing.T) {
+	resources := `{"file_search":{"vector_store_ids":["vs_1"]},"code_interpreter":{"file_ids":["file_1","file_2"]}}`
+	in := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"tool_resources":` + resources + `}`
+	req, out := roundTrip(t, in)
+
+	// Passed through verbatim, without being decoded
+	if string(req.ToolResources) != resources {
+		t.Errorf("ToolResources = %s, want %s", req.ToolResources, resources)
+	}
+	assertJSONEqual(t, out, in)
+
+	_, out = roundTrip(t, `{"model":"gpt-4o","messages":[]}`)
+	assertJSONEqual(t, out, `{"model":"gpt-4o","messages":[]}`)
+}
@@ -0,0 +1,93 @@
+// Package audit records every chat completion, request and response, for
+// compliance.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// Logger records audit entries.
+type Logger interface {
+	// Log writes entry durably before returning.
+	Log(ctx context.Context, entry Entry) error
+}
+
+// Entry is the record of one chat completion.
+type Entry struct {
+	RequestID     string        `json:"request_id,omitempty"`
+	ProviderID    string        `json:"provider"`
+	Model         string        `json:"model"`
+	Timestamp     time.Time     `json:"timestamp"`
+	InputMessages []api.Message `json:"input_messages"`
+	OutputMessage *api.Message  `json:"output_message,omitempty"`
+	TokenUsage    *api.Usage    `json:"token_usage,omitempty"`
+	DurationMs    int64         `json:"duration_ms"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// NoopLogger discards entries. It is the default Logger.
+type NoopLogger struct{}
+
+// Log does nothing.
+func (NoopLogger) Log(context.Context, Entry) error {
+	return nil
+}
+
+// FileLogger appends entries to a file as newline-delimited JSON. Each
+// entry is written unbuffered and synced to disk before Log returns.
+type FileLogger struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileLogger opens path for appending, creating it if needed.
+func NewFileLogger(path string) (*FileLogger, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &FileLogger{file: f}, nil
+}
+
+// Log appends entry as one JSON line.
+func (l *FileLogger) Log(_ context.Context, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return l.file.Sync()
+}
+
+// Close closes the file.
+func (l *FileLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose entries are recorded with id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID set with WithRequestID, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
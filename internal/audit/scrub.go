@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// piiPatterns match common personal data, most specific first so a credit
+// card number isn't partly taken for a phone number.
+var piiPatterns = []struct {
+	re          *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+	{regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), "[CARD]"},
+	{regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), "[SSN]"},
+	{regexp.MustCompile(`(?:\+?\d{1,3}[ .-]?)?\(?\d{3}\)?[ .-]?\d{3}[ .-]?\d{4}\b`), "[PHONE]"},
+	{regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), "[IP]"},
+}
+
+// ScrubPII replaces email addresses, card, social security and phone
+// numbers, and IPv4 addresses in s with placeholders. It is a best-effort
+// pattern match, not a guarantee that no personal data remains.
+func ScrubPII(s string) string {
+	for _, p := range piiPatterns {
+		s = p.re.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
+
+// scrubbingLogger scrubs message content before passing entries on.
+type scrubbingLogger struct {
+	next Logger
+}
+
+// WithPIIScrubbing returns a Logger that runs ScrubPII over the content and
+// tool call arguments of every message before logging to next.
+func WithPIIScrubbing(next Logger) Logger {
+	return &scrubbingLogger{next: next}
+}
+
+func (l *scrubbingLogger) Log(ctx context.Context, entry Entry) error {
+	messages := make([]api.Message, len(entry.InputMessages))
+	for i, msg := range entry.InputMessages {
+		messages[i] = scrubMessage(msg)
+	}
+	entry.InputMessages = messages
+	if entry.OutputMessage != nil {
+		out := scrubMessage(*entry.OutputMessage)
+		entry.OutputMessage = &out
+	}
+	return l.next.Log(ctx, entry)
+}
+
+// scrubMessage returns a scrubbed copy of msg.
+func scrubMessage(msg api.Message) api.Message {
+	if content := msg.GetContentString(); content != "" {
+		msg.SetContentString(ScrubPII(content))
+	} else if parts := msg.GetContentParts(); len(parts) > 0 {
+		scrubbed := make([]api.ContentPart, len(parts))
+		for i, part := range parts {
+			part.Text = ScrubPII(part.Text)
+			scrubbed[i] = part
+		}
+		msg.SetContentParts(scrubbed)
+	}
+
+	if len(msg.ToolCalls) > 0 {
+		calls := make([]api.ToolCall, len(msg.ToolCalls))
+		for i, tc := range msg.ToolCalls {
+			tc.Function.Arguments = ScrubPII(tc.Function.Arguments)
+			calls[i] = tc
+		}
+		msg.ToolCalls = calls
+	}
+	return msg
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/edgard/opencompat/internal/config"
+)
+
+// DefaultAccount is the account alias used when none is given. Its
+// credentials live in the provider's original credentials file.
+const DefaultAccount = "default"
+
+// accountSeparator joins a provider ID and a non-default alias into the
+// credentials key, e.g. "copilot@work".
+const accountSeparator = "@"
+
+// activeAccountsFile maps provider IDs to their active account alias.
+const activeAccountsFile = "accounts.json"
+
+// validAlias restricts aliases to names that are safe in file names.
+var validAlias = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// AccountKey returns the credentials key of a provider's account. Store
+// methods taking a provider ID accept such a key to address that account;
+// a plain provider ID addresses the active account.
+func AccountKey(providerID, alias string) string {
+	if alias == "" || alias == DefaultAccount {
+		return providerID
+	}
+	return providerID + accountSeparator + alias
+}
+
+// splitAccountKey returns the provider ID and alias of a credentials key.
+func splitAccountKey(key string) (providerID, alias string) {
+	providerID, alias, ok := strings.Cut(key, accountSeparator)
+	if !ok {
+		return key, DefaultAccount
+	}
+	return providerID, alias
+}
+
+// loginCommand returns the CLI command that logs in to the account of key.
+func loginCommand(key string) string {
+	providerID, alias := splitAccountKey(key)
+	if alias == DefaultAccount {
+		return "opencompat login " + providerID
+	}
+	return fmt.Sprintf("opencompat login %s --account %s", providerID, alias)
+}
+
+// ValidateAccountAlias checks that alias can be used as an account name.
+func ValidateAccountAlias(alias string) error {
+	if !validAlias.MatchString(alias) {
+		return fmt.Errorf("invalid account alias %q: use lowercase letters, digits, '-' and '_'", alias)
+	}
+	return nil
+}
+
+// credentialsKey resolves a provider ID to the key of its active account.
+// Account keys are returned unchanged.
+func (s *Store) credentialsKey(providerID string) string {
+	if strings.Contains(providerID, accountSeparator) {
+		return providerID
+	}
+	return AccountKey(providerID, s.GetActiveAccount(providerID))
+}
+
+// GetActiveAccount returns the alias of the provider's active account,
+// DefaultAccount unless SetActiveAccount chose another.
+func (s *Store) GetActiveAccount(providerID string) string {
+	s.accountsMu.Lock()
+	defer s.accountsMu.Unlock()
+	if err := s.loadActiveAccounts(); err != nil {
+		return DefaultAccount
+	}
+	if alias, ok := s.activeAccounts[providerID]; ok {
+		return alias
+	}
+	return DefaultAccount
+}
+
+// SetActiveAccount makes alias the provider's active account. The account
+// must have stored credentials. A running server picks up the change when
+// restarted.
+func (s *Store) SetActiveAccount(providerID, alias string) error {
+	if err := ValidateAccountAlias(alias); err != nil {
+		return err
+	}
+	if _, err := os.Stat(s.credentialsPath(AccountKey(providerID, alias))); err != nil {
+		return fmt.Errorf("no %s account %q - run '%s' first", providerID, alias, loginCommand(AccountKey(providerID, alias)))
+	}
+	return s.setActiveAccount(providerID, alias)
+}
+
+// setActiveAccount records alias without checking it.
+func (s *Store) setActiveAccount(providerID, alias string) error {
+	s.accountsMu.Lock()
+	defer s.accountsMu.Unlock()
+	if err := s.loadActiveAccounts(); err != nil {
+		return err
+	}
+
+	if alias == DefaultAccount {
+		delete(s.activeAccounts, providerID)
+	} else {
+		s.activeAccounts[providerID] = alias
+	}
+
+	if err := config.EnsureDataDir(); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s.activeAccounts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal active accounts: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dataDir, activeAccountsFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write active accounts: %w", err)
+	}
+	return nil
+}
+
+// loadActiveAccounts reads the active accounts file once. Callers hold
+// s.accountsMu.
+func (s *Store) loadActiveAccounts() error {
+	if s.activeAccounts != nil {
+		return nil
+	}
+	accounts := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(s.dataDir, activeAccountsFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read active accounts: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &accounts); err != nil {
+			return fmt.Errorf("failed to parse active accounts: %w", err)
+		}
+	}
+	s.activeAccounts = accounts
+	return nil
+}
+
+// ListAccounts returns the aliases of the provider's stored accounts,
+// DefaultAccount first and the others sorted.
+func (s *Store) ListAccounts(providerID string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dataDir, providerID+accountSeparator+"*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+
+	var aliases []string
+	for _, path := range matches {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		alias := strings.TrimPrefix(name, providerID+accountSeparator)
+		if ValidateAccountAlias(alias) == nil {
+			aliases = append(aliases, alias)
+		}
+	}
+	slices.Sort(aliases)
+
+	if _, err := os.Stat(s.credentialsPath(providerID)); err == nil {
+		aliases = append([]string{DefaultAccount}, aliases...)
+	}
+	return aliases, nil
+}
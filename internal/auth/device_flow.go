@@ -28,9 +28,58 @@ type DeviceFlowTokenResponse struct {
 	Error       string `json:"error,omitempty"`
 }
 
+// DeviceFlowCallbacks report the progress of a device flow login.
+// Any callback may be nil.
+type DeviceFlowCallbacks struct {
+	// OnCodeDisplayed is called once the user code is available.
+	OnCodeDisplayed func(code, verificationURL string)
+	// OnPolling is called before each token poll, after waiting nextPollIn.
+	OnPolling func(attempt int, nextPollIn time.Duration)
+	// OnSuccess is called after the credentials have been saved.
+	OnSuccess func()
+	// OnError is called when the flow fails.
+	OnError func(error)
+}
+
+// defaultDeviceFlowCallbacks prints plain progress messages to stdout.
+func defaultDeviceFlowCallbacks() *DeviceFlowCallbacks {
+	return &DeviceFlowCallbacks{
+		OnCodeDisplayed: func(code, verificationURL string) {
+			fmt.Println()
+			fmt.Println("To authenticate, please:")
+			fmt.Printf("  1. Open: %s\n", verificationURL)
+			fmt.Printf("  2. Enter code: %s\n", code)
+			fmt.Println()
+			fmt.Println("Waiting for authorization...")
+		},
+		OnSuccess: func() {
+			fmt.Println("Login successful!")
+		},
+	}
+}
+
 // PerformDeviceFlowLogin performs the OAuth device authorization flow.
 // This flow is used by providers like GitHub that support device code authentication.
-func PerformDeviceFlowLogin(store *Store, providerID string, cfg *DeviceFlowConfig) error {
+// Progress is reported through cb; if cb is nil, plain messages are printed.
+func PerformDeviceFlowLogin(store *Store, providerID string, cfg *DeviceFlowConfig, cb *DeviceFlowCallbacks) error {
+	if cb == nil {
+		cb = defaultDeviceFlowCallbacks()
+	}
+
+	if err := performDeviceFlow(store, providerID, cfg, cb); err != nil {
+		if cb.OnError != nil {
+			cb.OnError(err)
+		}
+		return err
+	}
+
+	if cb.OnSuccess != nil {
+		cb.OnSuccess()
+	}
+	return nil
+}
+
+func performDeviceFlow(store *Store, providerID string, cfg *DeviceFlowConfig, cb *DeviceFlowCallbacks) error {
 	// Step 1: Request device code
 	deviceCode, err := requestDeviceCode(cfg)
 	if err != nil {
@@ -38,19 +87,15 @@ func PerformDeviceFlowLogin(store *Store, providerID string, cfg *DeviceFlowConf
 	}
 
 	// Step 2: Display instructions to user
-	fmt.Println()
-	fmt.Println("To authenticate, please:")
-	fmt.Printf("  1. Open: %s\n", deviceCode.VerificationURI)
-	fmt.Printf("  2. Enter code: %s\n", deviceCode.UserCode)
-	fmt.Println()
+	if cb.OnCodeDisplayed != nil {
+		cb.OnCodeDisplayed(deviceCode.UserCode, deviceCode.VerificationURI)
+	}
 
 	// Try to open browser
 	if err := openBrowser(deviceCode.VerificationURI); err != nil {
 		fmt.Println("Could not open browser automatically. Please open the URL manually.")
 	}
 
-	fmt.Println("Waiting for authorization...")
-
 	// Step 3: Poll for token
 	interval := deviceCode.Interval
 	if interval < 5 {
@@ -59,8 +104,12 @@ func PerformDeviceFlowLogin(store *Store, providerID string, cfg *DeviceFlowConf
 
 	deadline := time.Now().Add(time.Duration(deviceCode.ExpiresIn) * time.Second)
 
-	for time.Now().Before(deadline) {
-		time.Sleep(time.Duration(interval) * time.Second)
+	for attempt := 1; time.Now().Before(deadline); attempt++ {
+		wait := time.Duration(interval) * time.Second
+		if cb.OnPolling != nil {
+			cb.OnPolling(attempt, wait)
+		}
+		time.Sleep(wait)
 
 		token, err := pollForToken(cfg, deviceCode.DeviceCode)
 		if err != nil {
@@ -101,7 +150,6 @@ func PerformDeviceFlowLogin(store *Store, providerID string, cfg *DeviceFlowConf
 			return fmt.Errorf("failed to save credentials: %w", err)
 		}
 
-		fmt.Println("Login successful!")
 		return nil
 	}
 
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/config"
+)
+
+// apiKeysFile holds the hashed API keys of the proxy server.
+const apiKeysFile = "api_keys.json"
+
+// apiKeyPrefix starts every generated API key.
+const apiKeyPrefix = "oc-"
+
+// StoredAPIKey is a proxy API key as persisted: only its SHA-256 hash is
+// kept.
+type StoredAPIKey struct {
+	Hash      string    `json:"hash"` // hex SHA-256 of the key
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProxyAuth checks the API keys clients present to the proxy server in
+// the Authorization header.
+type ProxyAuth struct {
+	hashes [][sha256.Size]byte
+}
+
+// NewProxyAuth returns a ProxyAuth accepting the plaintext keys and the
+// stored hashed keys. Stored entries with malformed hashes are an error.
+func NewProxyAuth(keys []string, stored []StoredAPIKey) (*ProxyAuth, error) {
+	a := &ProxyAuth{}
+	for _, key := range keys {
+		a.hashes = append(a.hashes, sha256.Sum256([]byte(key)))
+	}
+	for _, k := range stored {
+		raw, err := hex.DecodeString(k.Hash)
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("invalid API key hash %q", k.Hash)
+		}
+		a.hashes = append(a.hashes, [sha256.Size]byte(raw))
+	}
+	return a, nil
+}
+
+// Enabled reports whether any key is configured. Without keys the proxy
+// accepts every request.
+func (a *ProxyAuth) Enabled() bool {
+	return len(a.hashes) > 0
+}
+
+// Valid reports whether key is one of the configured keys. Keys are
+// compared by hash in constant time, and every configured key is checked
+// so the time taken doesn't reveal which one matched.
+func (a *ProxyAuth) Valid(key string) bool {
+	sum := sha256.Sum256([]byte(key))
+	match := 0
+	for _, h := range a.hashes {
+		match |= subtle.ConstantTimeCompare(sum[:], h[:])
+	}
+	return match == 1
+}
+
+// Middleware rejects requests without a valid Bearer token with a 401
+// invalid_api_key error. Requests pass unchecked while no key is
+// configured.
+func (a *ProxyAuth) Middleware(next http.Handler) http.Handler {
+	if !a.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r.Header.Get("Authorization"))
+		if !ok {
+			writeInvalidAPIKey(w, "Missing API key. Pass it as 'Authorization: Bearer <key>'.")
+			return
+		}
+		if !a.Valid(token) {
+			writeInvalidAPIKey(w, "Incorrect API key provided.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken extracts the token of a Bearer authorization header.
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func writeInvalidAPIKey(w http.ResponseWriter, message string) {
+	code := "invalid_api_key"
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	api.WriteError(w, http.StatusUnauthorized, api.ErrorTypeAuthentication, message, &code, nil)
+}
+
+// LoadProxyAuth returns a ProxyAuth accepting keys together with the
+// keys added by AddAPIKey.
+func (s *Store) LoadProxyAuth(keys []string) (*ProxyAuth, error) {
+	stored, err := s.APIKeys()
+	if err != nil {
+		return nil, err
+	}
+	return NewProxyAuth(keys, stored)
+}
+
+// APIKeys returns the stored proxy API keys.
+func (s *Store) APIKeys() ([]StoredAPIKey, error) {
+	data, err := os.ReadFile(filepath.Join(s.dataDir, apiKeysFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+	var keys []StoredAPIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// AddAPIKey generates a proxy API key, stores its hash and returns the
+// plaintext key, which cannot be recovered afterwards.
+func (s *Store) AddAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key := apiKeyPrefix + hex.EncodeToString(buf)
+
+	s.apiKeysMu.Lock()
+	defer s.apiKeysMu.Unlock()
+	keys, err := s.APIKeys()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(key))
+	keys = append(keys, StoredAPIKey{Hash: hex.EncodeToString(sum[:]), CreatedAt: time.Now().UTC()})
+
+	if err := config.EnsureDataDir(); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal API keys: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dataDir, apiKeysFile), data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write API keys: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testAPIKey = "oc-test-key"
+
+// okHandler answers 200 for requests that get through the middleware.
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestProxyAuthMiddleware(t *testing.T) {
+	a, err := NewProxyAuth([]string{testAPIKey}, []StoredAPIKey{{Hash: hashKey("oc-stored-key")}})
+	if err != nil {
+		t.Fatalf("NewProxyAuth() error = %v", err)
+	}
+	handler := a.Middleware(okHandler)
+
+	tests := []struct {
+		name       string
+		auth       string // Authorization header; empty sends none
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "valid key", auth: "Bearer " + testAPIKey, wantStatus: http.StatusOK},
+		{name: "lowercase scheme", auth: "bearer " + testAPIKey, wantStatus: http.StatusOK},
+		{name: "stored hashed key", auth: "Bearer oc-stored-key", wantStatus: http.StatusOK},
+		{
+			name:       "wrong key",
+			auth:       "Bearer oc-wrong-key",
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"error":{"message":"Incorrect API key provided.","type":"authentication_error","param":null,"code":"invalid_api_key"}}` + "\n",
+		},
+		{
+			name:       "hash of a key instead of the key",
+			auth:       "Bearer " + hashKey(testAPIKey),
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"error":{"message":"Incorrect API key provided.","type":"authentication_error","param":null,"code":"invalid_api_key"}}` + "\n",
+		},
+		// api.WriteError escapes < and > like encoding/json does
+		{
+			name:       "missing header",
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"error":{"message":"Missing API key. Pass it as 'Authorization: Bearer \u003ckey\u003e'.","type":"authentication_error","param":null,"code":"invalid_api_key"}}` + "\n",
+		},
+		{
+			name:       "other scheme",
+			auth:       "Basic " + testAPIKey,
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"error":{"message":"Missing API key. Pass it as 'Authorization: Bearer \u003ckey\u003e'.","type":"authentication_error","param":null,"code":"invalid_api_key"}}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusUnauthorized {
+				return
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %s, want %s", got, tt.wantBody)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != "Bearer" {
+				t.Errorf("WWW-Authenticate = %q, want Bearer", got)
+			}
+		})
+	}
+}
+
+func TestProxyAuthDisabledWithoutKeys(t *testing.T) {
+	a, err := NewProxyAuth(nil, nil)
+	if err != nil {
+		t.Fatalf("NewProxyAuth() error = %v", err)
+	}
+	if a.Enabled() {
+		t.Error("Enabled() = true without keys")
+	}
+	rec := httptest.NewRecorder()
+	a.Middleware(okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
+
+func TestNewProxyAuthInvalidHash(t *testing.T) {
+	for _, hash := range []string{"not-hex", hashKey(testAPIKey)[:32]} {
+		if _, err := NewProxyAuth(nil, []StoredAPIKey{{Hash: hash}}); err == nil {
+			t.Errorf("NewProxyAuth() accepted hash %q", hash)
+		}
+	}
+}
+
+func TestAddAPIKeyStoresOnlyHash(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataDir)
+	s := NewStore()
+
+	key, err := s.AddAPIKey()
+	if err != nil {
+		t.Fatalf("AddAPIKey() error = %v", err)
+	}
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		t.Errorf("key = %q, want prefix %q", key, apiKeyPrefix)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dataDir, apiKeysFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), key) {
+		t.Error("API keys file contains the plaintext key")
+	}
+	if !strings.Contains(string(data), hashKey(key)) {
+		t.Error("API keys file does not contain the key's hash")
+	}
+
+	a, err := s.LoadProxyAuth(nil)
+	if err != nil {
+		t.Fatalf("LoadProxyAuth() error = %v", err)
+	}
+	if !a.Valid(key) || a.Valid(key+"x") {
+		t.Errorf("Valid(key) = %v, Valid(key+x) = %v; want true, false", a.Valid(key), a.Valid(key+"x"))
+	}
+}
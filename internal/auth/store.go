@@ -16,12 +16,19 @@ import (
 	"github.com/edgard/opencompat/internal/config"
 )
 
-// Store manages credential persistence for all providers.
+// Store manages credential persistence for all providers. A provider can
+// have several accounts: methods taking a provider ID use its active
+// account, or the account named by an AccountKey.
 type Store struct {
 	dataDir   string
 	cache     map[string]any // providerID -> credentials
 	cacheMu   sync.RWMutex
 	refreshMu sync.Map // providerID -> *sync.Mutex (per-provider refresh locks)
+
+	accountsMu     sync.Mutex
+	activeAccounts map[string]string // providerID -> active alias, loaded lazily
+
+	apiKeysMu sync.Mutex // serializes AddAPIKey
 }
 
 // NewStore creates a new credential store.
@@ -34,13 +41,14 @@ func NewStore() *Store {
 
 // getRefreshMutex returns a per-provider mutex for refresh operations.
 func (s *Store) getRefreshMutex(providerID string) *sync.Mutex {
-	mu, _ := s.refreshMu.LoadOrStore(providerID, &sync.Mutex{})
+	mu, _ := s.refreshMu.LoadOrStore(s.credentialsKey(providerID), &sync.Mutex{})
 	return mu.(*sync.Mutex)
 }
 
-// credentialsPath returns the path for a provider's credentials file.
-func (s *Store) credentialsPath(providerID string) string {
-	return filepath.Join(s.dataDir, providerID+".json")
+// credentialsPath returns the path of the credentials file for key, a
+// resolved credentials key.
+func (s *Store) credentialsPath(key string) string {
+	return filepath.Join(s.dataDir, key+".json")
 }
 
 // copyOAuthCredentials returns a deep copy of OAuth credentials.
@@ -68,14 +76,16 @@ func copyAPIKeyCredentials(creds *APIKeyCredentials) *APIKeyCredentials {
 		Type:      creds.Type,
 		APIKey:    creds.APIKey,
 		CreatedAt: creds.CreatedAt,
+		ExpiresAt: creds.ExpiresAt,
 	}
 }
 
 // GetOAuthCredentials loads OAuth credentials for a provider.
 // Returns a copy of the credentials to prevent cache corruption.
 func (s *Store) GetOAuthCredentials(providerID string) (*OAuthCredentials, error) {
+	key := s.credentialsKey(providerID)
 	s.cacheMu.RLock()
-	if cached, ok := s.cache[providerID]; ok {
+	if cached, ok := s.cache[key]; ok {
 		if creds, ok := cached.(*OAuthCredentials); ok {
 			s.cacheMu.RUnlock()
 			return copyOAuthCredentials(creds), nil
@@ -84,24 +94,24 @@ func (s *Store) GetOAuthCredentials(providerID string) (*OAuthCredentials, error
 		s.cacheMu.RUnlock()
 		s.cacheMu.Lock()
 		// Re-check after acquiring write lock (another goroutine may have fixed it)
-		if cached, ok := s.cache[providerID]; ok {
+		if cached, ok := s.cache[key]; ok {
 			if creds, ok := cached.(*OAuthCredentials); ok {
 				s.cacheMu.Unlock()
 				return copyOAuthCredentials(creds), nil
 			}
 			// Still wrong type, evict it
-			delete(s.cache, providerID)
+			delete(s.cache, key)
 		}
 		s.cacheMu.Unlock()
 	} else {
 		s.cacheMu.RUnlock()
 	}
 
-	path := s.credentialsPath(providerID)
+	path := s.credentialsPath(key)
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("not logged in to %s - run 'opencompat login %s' first", providerID, providerID)
+			return nil, fmt.Errorf("not logged in to %s - run '%s' first", key, loginCommand(key))
 		}
 		return nil, fmt.Errorf("failed to read credentials: %w", err)
 	}
@@ -122,14 +132,14 @@ func (s *Store) GetOAuthCredentials(providerID string) (*OAuthCredentials, error
 
 	// Store in cache, but if another goroutine already populated it, return the cached value
 	s.cacheMu.Lock()
-	if cached, ok := s.cache[providerID]; ok {
+	if cached, ok := s.cache[key]; ok {
 		if cachedCreds, ok := cached.(*OAuthCredentials); ok {
 			s.cacheMu.Unlock()
 			return copyOAuthCredentials(cachedCreds), nil
 		}
 	}
 	credsCopy := copyOAuthCredentials(&creds)
-	s.cache[providerID] = credsCopy
+	s.cache[key] = credsCopy
 	s.cacheMu.Unlock()
 
 	// Return another copy to prevent caller from mutating the cached copy
@@ -139,8 +149,9 @@ func (s *Store) GetOAuthCredentials(providerID string) (*OAuthCredentials, error
 // GetAPIKeyCredentials loads API key credentials for a provider.
 // Returns a copy of the credentials to prevent cache corruption.
 func (s *Store) GetAPIKeyCredentials(providerID string) (*APIKeyCredentials, error) {
+	key := s.credentialsKey(providerID)
 	s.cacheMu.RLock()
-	if cached, ok := s.cache[providerID]; ok {
+	if cached, ok := s.cache[key]; ok {
 		if creds, ok := cached.(*APIKeyCredentials); ok {
 			s.cacheMu.RUnlock()
 			return copyAPIKeyCredentials(creds), nil
@@ -149,24 +160,24 @@ func (s *Store) GetAPIKeyCredentials(providerID string) (*APIKeyCredentials, err
 		s.cacheMu.RUnlock()
 		s.cacheMu.Lock()
 		// Re-check after acquiring write lock (another goroutine may have fixed it)
-		if cached, ok := s.cache[providerID]; ok {
+		if cached, ok := s.cache[key]; ok {
 			if creds, ok := cached.(*APIKeyCredentials); ok {
 				s.cacheMu.Unlock()
 				return copyAPIKeyCredentials(creds), nil
 			}
 			// Still wrong type, evict it
-			delete(s.cache, providerID)
+			delete(s.cache, key)
 		}
 		s.cacheMu.Unlock()
 	} else {
 		s.cacheMu.RUnlock()
 	}
 
-	path := s.credentialsPath(providerID)
+	path := s.credentialsPath(key)
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("not logged in to %s - run 'opencompat login %s' first", providerID, providerID)
+			return nil, fmt.Errorf("not logged in to %s - run '%s' first", key, loginCommand(key))
 		}
 		return nil, fmt.Errorf("failed to read credentials: %w", err)
 	}
@@ -187,14 +198,14 @@ func (s *Store) GetAPIKeyCredentials(providerID string) (*APIKeyCredentials, err
 
 	// Store in cache, but if another goroutine already populated it, return the cached value
 	s.cacheMu.Lock()
-	if cached, ok := s.cache[providerID]; ok {
+	if cached, ok := s.cache[key]; ok {
 		if cachedCreds, ok := cached.(*APIKeyCredentials); ok {
 			s.cacheMu.Unlock()
 			return copyAPIKeyCredentials(cachedCreds), nil
 		}
 	}
 	credsCopy := copyAPIKeyCredentials(&creds)
-	s.cache[providerID] = credsCopy
+	s.cache[key] = credsCopy
 	s.cacheMu.Unlock()
 
 	// Return another copy to prevent caller from mutating the cached copy
@@ -203,6 +214,7 @@ func (s *Store) GetAPIKeyCredentials(providerID string) (*APIKeyCredentials, err
 
 // SaveOAuthCredentials stores OAuth credentials for a provider.
 func (s *Store) SaveOAuthCredentials(providerID string, creds *OAuthCredentials) error {
+	key := s.credentialsKey(providerID)
 	if err := config.EnsureDataDir(); err != nil {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
@@ -216,21 +228,23 @@ func (s *Store) SaveOAuthCredentials(providerID string, creds *OAuthCredentials)
 		return fmt.Errorf("failed to marshal credentials: %w", err)
 	}
 
-	path := s.credentialsPath(providerID)
+	path := s.credentialsPath(key)
 	if err := os.WriteFile(path, data, 0600); err != nil {
 		return fmt.Errorf("failed to write credentials: %w", err)
 	}
 
 	// Store in cache (credsCopy is already a copy, safe to store directly)
 	s.cacheMu.Lock()
-	s.cache[providerID] = credsCopy
+	s.cache[key] = credsCopy
 	s.cacheMu.Unlock()
 
-	return nil
+	// A token exchanged for the previous credentials is no longer valid
+	return s.deleteCopilotToken(key)
 }
 
 // SaveAPIKeyCredentials stores API key credentials for a provider.
 func (s *Store) SaveAPIKeyCredentials(providerID string, creds *APIKeyCredentials) error {
+	key := s.credentialsKey(providerID)
 	if err := config.EnsureDataDir(); err != nil {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
@@ -244,35 +258,61 @@ func (s *Store) SaveAPIKeyCredentials(providerID string, creds *APIKeyCredential
 		return fmt.Errorf("failed to marshal credentials: %w", err)
 	}
 
-	path := s.credentialsPath(providerID)
+	path := s.credentialsPath(key)
 	if err := os.WriteFile(path, data, 0600); err != nil {
 		return fmt.Errorf("failed to write credentials: %w", err)
 	}
 
 	// Store in cache (credsCopy is already a copy, safe to store directly)
 	s.cacheMu.Lock()
-	s.cache[providerID] = credsCopy
+	s.cache[key] = credsCopy
 	s.cacheMu.Unlock()
 
 	return nil
 }
 
+// SetCredentialExpiry records when a provider's stored credentials expire.
+// It is meant for credentials imported from an external secret manager,
+// whose expiry is known but not embedded in the token. For OAuth
+// credentials this sets ExpiresAt, which a later token refresh replaces.
+func (s *Store) SetCredentialExpiry(providerID string, expiry time.Time) error {
+	if creds, err := s.GetOAuthCredentials(providerID); err == nil {
+		creds.ExpiresAt = expiry
+		return s.SaveOAuthCredentials(providerID, creds)
+	}
+
+	creds, err := s.GetAPIKeyCredentials(providerID)
+	if err != nil {
+		return err
+	}
+	creds.ExpiresAt = expiry
+	return s.SaveAPIKeyCredentials(providerID, creds)
+}
+
 // DeleteCredentials removes credentials for a provider.
 func (s *Store) DeleteCredentials(providerID string) error {
+	key := s.credentialsKey(providerID)
 	s.cacheMu.Lock()
-	delete(s.cache, providerID)
+	delete(s.cache, key)
 	s.cacheMu.Unlock()
 
-	path := s.credentialsPath(providerID)
+	path := s.credentialsPath(key)
 	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete credentials: %w", err)
 	}
-	return nil
+	// Without credentials the account can't stay active
+	if id, alias := splitAccountKey(key); alias != DefaultAccount && s.GetActiveAccount(id) == alias {
+		if err := s.setActiveAccount(id, DefaultAccount); err != nil {
+			return err
+		}
+	}
+	return s.deleteCopilotToken(key)
 }
 
 // IsLoggedIn checks if a provider has valid credentials.
 func (s *Store) IsLoggedIn(providerID string) bool {
-	path := s.credentialsPath(providerID)
+	key := s.credentialsKey(providerID)
+	path := s.credentialsPath(key)
 	_, err := os.Stat(path)
 	return err == nil
 }
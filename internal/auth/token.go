@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edgard/opencompat/internal/config"
+)
+
+// CopilotToken is a short-lived API token obtained by exchanging a
+// provider's long-lived credentials.
+type CopilotToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// tokenKeyInfo binds the derived key to its purpose.
+const tokenKeyInfo = "opencompat copilot token v1"
+
+// machineIDPaths hold a stable per-installation identifier on Linux.
+var machineIDPaths = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// copilotTokenPath returns the path of an account's encrypted token file.
+func (s *Store) copilotTokenPath(key string) string {
+	return filepath.Join(s.dataDir, key+"-token.enc")
+}
+
+// SetCopilotToken persists t encrypted with AES-GCM, so a restarted process
+// can reuse it until it expires. The key is derived from machine-specific
+// data and is never written to disk.
+func (s *Store) SetCopilotToken(providerID string, t *CopilotToken) error {
+	key := s.credentialsKey(providerID)
+	if err := config.EnsureDataDir(); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	plaintext, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %w", err)
+	}
+	gcm, err := tokenCipher(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	data := gcm.Seal(nonce, nonce, plaintext, []byte(key))
+
+	if err := os.WriteFile(s.copilotTokenPath(key), data, 0600); err != nil {
+		return fmt.Errorf("failed to write token: %w", err)
+	}
+	return nil
+}
+
+// GetCopilotToken loads the token saved by SetCopilotToken. The error wraps
+// os.ErrNotExist when no token is stored. Expiry is left to the caller.
+func (s *Store) GetCopilotToken(providerID string) (*CopilotToken, error) {
+	key := s.credentialsKey(providerID)
+	data, err := os.ReadFile(s.copilotTokenPath(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token: %w", err)
+	}
+
+	gcm, err := tokenCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("failed to decrypt token: file is truncated")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(key))
+	if err != nil {
+		// Also the result of copying the data directory to another machine
+		return nil, fmt.Errorf("failed to decrypt token: %w", err)
+	}
+
+	var t CopilotToken
+	if err := json.Unmarshal(plaintext, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+	return &t, nil
+}
+
+// deleteCopilotToken removes an account's persisted token, if any.
+func (s *Store) deleteCopilotToken(providerID string) error {
+	key := s.credentialsKey(providerID)
+	if err := os.Remove(s.copilotTokenPath(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+	return nil
+}
+
+// tokenCipher returns the AES-256-GCM cipher for a provider's token.
+func tokenCipher(providerID string) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, machineSecret(), []byte(providerID), tokenKeyInfo, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive token key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// machineSecret returns entropy specific to this machine and user: the
+// machine ID where available, plus the hostname, user ID and home
+// directory. It only keeps the token unreadable when the file is copied
+// elsewhere; other processes of the same user can derive the same key.
+func machineSecret() []byte {
+	var parts []string
+	for _, path := range machineIDPaths {
+		if id, err := os.ReadFile(path); err == nil {
+			parts = append(parts, strings.TrimSpace(string(id)))
+			break
+		}
+	}
+	if host, err := os.Hostname(); err == nil {
+		parts = append(parts, host)
+	}
+	parts = append(parts, strconv.Itoa(os.Getuid()))
+	if home, err := os.UserHomeDir(); err == nil {
+		parts = append(parts, home)
+	}
+	return []byte(strings.Join(parts, "\x00"))
+}
@@ -1,7 +1,14 @@
 // Package auth provides OAuth authentication for the Codex API.
 package auth
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// ErrCredentialExpired is returned when stored credentials are past the
+// expiry recorded with SetCredentialExpiry and cannot be refreshed.
+var ErrCredentialExpired = errors.New("credentials expired")
 
 // AuthMethod defines how a provider authenticates.
 type AuthMethod int
@@ -13,6 +20,8 @@ const (
 	AuthMethodAPIKey
 	// AuthMethodDeviceFlow uses OAuth device authorization flow.
 	AuthMethodDeviceFlow
+	// AuthMethodNone needs no credentials (e.g., local model servers).
+	AuthMethodNone
 )
 
 // String returns the string representation of the auth method.
@@ -24,6 +33,8 @@ func (m AuthMethod) String() string {
 		return "api_key"
 	case AuthMethodDeviceFlow:
 		return "device_flow"
+	case AuthMethodNone:
+		return "none"
 	default:
 		return "unknown"
 	}
@@ -40,6 +51,8 @@ type TokenData struct {
 }
 
 // OAuthCredentials contains the OAuth tokens and metadata stored on disk.
+// ExpiresAt is when the stored token expires; device flow credentials leave
+// it zero unless the expiry is known (see Store.SetCredentialExpiry).
 type OAuthCredentials struct {
 	Type         string    `json:"type"` // Always "oauth"
 	AccessToken  string    `json:"access_token"`
@@ -67,6 +80,12 @@ type APIKeyCredentials struct {
 	Type      string    `json:"type"` // Always "api_key"
 	APIKey    string    `json:"api_key"`
 	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"` // zero if the key does not expire
+}
+
+// IsExpired returns true if the key has a known expiry that has passed.
+func (c *APIKeyCredentials) IsExpired() bool {
+	return !c.ExpiresAt.IsZero() && time.Now().After(c.ExpiresAt)
 }
 
 // IsValid returns true if the credentials have an API key.
@@ -0,0 +1,90 @@
+// Package cache provides in-memory caches for upstream responses.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// LRU is a fixed-size, least-recently-used cache of chat completion
+// responses with a per-entry expiry. It is safe for concurrent use and
+// implements provider.ResponseCache.
+type LRU struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	key     string
+	resp    *api.ChatCompletionResponse
+	expires time.Time
+}
+
+// NewLRU creates a cache holding at most size entries; size must be
+// positive.
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// Get returns the response stored under key if it hasn't expired. The
+// returned response is shared; callers must not modify it.
+func (c *LRU) Get(key string) (*api.ChatCompletionResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e.resp, true
+}
+
+// Set stores resp under key for ttl, evicting the least recently used
+// entry when the cache is full.
+func (c *LRU) Set(key string, resp *api.ChatCompletionResponse, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry)
+		e.resp, e.expires = resp, expires
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{key: key, resp: resp, expires: expires})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Len returns the number of entries, including expired ones not yet
+// evicted.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// remove deletes el. Callers hold c.mu.
+func (c *LRU) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry).key)
+}
@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Application name for XDG paths
@@ -16,6 +18,13 @@ const (
 	DefaultPort      = 8080
 	DefaultLogLevel  = "info"
 	DefaultLogFormat = "text"
+
+	DefaultDrainTimeout   = 120 // seconds to wait for active streams on shutdown
+	DefaultPrepareTimeout = 10  // seconds allowed for provider request preparation
+
+	DefaultCircuitFailureThreshold = 5
+	DefaultCircuitWindow           = 60 // seconds
+	DefaultCircuitCooldown         = 30 // seconds
 )
 
 // Config holds global runtime configuration (server-level only).
@@ -25,6 +34,98 @@ type Config struct {
 	Port      int
 	LogLevel  string // debug, info, warn, error
 	LogFormat string // text, json
+
+	// DisableStreamingFallback returns an error instead of simulating
+	// streaming when the provider cannot stream.
+	DisableStreamingFallback bool
+
+	// ClampParameters moves sampling parameters outside the provider's
+	// bounds to the nearest bound; RejectOutOfBoundsParameters fails such
+	// requests with 400 instead and takes precedence.
+	ClampParameters             bool
+	RejectOutOfBoundsParameters bool
+
+	// UsageWebhookURL receives a JSON usage event after each completed
+	// request. Empty disables usage reporting.
+	UsageWebhookURL string
+
+	// ResponseTransformScript is the path to a Jsonnet script applied to
+	// every response. Empty disables transforms.
+	ResponseTransformScript string
+
+	// ResponseStripFields is a comma-separated list of JSON paths removed
+	// from every response, e.g. "usage,choices.*.logprobs". Empty disables
+	// stripping.
+	ResponseStripFields string
+
+	// JSONModeEnforcement controls validation of json_object responses:
+	// passthrough, strict or retry.
+	JSONModeEnforcement string
+
+	// DrainStreams makes shutdown wait up to DrainTimeout for active
+	// streaming responses to finish.
+	DrainStreams bool
+	DrainTimeout int // seconds
+
+	// RaceProviders sends each request to every provider serving the
+	// model and returns the fastest response.
+	RaceProviders bool
+
+	// PrepareTimeout bounds provider pre-flight work (RequestPreparer).
+	PrepareTimeout int // seconds
+
+	// EnabledProviders limits the providers that are served. Empty
+	// enables all compiled-in providers.
+	EnabledProviders []string
+
+	// Circuit breaker: after CircuitFailureThreshold consecutive failures
+	// within CircuitWindow a provider is skipped for CircuitCooldown.
+	// A threshold of 0 disables the breaker.
+	CircuitFailureThreshold int
+	CircuitWindow           int // seconds
+	CircuitCooldown         int // seconds
+
+	// PassthroughHeaders lists incoming request headers forwarded to the
+	// upstream API by providers that support it.
+	PassthroughHeaders []string
+
+	// ProviderConfigFile is an env file (KEY=VALUE lines) of provider
+	// settings that is watched and applied to running providers when it
+	// changes. Empty disables reloading.
+	ProviderConfigFile string
+
+	// ModelAliasesFile is a YAML file of model aliases merged over the
+	// built-in ones. Empty uses the built-in aliases only.
+	ModelAliasesFile string
+
+	// StreamingChunkDelay pauses between streamed chunks, plus a random
+	// extra of up to StreamingChunkDelayJitter. Zero sends chunks as they
+	// arrive.
+	StreamingChunkDelay       time.Duration
+	StreamingChunkDelayJitter time.Duration
+
+	// TLS: the server speaks HTTPS when TLSCertFile and TLSKeyFile are
+	// set. TLSClientAuth (none, request, require, verify) controls client
+	// certificates, checked against the TLSClientCACert bundle.
+	// TLSOCSPStapleFile optionally holds a DER OCSP response to staple.
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSClientCACert   string
+	TLSClientAuth     string
+	TLSOCSPStapleFile string
+
+	// APIKeys are plaintext keys clients must present as Bearer tokens,
+	// in addition to the hashed keys added with "opencompat apikey add".
+	// Without any key the server is open.
+	APIKeys []string
+
+	// CORS for browser clients. Empty origins, methods or headers use the
+	// cors package defaults, which allow any origin.
+	CORSAllowedOrigins   []string
+	CORSAllowedMethods   []string
+	CORSAllowedHeaders   []string
+	CORSAllowCredentials bool
+	CORSMaxAge           int // seconds; 0 uses the default, negative disables caching
 }
 
 // Load reads global configuration from environment variables.
@@ -34,6 +135,43 @@ func Load() *Config {
 		Port:      getEnvInt("OPENCOMPAT_PORT", DefaultPort),
 		LogLevel:  getEnv("OPENCOMPAT_LOG_LEVEL", DefaultLogLevel),
 		LogFormat: getEnv("OPENCOMPAT_LOG_FORMAT", DefaultLogFormat),
+
+		DisableStreamingFallback:    getEnvBool("OPENCOMPAT_DISABLE_STREAMING_FALLBACK", false),
+		ClampParameters:             getEnvBool("OPENCOMPAT_CLAMP_PARAMETERS", true),
+		RejectOutOfBoundsParameters: getEnvBool("OPENCOMPAT_REJECT_OUT_OF_BOUNDS_PARAMETERS", false),
+		UsageWebhookURL:             getEnv("OPENCOMPAT_USAGE_WEBHOOK_URL", ""),
+		ResponseTransformScript:     getEnv("OPENCOMPAT_RESPONSE_TRANSFORM_SCRIPT", ""),
+		ResponseStripFields:         getEnv("OPENCOMPAT_RESPONSE_STRIP_FIELDS", ""),
+		JSONModeEnforcement:         getEnv("OPENCOMPAT_JSON_MODE_ENFORCEMENT", "passthrough"),
+		DrainStreams:                getEnvBool("OPENCOMPAT_GRACEFUL_SHUTDOWN_DRAIN_STREAMS", false),
+		DrainTimeout:                getEnvInt("OPENCOMPAT_GRACEFUL_SHUTDOWN_DRAIN_TIMEOUT", DefaultDrainTimeout),
+		RaceProviders:               getEnvBool("OPENCOMPAT_CONCURRENT_PROVIDERS_RACE", false),
+		PrepareTimeout:              getEnvInt("OPENCOMPAT_PREPARE_TIMEOUT", DefaultPrepareTimeout),
+		EnabledProviders:            getEnvList("OPENCOMPAT_ENABLED_PROVIDERS"),
+		CircuitFailureThreshold:     getEnvInt("OPENCOMPAT_CIRCUIT_BREAKER_THRESHOLD", DefaultCircuitFailureThreshold),
+		CircuitWindow:               getEnvInt("OPENCOMPAT_CIRCUIT_BREAKER_WINDOW", DefaultCircuitWindow),
+		CircuitCooldown:             getEnvInt("OPENCOMPAT_CIRCUIT_BREAKER_COOLDOWN", DefaultCircuitCooldown),
+
+		PassthroughHeaders: getEnvList("OPENCOMPAT_PASSTHROUGH_HEADERS"),
+		ModelAliasesFile:   getEnv("OPENCOMPAT_MODEL_ALIASES_FILE", ""),
+		ProviderConfigFile: getEnv("OPENCOMPAT_PROVIDER_CONFIG_FILE", ""),
+
+		StreamingChunkDelay:       getEnvDuration("OPENCOMPAT_STREAMING_CHUNK_DELAY", 0),
+		StreamingChunkDelayJitter: getEnvDuration("OPENCOMPAT_STREAMING_CHUNK_DELAY_JITTER", 0),
+
+		TLSCertFile:       getEnv("OPENCOMPAT_TLS_CERT_FILE", ""),
+		TLSKeyFile:        getEnv("OPENCOMPAT_TLS_KEY_FILE", ""),
+		TLSClientCACert:   getEnv("OPENCOMPAT_TLS_CLIENT_CA_CERT", ""),
+		TLSClientAuth:     getEnv("OPENCOMPAT_TLS_CLIENT_AUTH", "none"),
+		TLSOCSPStapleFile: getEnv("OPENCOMPAT_TLS_OCSP_STAPLE_FILE", ""),
+
+		APIKeys: getEnvValues("OPENCOMPAT_API_KEYS"),
+
+		CORSAllowedOrigins:   getEnvValues("OPENCOMPAT_CORS_ALLOWED_ORIGINS"),
+		CORSAllowedMethods:   getEnvValues("OPENCOMPAT_CORS_ALLOWED_METHODS"),
+		CORSAllowedHeaders:   getEnvValues("OPENCOMPAT_CORS_ALLOWED_HEADERS"),
+		CORSAllowCredentials: getEnvBool("OPENCOMPAT_CORS_ALLOW_CREDENTIALS", false),
+		CORSMaxAge:           getEnvInt("OPENCOMPAT_CORS_MAX_AGE", 0),
 	}
 }
 
@@ -56,6 +194,20 @@ func EnsureDataDir() error {
 	return os.MkdirAll(DataDir(), 0700)
 }
 
+// CacheDir returns the XDG cache directory for the application.
+// Uses $XDG_CACHE_HOME/opencompat or ~/.cache/opencompat
+func CacheDir() string {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = "."
+		}
+		base = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(base, AppName)
+}
+
 func getEnv(key, defaultVal string) string {
 	if val := os.Getenv(key); val != "" {
 		return val
@@ -71,3 +223,43 @@ func getEnvInt(key string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+func getEnvBool(key string, defaultVal bool) bool {
+	if val := os.Getenv(key); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	}
+	return defaultVal
+}
+
+// getEnvDuration reads a Go duration such as "50ms". Negative values are
+// ignored.
+func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	if val := os.Getenv(key); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return defaultVal
+}
+
+// getEnvList reads a comma-separated list, dropping empty entries.
+func getEnvList(key string) []string {
+	list := getEnvValues(key)
+	for i, item := range list {
+		list[i] = strings.ToLower(item)
+	}
+	return list
+}
+
+// getEnvValues is getEnvList for case-sensitive values such as secrets.
+func getEnvValues(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
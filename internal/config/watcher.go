@@ -0,0 +1,117 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce collapses the burst of events a single save produces.
+const watchDebounce = 200 * time.Millisecond
+
+// Watcher calls a callback whenever a file changes. It watches the file's
+// directory, so files replaced by editors or Kubernetes ConfigMap updates
+// are picked up too.
+type Watcher struct {
+	fs   *fsnotify.Watcher
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWatcher starts watching path and calls onChange, from a single
+// goroutine, after each change.
+func NewWatcher(path string, onChange func()) (*Watcher, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	if err := fs.Add(filepath.Dir(path)); err != nil {
+		_ = fs.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+
+	w := &Watcher{fs: fs, done: make(chan struct{})}
+	w.wg.Add(1)
+	go w.run(path, onChange)
+	return w, nil
+}
+
+func (w *Watcher) run(path string, onChange func()) {
+	defer w.wg.Done()
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			// ConfigMaps swap a symlinked directory, touching other names
+			if filepath.Clean(event.Name) == path || strings.HasPrefix(filepath.Base(event.Name), "..") {
+				debounce = time.After(watchDebounce)
+			}
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("config file watcher error", "path", path, "error", err)
+		case <-debounce:
+			debounce = nil
+			onChange()
+		}
+	}
+}
+
+// Close stops watching and waits for a running callback to return.
+func (w *Watcher) Close() error {
+	close(w.done)
+	err := w.fs.Close()
+	w.wg.Wait()
+	return err
+}
+
+// ReadEnvFile reads KEY=VALUE lines, as in a Docker env file. Blank lines
+// and lines starting with # are skipped, and values may be quoted.
+func ReadEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.env")
+	writeFile(t, path, `
+# Copilot settings
+OPENCOMPAT_COPILOT_MODELS_REFRESH=5
+export OPENCOMPAT_COPILOT_TOKEN_EXPIRY_BUFFER = "2m"
+OPENCOMPAT_COPILOT_EXTRA_MODELS='a,b'
+EMPTY=
+`)
+
+	got, err := ReadEnvFile(path)
+	if err != nil {
+		t.Fatalf("ReadEnvFile() error = %v", err)
+	}
+	want := map[string]string{
+		"OPENCOMPAT_COPILOT_MODELS_REFRESH":      "5",
+		"OPENCOMPAT_COPILOT_TOKEN_EXPIRY_BUFFER": "2m",
+		"OPENCOMPAT_COPILOT_EXTRA_MODELS":        "a,b",
+		"EMPTY":                                  "",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("ReadEnvFile() = %v, want %v", got, want)
+	}
+}
+
+func TestReadEnvFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "providers.env")
+	writeFile(t, path, "A=1\nnot a setting\n")
+
+	if _, err := ReadEnvFile(path); err == nil || !strings.Contains(err.Error(), "providers.env:2: expected KEY=VALUE") {
+		t.Errorf("ReadEnvFile() error = %v, want the line reported", err)
+	}
+	if _, err := ReadEnvFile(filepath.Join(dir, "missing.env")); !os.IsNotExist(err) {
+		t.Errorf("ReadEnvFile(missing) error = %v, want not exist", err)
+	}
+}
+
+// watch starts a Watcher on path that signals each change on the returned
+// channel.
+func watch(t *testing.T, path string) <-chan struct{} {
+	t.Helper()
+	changed := make(chan struct{}, 10)
+	w, err := NewWatcher(path, func() { changed <- struct{}{} })
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if err := w.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	})
+	return changed
+}
+
+// waitChange fails unless a change is signalled within a few debounce
+// periods.
+func waitChange(t *testing.T, changed <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-changed:
+	case <-time.After(10 * watchDebounce):
+		t.Fatal("onChange was not called")
+	}
+}
+
+// assertNoChange fails if a change is signalled within a few debounce
+// periods.
+func assertNoChange(t *testing.T, changed <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-changed:
+		t.Fatal("onChange was called")
+	case <-time.After(3 * watchDebounce):
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "providers.env")
+	writeFile(t, path, "A=1\n")
+	changed := watch(t, path)
+
+	// A burst of writes is reported once
+	for i := range 3 {
+		writeFile(t, path, strings.Repeat("A=2\n", i+1))
+	}
+	waitChange(t, changed)
+	assertNoChange(t, changed)
+
+	// Editors and ConfigMaps replace the file
+	tmp := filepath.Join(dir, "providers.env.tmp")
+	writeFile(t, tmp, "A=3\n")
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	waitChange(t, changed)
+
+	// Other files in the directory are ignored
+	writeFile(t, filepath.Join(dir, "other.env"), "B=1\n")
+	assertNoChange(t, changed)
+}
+
+func TestNewWatcherMissingDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "providers.env")
+	if _, err := NewWatcher(path, func() {}); err == nil || !strings.Contains(err.Error(), "failed to watch") {
+		t.Errorf("NewWatcher() error = %v, want failed to watch", err)
+	}
+}
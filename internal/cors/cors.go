@@ -0,0 +1,125 @@
+// Package cors implements Cross-Origin Resource Sharing for browser-based
+// clients of the HTTP server.
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Defaults applied to empty Config fields.
+var (
+	DefaultAllowedOrigins = []string{"*"}
+	DefaultAllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodOptions}
+	DefaultAllowedHeaders = []string{"Content-Type", "Authorization", "Accept", "OpenAI-Beta"}
+)
+
+// DefaultMaxAge is the preflight cache lifetime in seconds.
+const DefaultMaxAge = 86400
+
+// Config configures Middleware.
+type Config struct {
+	// AllowedOrigins lists the origins allowed to call the server, e.g.
+	// "https://app.example.com"; "*" allows any origin. Empty uses
+	// DefaultAllowedOrigins.
+	AllowedOrigins []string
+	// AllowedMethods and AllowedHeaders are announced in preflight
+	// responses. Empty uses the defaults.
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers scripts may read.
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and client certificates
+	// and read the response. It cannot be combined with the "*" origin.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response, in
+	// seconds; 0 uses DefaultMaxAge and a negative value disables caching.
+	MaxAge int
+}
+
+// Validate reports configurations that browsers would reject.
+func (c Config) Validate() error {
+	// An empty list means the default "*"
+	if c.AllowCredentials && (len(c.AllowedOrigins) == 0 || slices.Contains(c.AllowedOrigins, "*")) {
+		return errors.New(`the "*" origin cannot be used with credentials; list the allowed origins`)
+	}
+	return nil
+}
+
+// Middleware sets the Access-Control headers for requests from allowed
+// origins and answers preflight OPTIONS requests with 204. Requests from
+// other origins are served without CORS headers, so browsers block them.
+// cfg should pass Validate.
+func Middleware(cfg Config) func(http.Handler) http.Handler {
+	origins := cfg.AllowedOrigins
+	if len(origins) == 0 {
+		origins = DefaultAllowedOrigins
+	}
+	anyOrigin := slices.Contains(origins, "*") && !cfg.AllowCredentials
+
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = DefaultAllowedMethods
+	}
+	allowMethods := strings.ToUpper(strings.Join(methods, ", "))
+
+	headers := cfg.AllowedHeaders
+	if len(headers) == 0 {
+		headers = DefaultAllowedHeaders
+	}
+	allowHeaders := strings.Join(headers, ", ")
+	exposeHeaders := strings.Join(cfg.ExposedHeaders, ", ")
+
+	maxAge := cfg.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultMaxAge
+	}
+
+	allowed := func(origin string) bool {
+		return slices.ContainsFunc(origins, func(o string) bool { return strings.EqualFold(o, origin) })
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			origin := r.Header.Get("Origin")
+			cors := true
+			switch {
+			case anyOrigin:
+				h.Set("Access-Control-Allow-Origin", "*")
+			case origin != "" && allowed(origin):
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Add("Vary", "Origin")
+				if cfg.AllowCredentials {
+					h.Set("Access-Control-Allow-Credentials", "true")
+				}
+			default:
+				// The response differs by origin, so caches must key on it
+				h.Add("Vary", "Origin")
+				cors = false
+			}
+
+			if cors {
+				if exposeHeaders != "" {
+					h.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+				if r.Method == http.MethodOptions {
+					h.Set("Access-Control-Allow-Methods", allowMethods)
+					h.Set("Access-Control-Allow-Headers", allowHeaders)
+					if maxAge > 0 {
+						h.Set("Access-Control-Max-Age", strconv.Itoa(maxAge))
+					}
+				}
+			}
+
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
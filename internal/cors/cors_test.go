@@ -0,0 +1,194 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	const origin = "https://app.example.com"
+	listed := Config{AllowedOrigins: []string{origin}}
+	credentialed := Config{AllowedOrigins: []string{origin}, AllowCredentials: true}
+
+	tests := []struct {
+		name       string
+		cfg        Config
+		method     string
+		origin     string
+		wantStatus int
+		wantHeader map[string]string // "" means the header must be absent
+	}{
+		{
+			name:       "preflight with defaults",
+			method:     http.MethodOptions,
+			origin:     origin,
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Methods":     "GET, POST, OPTIONS",
+				"Access-Control-Allow-Headers":     "Content-Type, Authorization, Accept, OpenAI-Beta",
+				"Access-Control-Max-Age":           "86400",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		{
+			name:       "preflight with configured methods and headers",
+			cfg:        Config{AllowedOrigins: []string{origin}, AllowedMethods: []string{"post"}, AllowedHeaders: []string{"X-Custom"}, MaxAge: 60},
+			method:     http.MethodOptions,
+			origin:     origin,
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":  origin,
+				"Access-Control-Allow-Methods": "POST",
+				"Access-Control-Allow-Headers": "X-Custom",
+				"Access-Control-Max-Age":       "60",
+				"Vary":                         "Origin",
+			},
+		},
+		{
+			name:       "preflight without caching",
+			cfg:        Config{MaxAge: -1},
+			method:     http.MethodOptions,
+			origin:     origin,
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{"Access-Control-Max-Age": ""},
+		},
+		{
+			name:       "preflight from unlisted origin",
+			cfg:        listed,
+			method:     http.MethodOptions,
+			origin:     "https://evil.example.com",
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+				"Vary":                         "Origin",
+			},
+		},
+		{
+			name:       "simple request with defaults",
+			method:     http.MethodPost,
+			origin:     origin,
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "",
+				"Access-Control-Max-Age":       "",
+			},
+		},
+		{
+			name:       "simple request from listed origin",
+			cfg:        Config{AllowedOrigins: []string{"HTTPS://APP.EXAMPLE.COM"}, ExposedHeaders: []string{"x-request-id"}},
+			method:     http.MethodGet,
+			origin:     origin,
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":   origin,
+				"Access-Control-Expose-Headers": "x-request-id",
+				"Vary":                          "Origin",
+			},
+		},
+		{
+			name:       "simple request from unlisted origin",
+			cfg:        listed,
+			method:     http.MethodGet,
+			origin:     "https://evil.example.com",
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"},
+		},
+		{
+			name:       "request without origin",
+			cfg:        listed,
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:       "credentialed request",
+			cfg:        credentialed,
+			method:     http.MethodPost,
+			origin:     origin,
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":      origin,
+				"Access-Control-Allow-Credentials": "true",
+				"Vary":                             "Origin",
+			},
+		},
+		{
+			name:       "credentialed preflight",
+			cfg:        credentialed,
+			method:     http.MethodOptions,
+			origin:     origin,
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":      origin,
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, POST, OPTIONS",
+			},
+		},
+		{
+			name:       "credentialed request from unlisted origin",
+			cfg:        credentialed,
+			method:     http.MethodPost,
+			origin:     "https://evil.example.com",
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":      "",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			called := false
+			handler := Middleware(tt.cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+
+			req := httptest.NewRequest(tt.method, "/v1/chat/completions", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if wantCalled := tt.method != http.MethodOptions; called != wantCalled {
+				t.Errorf("next handler called = %v, want %v", called, wantCalled)
+			}
+			for key, want := range tt.wantHeader {
+				if got := rec.Header().Get(key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "defaults", cfg: Config{}},
+		{name: "wildcard", cfg: Config{AllowedOrigins: []string{"*"}}},
+		{name: "credentials with listed origins", cfg: Config{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true}},
+		{name: "credentials with default origins", cfg: Config{AllowCredentials: true}, wantErr: true},
+		{name: "credentials with wildcard", cfg: Config{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
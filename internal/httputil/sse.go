@@ -0,0 +1,62 @@
+package httputil
+
+import "net/http"
+
+// SSEResponseWriter wraps an http.ResponseWriter for Server-Sent Events.
+// It sets the SSE headers before the response is first written and flushes
+// after every Write, so events reach the client immediately instead of
+// batching in the server's buffers.
+type SSEResponseWriter struct {
+	http.ResponseWriter
+	flusher     http.Flusher
+	wroteHeader bool
+}
+
+// NewSSEResponseWriter wraps w. It panics if w does not implement
+// http.Flusher, since SSE cannot work without flushing.
+func NewSSEResponseWriter(w http.ResponseWriter) *SSEResponseWriter {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		panic("httputil: SSEResponseWriter requires an http.ResponseWriter that implements http.Flusher")
+	}
+	return &SSEResponseWriter{ResponseWriter: w, flusher: flusher}
+}
+
+// WriteHeader sets the SSE headers and writes the status code.
+func (s *SSEResponseWriter) WriteHeader(statusCode int) {
+	if s.wroteHeader {
+		return
+	}
+	s.wroteHeader = true
+
+	h := s.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+
+	s.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write writes p and flushes it to the client.
+func (s *SSEResponseWriter) Write(p []byte) (int, error) {
+	if !s.wroteHeader {
+		s.WriteHeader(http.StatusOK)
+	}
+	n, err := s.ResponseWriter.Write(p)
+	if err != nil {
+		return n, err
+	}
+	s.flusher.Flush()
+	return n, nil
+}
+
+// Flush flushes any buffered data to the client.
+func (s *SSEResponseWriter) Flush() {
+	s.flusher.Flush()
+}
+
+// Unwrap returns the underlying writer (for http.ResponseController).
+func (s *SSEResponseWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
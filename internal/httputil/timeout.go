@@ -0,0 +1,192 @@
+package httputil
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Adaptive timeout defaults.
+const (
+	DefaultMinTimeout = 30 * time.Second
+
+	// adaptiveMultiplier scales the p99 latency into the request timeout.
+	adaptiveMultiplier = 3
+
+	// adaptiveMinSamples is the number of observations needed before the
+	// p99 estimate is trusted; until then the maximum timeout applies.
+	adaptiveMinSamples = 20
+)
+
+// Histogram layout: bucket i covers durations up to
+// histogramBase * histogramGrowth^i, so 128 buckets span 1ms to ~4.6h with
+// about 14% relative error.
+const (
+	histogramBase    = time.Millisecond
+	histogramGrowth  = 1.14
+	histogramBuckets = 128
+
+	// histogramWindow halves all counts once this many observations have
+	// accumulated, so the estimate follows recent latency.
+	histogramWindow = 1000
+)
+
+// p99Gauge exposes the current p99 estimate of every AdaptiveTimeout, in
+// seconds, under /debug/vars.
+var p99Gauge = expvar.NewMap("opencompat_upstream_p99_seconds")
+
+// AdaptiveTimeout is an http.RoundTripper that bounds the time to response
+// headers by max(p99 * 3, minTimeout) of recent 2xx responses, capped at
+// maxTimeout. Reading the body is not covered, so long streams are bounded
+// only by the request context.
+type AdaptiveTimeout struct {
+	next       http.RoundTripper
+	minTimeout time.Duration
+	maxTimeout time.Duration
+
+	mu     sync.Mutex
+	counts [histogramBuckets]float64
+	total  float64
+	seen   int // observations since creation, for adaptiveMinSamples
+}
+
+// NewAdaptiveTimeout wraps next. name identifies the instance in the p99
+// gauge; minTimeout defaults to DefaultMinTimeout when zero.
+func NewAdaptiveTimeout(name string, next http.RoundTripper, minTimeout, maxTimeout time.Duration) *AdaptiveTimeout {
+	if minTimeout <= 0 {
+		minTimeout = DefaultMinTimeout
+	}
+	a := &AdaptiveTimeout{
+		next:       next,
+		minTimeout: minTimeout,
+		maxTimeout: max(maxTimeout, minTimeout),
+	}
+	p99Gauge.Set(name, expvar.Func(func() any { return a.P99().Seconds() }))
+	return a
+}
+
+// Timeout returns the timeout applied to the next request.
+func (a *AdaptiveTimeout) Timeout() time.Duration {
+	a.mu.Lock()
+	seen, minTimeout, maxTimeout := a.seen, a.minTimeout, a.maxTimeout
+	a.mu.Unlock()
+	if seen < adaptiveMinSamples {
+		return maxTimeout
+	}
+	return min(max(a.P99()*adaptiveMultiplier, minTimeout), maxTimeout)
+}
+
+// SetMinTimeout changes the lower bound for subsequent requests; zero
+// restores DefaultMinTimeout. Requests in flight keep their timeout.
+func (a *AdaptiveTimeout) SetMinTimeout(minTimeout time.Duration) {
+	if minTimeout <= 0 {
+		minTimeout = DefaultMinTimeout
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.minTimeout = minTimeout
+	a.maxTimeout = max(a.maxTimeout, minTimeout)
+}
+
+// P99 returns the estimated 99th percentile of the time to headers of
+// recent 2xx responses, or 0 before any has been observed.
+func (a *AdaptiveTimeout) P99() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.total == 0 {
+		return 0
+	}
+	// Walk down from the slowest bucket until 1% of the weight is above us
+	tail := a.total * 0.01
+	var above float64
+	for i := histogramBuckets - 1; i >= 0; i-- {
+		above += a.counts[i]
+		if above >= tail {
+			return bucketUpperBound(i)
+		}
+	}
+	return bucketUpperBound(0)
+}
+
+// Observe records the time to headers of a 2xx response.
+func (a *AdaptiveTimeout) Observe(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.counts[bucketIndex(d)]++
+	a.total++
+	a.seen++
+	if a.total >= histogramWindow {
+		for i := range a.counts {
+			a.counts[i] /= 2
+		}
+		a.total /= 2
+	}
+}
+
+// RoundTrip sends req, canceling it if the response headers don't arrive
+// within the current adaptive timeout.
+func (a *AdaptiveTimeout) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A timer rather than a context deadline, which would also cut off the
+	// body; the context stays alive until the body is closed
+	ctx, cancel := context.WithCancel(req.Context())
+	timeout := a.Timeout()
+	timer := time.AfterFunc(timeout, cancel)
+	start := time.Now()
+
+	resp, err := a.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		timer.Stop()
+		cancel()
+		if ctx.Err() != nil && req.Context().Err() == nil {
+			return nil, headerTimeoutError(timeout)
+		}
+		return nil, err
+	}
+	if !timer.Stop() {
+		// Fired just as the headers arrived; the body is already canceled
+		resp.Body.Close()
+		return nil, headerTimeoutError(timeout)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		a.Observe(time.Since(start))
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func headerTimeoutError(timeout time.Duration) error {
+	return fmt.Errorf("%w: no response headers within %s", context.DeadlineExceeded, timeout)
+}
+
+// cancelBody releases the request context when the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// bucketIndex returns the histogram bucket for d.
+func bucketIndex(d time.Duration) int {
+	if d <= histogramBase {
+		return 0
+	}
+	i := int(math.Ceil(math.Log(float64(d)/float64(histogramBase)) / math.Log(histogramGrowth)))
+	return min(i, histogramBuckets-1)
+}
+
+// bucketUpperBound returns the largest duration counted in bucket i.
+func bucketUpperBound(i int) time.Duration {
+	return time.Duration(float64(histogramBase) * math.Pow(histogramGrowth, float64(i)))
+}
@@ -0,0 +1,84 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTimeoutBoundsHeadersOnly(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-headers" {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		// Headers right away, then a body that outlasts the timeout
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		_, _ = io.WriteString(w, "done")
+	}))
+	defer srv.Close()
+
+	a := NewAdaptiveTimeout(t.Name(), http.DefaultTransport, 50*time.Millisecond, 50*time.Millisecond)
+	client := &http.Client{Transport: a}
+
+	resp, err := client.Get(srv.URL + "/slow-body")
+	if err != nil {
+		t.Fatalf("slow body: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "done" {
+		t.Errorf("slow body: read %q, %v; want %q", body, err, "done")
+	}
+
+	_, err = client.Get(srv.URL + "/slow-headers")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("slow headers: error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestAdaptiveTimeoutFollowsP99(t *testing.T) {
+	a := NewAdaptiveTimeout(t.Name(), http.DefaultTransport, time.Second, time.Minute)
+	if got := a.Timeout(); got != time.Minute {
+		t.Errorf("Timeout() before samples = %s, want the maximum", got)
+	}
+	for range adaptiveMinSamples {
+		a.Observe(2 * time.Second)
+	}
+	got := a.Timeout()
+	if got < 6*time.Second || got > 7*time.Second {
+		t.Errorf("Timeout() = %s, want about 3x the 2s p99", got)
+	}
+}
+
+func TestAdaptiveTimeoutSetMinTimeout(t *testing.T) {
+	a := NewAdaptiveTimeout(t.Name(), http.DefaultTransport, time.Second, time.Minute)
+	for range adaptiveMinSamples {
+		a.Observe(100 * time.Millisecond)
+	}
+	if got := a.Timeout(); got != time.Second {
+		t.Fatalf("Timeout() = %s, want the 1s minimum", got)
+	}
+
+	a.SetMinTimeout(10 * time.Second)
+	if got := a.Timeout(); got != 10*time.Second {
+		t.Errorf("Timeout() after SetMinTimeout(10s) = %s, want 10s", got)
+	}
+	a.SetMinTimeout(2 * time.Minute)
+	if got := a.Timeout(); got != 2*time.Minute {
+		t.Errorf("Timeout() after SetMinTimeout(2m) = %s, want the maximum raised to 2m", got)
+	}
+	a.SetMinTimeout(0)
+	if got := a.Timeout(); got != DefaultMinTimeout {
+		t.Errorf("Timeout() after SetMinTimeout(0) = %s, want %s", got, DefaultMinTimeout)
+	}
+}
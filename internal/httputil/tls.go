@@ -0,0 +1,92 @@
+package httputil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Client certificate policies accepted by TLSConfig.ClientAuth.
+const (
+	ClientAuthNone    = "none"    // don't ask for a certificate
+	ClientAuthRequest = "request" // ask, verifying it against the CA when one is sent
+	ClientAuthRequire = "require" // require a certificate, verifying it against the CA if set
+	ClientAuthVerify  = "verify"  // require a certificate signed by the CA
+)
+
+// TLSConfig configures BuildTLSConfig.
+type TLSConfig struct {
+	// CertFile and KeyFile are the PEM server certificate chain and key.
+	CertFile string
+	KeyFile  string
+
+	// ClientCACert is a PEM bundle of CAs trusted to sign client
+	// certificates. Required when ClientAuth is "verify".
+	ClientCACert string
+
+	// ClientAuth is one of the ClientAuth* policies; empty means "none".
+	ClientAuth string
+
+	// OCSPStapleFile is a DER-encoded OCSP response for the server
+	// certificate, stapled to every handshake. Empty disables stapling.
+	OCSPStapleFile string
+}
+
+// BuildTLSConfig returns the server TLS configuration for cfg.
+func BuildTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("TLS requires both a certificate and a key file")
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+	if cfg.OCSPStapleFile != "" {
+		if cert.OCSPStaple, err = os.ReadFile(cfg.OCSPStapleFile); err != nil {
+			return nil, fmt.Errorf("read OCSP staple: %w", err)
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCACert != "" {
+		pem, err := os.ReadFile(cfg.ClientCACert)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client CA bundle %s contains no PEM certificates", cfg.ClientCACert)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	verify := tlsConfig.ClientCAs != nil
+	switch cfg.ClientAuth {
+	case "", ClientAuthNone:
+		tlsConfig.ClientAuth = tls.NoClientCert
+	case ClientAuthRequest:
+		tlsConfig.ClientAuth = tls.RequestClientCert
+		if verify {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	case ClientAuthRequire:
+		tlsConfig.ClientAuth = tls.RequireAnyClientCert
+		if verify {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	case ClientAuthVerify:
+		if !verify {
+			return nil, errors.New(`client auth "verify" requires a client CA bundle`)
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("invalid client auth %q (must be none, request, require or verify)", cfg.ClientAuth)
+	}
+	return tlsConfig, nil
+}
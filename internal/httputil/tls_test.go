@@ -0,0 +1,267 @@
+package httputil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate and key minted by newTestCert.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// newTestCert mints a certificate from template, signed by parent or
+// self-signed when parent is nil.
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// newTestCA mints a self-signed CA.
+func newTestCA(t *testing.T, name string) *testCert {
+	t.Helper()
+	return newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil)
+}
+
+// writePEM writes the certificate and key as PEM files in dir and returns
+// their paths.
+func (c *testCert) writePEM(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	certFile = filepath.Join(dir, name+".crt")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// tlsCertificate returns c as a client certificate.
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+// mtlsFixture is a CA with a server and a client certificate it signed.
+type mtlsFixture struct {
+	ca, server, client *testCert
+	caFile             string
+	certFile, keyFile  string
+}
+
+func newMTLSFixture(t *testing.T) *mtlsFixture {
+	t.Helper()
+	dir := t.TempDir()
+	f := &mtlsFixture{ca: newTestCA(t, "test CA")}
+	f.server = newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "server"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, f.ca)
+	f.client = newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "client"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, f.ca)
+	f.caFile, _ = f.ca.writePEM(t, dir, "ca")
+	f.certFile, f.keyFile = f.server.writePEM(t, dir, "server")
+	return f
+}
+
+// startTLSServer serves 200 OK over TLS configured by cfg.
+func startTLSServer(t *testing.T, cfg TLSConfig) *httptest.Server {
+	t.Helper()
+	tlsConfig, err := BuildTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildTLSConfig() error = %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = tlsConfig
+	// Rejected handshakes are expected
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// get requests url trusting ca and presenting certs.
+func get(url string, ca *testCert, certs ...tls.Certificate) error {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: certs,
+	}}}
+	defer client.CloseIdleConnections()
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestBuildTLSConfigVerifiesClientCert(t *testing.T) {
+	f := newMTLSFixture(t)
+	srv := startTLSServer(t, TLSConfig{
+		CertFile:     f.certFile,
+		KeyFile:      f.keyFile,
+		ClientCACert: f.caFile,
+		ClientAuth:   ClientAuthVerify,
+	})
+
+	if err := get(srv.URL, f.ca); err == nil {
+		t.Error("request without a client certificate succeeded")
+	}
+
+	other := newTestCA(t, "other CA")
+	untrusted := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "client"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, other)
+	if err := get(srv.URL, f.ca, untrusted.tlsCertificate()); err == nil {
+		t.Error("request with a certificate from another CA succeeded")
+	}
+
+	if err := get(srv.URL, f.ca, f.client.tlsCertificate()); err != nil {
+		t.Errorf("request with a CA-signed client certificate error = %v", err)
+	}
+}
+
+func TestBuildTLSConfigWithoutClientAuth(t *testing.T) {
+	f := newMTLSFixture(t)
+	srv := startTLSServer(t, TLSConfig{CertFile: f.certFile, KeyFile: f.keyFile})
+
+	if err := get(srv.URL, f.ca); err != nil {
+		t.Errorf("request without a client certificate error = %v", err)
+	}
+}
+
+func TestBuildTLSConfigClientAuth(t *testing.T) {
+	f := newMTLSFixture(t)
+	tests := []struct {
+		clientAuth string
+		withCA     bool
+		want       tls.ClientAuthType
+	}{
+		{clientAuth: "", want: tls.NoClientCert},
+		{clientAuth: ClientAuthNone, withCA: true, want: tls.NoClientCert},
+		{clientAuth: ClientAuthRequest, want: tls.RequestClientCert},
+		{clientAuth: ClientAuthRequest, withCA: true, want: tls.VerifyClientCertIfGiven},
+		{clientAuth: ClientAuthRequire, want: tls.RequireAnyClientCert},
+		{clientAuth: ClientAuthRequire, withCA: true, want: tls.RequireAndVerifyClientCert},
+		{clientAuth: ClientAuthVerify, withCA: true, want: tls.RequireAndVerifyClientCert},
+	}
+	for _, tt := range tests {
+		cfg := TLSConfig{CertFile: f.certFile, KeyFile: f.keyFile, ClientAuth: tt.clientAuth}
+		if tt.withCA {
+			cfg.ClientCACert = f.caFile
+		}
+		got, err := BuildTLSConfig(cfg)
+		if err != nil {
+			t.Errorf("BuildTLSConfig(%q, CA %v) error = %v", tt.clientAuth, tt.withCA, err)
+			continue
+		}
+		if got.ClientAuth != tt.want {
+			t.Errorf("BuildTLSConfig(%q, CA %v) ClientAuth = %v, want %v", tt.clientAuth, tt.withCA, got.ClientAuth, tt.want)
+		}
+		if got.MinVersion != tls.VersionTLS12 {
+			t.Errorf("MinVersion = %x, want TLS 1.2", got.MinVersion)
+		}
+	}
+}
+
+func TestBuildTLSConfigOCSPStaple(t *testing.T) {
+	f := newMTLSFixture(t)
+	staple := filepath.Join(t.TempDir(), "ocsp.der")
+	if err := os.WriteFile(staple, []byte("ocsp response"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := BuildTLSConfig(TLSConfig{CertFile: f.certFile, KeyFile: f.keyFile, OCSPStapleFile: staple})
+	if err != nil {
+		t.Fatalf("BuildTLSConfig() error = %v", err)
+	}
+	if string(got.Certificates[0].OCSPStaple) != "ocsp response" {
+		t.Errorf("OCSPStaple = %q, want the file contents", got.Certificates[0].OCSPStaple)
+	}
+}
+
+func TestBuildTLSConfigErrors(t *testing.T) {
+	f := newMTLSFixture(t)
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     TLSConfig
+		wantErr string
+	}{
+		{name: "missing key", cfg: TLSConfig{CertFile: f.certFile}, wantErr: "both a certificate and a key file"},
+		{name: "unreadable certificate", cfg: TLSConfig{CertFile: f.certFile + ".missing", KeyFile: f.keyFile}, wantErr: "load server certificate"},
+		{name: "verify without CA", cfg: TLSConfig{CertFile: f.certFile, KeyFile: f.keyFile, ClientAuth: ClientAuthVerify}, wantErr: "requires a client CA bundle"},
+		{name: "CA without certificates", cfg: TLSConfig{CertFile: f.certFile, KeyFile: f.keyFile, ClientCACert: notPEM}, wantErr: "contains no PEM certificates"},
+		{name: "missing OCSP staple", cfg: TLSConfig{CertFile: f.certFile, KeyFile: f.keyFile, OCSPStapleFile: notPEM + ".missing"}, wantErr: "read OCSP staple"},
+		{name: "invalid client auth", cfg: TLSConfig{CertFile: f.certFile, KeyFile: f.keyFile, ClientAuth: "always"}, wantErr: `invalid client auth "always"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildTLSConfig(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("BuildTLSConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
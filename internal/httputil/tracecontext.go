@@ -0,0 +1,123 @@
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// W3C Trace Context headers.
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// ExtractTraceContext returns the traceparent and tracestate headers of r,
+// keyed by header name, or nil when r has no valid traceparent. A
+// tracestate without a valid traceparent is dropped, as the spec requires.
+func ExtractTraceContext(r *http.Request) map[string]string {
+	return traceContextFromHeader(r.Header)
+}
+
+// InjectTraceContext sets the traceparent and tracestate headers of req
+// from tc, a map returned by ExtractTraceContext. Nil tc does nothing.
+func InjectTraceContext(req *http.Request, tc map[string]string) {
+	for _, name := range []string{TraceParentHeader, TraceStateHeader} {
+		if v := tc[name]; v != "" {
+			req.Header.Set(name, v)
+		}
+	}
+}
+
+func traceContextFromHeader(h http.Header) map[string]string {
+	parent := strings.TrimSpace(h.Get(TraceParentHeader))
+	if !validTraceParent(parent) {
+		return nil
+	}
+	tc := map[string]string{TraceParentHeader: parent}
+	if state := strings.TrimSpace(strings.Join(h.Values(TraceStateHeader), ",")); state != "" {
+		tc[TraceStateHeader] = state
+	}
+	return tc
+}
+
+// validTraceParent checks the version-traceid-parentid-flags layout with
+// lowercase hex fields and non-zero IDs. Versions after 00 may append
+// fields.
+func validTraceParent(s string) bool {
+	if len(s) < 55 || (len(s) > 55 && (s[:2] == "00" || s[55] != '-')) {
+		return false
+	}
+	fields := strings.Split(s[:55], "-")
+	if len(fields) != 4 || fields[0] == "ff" {
+		return false
+	}
+	for i, n := range []int{2, 32, 16, 2} {
+		if len(fields[i]) != n || !isLowerHex(fields[i]) {
+			return false
+		}
+	}
+	return strings.Trim(fields[1], "0") != "" && strings.Trim(fields[2], "0") != ""
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+type traceContextKey struct{}
+
+// traceContext is the trace context of one proxied request.
+type traceContext struct {
+	outgoing map[string]string
+
+	mu       sync.Mutex
+	upstream map[string]string
+}
+
+// WithTraceContext returns a context carrying tc for upstream requests. It
+// also records the trace context of the upstream response, see
+// RecordUpstreamTraceContext; tc may be nil for that alone.
+func WithTraceContext(ctx context.Context, tc map[string]string) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, &traceContext{outgoing: tc})
+}
+
+// TraceContextFrom returns the trace context carried by ctx, or nil.
+func TraceContextFrom(ctx context.Context) map[string]string {
+	if tc, ok := ctx.Value(traceContextKey{}).(*traceContext); ok {
+		return tc.outgoing
+	}
+	return nil
+}
+
+// RecordUpstreamTraceContext stores the trace context headers of an
+// upstream response in ctx. It does nothing if ctx carries no trace
+// context or the response has no valid traceparent.
+func RecordUpstreamTraceContext(ctx context.Context, h http.Header) {
+	tc, ok := ctx.Value(traceContextKey{}).(*traceContext)
+	if !ok {
+		return
+	}
+	if upstream := traceContextFromHeader(h); upstream != nil {
+		tc.mu.Lock()
+		tc.upstream = upstream
+		tc.mu.Unlock()
+	}
+}
+
+// UpstreamTraceContext returns the trace context recorded from the last
+// upstream response, or nil.
+func UpstreamTraceContext(ctx context.Context) map[string]string {
+	tc, ok := ctx.Value(traceContextKey{}).(*traceContext)
+	if !ok {
+		return nil
+	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.upstream
+}
@@ -0,0 +1,101 @@
+package httputil
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestExtractTraceContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   map[string]string
+	}{
+		{
+			name:   "traceparent",
+			header: http.Header{"Traceparent": {testTraceParent}},
+			want:   map[string]string{TraceParentHeader: testTraceParent},
+		},
+		{
+			name:   "with tracestate",
+			header: http.Header{"Traceparent": {" " + testTraceParent + " "}, "Tracestate": {"congo=t61rcWkgMzE", "rojo=00f067aa0ba902b7"}},
+			want:   map[string]string{TraceParentHeader: testTraceParent, TraceStateHeader: "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7"},
+		},
+		{
+			name:   "future version with extra fields",
+			header: http.Header{"Traceparent": {"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"}},
+			want:   map[string]string{TraceParentHeader: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		},
+		{name: "missing", header: http.Header{}},
+		{name: "tracestate alone", header: http.Header{"Tracestate": {"congo=t61rcWkgMzE"}}},
+		{name: "uppercase hex", header: http.Header{"Traceparent": {"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"}}},
+		{name: "zero trace ID", header: http.Header{"Traceparent": {"00-00000000000000000000000000000000-00f067aa0ba902b7-01"}}},
+		{name: "zero parent ID", header: http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"}}},
+		{name: "invalid version", header: http.Header{"Traceparent": {"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}},
+		{name: "version 00 with extra fields", header: http.Header{"Traceparent": {testTraceParent + "-extra"}}},
+		{name: "short", header: http.Header{"Traceparent": {"00-4bf92f3577b34da6-00f067aa0ba902b7-01"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			r.Header = tt.header
+			if got := ExtractTraceContext(r); !maps.Equal(got, tt.want) {
+				t.Errorf("ExtractTraceContext() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInjectTraceContext(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/chat/completions", nil)
+	InjectTraceContext(req, map[string]string{TraceParentHeader: testTraceParent, TraceStateHeader: "congo=t61rcWkgMzE"})
+	if got := req.Header.Get(TraceParentHeader); got != testTraceParent {
+		t.Errorf("traceparent = %q, want %q", got, testTraceParent)
+	}
+	if got := req.Header.Get(TraceStateHeader); got != "congo=t61rcWkgMzE" {
+		t.Errorf("tracestate = %q, want congo=t61rcWkgMzE", got)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/chat/completions", nil)
+	InjectTraceContext(req, nil)
+	if len(req.Header) != 0 {
+		t.Errorf("headers after injecting nil = %v, want none", req.Header)
+	}
+}
+
+func TestUpstreamTraceContext(t *testing.T) {
+	const upstreamParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-b7ad6b7169203331-01"
+	outgoing := map[string]string{TraceParentHeader: testTraceParent}
+	ctx := WithTraceContext(context.Background(), outgoing)
+	if got := TraceContextFrom(ctx); !maps.Equal(got, outgoing) {
+		t.Errorf("TraceContextFrom() = %v, want %v", got, outgoing)
+	}
+	if got := UpstreamTraceContext(ctx); got != nil {
+		t.Errorf("UpstreamTraceContext() before a response = %v, want nil", got)
+	}
+
+	// Invalid upstream values are ignored
+	RecordUpstreamTraceContext(ctx, http.Header{"Traceparent": {"garbage"}})
+	if got := UpstreamTraceContext(ctx); got != nil {
+		t.Errorf("UpstreamTraceContext() after an invalid traceparent = %v, want nil", got)
+	}
+	RecordUpstreamTraceContext(ctx, http.Header{"Traceparent": {upstreamParent}})
+	if got := UpstreamTraceContext(ctx); got[TraceParentHeader] != upstreamParent {
+		t.Errorf("UpstreamTraceContext() = %v, want traceparent %s", got, upstreamParent)
+	}
+
+	// Contexts without a trace context record nothing
+	plain := context.Background()
+	RecordUpstreamTraceContext(plain, http.Header{"Traceparent": {upstreamParent}})
+	if got := UpstreamTraceContext(plain); got != nil {
+		t.Errorf("UpstreamTraceContext() without WithTraceContext = %v, want nil", got)
+	}
+	if got := TraceContextFrom(plain); got != nil {
+		t.Errorf("TraceContextFrom() without WithTraceContext = %v, want nil", got)
+	}
+}
@@ -0,0 +1,121 @@
+package httputil
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// TransportConfig configures BuildTransport.
+type TransportConfig struct {
+	// ProxyURL routes requests through this proxy (http, https or socks5,
+	// with optional userinfo credentials) unless the host matches NoProxy.
+	// Nil uses the proxy environment, see ProxyFromEnvironment.
+	ProxyURL *url.URL
+	NoProxy  []string
+
+	// TLSConfig replaces the default TLS client configuration when set.
+	TLSConfig *tls.Config
+
+	// HTTP2 tunes HTTP/2 connections; nil keeps the defaults. HTTP1Only
+	// disables HTTP/2 altogether.
+	HTTP2     *http.HTTP2Config
+	HTTP1Only bool
+}
+
+// BuildTransport returns a transport cloned from http.DefaultTransport with
+// cfg applied. Providers share it so proxy, TLS and HTTP/2 settings behave
+// the same everywhere.
+func BuildTransport(cfg TransportConfig) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.ProxyURL != nil {
+		proxyURL, noProxy := cfg.ProxyURL, cfg.NoProxy
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if BypassProxy(req.URL.Hostname(), noProxy) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+	} else {
+		transport.Proxy = ProxyFromEnvironment
+	}
+
+	if cfg.TLSConfig != nil {
+		transport.TLSClientConfig = cfg.TLSConfig
+	}
+
+	if cfg.HTTP1Only {
+		var protocols http.Protocols
+		protocols.SetHTTP1(true)
+		transport.Protocols = &protocols
+		// The clone may already offer h2 over ALPN, which a server would accept
+		if transport.TLSClientConfig != nil {
+			transport.TLSClientConfig.NextProtos = slices.DeleteFunc(
+				slices.Clone(transport.TLSClientConfig.NextProtos),
+				func(proto string) bool { return proto == "h2" })
+		}
+	} else if cfg.HTTP2 != nil {
+		transport.HTTP2 = cfg.HTTP2
+	}
+	return transport
+}
+
+// ProxyFromEnvironment extends http.ProxyFromEnvironment (HTTPS_PROXY,
+// HTTP_PROXY and NO_PROXY) with ALL_PROXY, used for hosts the former
+// leave unproxied unless NO_PROXY matches or the host is a loopback
+// address.
+func ProxyFromEnvironment(req *http.Request) (*url.URL, error) {
+	proxyURL, err := http.ProxyFromEnvironment(req)
+	if proxyURL != nil || err != nil {
+		return proxyURL, err
+	}
+
+	all := getenvAny("ALL_PROXY", "all_proxy")
+	if all == "" {
+		return nil, nil
+	}
+	host := req.URL.Hostname()
+	if host == "localhost" || isLoopback(host) {
+		return nil, nil
+	}
+	if BypassProxy(host, strings.Split(getenvAny("NO_PROXY", "no_proxy"), ",")) {
+		return nil, nil
+	}
+	return url.Parse(all)
+}
+
+// BypassProxy reports whether host matches a no-proxy entry, either exactly
+// or as a subdomain. A "*" entry bypasses the proxy for all hosts.
+func BypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(entry), "."))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" || host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// getenvAny returns the first non-empty environment variable among keys.
+func getenvAny(keys ...string) string {
+	for _, key := range keys {
+		if val := os.Getenv(key); val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,100 @@
+//go:build !nometrics
+
+// Package metrics exports Prometheus metrics for upstream requests.
+// Build with -tags nometrics to leave Prometheus out of the binary; the
+// recording functions then do nothing.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registry holds the opencompat metrics plus the standard Go and process
+// collectors, keeping them apart from prometheus.DefaultRegisterer.
+var registry = prometheus.NewRegistry()
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "opencompat_requests_total",
+		Help: "Upstream requests by provider, model and HTTP status (\"error\" when no response was received).",
+	}, []string{"provider", "model", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "opencompat_request_duration_seconds",
+		Help:    "Time until the upstream response headers arrived.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 12), // 100ms to ~3.4m
+	}, []string{"provider", "model"})
+
+	tokenUsage = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "opencompat_token_usage_total",
+		Help: "Tokens reported by upstream, by type (prompt, completion, total).",
+	}, []string{"provider", "model", "type"})
+
+	streamFirstByte = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "opencompat_stream_first_byte_seconds",
+		Help:    "Time from sending a streaming request until its first chunk.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 12), // 50ms to ~1.7m
+	}, []string{"provider", "model"})
+
+	streamFirstToken = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "opencompat_stream_first_token_seconds",
+		Help:    "Time from sending a streaming request until its first chunk with content.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 12), // 50ms to ~1.7m
+	}, []string{"provider", "model"})
+)
+
+func init() {
+	registry.MustRegister(
+		requestsTotal,
+		requestDuration,
+		tokenUsage,
+		streamFirstByte,
+		streamFirstToken,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler returns the Prometheus scrape handler, to be mounted at /metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records an upstream request. status is the HTTP status
+// code, or 0 if the request failed before a response arrived.
+func ObserveRequest(provider, model string, status int, d time.Duration) {
+	label := "error"
+	if status > 0 {
+		label = strconv.Itoa(status)
+	}
+	requestsTotal.WithLabelValues(provider, model, label).Inc()
+	requestDuration.WithLabelValues(provider, model).Observe(d.Seconds())
+}
+
+// ObserveFirstByte records the time until the first chunk of a stream.
+func ObserveFirstByte(provider, model string, d time.Duration) {
+	streamFirstByte.WithLabelValues(provider, model).Observe(d.Seconds())
+}
+
+// ObserveFirstToken records the time until the first content of a stream.
+func ObserveFirstToken(provider, model string, d time.Duration) {
+	streamFirstToken.WithLabelValues(provider, model).Observe(d.Seconds())
+}
+
+// ObserveUsage adds the token counts of a response or final stream chunk.
+// A nil usage is ignored.
+func ObserveUsage(provider, model string, usage *api.Usage) {
+	if usage == nil {
+		return
+	}
+	tokenUsage.WithLabelValues(provider, model, "prompt").Add(float64(usage.PromptTokens))
+	tokenUsage.WithLabelValues(provider, model, "completion").Add(float64(usage.CompletionTokens))
+	tokenUsage.WithLabelValues(provider, model, "total").Add(float64(usage.TotalTokens))
+}
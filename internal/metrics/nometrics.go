@@ -0,0 +1,27 @@
+//go:build nometrics
+
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// Handler returns a handler that answers 404, as metrics are compiled out.
+func Handler() http.Handler {
+	return http.NotFoundHandler()
+}
+
+// ObserveRequest does nothing in nometrics builds.
+func ObserveRequest(provider, model string, status int, d time.Duration) {}
+
+// ObserveFirstByte does nothing in nometrics builds.
+func ObserveFirstByte(provider, model string, d time.Duration) {}
+
+// ObserveFirstToken does nothing in nometrics builds.
+func ObserveFirstToken(provider, model string, d time.Duration) {}
+
+// ObserveUsage does nothing in nometrics builds.
+func ObserveUsage(provider, model string, usage *api.Usage) {}
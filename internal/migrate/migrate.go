@@ -0,0 +1,127 @@
+// Package migrate converts configuration files written for an older
+// release to the current format.
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/edgard/opencompat/internal/modelalias"
+)
+
+// MigrationFunc converts a decoded configuration document from one version
+// to another. It must not modify old.
+type MigrationFunc func(old map[string]any) (map[string]any, error)
+
+// versionPair identifies a migration, e.g. {"v1", "v2"}.
+type versionPair struct {
+	from, to string
+}
+
+// migrations are the supported conversions.
+var migrations = map[versionPair]MigrationFunc{
+	{"v1", "v2"}: migrateV1ToV2,
+}
+
+// validators check that a document matches the schema of a version.
+var validators = map[string]func(map[string]any) error{
+	"v2": validateV2,
+}
+
+// Versions returns the supported "from -> to" pairs, sorted.
+func Versions() []string {
+	pairs := make([]string, 0, len(migrations))
+	for pair := range migrations {
+		pairs = append(pairs, pair.from+" -> "+pair.to)
+	}
+	slices.Sort(pairs)
+	return pairs
+}
+
+// Migrate converts doc from version from to version to and validates the
+// result against the schema of to.
+func Migrate(doc map[string]any, from, to string) (map[string]any, error) {
+	migration, ok := migrations[versionPair{from, to}]
+	if !ok {
+		return nil, fmt.Errorf("no migration from %s to %s (supported: %s)", from, to, strings.Join(Versions(), ", "))
+	}
+
+	migrated, err := migration(doc)
+	if err != nil {
+		return nil, fmt.Errorf("migrating from %s to %s: %w", from, to, err)
+	}
+	if validate, ok := validators[to]; ok {
+		if err := validate(migrated); err != nil {
+			return nil, fmt.Errorf("migrated configuration is not valid %s: %w", to, err)
+		}
+	}
+	return migrated, nil
+}
+
+// File migrates the YAML (or JSON) file input and writes the result as YAML
+// to output. Output may be input, which is only replaced on success.
+func File(input, output, from, to string) error {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", input, err)
+	}
+	if doc == nil {
+		doc = map[string]any{}
+	}
+
+	migrated, err := Migrate(doc, from, to)
+	if err != nil {
+		return err
+	}
+	out, err := yaml.Marshal(migrated)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, out, 0o644); err != nil {
+		return fmt.Errorf("failed to write configuration: %w", err)
+	}
+	return nil
+}
+
+// migrateV1ToV2 moves the flat alias mapping of a version 1 model alias
+// file under "aliases" and declares version 2.
+func migrateV1ToV2(old map[string]any) (map[string]any, error) {
+	aliases := make(map[string]any, len(old))
+	for alias, target := range old {
+		switch target := target.(type) {
+		case string:
+			aliases[alias] = target
+		case nil:
+			// An empty target removes a built-in alias
+			aliases[alias] = ""
+		default:
+			return nil, fmt.Errorf("alias %q: target must be a string, not %T (is the file already migrated?)", alias, target)
+		}
+	}
+	return map[string]any{
+		"version": 2,
+		"aliases": aliases,
+	}, nil
+}
+
+// validateV2 checks doc against the version 2 model alias file schema.
+func validateV2(doc map[string]any) error {
+	if version, _ := doc["version"].(int); version != 2 {
+		return fmt.Errorf("version is %v, want 2", doc["version"])
+	}
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = modelalias.Parse(data)
+	return err
+}
@@ -0,0 +1,74 @@
+package migrate
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/modelalias"
+)
+
+func TestFileV1ToV2(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "aliases.yaml")
+	if err := File("testdata/aliases-v1.yaml", output, "v1", "v2"); err != nil {
+		t.Fatalf("File() error = %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/aliases-v2.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("migrated file:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Both files must resolve to the same aliases
+	oldAliases, err := modelalias.Load("testdata/aliases-v1.yaml")
+	if err != nil {
+		t.Fatalf("Load(v1) error = %v", err)
+	}
+	newAliases, err := modelalias.Load(output)
+	if err != nil {
+		t.Fatalf("Load(v2) error = %v", err)
+	}
+	if !maps.Equal(oldAliases, newAliases) {
+		t.Errorf("aliases = %v, want %v", newAliases, oldAliases)
+	}
+}
+
+func TestMigrateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      map[string]any
+		from, to string
+		wantErr  string
+	}{
+		{name: "unknown versions", doc: map[string]any{}, from: "v2", to: "v3", wantErr: "no migration from v2 to v3 (supported: v1 -> v2)"},
+		{name: "already migrated", doc: map[string]any{"version": 2, "aliases": map[string]any{}}, from: "v1", to: "v2", wantErr: "is the file already migrated?"},
+		{name: "fails validation", doc: map[string]any{"fast": "gpt-4.1-mini"}, from: "v1", to: "v2", wantErr: "migrated configuration is not valid v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Migrate(tt.doc, tt.from, tt.to)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Migrate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileKeepsOutputOnFailure(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "aliases.yaml")
+	if err := File("testdata/aliases-v2.yaml", output, "v1", "v2"); err == nil {
+		t.Fatal("File() succeeded for a version 2 input")
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("output was written: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Package modelalias maps short model names to provider-qualified model IDs.
+package modelalias
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AliasMap maps aliases (e.g. "sonnet") to provider-qualified model IDs
+// (e.g. "claude/claude-sonnet-4-5").
+type AliasMap map[string]string
+
+// defaults are the aliases available without configuration.
+var defaults = AliasMap{
+	"claude":  "claude/claude-sonnet-4-5",
+	"sonnet":  "claude/claude-sonnet-4-5",
+	"opus":    "claude/claude-opus-4-1",
+	"codex":   "chatgpt/gpt-5.2-codex",
+	"gpt-5":   "chatgpt/gpt-5.2",
+	"gpt-4o":  "copilot/gpt-4o",
+	"gpt-4.1": "copilot/gpt-4.1",
+}
+
+// Defaults returns a copy of the built-in aliases.
+func Defaults() AliasMap {
+	return maps.Clone(defaults)
+}
+
+// Version is the current alias file format. Version 1 files hold a flat
+// mapping of alias to model ID:
+//
+//	fast: copilot/gpt-4.1-mini
+//	sonnet: claude/claude-sonnet-4-5
+//
+// Version 2 files declare their version and hold the mapping under
+// "aliases":
+//
+//	version: 2
+//	aliases:
+//	  fast: copilot/gpt-4.1-mini
+//
+// Both are read; "opencompat migrate" converts version 1 files.
+const Version = 2
+
+// file is the version 2 alias file.
+type file struct {
+	Version int      `yaml:"version"`
+	Aliases AliasMap `yaml:"aliases"`
+}
+
+// Load reads aliases from a YAML (or JSON) file in either format.
+func Load(path string) (AliasMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model aliases: %w", err)
+	}
+
+	m, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid model aliases %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Parse parses and validates the contents of an alias file.
+func Parse(data []byte) (AliasMap, error) {
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	var m AliasMap
+	// In version 1 files every value is a string, even for an alias named "version"
+	if _, versioned := raw["version"].(int); versioned {
+		var f file
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&f); err != nil {
+			return nil, err
+		}
+		if f.Version != Version {
+			return nil, fmt.Errorf("unsupported version %d (want %d)", f.Version, Version)
+		}
+		m = f.Aliases
+	} else if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	for alias, target := range m {
+		if strings.TrimSpace(alias) == "" {
+			return nil, errors.New("empty alias name")
+		}
+		if target != "" && !strings.Contains(target, "/") {
+			return nil, fmt.Errorf("alias %q: target %q must include provider prefix (e.g., 'copilot/gpt-4o')", alias, target)
+		}
+	}
+	return m, nil
+}
+
+// LoadWithDefaults returns the built-in aliases overridden by those in
+// path; an empty path returns the defaults. Mapping an alias to an empty
+// target in the file removes it.
+func LoadWithDefaults(path string) (AliasMap, error) {
+	m := Defaults()
+	if path == "" {
+		return m, nil
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	for alias, target := range loaded {
+		if target == "" {
+			delete(m, alias)
+		} else {
+			m[alias] = target
+		}
+	}
+	return m, nil
+}
+
+// Resolve returns the model ID for alias, if it is one.
+func (m AliasMap) Resolve(alias string) (string, bool) {
+	target, ok := m[alias]
+	return target, ok
+}
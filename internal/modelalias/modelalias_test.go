@@ -0,0 +1,40 @@
+package modelalias
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    AliasMap
+		wantErr string
+	}{
+		{name: "version 1", data: "fast: copilot/gpt-4.1-mini\nopus: \"\"\n", want: AliasMap{"fast": "copilot/gpt-4.1-mini", "opus": ""}},
+		{name: "version 1 alias named version", data: "version: copilot/gpt-5\n", want: AliasMap{"version": "copilot/gpt-5"}},
+		{name: "version 2", data: "version: 2\naliases:\n  fast: copilot/gpt-4.1-mini\n", want: AliasMap{"fast": "copilot/gpt-4.1-mini"}},
+		{name: "unsupported version", data: "version: 3\naliases: {}\n", wantErr: "unsupported version 3"},
+		{name: "unknown version 2 field", data: "version: 2\nalias: {}\n", wantErr: "field alias not found"},
+		{name: "missing provider prefix", data: "version: 2\naliases:\n  fast: gpt-4.1-mini\n", wantErr: "must include provider prefix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
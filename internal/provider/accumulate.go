@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// AccumulateStream reads s to the end and returns the equivalent
+// non-streaming response, closing s in all cases. Streamed chunks are
+// merged with api.MergeChunks, which concatenates content deltas,
+// reassembles tool calls and keeps the usage of the final usage chunk
+// (sent when stream_options.include_usage is set). Streams that yield no
+// chunks, such as non-streaming requests, return s.Response().
+func AccumulateStream(ctx context.Context, s Stream) (*api.ChatCompletionResponse, error) {
+	defer func() { _ = s.Close() }()
+
+	var chunks []api.ChatCompletionChunk
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		chunk, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, *chunk)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	buffered := s.Response()
+	if len(chunks) == 0 {
+		if buffered == nil {
+			return nil, errors.New("stream ended without a response")
+		}
+		return buffered, nil
+	}
+
+	resp, err := api.MergeChunks(chunks)
+	if err != nil {
+		return nil, err
+	}
+	// Some providers only report usage on their accumulated response
+	if resp.Usage == nil && buffered != nil {
+		resp.Usage = buffered.Usage
+	}
+	return resp, nil
+}
@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// chunkStream yields chunks, then fails with err or ends with io.EOF.
+type chunkStream struct {
+	chunks   []api.ChatCompletionChunk
+	response *api.ChatCompletionResponse
+	err      error
+	closed   bool
+}
+
+func (s *chunkStream) Next() (*api.ChatCompletionChunk, error) {
+	if len(s.chunks) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return &chunk, nil
+}
+func (s *chunkStream) Response() *api.ChatCompletionResponse { return s.response }
+func (s *chunkStream) Err() error                            { return s.err }
+func (s *chunkStream) Close() error                          { s.closed = true; return nil }
+
+func contentDelta(content string) api.ChatCompletionChunk {
+	return api.ChatCompletionChunk{ID: "1", Model: "m", Choices: []api.Choice{{Delta: &api.Delta{Content: content}}}}
+}
+
+func toolCallDelta(index int, id, name, args string) api.ChatCompletionChunk {
+	return api.ChatCompletionChunk{ID: "1", Choices: []api.Choice{{Delta: &api.Delta{ToolCalls: []api.ToolCall{
+		{Index: &index, ID: id, Function: api.FunctionCall{Name: name, Arguments: args}},
+	}}}}}
+}
+
+func finish(reason string, usage *api.Usage) api.ChatCompletionChunk {
+	return api.ChatCompletionChunk{ID: "1", Choices: []api.Choice{{Delta: &api.Delta{}, FinishReason: &reason}}, Usage: usage}
+}
+
+func TestAccumulateStream(t *testing.T) {
+	errMidStream := errors.New("connection reset")
+	tests := []struct {
+		name          string
+		stream        *chunkStream
+		wantContent   string
+		wantToolCalls []api.ToolCall
+		wantFinish    string
+		wantUsage     int // total tokens, 0 for none
+		wantErr       error
+	}{
+		{
+			name: "multi-chunk content",
+			stream: &chunkStream{chunks: []api.ChatCompletionChunk{
+				{ID: "1", Choices: []api.Choice{{Delta: &api.Delta{Role: "assistant"}}}},
+				contentDelta("Hel"), contentDelta("lo, "), contentDelta("world"),
+				finish("stop", &api.Usage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5}),
+			}},
+			wantContent: "Hello, world",
+			wantFinish:  "stop",
+			wantUsage:   5,
+		},
+		{
+			name: "multiple tool calls",
+			stream: &chunkStream{chunks: []api.ChatCompletionChunk{
+				toolCallDelta(0, "call_a", "get_weather", ""),
+				toolCallDelta(0, "", "", `{"city":`),
+				toolCallDelta(1, "call_b", "get_time", `{"tz":"UTC"}`),
+				toolCallDelta(0, "", "", `"Paris"}`),
+				finish("tool_calls", nil),
+			}},
+			wantToolCalls: []api.ToolCall{
+				{ID: "call_a", Type: "function", Function: api.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+				{ID: "call_b", Type: "function", Function: api.FunctionCall{Name: "get_time", Arguments: `{"tz":"UTC"}`}},
+			},
+			wantFinish: "tool_calls",
+		},
+		{
+			name: "usage from the buffered response",
+			stream: &chunkStream{
+				chunks:   []api.ChatCompletionChunk{contentDelta("hi"), finish("stop", nil)},
+				response: &api.ChatCompletionResponse{Usage: &api.Usage{TotalTokens: 7}},
+			},
+			wantContent: "hi",
+			wantFinish:  "stop",
+			wantUsage:   7,
+		},
+		{
+			name:    "mid-stream error",
+			stream:  &chunkStream{chunks: []api.ChatCompletionChunk{contentDelta("partial")}, err: errMidStream},
+			wantErr: errMidStream,
+		},
+		{
+			name: "non-streaming response",
+			stream: &chunkStream{response: &api.ChatCompletionResponse{ID: "1", Choices: []api.Choice{
+				{Message: &api.Message{Role: "assistant", Content: []byte(`"buffered"`)}},
+			}}},
+			wantContent: "buffered",
+		},
+		{
+			name:    "no response",
+			stream:  &chunkStream{},
+			wantErr: errors.New("stream ended without a response"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := AccumulateStream(context.Background(), tt.stream)
+			if !tt.stream.closed {
+				t.Error("stream was not closed")
+			}
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Errorf("AccumulateStream() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AccumulateStream() error = %v", err)
+			}
+
+			msg := resp.Choices[0].Message
+			if got := msg.GetContentString(); got != tt.wantContent {
+				t.Errorf("content = %q, want %q", got, tt.wantContent)
+			}
+			if len(msg.ToolCalls) != len(tt.wantToolCalls) {
+				t.Fatalf("tool calls = %+v, want %+v", msg.ToolCalls, tt.wantToolCalls)
+			}
+			for i, want := range tt.wantToolCalls {
+				if got := msg.ToolCalls[i]; got.ID != want.ID || got.Type != want.Type || got.Function != want.Function {
+					t.Errorf("tool call %d = %+v, want %+v", i, got, want)
+				}
+			}
+			if got := resp.Choices[0].FinishReason; tt.wantFinish != "" && (got == nil || *got != tt.wantFinish) {
+				t.Errorf("finish_reason = %v, want %q", got, tt.wantFinish)
+			}
+			switch {
+			case tt.wantUsage == 0 && resp.Usage != nil:
+				t.Errorf("usage = %+v, want none", resp.Usage)
+			case tt.wantUsage != 0 && (resp.Usage == nil || resp.Usage.TotalTokens != tt.wantUsage):
+				t.Errorf("usage = %+v, want %d total tokens", resp.Usage, tt.wantUsage)
+			}
+		})
+	}
+}
+
+func TestAccumulateStreamCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := &chunkStream{chunks: []api.ChatCompletionChunk{contentDelta("hi")}}
+	if _, err := AccumulateStream(ctx, s); !errors.Is(err, context.Canceled) {
+		t.Errorf("AccumulateStream() error = %v, want context.Canceled", err)
+	}
+	if !s.closed {
+		t.Error("stream was not closed")
+	}
+}
@@ -0,0 +1,179 @@
+package azureopenai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
+)
+
+// HTTPTimeout is long enough for streaming responses.
+const HTTPTimeout = 5 * time.Minute
+
+// Managed identity token endpoints. App Service and Container Apps expose
+// IDENTITY_ENDPOINT/IDENTITY_HEADER; VMs and AKS use the instance metadata
+// service.
+const (
+	imdsTokenURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+	// tokenRefreshMargin refreshes tokens this long before they expire.
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// Client handles communication with the Azure OpenAI API.
+type Client struct {
+	httpClient *http.Client
+	store      *auth.Store
+	cfg        *Config
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewClient creates a new Azure OpenAI client.
+func NewClient(store *auth.Store, cfg *Config) *Client {
+	return &Client{
+		httpClient: &http.Client{
+			Timeout: HTTPTimeout,
+		},
+		store: store,
+		cfg:   cfg,
+	}
+}
+
+// SendRequest sends a chat completion request to the deployment serving
+// chatReq.Model.
+func (c *Client) SendRequest(ctx context.Context, deployment string, chatReq *api.ChatCompletionRequest) (*http.Response, error) {
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		c.cfg.Endpoint, url.PathEscape(deployment), url.QueryEscape(c.cfg.APIVersion))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.authorize(ctx, req); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if chatReq.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
+
+// authorize sets the api-key or Entra ID bearer token header.
+func (c *Client) authorize(ctx context.Context, req *http.Request) error {
+	creds, err := c.store.GetAPIKeyCredentials(ProviderID)
+	if err != nil {
+		return fmt.Errorf("auth error: %w", err)
+	}
+
+	if c.cfg.Auth != AuthManagedIdentity {
+		req.Header.Set("api-key", creds.APIKey)
+		return nil
+	}
+
+	// In managed identity mode the stored credential names the identity
+	token, err := c.managedIdentityToken(ctx, creds.APIKey)
+	if err != nil {
+		return fmt.Errorf("auth error: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// managedIdentityToken returns a cached Entra ID token, fetching a new one
+// when it is close to expiry. clientID selects a user-assigned identity;
+// "system" uses the system-assigned identity.
+func (c *Client) managedIdentityToken(ctx context.Context, clientID string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Until(c.tokenExpiry) > tokenRefreshMargin {
+		return c.token, nil
+	}
+
+	req, err := newTokenRequest(ctx, clientID)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get managed identity token: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("managed identity token request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresOn   string `json:"expires_on"` // Unix seconds, as a string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to parse managed identity token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("managed identity token response has no access_token")
+	}
+
+	c.token = token.AccessToken
+	c.tokenExpiry = time.Now().Add(time.Hour)
+	if secs, err := strconv.ParseInt(token.ExpiresOn, 10, 64); err == nil {
+		c.tokenExpiry = time.Unix(secs, 0)
+	}
+	return c.token, nil
+}
+
+// newTokenRequest builds the managed identity token request for the
+// current hosting environment.
+func newTokenRequest(ctx context.Context, clientID string) (*http.Request, error) {
+	query := url.Values{"resource": {CognitiveServicesResource}}
+	if clientID != "" && clientID != "system" {
+		query.Set("client_id", clientID)
+	}
+
+	endpoint := imdsTokenURL
+	identityEndpoint, identityHeader := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER")
+	if identityEndpoint != "" && identityHeader != "" {
+		endpoint = identityEndpoint
+		query.Set("api-version", "2019-08-01")
+	} else {
+		query.Set("api-version", "2018-02-01")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if identityHeader != "" && endpoint == identityEndpoint {
+		req.Header.Set("X-IDENTITY-HEADER", identityHeader)
+	} else {
+		req.Header.Set("Metadata", "true")
+	}
+	return req, nil
+}
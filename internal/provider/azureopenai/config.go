@@ -0,0 +1,120 @@
+package azureopenai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Provider identification
+const ProviderID = "azure"
+
+// Environment variable names for Azure OpenAI provider
+const (
+	EnvEndpoint    = "OPENCOMPAT_AZURE_ENDPOINT"
+	EnvDeployments = "OPENCOMPAT_AZURE_DEPLOYMENTS"
+	EnvAPIVersion  = "OPENCOMPAT_AZURE_API_VERSION"
+	EnvAuth        = "OPENCOMPAT_AZURE_AUTH"
+)
+
+// Default values
+const (
+	DefaultAPIVersion = "2024-05-01-preview"
+)
+
+// Authentication modes
+const (
+	AuthAPIKey          = "api-key"          // stored credential is sent as the api-key header
+	AuthManagedIdentity = "managed-identity" // Entra ID tokens come from the managed identity endpoint
+)
+
+// CognitiveServicesResource is the Entra ID resource for Azure OpenAI tokens.
+const CognitiveServicesResource = "https://cognitiveservices.azure.com"
+
+// Config holds Azure OpenAI-specific configuration.
+type Config struct {
+	Endpoint    string            // resource endpoint, e.g. https://myresource.openai.azure.com
+	Deployments map[string]string // model ID -> deployment name
+	APIVersion  string
+	Auth        string // AuthAPIKey or AuthManagedIdentity
+}
+
+// LoadConfig reads Azure OpenAI configuration from environment variables.
+// The endpoint and at least one deployment are required.
+func LoadConfig() (*Config, error) {
+	endpoint := strings.TrimRight(os.Getenv(EnvEndpoint), "/")
+	if endpoint == "" {
+		return nil, fmt.Errorf("%s is required", EnvEndpoint)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s %q: expected an https:// URL", EnvEndpoint, endpoint)
+	}
+
+	deployments, err := parseDeployments(os.Getenv(EnvDeployments))
+	if err != nil {
+		return nil, err
+	}
+
+	authMode := os.Getenv(EnvAuth)
+	switch authMode {
+	case "":
+		authMode = AuthAPIKey
+	case AuthAPIKey, AuthManagedIdentity:
+	default:
+		return nil, fmt.Errorf("invalid %s %q (use %s or %s)", EnvAuth, authMode, AuthAPIKey, AuthManagedIdentity)
+	}
+
+	return &Config{
+		Endpoint:    endpoint,
+		Deployments: deployments,
+		APIVersion:  getEnv(EnvAPIVersion, DefaultAPIVersion),
+		Auth:        authMode,
+	}, nil
+}
+
+// parseDeployments reads the JSON object mapping model IDs to deployment names.
+func parseDeployments(val string) (map[string]string, error) {
+	if val == "" {
+		return nil, fmt.Errorf("%s is required, e.g. {\"gpt-4o\":\"my-gpt-4o-deployment\"}", EnvDeployments)
+	}
+	var deployments map[string]string
+	if err := json.Unmarshal([]byte(val), &deployments); err != nil {
+		return nil, fmt.Errorf("invalid %s: expected a JSON object of model ID to deployment name: %w", EnvDeployments, err)
+	}
+	if len(deployments) == 0 {
+		return nil, fmt.Errorf("%s must list at least one deployment", EnvDeployments)
+	}
+	for model, deployment := range deployments {
+		if model == "" || deployment == "" {
+			return nil, fmt.Errorf("invalid %s: model IDs and deployment names must be non-empty", EnvDeployments)
+		}
+	}
+	return deployments, nil
+}
+
+// EnvVarDoc documents an environment variable.
+type EnvVarDoc struct {
+	Name        string
+	Description string
+	Default     string
+}
+
+// EnvVarDocs returns documentation for environment variables.
+func EnvVarDocs() []EnvVarDoc {
+	return []EnvVarDoc{
+		{Name: EnvEndpoint, Description: "Azure OpenAI resource endpoint (required)", Default: "none"},
+		{Name: EnvDeployments, Description: "JSON object of model ID to deployment name (required)", Default: "none"},
+		{Name: EnvAPIVersion, Description: "Azure OpenAI API version", Default: DefaultAPIVersion},
+		{Name: EnvAuth, Description: "Authentication (api-key, managed-identity)", Default: AuthAPIKey},
+	}
+}
+
+func getEnv(key, defaultVal string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return defaultVal
+}
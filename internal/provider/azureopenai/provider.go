@@ -0,0 +1,133 @@
+// Package azureopenai implements the Azure OpenAI Service provider.
+package azureopenai
+
+import (
+	"context"
+	"sort"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/provider/openaicompat"
+)
+
+func init() {
+	provider.AddRegistration(func(r *provider.Registry) {
+		r.RegisterMeta(provider.ProviderMeta{
+			ID:              ProviderID,
+			Name:            "Azure OpenAI",
+			AuthMethod:      auth.AuthMethodAPIKey,
+			EnvVars:         convertEnvVarDocs(EnvVarDocs()),
+			Factory:         New,
+			Capabilities:    provider.CapabilityStreaming | provider.CapabilityToolCalls | provider.CapabilityVision | provider.CapabilityStructuredOutput,
+			ParameterBounds: provider.OpenAIParameterBounds,
+		})
+	})
+}
+
+// convertEnvVarDocs converts azureopenai.EnvVarDoc to provider.EnvVarDoc.
+func convertEnvVarDocs(docs []EnvVarDoc) []provider.EnvVarDoc {
+	result := make([]provider.EnvVarDoc, len(docs))
+	for i, d := range docs {
+		result[i] = provider.EnvVarDoc{
+			Name:        d.Name,
+			Description: d.Description,
+			Default:     d.Default,
+		}
+	}
+	return result
+}
+
+// Provider implements the Azure OpenAI provider.
+type Provider struct {
+	client *Client
+	cfg    *Config
+	models []api.Model
+}
+
+// New creates a new Azure OpenAI provider. The models list is derived from
+// the configured deployments.
+func New(store *auth.Store) (provider.Provider, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]api.Model, 0, len(cfg.Deployments))
+	for id := range cfg.Deployments {
+		models = append(models, api.Model{ID: id, Object: "model", OwnedBy: "azure"})
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+
+	return &Provider{
+		client: NewClient(store, cfg),
+		cfg:    cfg,
+		models: models,
+	}, nil
+}
+
+// ID returns the provider identifier.
+func (p *Provider) ID() string {
+	return ProviderID
+}
+
+// Models returns the configured deployments as models.
+func (p *Provider) Models() []api.Model {
+	return p.models
+}
+
+// SupportedParameters returns the optional request parameters forwarded to Azure.
+func (p *Provider) SupportedParameters() []string {
+	return []string{
+		"n",
+		"temperature",
+		"top_p",
+		"stop",
+		"max_tokens",
+		"max_completion_tokens",
+		"presence_penalty",
+		"frequency_penalty",
+		"response_format",
+		"parallel_tool_calls",
+	}
+}
+
+// SupportedToolTypes returns the tool types accepted by the upstream API.
+func (p *Provider) SupportedToolTypes() []string {
+	return []string{"function"}
+}
+
+// SupportsModel checks if a model ID has a configured deployment.
+func (p *Provider) SupportsModel(modelID string) bool {
+	_, ok := p.cfg.Deployments[modelID]
+	return ok
+}
+
+// ChatCompletion sends a chat completion request to the model's deployment.
+func (p *Provider) ChatCompletion(ctx context.Context, req *provider.ChatCompletionRequest) (provider.Stream, error) {
+	chatReq := &api.ChatCompletionRequest{
+		Model:               req.Model,
+		Messages:            req.Messages,
+		Tools:               req.Tools,
+		ToolChoice:          req.ToolChoice,
+		Stream:              req.Stream,
+		StreamOptions:       req.StreamOptions,
+		N:                   req.N,
+		Temperature:         req.Temperature,
+		TopP:                req.TopP,
+		MaxTokens:           req.MaxTokens,
+		MaxCompletionTokens: req.MaxCompletionTokens,
+		Stop:                req.Stop,
+		PresencePenalty:     req.PresencePenalty,
+		FrequencyPenalty:    req.FrequencyPenalty,
+		ResponseFormat:      req.ResponseFormat,
+		ParallelToolCalls:   req.ParallelToolCalls,
+	}
+
+	resp, err := p.client.SendRequest(ctx, p.cfg.Deployments[req.Model], chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return openaicompat.NewStream(resp, chatReq.Stream, nil), nil
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// Bounds is the valid range of a numeric request parameter, inclusive.
+type Bounds struct {
+	Min, Max float64
+}
+
+// OpenAIParameterBounds are the sampling parameter ranges of the OpenAI
+// chat completions API.
+var OpenAIParameterBounds = map[string]Bounds{
+	"temperature":       {Min: 0, Max: 2},
+	"top_p":             {Min: 0, Max: 1},
+	"presence_penalty":  {Min: -2, Max: 2},
+	"frequency_penalty": {Min: -2, Max: 2},
+}
+
+// ParameterBoundsError describes a request parameter outside its bounds.
+type ParameterBoundsError struct {
+	Param  string // request field, e.g. "temperature"
+	Value  float64
+	Bounds Bounds
+}
+
+func (e *ParameterBoundsError) Error() string {
+	return fmt.Sprintf("%s %s is out of range [%s, %s]", e.Param,
+		formatFloat(e.Value), formatFloat(e.Bounds.Min), formatFloat(e.Bounds.Max))
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// CheckParameterBounds returns the parameters of req outside bounds,
+// without changing req.
+func CheckParameterBounds(req *api.ChatCompletionRequest, bounds map[string]Bounds) []*ParameterBoundsError {
+	return applyBounds(req, bounds, false)
+}
+
+// ClampParameters moves the parameters of req outside bounds to the nearest
+// bound and returns them with their original values.
+func ClampParameters(req *api.ChatCompletionRequest, bounds map[string]Bounds) []*ParameterBoundsError {
+	return applyBounds(req, bounds, true)
+}
+
+func applyBounds(req *api.ChatCompletionRequest, bounds map[string]Bounds, clamp bool) []*ParameterBoundsError {
+	params := []struct {
+		name  string
+		value **float64
+	}{
+		{"temperature", &req.Temperature},
+		{"top_p", &req.TopP},
+		{"presence_penalty", &req.PresencePenalty},
+		{"frequency_penalty", &req.FrequencyPenalty},
+	}
+
+	var violations []*ParameterBoundsError
+	for _, param := range params {
+		b, ok := bounds[param.name]
+		v := *param.value
+		if !ok || v == nil || (*v >= b.Min && *v <= b.Max) {
+			continue
+		}
+		violations = append(violations, &ParameterBoundsError{Param: param.name, Value: *v, Bounds: b})
+		if clamp {
+			// Copy, since the pointer may be shared with the caller
+			clamped := min(max(*v, b.Min), b.Max)
+			*param.value = &clamped
+		}
+	}
+	return violations
+}
+
+// ParameterBounds returns the parameter bounds declared by a provider type;
+// nil for unknown providers or providers that declare none.
+func (r *Registry) ParameterBounds(id string) map[string]Bounds {
+	return r.metas[id].ParameterBounds
+}
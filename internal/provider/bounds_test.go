@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestApplyBounds(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        api.ChatCompletionRequest
+		bounds     map[string]Bounds
+		wantParams []string           // violations, in field order
+		wantValues map[string]float64 // values after clamping
+	}{
+		{
+			name:       "within bounds",
+			req:        api.ChatCompletionRequest{Temperature: floatPtr(1), TopP: floatPtr(0.5)},
+			bounds:     OpenAIParameterBounds,
+			wantValues: map[string]float64{"temperature": 1, "top_p": 0.5},
+		},
+		{
+			name:       "inclusive edges",
+			req:        api.ChatCompletionRequest{Temperature: floatPtr(2), PresencePenalty: floatPtr(-2)},
+			bounds:     OpenAIParameterBounds,
+			wantValues: map[string]float64{"temperature": 2, "presence_penalty": -2},
+		},
+		{
+			name:       "above max",
+			req:        api.ChatCompletionRequest{Temperature: floatPtr(3.5)},
+			bounds:     OpenAIParameterBounds,
+			wantParams: []string{"temperature"},
+			wantValues: map[string]float64{"temperature": 2},
+		},
+		{
+			name:       "below min",
+			req:        api.ChatCompletionRequest{FrequencyPenalty: floatPtr(-5)},
+			bounds:     OpenAIParameterBounds,
+			wantParams: []string{"frequency_penalty"},
+			wantValues: map[string]float64{"frequency_penalty": -2},
+		},
+		{
+			name: "several out of range",
+			req: api.ChatCompletionRequest{
+				Temperature:      floatPtr(-1),
+				TopP:             floatPtr(1.5),
+				PresencePenalty:  floatPtr(1),
+				FrequencyPenalty: floatPtr(2.5),
+			},
+			bounds:     OpenAIParameterBounds,
+			wantParams: []string{"temperature", "top_p", "frequency_penalty"},
+			wantValues: map[string]float64{"temperature": 0, "top_p": 1, "presence_penalty": 1, "frequency_penalty": 2},
+		},
+		{
+			name:       "unset parameters",
+			req:        api.ChatCompletionRequest{},
+			bounds:     OpenAIParameterBounds,
+			wantValues: map[string]float64{},
+		},
+		{
+			name:       "unbounded parameter",
+			req:        api.ChatCompletionRequest{Temperature: floatPtr(5), TopP: floatPtr(7)},
+			bounds:     map[string]Bounds{"top_p": {Min: 0, Max: 1}},
+			wantParams: []string{"top_p"},
+			wantValues: map[string]float64{"temperature": 5, "top_p": 1},
+		},
+		{
+			name:       "no bounds",
+			req:        api.ChatCompletionRequest{Temperature: floatPtr(5)},
+			bounds:     nil,
+			wantValues: map[string]float64{"temperature": 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("reject", func(t *testing.T) {
+				req := tt.req
+				before := paramValues(&req)
+				got := CheckParameterBounds(&req, tt.bounds)
+				if params := violationParams(got); !reflect.DeepEqual(params, tt.wantParams) {
+					t.Errorf("CheckParameterBounds() = %v, want %v", params, tt.wantParams)
+				}
+				if after := paramValues(&req); !reflect.DeepEqual(after, before) {
+					t.Errorf("CheckParameterBounds() changed the request to %v, want %v", after, before)
+				}
+			})
+
+			t.Run("clamp", func(t *testing.T) {
+				req := tt.req
+				before := paramValues(&tt.req)
+				got := ClampParameters(&req, tt.bounds)
+				if params := violationParams(got); !reflect.DeepEqual(params, tt.wantParams) {
+					t.Errorf("ClampParameters() = %v, want %v", params, tt.wantParams)
+				}
+				for _, v := range got {
+					if v.Value != before[v.Param] {
+						t.Errorf("%s violation value = %v, want the original %v", v.Param, v.Value, before[v.Param])
+					}
+					if v.Bounds != tt.bounds[v.Param] {
+						t.Errorf("%s violation bounds = %+v, want %+v", v.Param, v.Bounds, tt.bounds[v.Param])
+					}
+				}
+				if after := paramValues(&req); !reflect.DeepEqual(after, tt.wantValues) {
+					t.Errorf("clamped values = %v, want %v", after, tt.wantValues)
+				}
+				// The caller's pointers are shared by the copy and must not change
+				if after := paramValues(&tt.req); !reflect.DeepEqual(after, before) {
+					t.Errorf("ClampParameters() wrote through to the caller's values: %v, want %v", after, before)
+				}
+			})
+		})
+	}
+}
+
+func TestParameterBoundsErrorMessage(t *testing.T) {
+	err := &ParameterBoundsError{Param: "temperature", Value: 2.5, Bounds: Bounds{Min: 0, Max: 2}}
+	if got, want := err.Error(), "temperature 2.5 is out of range [0, 2]"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+// paramValues returns the set sampling parameters of req by field name.
+func paramValues(req *api.ChatCompletionRequest) map[string]float64 {
+	values := map[string]float64{}
+	for name, v := range map[string]*float64{
+		"temperature":       req.Temperature,
+		"top_p":             req.TopP,
+		"presence_penalty":  req.PresencePenalty,
+		"frequency_penalty": req.FrequencyPenalty,
+	} {
+		if v != nil {
+			values[name] = *v
+		}
+	}
+	return values
+}
+
+func violationParams(violations []*ParameterBoundsError) []string {
+	var params []string
+	for _, v := range violations {
+		params = append(params, v.Param)
+	}
+	return params
+}
@@ -0,0 +1,16 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// ResponseCache stores non-streaming responses by request key. See
+// cache.LRU for the in-memory implementation.
+type ResponseCache interface {
+	// Get returns the response cached under key, if present and fresh.
+	Get(key string) (*api.ChatCompletionResponse, bool)
+	// Set caches resp under key for ttl.
+	Set(key string, resp *api.ChatCompletionResponse, ttl time.Duration)
+}
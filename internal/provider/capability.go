@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// CapabilitySet is a set of API features a provider supports, declared in
+// ProviderMeta.Capabilities.
+type CapabilitySet uint
+
+// Standard capabilities.
+const (
+	CapabilityStreaming        CapabilitySet = 1 << iota // streamed chat completions
+	CapabilityToolCalls                                  // tools and tool_choice
+	CapabilityVision                                     // image inputs
+	CapabilityStructuredOutput                           // json_schema response_format
+	CapabilityLogprobs                                   // logprobs and top_logprobs
+	CapabilityEmbeddings                                 // /v1/embeddings
+)
+
+var capabilityNames = []string{
+	"streaming",
+	"tool_calls",
+	"vision",
+	"structured_output",
+	"logprobs",
+	"embeddings",
+}
+
+// Has reports whether s contains every capability in c.
+func (s CapabilitySet) Has(c CapabilitySet) bool {
+	return s&c == c
+}
+
+// Names returns the names of the capabilities in s, e.g. "vision".
+func (s CapabilitySet) Names() []string {
+	names := make([]string, 0, bits.OnesCount(uint(s)))
+	for i, name := range capabilityNames {
+		if s.Has(1 << i) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// String returns the capability names separated by commas.
+func (s CapabilitySet) String() string {
+	return strings.Join(s.Names(), ", ")
+}
+
+// ProviderCapabilities returns the capabilities declared by a provider type;
+// empty for unknown providers or providers that declare none.
+func (r *Registry) ProviderCapabilities(id string) CapabilitySet {
+	return r.metas[id].Capabilities
+}
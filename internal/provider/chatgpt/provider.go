@@ -19,12 +19,14 @@ const ProviderID = "chatgpt"
 func init() {
 	provider.AddRegistration(func(r *provider.Registry) {
 		r.RegisterMeta(provider.ProviderMeta{
-			ID:         ProviderID,
-			Name:       "ChatGPT",
-			AuthMethod: auth.AuthMethodOAuth,
-			OAuthCfg:   GetOAuthConfig(),
-			EnvVars:    convertEnvVarDocs(EnvVarDocs()),
-			Factory:    New,
+			ID:              ProviderID,
+			Name:            "ChatGPT",
+			AuthMethod:      auth.AuthMethodOAuth,
+			OAuthCfg:        GetOAuthConfig(),
+			EnvVars:         convertEnvVarDocs(EnvVarDocs()),
+			Factory:         New,
+			Capabilities:    provider.CapabilityStreaming | provider.CapabilityToolCalls | provider.CapabilityVision,
+			ParameterBounds: provider.OpenAIParameterBounds,
 		})
 	})
 }
@@ -77,6 +79,16 @@ func (p *Provider) Models() []api.Model {
 	}
 }
 
+// SupportedParameters returns the optional request parameters used by ChatGPT.
+func (p *Provider) SupportedParameters() []string {
+	return []string{"reasoning_effort"}
+}
+
+// SupportedToolTypes returns the tool types accepted by the upstream API.
+func (p *Provider) SupportedToolTypes() []string {
+	return []string{"function"}
+}
+
 // SupportsModel checks if a model ID is supported, including effort suffixes.
 func (p *Provider) SupportsModel(modelID string) bool {
 	// Normalize model name (handles aliases and effort suffixes)
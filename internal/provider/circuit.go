@@ -0,0 +1,310 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// ErrCircuitOpen is returned instead of calling a provider whose circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Default circuit breaker settings.
+const (
+	DefaultCircuitFailureThreshold = 5
+	DefaultCircuitWindow           = 60 * time.Second
+	DefaultCircuitCooldown         = 30 * time.Second
+)
+
+// CircuitConfig configures a provider's circuit breaker.
+type CircuitConfig struct {
+	FailureThreshold int           // consecutive failures that open the circuit; 0 disables the breaker
+	Window           time.Duration // failures older than this are forgotten
+	Cooldown         time.Duration // time the circuit stays open before a probe is allowed
+}
+
+// DefaultCircuitConfig returns the default circuit breaker settings.
+func DefaultCircuitConfig() CircuitConfig {
+	return CircuitConfig{
+		FailureThreshold: DefaultCircuitFailureThreshold,
+		Window:           DefaultCircuitWindow,
+		Cooldown:         DefaultCircuitCooldown,
+	}
+}
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects requests with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe request through.
+	CircuitHalfOpen
+)
+
+// String returns the state name.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker tracks consecutive failures of one provider.
+type circuitBreaker struct {
+	id  string
+	cfg CircuitConfig
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures []time.Time // consecutive failures within the window
+	openedAt time.Time
+	probing  bool // a half-open probe is in flight
+}
+
+func newCircuitBreaker(id string, cfg CircuitConfig) *circuitBreaker {
+	return &circuitBreaker{id: id, cfg: cfg}
+}
+
+// allow reports whether a request may be sent, moving an open circuit to
+// half-open once the cool-down has passed.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.cfg.Cooldown {
+			return fmt.Errorf("%w for provider %s", ErrCircuitOpen, b.id)
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		slog.Info("circuit breaker half-open, sending probe", "provider", b.id)
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w for provider %s", ErrCircuitOpen, b.id)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record registers the outcome of a request; err is nil on success.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !isCircuitFailure(err) {
+		if b.state != CircuitClosed {
+			slog.Info("circuit breaker closed", "provider", b.id)
+		}
+		b.state = CircuitClosed
+		b.failures = b.failures[:0]
+		return
+	}
+
+	now := time.Now()
+	if b.state == CircuitHalfOpen {
+		b.open(now, err)
+		return
+	}
+
+	// Forget failures that fell out of the window
+	kept := b.failures[:0]
+	for _, t := range b.failures {
+		if now.Sub(t) < b.cfg.Window {
+			kept = append(kept, t)
+		}
+	}
+	b.failures = append(kept, now)
+	if len(b.failures) >= b.cfg.FailureThreshold {
+		b.open(now, err)
+	}
+}
+
+// open trips the breaker. Callers hold b.mu.
+func (b *circuitBreaker) open(now time.Time, err error) {
+	b.state = CircuitOpen
+	b.openedAt = now
+	b.failures = b.failures[:0]
+	slog.Warn("circuit breaker opened",
+		"provider", b.id,
+		"cooldown", b.cfg.Cooldown,
+		"error", err,
+	)
+}
+
+// release ends a request without judging the provider, e.g. when the
+// client went away mid-stream.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *circuitBreaker) status() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.cfg.Cooldown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+func (b *circuitBreaker) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = CircuitClosed
+	b.failures = b.failures[:0]
+	b.probing = false
+}
+
+// LocalError marks an error produced by the proxy itself rather than the
+// upstream, such as a full concurrency limit, so the circuit breaker
+// doesn't count it. It unwraps to the original error, which still decides
+// the response status.
+type LocalError struct {
+	Err error
+}
+
+func (e *LocalError) Error() string { return e.Err.Error() }
+
+func (e *LocalError) Unwrap() error { return e.Err }
+
+// isCircuitFailure reports whether err indicates an unhealthy provider:
+// an upstream 5xx, a transport error, a timeout or a corrupted stream.
+// Everything else, such as client errors (4xx, unsupported parameters,
+// context length), local limits and canceled requests, says nothing about
+// the provider's health.
+func isCircuitFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var localErr *LocalError
+	if errors.As(err, &localErr) {
+		return false
+	}
+	var upstreamErr *api.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, ErrStreamCorrupted) ||
+		errors.As(err, &netErr)
+}
+
+// circuitProvider guards a provider's ChatCompletion with its breaker.
+// Only the Provider methods are promoted: use it to send requests and the
+// unwrapped provider for optional interface checks.
+type circuitProvider struct {
+	Provider
+	breaker *circuitBreaker
+}
+
+func (p *circuitProvider) ChatCompletion(ctx context.Context, req *ChatCompletionRequest) (Stream, error) {
+	if err := p.breaker.allow(); err != nil {
+		return nil, err
+	}
+	stream, err := p.Provider.ChatCompletion(ctx, req)
+	if err != nil {
+		p.breaker.record(err)
+		return nil, err
+	}
+	cs := &circuitStream{Stream: stream, breaker: p.breaker}
+	// Keep raw passthrough available for streams that support it
+	if _, ok := stream.(io.WriterTo); ok {
+		return &circuitWriterToStream{cs}, nil
+	}
+	return cs, nil
+}
+
+// circuitStream records the outcome of a stream once it ends.
+type circuitStream struct {
+	Stream
+	breaker  *circuitBreaker
+	recorded bool
+}
+
+func (s *circuitStream) Next() (*api.ChatCompletionChunk, error) {
+	chunk, err := s.Stream.Next()
+	if err != nil && !s.recorded {
+		s.recorded = true
+		if err == io.EOF {
+			s.breaker.record(s.Stream.Err())
+		} else {
+			s.breaker.record(err)
+		}
+	}
+	return chunk, err
+}
+
+// Metadata forwards the metadata of the wrapped stream, if it reports any.
+func (s *circuitStream) Metadata() map[string]string {
+	if mr, ok := s.Stream.(MetadataReporter); ok {
+		return mr.Metadata()
+	}
+	return nil
+}
+
+func (s *circuitStream) Close() error {
+	if !s.recorded {
+		s.recorded = true
+		s.breaker.release()
+	}
+	return s.Stream.Close()
+}
+
+// circuitWriterToStream is a circuitStream over a stream implementing
+// io.WriterTo, recording the outcome of WriteTo as well.
+type circuitWriterToStream struct {
+	*circuitStream
+}
+
+// WriteTo records upstream errors; failing to write to w, e.g. because the
+// client went away, says nothing about the provider.
+func (s *circuitWriterToStream) WriteTo(w io.Writer) (int64, error) {
+	cw := &clientWriter{Writer: w}
+	n, err := s.Stream.(io.WriterTo).WriteTo(cw)
+	if !s.recorded {
+		s.recorded = true
+		if err != nil && errors.Is(err, cw.err) {
+			s.breaker.release()
+		} else {
+			s.breaker.record(err)
+		}
+	}
+	return n, err
+}
+
+// clientWriter remembers the last error of its Writer.
+type clientWriter struct {
+	io.Writer
+	err error
+}
+
+func (w *clientWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
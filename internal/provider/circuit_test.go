@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// fakeProvider returns the stream or error it holds.
+type fakeProvider struct {
+	stream Stream
+	err    error
+}
+
+func (p *fakeProvider) ID() string                { return "fake" }
+func (p *fakeProvider) Models() []api.Model       { return nil }
+func (p *fakeProvider) SupportsModel(string) bool { return true }
+
+func (p *fakeProvider) ChatCompletion(context.Context, *ChatCompletionRequest) (Stream, error) {
+	return p.stream, p.err
+}
+
+// fakeStream ends with err, or io.EOF when err is nil.
+type fakeStream struct {
+	err error
+}
+
+func (s *fakeStream) Next() (*api.ChatCompletionChunk, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return nil, io.EOF
+}
+func (s *fakeStream) Response() *api.ChatCompletionResponse { return nil }
+func (s *fakeStream) Err() error                            { return s.err }
+func (s *fakeStream) Close() error                          { return nil }
+
+// fakeWriterToStream also supports raw passthrough, writing data.
+type fakeWriterToStream struct {
+	fakeStream
+	data string
+}
+
+func (s *fakeWriterToStream) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, s.data)
+	if err != nil {
+		return int64(n), err
+	}
+	return int64(n), s.err
+}
+
+// failingWriter fails every write, like a client that went away.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("broken pipe") }
+
+var errUpstream = api.NewUpstreamError(502, "bad gateway")
+
+func newTestBreaker(threshold int) *circuitBreaker {
+	return newCircuitBreaker("fake", CircuitConfig{FailureThreshold: threshold, Window: time.Minute, Cooldown: time.Minute})
+}
+
+func TestCircuitStreamKeepsWriterTo(t *testing.T) {
+	breaker := newTestBreaker(1)
+	p := &circuitProvider{Provider: &fakeProvider{stream: &fakeWriterToStream{data: "data: [DONE]\n\n"}}, breaker: breaker}
+
+	stream, err := p.ChatCompletion(context.Background(), &ChatCompletionRequest{})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	wt, ok := stream.(io.WriterTo)
+	if !ok {
+		t.Fatal("stream does not implement io.WriterTo")
+	}
+	var buf bytes.Buffer
+	if _, err := wt.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if buf.String() != "data: [DONE]\n\n" {
+		t.Errorf("WriteTo() wrote %q", buf.String())
+	}
+	if got := breaker.status(); got != CircuitClosed {
+		t.Errorf("status = %s, want closed", got)
+	}
+}
+
+func TestCircuitStreamWithoutWriterTo(t *testing.T) {
+	p := &circuitProvider{Provider: &fakeProvider{stream: &fakeStream{}}, breaker: newTestBreaker(1)}
+
+	stream, err := p.ChatCompletion(context.Background(), &ChatCompletionRequest{})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if _, ok := stream.(io.WriterTo); ok {
+		t.Error("stream implements io.WriterTo, but the wrapped stream does not")
+	}
+}
+
+func TestCircuitWriteToRecordsOutcome(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		writer io.Writer
+		want   CircuitState
+	}{
+		{name: "success", writer: io.Discard, want: CircuitClosed},
+		{name: "upstream failure", err: errUpstream, writer: io.Discard, want: CircuitOpen},
+		{name: "client write failure", writer: failingWriter{}, want: CircuitClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := newTestBreaker(1)
+			inner := &fakeWriterToStream{fakeStream: fakeStream{err: tt.err}, data: "data: {}\n\n"}
+			p := &circuitProvider{Provider: &fakeProvider{stream: inner}, breaker: breaker}
+
+			stream, err := p.ChatCompletion(context.Background(), &ChatCompletionRequest{})
+			if err != nil {
+				t.Fatalf("ChatCompletion() error = %v", err)
+			}
+			_, _ = stream.(io.WriterTo).WriteTo(tt.writer)
+			_ = stream.Close()
+			if got := breaker.status(); got != tt.want {
+				t.Errorf("status = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsCircuitFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "upstream 5xx", err: errUpstream, want: true},
+		{name: "wrapped upstream 5xx", err: fmt.Errorf("send: %w", errUpstream), want: true},
+		{name: "upstream 4xx", err: api.NewUpstreamError(http.StatusTooManyRequests, "rate limited"), want: false},
+		{name: "local 503", err: &LocalError{Err: api.NewUpstreamError(http.StatusServiceUnavailable, "too many concurrent requests")}, want: false},
+		{name: "context length", err: &api.ErrContextLengthExceeded{}, want: false},
+		{name: "unsupported parameter", err: &ParameterNotSupportedError{Param: "logprobs"}, want: false},
+		{name: "out of bounds parameter", err: &ParameterBoundsError{Param: "temperature", Value: 3, Bounds: Bounds{Max: 2}}, want: false},
+		{name: "unsupported capability", err: ErrCapabilityNotSupported, want: false},
+		{name: "validation error", err: errors.New("messages must not be empty"), want: false},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, want: true},
+		{name: "transport error", err: &url.Error{Op: "Post", URL: "https://example.com", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, want: true},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, want: true},
+		{name: "corrupted stream", err: fmt.Errorf("%w: 5 consecutive malformed events", ErrStreamCorrupted), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCircuitFailure(tt.err); got != tt.want {
+				t.Errorf("isCircuitFailure(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalErrorKeepsStatus(t *testing.T) {
+	err := error(&LocalError{Err: api.NewUpstreamError(http.StatusServiceUnavailable, "limit reached")})
+	var upstreamErr *api.UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("errors.As(%v) did not find the 503 UpstreamError", err)
+	}
+}
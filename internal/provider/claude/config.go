@@ -0,0 +1,67 @@
+package claude
+
+import (
+	"os"
+	"strconv"
+)
+
+// Provider identification
+const ProviderID = "claude"
+
+// Environment variable names for Claude provider
+const (
+	EnvMaxTokens = "OPENCOMPAT_CLAUDE_MAX_TOKENS"
+)
+
+// Default values
+const (
+	// DefaultMaxTokens is sent when the request sets no token limit, since
+	// the Messages API requires max_tokens.
+	DefaultMaxTokens = 4096
+	// DefaultContextWindow is the context size of current Claude models; the
+	// models endpoint doesn't report it.
+	DefaultContextWindow = 200000
+)
+
+// Anthropic API configuration
+const (
+	AnthropicBaseURL     = "https://api.anthropic.com"
+	AnthropicMessagesURL = AnthropicBaseURL + "/v1/messages"
+	AnthropicModelsURL   = AnthropicBaseURL + "/v1/models"
+	AnthropicVersion     = "2023-06-01"
+)
+
+// Config holds Claude-specific configuration.
+type Config struct {
+	MaxTokens int // max_tokens sent when the request doesn't set one
+}
+
+// LoadConfig reads Claude configuration from environment variables.
+func LoadConfig() *Config {
+	return &Config{
+		MaxTokens: getEnvInt(EnvMaxTokens, DefaultMaxTokens),
+	}
+}
+
+// EnvVarDoc documents an environment variable.
+type EnvVarDoc struct {
+	Name        string
+	Description string
+	Default     string
+}
+
+// EnvVarDocs returns documentation for environment variables.
+func EnvVarDocs() []EnvVarDoc {
+	return []EnvVarDoc{
+		{Name: EnvMaxTokens, Description: "max_tokens sent when the request sets no limit", Default: strconv.Itoa(DefaultMaxTokens)},
+	}
+}
+
+func getEnvInt(key string, defaultVal int) int {
+	if val := os.Getenv(key); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			return i
+		}
+	}
+	return defaultVal
+}
@@ -0,0 +1,166 @@
+// Package claude implements the Anthropic Claude provider.
+package claude
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+func init() {
+	provider.AddRegistration(func(r *provider.Registry) {
+		r.RegisterMeta(provider.ProviderMeta{
+			ID:              ProviderID,
+			Name:            "Anthropic Claude",
+			AuthMethod:      auth.AuthMethodAPIKey,
+			EnvVars:         convertEnvVarDocs(EnvVarDocs()),
+			Factory:         New,
+			Capabilities:    provider.CapabilityStreaming | provider.CapabilityToolCalls | provider.CapabilityVision,
+			ParameterBounds: parameterBounds,
+		})
+	})
+}
+
+// parameterBounds are the Messages API sampling parameter ranges.
+var parameterBounds = map[string]provider.Bounds{
+	"temperature": {Min: 0, Max: 1},
+	"top_p":       {Min: 0, Max: 1},
+}
+
+// convertEnvVarDocs converts claude.EnvVarDoc to provider.EnvVarDoc.
+func convertEnvVarDocs(docs []EnvVarDoc) []provider.EnvVarDoc {
+	result := make([]provider.EnvVarDoc, len(docs))
+	for i, d := range docs {
+		result[i] = provider.EnvVarDoc{
+			Name:        d.Name,
+			Description: d.Description,
+			Default:     d.Default,
+		}
+	}
+	return result
+}
+
+// defaultModels is used until the models endpoint has been queried, and
+// whenever it fails.
+var defaultModels = []api.Model{
+	{ID: "claude-opus-4-1", Object: "model", OwnedBy: "anthropic", ContextWindow: DefaultContextWindow},
+	{ID: "claude-opus-4-0", Object: "model", OwnedBy: "anthropic", ContextWindow: DefaultContextWindow},
+	{ID: "claude-sonnet-4-5", Object: "model", OwnedBy: "anthropic", ContextWindow: DefaultContextWindow},
+	{ID: "claude-sonnet-4-0", Object: "model", OwnedBy: "anthropic", ContextWindow: DefaultContextWindow},
+	{ID: "claude-haiku-4-5", Object: "model", OwnedBy: "anthropic", ContextWindow: DefaultContextWindow},
+	{ID: "claude-3-5-haiku-latest", Object: "model", OwnedBy: "anthropic", ContextWindow: DefaultContextWindow},
+}
+
+// modelsFetchTimeout bounds the models request made during Init.
+const modelsFetchTimeout = 30 * time.Second
+
+// Provider implements the Claude provider.
+type Provider struct {
+	client *Client
+	cfg    *Config
+
+	mu     sync.RWMutex
+	models []api.Model
+}
+
+// New creates a new Claude provider.
+func New(store *auth.Store) (provider.Provider, error) {
+	return &Provider{
+		client: NewClient(store),
+		cfg:    LoadConfig(),
+		models: defaultModels,
+	}, nil
+}
+
+// ID returns the provider identifier.
+func (p *Provider) ID() string {
+	return ProviderID
+}
+
+// Models returns the list of supported models.
+func (p *Provider) Models() []api.Model {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.models
+}
+
+// SupportedParameters returns the optional request parameters mapped to the Messages API.
+func (p *Provider) SupportedParameters() []string {
+	return []string{
+		"temperature",
+		"top_p",
+		"stop",
+		"max_tokens",
+		"max_completion_tokens",
+		"parallel_tool_calls",
+	}
+}
+
+// SupportedToolTypes returns the tool types accepted by the upstream API.
+func (p *Provider) SupportedToolTypes() []string {
+	return []string{"function"}
+}
+
+// SupportsModel checks if a model ID is supported. Models missing from the
+// list (e.g., dated snapshots) are still accepted when they look like
+// Claude models, leaving validation to the API.
+func (p *Provider) SupportsModel(modelID string) bool {
+	for _, m := range p.Models() {
+		if m.ID == modelID {
+			return true
+		}
+	}
+	return strings.HasPrefix(modelID, "claude-")
+}
+
+// ChatCompletion sends a chat completion request.
+func (p *Provider) ChatCompletion(ctx context.Context, req *provider.ChatCompletionRequest) (provider.Stream, error) {
+	msgReq, err := TransformRequest(req, p.cfg)
+	if err != nil {
+		return nil, api.NewUpstreamError(http.StatusBadRequest, err.Error())
+	}
+
+	resp, err := p.client.SendRequest(ctx, msgReq)
+	if err != nil {
+		return nil, err
+	}
+
+	includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+	return NewStream(resp, includeUsage), nil
+}
+
+// Init fetches the models list, keeping the defaults if it fails.
+func (p *Provider) Init() error {
+	ctx, cancel := context.WithTimeout(context.Background(), modelsFetchTimeout)
+	defer cancel()
+	if err := p.RefreshModels(ctx); err != nil {
+		slog.Warn("failed to fetch models, using defaults", "provider", ProviderID, "error", err)
+	}
+	return nil
+}
+
+// Start begins background tasks. The Claude catalog changes rarely, so it
+// is only refreshed at startup and on demand via RefreshModels.
+func (p *Provider) Start() {}
+
+// Close stops background tasks.
+func (p *Provider) Close() {}
+
+// RefreshModels re-fetches the models list.
+func (p *Provider) RefreshModels(ctx context.Context) error {
+	models, err := p.client.FetchModels(ctx)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.models = models
+	p.mu.Unlock()
+	return nil
+}
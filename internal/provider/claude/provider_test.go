@@ -0,0 +1,134 @@
+package claude
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/testutil"
+)
+
+// rewriteTransport sends every request to target instead of the Anthropic API.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (rt rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newTestProvider returns a provider logged in with key whose requests go
+// to handler.
+func newTestProvider(t *testing.T, handler http.Handler) *Provider {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	store := auth.NewStore()
+	if err := store.SaveAPIKeyCredentials(ProviderID, &auth.APIKeyCredentials{APIKey: "sk-ant-test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+
+	p, err := New(store)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	cp := p.(*Provider)
+	cp.client.httpClient.Transport = rewriteTransport{target: target}
+	return cp
+}
+
+func TestChatCompletion(t *testing.T) {
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, messagesSSE)
+	p := newTestProvider(t, m)
+
+	stream, err := p.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{
+		Model:    "claude-sonnet-4-5",
+		Messages: []api.Message{api.SystemMessage("Be brief."), api.UserMessage("Weather in Paris?")},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	defer func() { _ = stream.Close() }()
+	if _, err := readAll(stream.(*Stream)); err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if resp := stream.Response(); resp == nil || len(resp.Choices) != 1 {
+		t.Fatalf("Response() = %+v, want one choice", resp)
+	}
+
+	m.AssertMethod(http.MethodPost).
+		AssertURL("/v1/messages").
+		AssertHeader("x-api-key", "sk-ant-test").
+		AssertHeader("anthropic-version", AnthropicVersion).
+		AssertHeader("Accept", "text/event-stream").
+		AssertBody("system", "Be brief.").
+		AssertBody("stream", true).
+		AssertBody("max_tokens", DefaultMaxTokens).
+		AssertBody("messages.#", 1).
+		AssertBody("messages.0.role", "user")
+}
+
+func TestChatCompletionInvalidRequest(t *testing.T) {
+	m := testutil.NewRequestMatcher(t)
+	p := newTestProvider(t, m)
+
+	_, err := p.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{
+		Model:    "claude-sonnet-4-5",
+		Messages: []api.Message{api.UserMessage("hi")},
+		Stop:     []byte(`42`),
+	})
+	upstreamErr, ok := err.(*api.UpstreamError)
+	if !ok || upstreamErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("ChatCompletion() error = %v, want a 400 UpstreamError", err)
+	}
+	if got := len(m.Requests()); got != 0 {
+		t.Errorf("upstream calls = %d, want 0", got)
+	}
+}
+
+func TestInitFetchesModels(t *testing.T) {
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusOK,
+		`{"data":[{"id":"claude-new-5","created_at":"2026-01-01T00:00:00Z"}]}`)
+	p := newTestProvider(t, m)
+
+	if err := p.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	m.AssertMethod(http.MethodGet).
+		AssertURL("/v1/models?limit=1000").
+		AssertHeader("x-api-key", "sk-ant-test")
+
+	models := p.Models()
+	if len(models) != 1 || models[0].ID != "claude-new-5" || models[0].ContextWindow != DefaultContextWindow {
+		t.Errorf("Models() = %+v, want claude-new-5 only", models)
+	}
+	if !p.SupportsModel("claude-3-7-sonnet-20250219") {
+		t.Error("SupportsModel(dated snapshot) = false, want true")
+	}
+	if p.SupportsModel("gpt-4o") {
+		t.Error("SupportsModel(gpt-4o) = true, want false")
+	}
+}
+
+func TestInitKeepsDefaultModelsOnError(t *testing.T) {
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusUnauthorized, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+	p := newTestProvider(t, m)
+
+	if err := p.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if got := len(p.Models()); got != len(defaultModels) {
+		t.Errorf("Models() has %d models, want the %d defaults", got, len(defaultModels))
+	}
+}
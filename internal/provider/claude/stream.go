@@ -0,0 +1,292 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/sse"
+)
+
+// Stream implements the provider.Stream interface for Anthropic responses,
+// converting Messages API events into chat completion chunks. The upstream
+// request always streams; for non-streaming requests the chunks are merged
+// into the response returned by Response().
+type Stream struct {
+	resp          *http.Response
+	reader        *sse.Reader
+	includeUsage  bool
+	statusChecked bool
+	done          bool
+	err           error
+
+	id      string
+	model   string
+	created int64
+	usage   Usage
+	reason  string
+
+	toolCalls map[int]int // content block index -> tool call index
+	chunks    []api.ChatCompletionChunk
+	response  *api.ChatCompletionResponse
+}
+
+// NewStream creates a new stream from an HTTP response.
+func NewStream(resp *http.Response, includeUsage bool) *Stream {
+	return &Stream{
+		resp:         resp,
+		reader:       sse.NewReader(resp.Body),
+		includeUsage: includeUsage,
+		created:      time.Now().Unix(),
+		toolCalls:    make(map[int]int),
+	}
+}
+
+// Next returns the next chunk from the stream.
+func (s *Stream) Next() (*api.ChatCompletionChunk, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+
+	// Check HTTP status once
+	if !s.statusChecked {
+		s.statusChecked = true
+		if s.resp.StatusCode != http.StatusOK {
+			s.done = true
+			body, _ := io.ReadAll(s.resp.Body)
+			s.err = newUpstreamError(s.resp.StatusCode, body)
+			return nil, s.err
+		}
+	}
+
+	for {
+		event, err := s.reader.ReadEvent()
+		if err == io.EOF {
+			// Streams normally end with message_stop; treat a bare EOF the same
+			return s.finish()
+		}
+		if err != nil {
+			s.done = true
+			s.err = err
+			return nil, err
+		}
+
+		chunk, stop, err := s.processEvent(event)
+		if err != nil {
+			s.done = true
+			s.err = err
+			return nil, err
+		}
+		if stop {
+			return s.finish()
+		}
+		if chunk != nil {
+			s.chunks = append(s.chunks, *chunk)
+			return chunk, nil
+		}
+	}
+}
+
+// processEvent converts one SSE event. It returns the chunk to emit (nil if
+// the event produces none) and whether the message is complete.
+func (s *Stream) processEvent(event *sse.Event) (*api.ChatCompletionChunk, bool, error) {
+	switch event.Event {
+	case "message_start":
+		var e MessageStartEvent
+		if err := json.Unmarshal(event.Data, &e); err != nil {
+			return nil, false, fmt.Errorf("invalid message_start event: %w", err)
+		}
+		s.id = e.Message.ID
+		s.model = e.Message.Model
+		s.usage = e.Message.Usage
+		return s.chunk(api.Delta{Role: "assistant"}, nil), false, nil
+
+	case "content_block_start":
+		var e ContentBlockStartEvent
+		if err := json.Unmarshal(event.Data, &e); err != nil {
+			return nil, false, fmt.Errorf("invalid content_block_start event: %w", err)
+		}
+		if e.ContentBlock.Type != "tool_use" {
+			return nil, false, nil
+		}
+		index := len(s.toolCalls)
+		s.toolCalls[e.Index] = index
+		return s.chunk(api.Delta{ToolCalls: []api.ToolCall{{
+			Index:    &index,
+			ID:       e.ContentBlock.ID,
+			Type:     "function",
+			Function: api.FunctionCall{Name: e.ContentBlock.Name},
+		}}}, nil), false, nil
+
+	case "content_block_delta":
+		var e ContentBlockDeltaEvent
+		if err := json.Unmarshal(event.Data, &e); err != nil {
+			return nil, false, fmt.Errorf("invalid content_block_delta event: %w", err)
+		}
+		switch e.Delta.Type {
+		case "text_delta":
+			return s.chunk(api.Delta{Content: e.Delta.Text}, nil), false, nil
+		case "input_json_delta":
+			index, ok := s.toolCalls[e.Index]
+			if !ok {
+				return nil, false, nil
+			}
+			return s.chunk(api.Delta{ToolCalls: []api.ToolCall{{
+				Index:    &index,
+				Function: api.FunctionCall{Arguments: e.Delta.PartialJSON},
+			}}}, nil), false, nil
+		}
+		return nil, false, nil
+
+	case "message_delta":
+		var e MessageDeltaEvent
+		if err := json.Unmarshal(event.Data, &e); err != nil {
+			return nil, false, fmt.Errorf("invalid message_delta event: %w", err)
+		}
+		s.usage.OutputTokens = e.Usage.OutputTokens
+		if e.Delta.StopReason == "" {
+			return nil, false, nil
+		}
+		s.reason = finishReason(e.Delta.StopReason)
+		return s.chunk(api.Delta{}, &s.reason), false, nil
+
+	case "message_stop":
+		return nil, true, nil
+
+	case "error":
+		var e ErrorEvent
+		if err := json.Unmarshal(event.Data, &e); err != nil {
+			return nil, false, fmt.Errorf("invalid error event: %w", err)
+		}
+		return nil, false, api.NewUpstreamError(errorStatus(e.Error.Type), e.Error.Message)
+	}
+
+	// ping, content_block_stop and unknown events carry nothing to forward
+	return nil, false, nil
+}
+
+// chunk builds a single-choice chunk.
+func (s *Stream) chunk(delta api.Delta, finishReason *string) *api.ChatCompletionChunk {
+	return &api.ChatCompletionChunk{
+		ID:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []api.Choice{{
+			Index:        0,
+			Delta:        &delta,
+			FinishReason: finishReason,
+		}},
+	}
+}
+
+// finish ends the stream, builds the merged response and returns the usage
+// chunk if the client asked for one.
+func (s *Stream) finish() (*api.ChatCompletionChunk, error) {
+	s.done = true
+
+	usageChunk := &api.ChatCompletionChunk{
+		ID:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []api.Choice{},
+		Usage:   s.openAIUsage(),
+	}
+	if resp, err := api.MergeChunks(append(s.chunks, *usageChunk)); err == nil {
+		resp.ID = s.id
+		resp.Created = s.created
+		resp.Model = s.model
+		s.response = resp
+	}
+
+	if s.includeUsage {
+		return usageChunk, nil
+	}
+	return nil, io.EOF
+}
+
+// openAIUsage converts the accumulated usage. Cached and cache-creation
+// tokens are billed as input, so they count toward prompt tokens.
+func (s *Stream) openAIUsage() *api.Usage {
+	prompt := s.usage.InputTokens + s.usage.CacheCreationInputTokens + s.usage.CacheReadInputTokens
+	usage := &api.Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: s.usage.OutputTokens,
+		TotalTokens:      prompt + s.usage.OutputTokens,
+	}
+	if s.usage.CacheReadInputTokens > 0 {
+		usage.PromptTokensDetails = &api.PromptTokenDetails{CachedTokens: s.usage.CacheReadInputTokens}
+	}
+	return usage
+}
+
+// Response returns the accumulated response. Call after Next() returns io.EOF.
+func (s *Stream) Response() *api.ChatCompletionResponse {
+	return s.response
+}
+
+// Err returns any error that occurred during streaming.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Close releases resources associated with the stream.
+func (s *Stream) Close() error {
+	if s.resp != nil && s.resp.Body != nil {
+		return s.resp.Body.Close()
+	}
+	return nil
+}
+
+// errorStatus maps Anthropic error types to HTTP status codes.
+func errorStatus(errType string) int {
+	switch errType {
+	case "invalid_request_error":
+		return http.StatusBadRequest
+	case "authentication_error":
+		return http.StatusUnauthorized
+	case "permission_error":
+		return http.StatusForbidden
+	case "not_found_error":
+		return http.StatusNotFound
+	case "rate_limit_error":
+		return http.StatusTooManyRequests
+	case "overloaded_error":
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// newUpstreamError builds the error for a non-200 response, recognizing
+// context length errors so the handler can suggest larger models.
+func newUpstreamError(statusCode int, body []byte) error {
+	message := parseUpstreamError(body)
+	if statusCode == http.StatusBadRequest {
+		if ctxErr := api.ParseContextLengthExceeded(message); ctxErr != nil {
+			return ctxErr
+		}
+	}
+	return api.NewUpstreamError(statusCode, message)
+}
+
+// parseUpstreamError extracts the message from an Anthropic error body.
+func parseUpstreamError(body []byte) string {
+	var errResp ErrorEvent
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+		return errResp.Error.Message
+	}
+
+	bodyStr := string(body)
+	if len(bodyStr) > 500 {
+		bodyStr = bodyStr[:500] + "..."
+	}
+	if bodyStr == "" {
+		return "unknown error"
+	}
+	return bodyStr
+}
@@ -0,0 +1,195 @@
+package claude
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// messagesSSE is a Messages API stream with text and a tool call.
+const messagesSSE = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-5","usage":{"input_tokens":20,"cache_read_input_tokens":5,"output_tokens":1}}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me "}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"check."}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":15}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+// newTestStream returns a stream reading body as a response with status.
+func newTestStream(status int, body string, includeUsage bool) *Stream {
+	return NewStream(&http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, includeUsage)
+}
+
+// readAll returns the chunks of s until io.EOF or an error.
+func readAll(s *Stream) ([]*api.ChatCompletionChunk, error) {
+	var chunks []*api.ChatCompletionChunk
+	for {
+		chunk, err := s.Next()
+		if err == io.EOF {
+			return chunks, nil
+		}
+		if err != nil {
+			return chunks, err
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+func TestStreamConvertsEvents(t *testing.T) {
+	s := newTestStream(http.StatusOK, messagesSSE, true)
+	defer func() { _ = s.Close() }()
+	chunks, err := readAll(s)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+
+	// role, two text deltas, tool call start, two argument deltas, finish
+	// reason and usage
+	if len(chunks) != 8 {
+		t.Fatalf("got %d chunks, want 8", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if chunk.ID != "msg_1" || chunk.Model != "claude-sonnet-4-5" || chunk.Object != "chat.completion.chunk" {
+			t.Errorf("chunk id, model, object = %q, %q, %q", chunk.ID, chunk.Model, chunk.Object)
+		}
+	}
+	if got := chunks[0].Choices[0].Delta.Role; got != "assistant" {
+		t.Errorf("first delta role = %q, want assistant", got)
+	}
+	start := chunks[3].Choices[0].Delta.ToolCalls
+	if len(start) != 1 || start[0].ID != "toolu_1" || start[0].Function.Name != "get_weather" || *start[0].Index != 0 {
+		t.Errorf("tool call start = %+v, want toolu_1 get_weather at index 0", start)
+	}
+	if reason := chunks[6].Choices[0].FinishReason; reason == nil || *reason != "tool_calls" {
+		t.Errorf("finish reason = %v, want tool_calls", reason)
+	}
+
+	usage := chunks[7].Usage
+	if usage == nil || len(chunks[7].Choices) != 0 {
+		t.Fatalf("last chunk = %+v, want a usage chunk without choices", chunks[7])
+	}
+	if usage.PromptTokens != 25 || usage.CompletionTokens != 15 || usage.TotalTokens != 40 {
+		t.Errorf("usage = %d/%d/%d, want 25/15/40", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens)
+	}
+	if usage.PromptTokensDetails == nil || usage.PromptTokensDetails.CachedTokens != 5 {
+		t.Errorf("cached tokens = %+v, want 5", usage.PromptTokensDetails)
+	}
+
+	resp := s.Response()
+	if resp == nil {
+		t.Fatal("Response() = nil")
+	}
+	msg := resp.Choices[0].Message
+	if got := msg.GetContentParts(); len(got) != 1 || got[0].Text != "Let me check." {
+		t.Errorf("merged content = %+v, want \"Let me check.\"", got)
+	}
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("merged tool calls = %+v, want get_weather with {\"city\":\"Paris\"}", msg.ToolCalls)
+	}
+}
+
+func TestStreamWithoutUsage(t *testing.T) {
+	s := newTestStream(http.StatusOK, messagesSSE, false)
+	chunks, err := readAll(s)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if last := chunks[len(chunks)-1]; last.Usage != nil {
+		t.Errorf("last chunk has usage %+v, want none when not requested", last.Usage)
+	}
+	if s.Response() == nil || s.Response().Usage == nil {
+		t.Error("Response() usage missing, want it merged regardless of stream_options")
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus int
+		wantMsg    string
+		wantCtxErr bool
+	}{
+		{
+			name:       "error event",
+			status:     http.StatusOK,
+			body:       "event: message_start\ndata: {\"message\":{\"id\":\"msg_1\"}}\n\nevent: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n",
+			wantStatus: http.StatusServiceUnavailable,
+			wantMsg:    "Overloaded",
+		},
+		{
+			name:       "error response",
+			status:     http.StatusTooManyRequests,
+			body:       `{"type":"error","error":{"type":"rate_limit_error","message":"Rate limited"}}`,
+			wantStatus: http.StatusTooManyRequests,
+			wantMsg:    "Rate limited",
+		},
+		{
+			name:       "context length",
+			status:     http.StatusBadRequest,
+			body:       `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`,
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "prompt is too long: 210000 tokens > 200000 maximum",
+			wantCtxErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStream(tt.status, tt.body, false)
+			_, err := readAll(s)
+
+			var upstreamErr *api.UpstreamError
+			if !errors.As(err, &upstreamErr) {
+				t.Fatalf("error = %v, want an UpstreamError", err)
+			}
+			if upstreamErr.StatusCode != tt.wantStatus || upstreamErr.Message != tt.wantMsg {
+				t.Errorf("error = %d %q, want %d %q", upstreamErr.StatusCode, upstreamErr.Message, tt.wantStatus, tt.wantMsg)
+			}
+			var ctxErr *api.ErrContextLengthExceeded
+			if got := errors.As(err, &ctxErr); got != tt.wantCtxErr {
+				t.Errorf("context length error = %v, want %v", got, tt.wantCtxErr)
+			}
+			if ctxErr != nil && (ctxErr.PromptTokens != 210000 || ctxErr.ModelLimit != 200000) {
+				t.Errorf("tokens = (%d, %d), want (210000, 200000)", ctxErr.PromptTokens, ctxErr.ModelLimit)
+			}
+			if s.Err() != err {
+				t.Errorf("Err() = %v, want %v", s.Err(), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// TransformRequest converts a chat completion request to a Messages API request.
+func TransformRequest(req *provider.ChatCompletionRequest, cfg *Config) (*MessagesRequest, error) {
+	system, messages, err := transformMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	stop, err := parseStop(req.Stop)
+	if err != nil {
+		return nil, err
+	}
+
+	toolChoice, err := transformToolChoice(req.ToolChoice, req.ParallelToolCalls)
+	if err != nil {
+		return nil, err
+	}
+
+	maxTokens := cfg.MaxTokens
+	switch {
+	case req.MaxCompletionTokens != nil:
+		maxTokens = *req.MaxCompletionTokens
+	case req.MaxTokens != nil:
+		maxTokens = *req.MaxTokens
+	}
+
+	out := &MessagesRequest{
+		Model:         req.Model,
+		System:        system,
+		Messages:      messages,
+		MaxTokens:     maxTokens,
+		Stream:        true, // Always stream upstream; non-streaming responses are merged from chunks
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		StopSequences: stop,
+		ToolChoice:    toolChoice,
+	}
+	for _, tool := range req.Tools {
+		schema := tool.Function.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		out.Tools = append(out.Tools, Tool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	return out, nil
+}
+
+// transformMessages splits out the system prompt, which Anthropic takes as a
+// top-level field, and converts the remaining messages. Tool results become
+// user tool_result blocks, and consecutive messages with the same role are
+// merged because the API requires user and assistant turns to alternate.
+func transformMessages(messages []api.Message) (string, []Message, error) {
+	var system []string
+	var result []Message
+
+	appendBlocks := func(role string, blocks []ContentBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(result); n > 0 && result[n-1].Role == role {
+			result[n-1].Content = append(result[n-1].Content, blocks...)
+			return
+		}
+		result = append(result, Message{Role: role, Content: blocks})
+	}
+
+	for i, msg := range messages {
+		switch msg.Role {
+		case "system":
+			if text := textContent(&msg); text != "" {
+				system = append(system, text)
+			}
+
+		case "user":
+			blocks, err := userBlocks(&msg)
+			if err != nil {
+				return "", nil, fmt.Errorf("messages[%d]: %w", i, err)
+			}
+			appendBlocks("user", blocks)
+
+		case "assistant":
+			var blocks []ContentBlock
+			if text := textContent(&msg); text != "" {
+				blocks = append(blocks, ContentBlock{Type: "text", Text: text})
+			}
+			for _, tc := range msg.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if len(strings.TrimSpace(tc.Function.Arguments)) == 0 {
+					input = json.RawMessage(`{}`)
+				} else if !json.Valid(input) {
+					return "", nil, fmt.Errorf("messages[%d]: tool call %s has invalid JSON arguments", i, tc.ID)
+				}
+				blocks = append(blocks, ContentBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Function.Name,
+					Input: input,
+				})
+			}
+			appendBlocks("assistant", blocks)
+
+		case "tool":
+			appendBlocks("user", []ContentBlock{{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   textContent(&msg),
+			}})
+		}
+	}
+
+	return strings.Join(system, "\n\n"), result, nil
+}
+
+// textContent joins the text parts of a message.
+func textContent(msg *api.Message) string {
+	var texts []string
+	for _, part := range msg.GetContentParts() {
+		if part.Type == "text" && part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// userBlocks converts user content parts to text and image blocks.
+func userBlocks(msg *api.Message) ([]ContentBlock, error) {
+	var blocks []ContentBlock
+	for _, part := range msg.GetContentParts() {
+		switch part.Type {
+		case "text":
+			if part.Text != "" {
+				blocks = append(blocks, ContentBlock{Type: "text", Text: part.Text})
+			}
+		case "image_url":
+			if part.ImageURL == nil {
+				continue
+			}
+			source, err := imageSource(part.ImageURL.URL)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, ContentBlock{Type: "image", Source: source})
+		}
+	}
+	return blocks, nil
+}
+
+// imageSource converts an image URL, which may be a base64 data URL.
+func imageSource(url string) (*ImageSource, error) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return &ImageSource{Type: "url", URL: url}, nil
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	mediaType, isBase64 := strings.CutSuffix(meta, ";base64")
+	if !ok || !isBase64 {
+		return nil, fmt.Errorf("unsupported image data URL; expected data:<media type>;base64,<data>")
+	}
+	return &ImageSource{Type: "base64", MediaType: mediaType, Data: data}, nil
+}
+
+// parseStop converts stop (a string or array of strings) to stop sequences.
+func parseStop(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("stop must be a string or an array of strings")
+	}
+	return list, nil
+}
+
+// transformToolChoice maps tool_choice ("none", "auto", "required" or a
+// named function) and parallel_tool_calls to Anthropic's tool_choice.
+func transformToolChoice(raw json.RawMessage, parallel *bool) (*ToolChoice, error) {
+	disableParallel := parallel != nil && !*parallel
+
+	choice, err := api.ParseToolChoice(raw)
+	if err != nil {
+		return nil, err
+	}
+	if choice == nil {
+		if disableParallel {
+			return &ToolChoice{Type: "auto", DisableParallelToolUse: true}, nil
+		}
+		return nil, nil
+	}
+
+	switch choice.Mode {
+	case api.ToolChoiceNone:
+		return &ToolChoice{Type: "none"}, nil
+	case api.ToolChoiceRequired:
+		return &ToolChoice{Type: "any", DisableParallelToolUse: disableParallel}, nil
+	case api.ToolChoiceFunction:
+		return &ToolChoice{Type: "tool", Name: choice.Function, DisableParallelToolUse: disableParallel}, nil
+	default:
+		return &ToolChoice{Type: "auto", DisableParallelToolUse: disableParallel}, nil
+	}
+}
+
+// finishReasons maps Anthropic stop reasons to OpenAI finish reasons.
+var finishReasons = map[string]string{
+	"end_turn":      "stop",
+	"stop_sequence": "stop",
+	"pause_turn":    "stop",
+	"max_tokens":    "length",
+	"tool_use":      "tool_calls",
+	"refusal":       "content_filter",
+}
+
+// finishReason maps a stop reason, defaulting to "stop".
+func finishReason(stopReason string) string {
+	if reason, ok := finishReasons[stopReason]; ok {
+		return reason
+	}
+	return "stop"
+}
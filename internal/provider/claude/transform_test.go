@@ -0,0 +1,189 @@
+package claude
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// assertJSONEqual fails unless v encodes to the same JSON value as want.
+func assertJSONEqual(t *testing.T, v any, want string) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, expected any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatalf("invalid expected JSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("JSON = %s\nwant %s", data, want)
+	}
+}
+
+func TestTransformRequest(t *testing.T) {
+	image := api.Message{Role: "user"}
+	image.SetContentParts([]api.ContentPart{
+		{Type: "text", Text: "and this?"},
+		{Type: "image_url", ImageURL: &api.ImageURL{URL: "data:image/png;base64,iVBORw0KGgo="}},
+	})
+	assistant := api.AssistantMessage("Checking both.")
+	assistant.ToolCalls = []api.ToolCall{
+		{ID: "toolu_1", Type: "function", Function: api.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		{ID: "toolu_2", Type: "function", Function: api.FunctionCall{Name: "get_time"}},
+	}
+	temperature := 0.5
+	maxTokens := 512
+
+	req := &provider.ChatCompletionRequest{
+		Model: "claude-sonnet-4-5",
+		Messages: []api.Message{
+			api.SystemMessage("Be brief."),
+			api.SystemMessage("Answer in English."),
+			api.UserMessage("Weather and time in Paris?"),
+			api.UserMessage("Please."),
+			assistant,
+			{Role: "tool", ToolCallID: "toolu_1", Content: json.RawMessage(`"Sunny"`)},
+			{Role: "tool", ToolCallID: "toolu_2", Content: json.RawMessage(`"10:00"`)},
+			image,
+		},
+		Tools: []api.Tool{
+			{Type: "function", Function: api.Function{Name: "get_weather", Description: "Current weather", Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`)}},
+			{Type: "function", Function: api.Function{Name: "get_time"}},
+		},
+		ToolChoice:  json.RawMessage(`"required"`),
+		Temperature: &temperature,
+		MaxTokens:   &maxTokens,
+		Stop:        json.RawMessage(`"END"`),
+	}
+
+	got, err := TransformRequest(req, &Config{MaxTokens: DefaultMaxTokens})
+	if err != nil {
+		t.Fatalf("TransformRequest() error = %v", err)
+	}
+	assertJSONEqual(t, got, `{
+		"model": "claude-sonnet-4-5",
+		"system": "Be brief.\n\nAnswer in English.",
+		"messages": [
+			{"role": "user", "content": [
+				{"type": "text", "text": "Weather and time in Paris?"},
+				{"type": "text", "text": "Please."}
+			]},
+			{"role": "assistant", "content": [
+				{"type": "text", "text": "Checking both."},
+				{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Paris"}},
+				{"type": "tool_use", "id": "toolu_2", "name": "get_time", "input": {}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": "Sunny"},
+				{"type": "tool_result", "tool_use_id": "toolu_2", "content": "10:00"},
+				{"type": "text", "text": "and this?"},
+				{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo="}}
+			]}
+		],
+		"max_tokens": 512,
+		"stream": true,
+		"temperature": 0.5,
+		"stop_sequences": ["END"],
+		"tools": [
+			{"name": "get_weather", "description": "Current weather", "input_schema": {"type": "object", "properties": {"city": {"type": "string"}}}},
+			{"name": "get_time", "input_schema": {"type": "object", "properties": {}}}
+		],
+		"tool_choice": {"type": "any"}
+	}`)
+}
+
+func TestTransformRequestMaxTokens(t *testing.T) {
+	maxTokens, maxCompletion := 100, 200
+	tests := []struct {
+		name string
+		req  provider.ChatCompletionRequest
+		want int
+	}{
+		{name: "default", want: 1024},
+		{name: "max_tokens", req: provider.ChatCompletionRequest{MaxTokens: &maxTokens}, want: 100},
+		{name: "max_completion_tokens wins", req: provider.ChatCompletionRequest{MaxTokens: &maxTokens, MaxCompletionTokens: &maxCompletion}, want: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TransformRequest(&tt.req, &Config{MaxTokens: 1024})
+			if err != nil {
+				t.Fatalf("TransformRequest() error = %v", err)
+			}
+			if got.MaxTokens != tt.want {
+				t.Errorf("MaxTokens = %d, want %d", got.MaxTokens, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformToolChoice(t *testing.T) {
+	no := false
+	tests := []struct {
+		name       string
+		toolChoice string
+		parallel   *bool
+		want       string // JSON; "null" for none sent
+	}{
+		{name: "unset", want: `null`},
+		{name: "unset without parallel calls", parallel: &no, want: `{"type":"auto","disable_parallel_tool_use":true}`},
+		{name: "none", toolChoice: `"none"`, want: `{"type":"none"}`},
+		{name: "auto", toolChoice: `"auto"`, want: `{"type":"auto"}`},
+		{name: "required", toolChoice: `"required"`, parallel: &no, want: `{"type":"any","disable_parallel_tool_use":true}`},
+		{name: "named", toolChoice: `{"type":"function","function":{"name":"get_weather"}}`, want: `{"type":"tool","name":"get_weather"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := transformToolChoice(json.RawMessage(tt.toolChoice), tt.parallel)
+			if err != nil {
+				t.Fatalf("transformToolChoice() error = %v", err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestTransformMessagesErrors(t *testing.T) {
+	badArgs := api.AssistantMessage("")
+	badArgs.ToolCalls = []api.ToolCall{{ID: "toolu_1", Function: api.FunctionCall{Name: "f", Arguments: "{not json"}}}
+	badImage := api.Message{Role: "user"}
+	badImage.SetContentParts([]api.ContentPart{{Type: "image_url", ImageURL: &api.ImageURL{URL: "data:image/png,raw"}}})
+
+	tests := []struct {
+		name    string
+		message api.Message
+		want    string
+	}{
+		{name: "invalid tool arguments", message: badArgs, want: "messages[0]: tool call toolu_1 has invalid JSON arguments"},
+		{name: "non-base64 data URL", message: badImage, want: "messages[0]: unsupported image data URL; expected data:<media type>;base64,<data>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := transformMessages([]api.Message{tt.message})
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("transformMessages() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinishReason(t *testing.T) {
+	for stopReason, want := range map[string]string{
+		"end_turn":   "stop",
+		"max_tokens": "length",
+		"tool_use":   "tool_calls",
+		"refusal":    "content_filter",
+		"new_reason": "stop",
+	} {
+		if got := finishReason(stopReason); got != want {
+			t.Errorf("finishReason(%q) = %q, want %q", stopReason, got, want)
+		}
+	}
+}
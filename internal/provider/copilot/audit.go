@@ -0,0 +1,116 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/audit"
+)
+
+// errAuditIncomplete is recorded for streams closed before they ended.
+var errAuditIncomplete = errors.New("stream closed before completion")
+
+// newAuditLogger returns the audit logger for cfg, honoring an injected
+// one. The returned FileLogger is non-nil when the provider opened the log
+// itself and must close it.
+func newAuditLogger(cfg *Config, injected audit.Logger) (audit.Logger, *audit.FileLogger, error) {
+	logger := injected
+	var file *audit.FileLogger
+	if logger == nil && cfg.AuditLog != "" {
+		var err error
+		if file, err = audit.NewFileLogger(cfg.AuditLog); err != nil {
+			return nil, nil, err
+		}
+		logger = file
+	}
+	if logger == nil {
+		return audit.NoopLogger{}, nil, nil
+	}
+	if cfg.AuditScrubPII {
+		logger = audit.WithPIIScrubbing(logger)
+	}
+	return logger, file, nil
+}
+
+// auditRecord builds the audit entry of one upstream request while its
+// stream is read, and logs it once the stream ends.
+type auditRecord struct {
+	ctx    context.Context
+	logger audit.Logger
+	log    *slog.Logger
+	entry  audit.Entry
+	start  time.Time
+	chunks []api.ChatCompletionChunk
+	done   bool
+}
+
+// newAuditRecord starts the record of chatReq, or returns nil when
+// auditing is off.
+func (p *Provider) newAuditRecord(ctx context.Context, chatReq *api.ChatCompletionRequest, start time.Time) *auditRecord {
+	if _, ok := p.audit.(audit.NoopLogger); ok {
+		return nil
+	}
+	return &auditRecord{
+		// The entry is logged even if the client has gone away
+		ctx:    context.WithoutCancel(ctx),
+		logger: p.audit,
+		log:    p.logger,
+		start:  start,
+		entry: audit.Entry{
+			RequestID:     audit.RequestID(ctx),
+			ProviderID:    ProviderID,
+			Model:         chatReq.Model,
+			Timestamp:     start.UTC(),
+			InputMessages: chatReq.Messages,
+		},
+	}
+}
+
+// observe handles the result of one Next call, logging the entry when the
+// stream has ended.
+func (r *auditRecord) observe(s *Stream, chunk *api.ChatCompletionChunk, err error) {
+	switch {
+	case r == nil || r.done:
+	case err == nil:
+		if chunk != nil {
+			r.chunks = append(r.chunks, *chunk)
+		}
+	case err == io.EOF:
+		r.finish(s.Response(), s.Err())
+	default:
+		r.finish(nil, err)
+	}
+}
+
+// finish logs the entry with the final response or error. Only the first
+// call has an effect.
+func (r *auditRecord) finish(resp *api.ChatCompletionResponse, err error) {
+	if r == nil || r.done {
+		return
+	}
+	r.done = true
+
+	if len(r.chunks) > 0 {
+		if merged, mergeErr := api.MergeChunks(r.chunks); mergeErr == nil {
+			resp = merged
+		}
+	}
+	if resp != nil {
+		r.entry.TokenUsage = resp.Usage
+		if len(resp.Choices) > 0 {
+			r.entry.OutputMessage = resp.Choices[0].Message
+		}
+	}
+	if err != nil {
+		r.entry.Error = err.Error()
+	}
+	r.entry.DurationMs = time.Since(r.start).Milliseconds()
+
+	if logErr := r.logger.Log(r.ctx, r.entry); logErr != nil {
+		r.log.Error("failed to write audit entry", "model", r.entry.Model, "error", logErr)
+	}
+}
@@ -0,0 +1,89 @@
+package copilot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// cacheable reports whether the response to chatReq may be served from the
+// response cache: only deterministic (temperature 0) non-streaming requests
+// are.
+func cacheable(chatReq *api.ChatCompletionRequest) bool {
+	return !chatReq.Stream && chatReq.Temperature != nil && *chatReq.Temperature == 0
+}
+
+// responseCacheKey hashes the upstream request, ignoring stream settings.
+func responseCacheKey(chatReq *api.ChatCompletionRequest) (string, error) {
+	keyReq := *chatReq
+	keyReq.Stream = false
+	keyReq.StreamOptions = nil
+	body, err := json.Marshal(&keyReq)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// cloneResponse deep-copies resp.
+func cloneResponse(resp *api.ChatCompletionResponse) (*api.ChatCompletionResponse, error) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	var clone api.ChatCompletionResponse
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+// cachedStream serves a cached non-streaming response.
+type cachedStream struct {
+	resp *api.ChatCompletionResponse
+}
+
+func (s *cachedStream) Next() (*api.ChatCompletionChunk, error) {
+	return nil, io.EOF
+}
+
+func (s *cachedStream) Response() *api.ChatCompletionResponse {
+	return s.resp
+}
+
+func (s *cachedStream) Err() error {
+	return nil
+}
+
+func (s *cachedStream) Close() error {
+	return nil
+}
+
+// cachingStream stores the upstream response in the cache once it has
+// been read successfully.
+type cachingStream struct {
+	provider.Stream
+	cache  provider.ResponseCache
+	key    string
+	ttl    time.Duration
+	stored bool
+}
+
+func (s *cachingStream) Next() (*api.ChatCompletionChunk, error) {
+	chunk, err := s.Stream.Next()
+	if err == io.EOF && !s.stored && s.Stream.Err() == nil {
+		s.stored = true
+		if resp := s.Stream.Response(); resp != nil {
+			if clone, cloneErr := cloneResponse(resp); cloneErr == nil {
+				s.cache.Set(s.key, clone, s.ttl)
+			}
+		}
+	}
+	return chunk, err
+}
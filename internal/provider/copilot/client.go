@@ -3,59 +3,212 @@ package copilot
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/edgard/opencompat/internal/api"
 	"github.com/edgard/opencompat/internal/auth"
 	"github.com/edgard/opencompat/internal/httputil"
+	"github.com/edgard/opencompat/internal/metrics"
+	"github.com/edgard/opencompat/internal/provider"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
 // CopilotToken represents a token obtained from the Copilot API.
-type CopilotToken struct {
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expires_at"`
-}
+type CopilotToken = auth.CopilotToken
+
+// HTTPTimeout bounds token, model and embeddings requests, and caps the
+// adaptive timeout of chat requests. Chat requests are otherwise bounded by
+// Config.RequestTimeout and Config.StreamTimeout.
+const HTTPTimeout = 5 * time.Minute
 
 // Client handles communication with the Copilot API.
 type Client struct {
 	store        *auth.Store
+	cfg          *Config
+	retry        RetryConfig
+	expiryBuffer time.Duration // see Config.TokenExpiryBuffer
+	logger       *slog.Logger
 	httpClient   *http.Client
+	chatClient   *http.Client              // adaptive timeout, no overall timeout
+	adaptive     *httputil.AdaptiveTimeout // nil with a fixed timeout
 	mu           sync.RWMutex
 	copilotToken *CopilotToken
+
+	// noCompression is set once Copilot rejects a gzip request body
+	noCompression atomic.Bool
+
+	inflight singleflight.Group // see Config.Deduplication
+
+	// active counts chat requests until their response body is closed
+	active       sync.WaitGroup
+	shutdownMu   sync.RWMutex
+	shuttingDown bool
 }
 
-// NewClient creates a new Copilot client.
-func NewClient(store *auth.Store) *Client {
+// NewClient creates a new Copilot client. Chat requests are retried
+// according to retry.
+func NewClient(store *auth.Store, cfg *Config, retry RetryConfig, opts ...Option) *Client {
+	o := applyOptions(opts)
+	transport := newTransport(cfg)
+
+	// Chat requests get an adaptive timeout; token and model requests are
+	// much faster and would skew its latency estimate
+	chatTransport := transport
+	var adaptive *httputil.AdaptiveTimeout
+	if cfg.AdaptiveTimeoutMin > 0 {
+		adaptive = httputil.NewAdaptiveTimeout(ProviderID, transport, cfg.AdaptiveTimeoutMin, HTTPTimeout)
+		chatTransport = adaptive
+	}
+
 	return &Client{
-		store: store,
+		adaptive:     adaptive,
+		store:        store,
+		cfg:          cfg,
+		retry:        retry,
+		expiryBuffer: cfg.TokenExpiryBuffer,
+		logger:       o.logger,
 		httpClient: &http.Client{
-			Timeout: 5 * time.Minute,
+			Timeout:       HTTPTimeout,
+			Transport:     transport,
+			CheckRedirect: checkRedirect(cfg, o.logger),
+		},
+		// Timeouts are per request, see SendRequest
+		chatClient: &http.Client{
+			Transport:     chatTransport,
+			CheckRedirect: checkRedirect(cfg, o.logger),
+		},
+	}
+}
+
+// checkRedirect limits redirects to cfg.RedirectMax and, unless
+// cfg.AllowDowngrade is set, refuses redirects from https to http.
+func checkRedirect(cfg *Config, logger *slog.Logger) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		prev := via[len(via)-1]
+		logger.Debug("following upstream redirect",
+			"from", prev.URL.Redacted(),
+			"to", req.URL.Redacted(),
+		)
+
+		if len(via) > cfg.RedirectMax {
+			return fmt.Errorf("stopped after %d redirects", cfg.RedirectMax)
+		}
+		if !cfg.AllowDowngrade && via[0].URL.Scheme == "https" && req.URL.Scheme == "http" {
+			return fmt.Errorf("refusing redirect from %s to insecure %s", prev.URL.Redacted(), req.URL.Redacted())
+		}
+		return nil
+	}
+}
+
+// newTransport returns the HTTP transport for Copilot requests, routing
+// through the configured proxy (or the proxy environment) and enforcing the
+// certificate pin.
+func newTransport(cfg *Config) http.RoundTripper {
+	if cfg == nil {
+		return httputil.BuildTransport(httputil.TransportConfig{})
+	}
+
+	tc := httputil.TransportConfig{
+		ProxyURL:  cfg.ProxyURL,
+		NoProxy:   cfg.NoProxy,
+		HTTP1Only: !cfg.HTTP2,
+		// Ping idle connections so a stalled stream fails instead of hanging
+		HTTP2: &http.HTTP2Config{
+			SendPingTimeout: http2PingInterval,
+			PingTimeout:     http2PingTimeout,
 		},
 	}
+	if cfg.CertPin != nil {
+		tc.TLSConfig = &tls.Config{
+			MinVersion:       tls.VersionTLS12,
+			VerifyConnection: verifyCertPin(copilotAPIHost(cfg.ChatURL), cfg.CertPin),
+		}
+	}
+	return httputil.BuildTransport(tc)
 }
 
-// getGitHubToken retrieves the GitHub OAuth token (stored as refresh token).
+// copilotAPIHost returns the hostname of the Copilot API endpoint.
+func copilotAPIHost(chatURL string) string {
+	u, err := url.Parse(chatURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// verifyCertPin returns a TLS verification callback that requires the leaf
+// certificate presented by host to match the SHA-256 fingerprint pin. It runs
+// after the standard chain verification, and other hosts are not affected.
+func verifyCertPin(host string, pin []byte) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if !strings.EqualFold(cs.ServerName, host) {
+			return nil
+		}
+		if len(cs.PeerCertificates) == 0 {
+			return fmt.Errorf("certificate pin check failed for %s: no certificate presented", host)
+		}
+		fingerprint := sha256.Sum256(cs.PeerCertificates[0].Raw)
+		if subtle.ConstantTimeCompare(fingerprint[:], pin) != 1 {
+			return fmt.Errorf("certificate pin mismatch for %s: got %s, want %s",
+				host,
+				base64.StdEncoding.EncodeToString(fingerprint[:]),
+				base64.StdEncoding.EncodeToString(pin),
+			)
+		}
+		return nil
+	}
+}
+
+// getGitHubToken retrieves the GitHub OAuth token (stored as refresh token)
+// of the active account.
 func (c *Client) getGitHubToken() (string, error) {
-	creds, err := c.store.GetOAuthCredentials(ProviderID)
+	creds, err := c.store.GetOAuthCredentials(auth.AccountKey(ProviderID, c.store.GetActiveAccount(ProviderID)))
 	if err != nil {
 		return "", fmt.Errorf("failed to get credentials: %w", err)
 	}
 	if creds.RefreshToken == "" {
 		return "", fmt.Errorf("no GitHub token found - please run: opencompat login %s", ProviderID)
 	}
+	// The GitHub token can't be refreshed; fail early once its known expiry passes
+	if !creds.ExpiresAt.IsZero() && time.Now().After(creds.ExpiresAt) {
+		return "", fmt.Errorf("%w: GitHub token expired at %s - please run: opencompat login %s",
+			auth.ErrCredentialExpired, creds.ExpiresAt.Format(time.RFC3339), ProviderID)
+	}
 	return creds.RefreshToken, nil
 }
 
 // getCopilotToken returns a valid Copilot API token, refreshing if necessary.
-func (c *Client) getCopilotToken(ctx context.Context) (string, error) {
+// Tokens are persisted (see loadCachedToken), so a restarted process reuses
+// the last token until it expires.
+func (c *Client) getCopilotToken(ctx context.Context) (token string, err error) {
+	ctx, span := tracer().Start(ctx, "copilot.getCopilotToken")
+	refreshed := false
+	defer func() {
+		span.SetAttributes(attribute.Bool("token.refreshed", refreshed))
+		endSpan(span, err)
+	}()
+
 	c.mu.RLock()
-	if c.copilotToken != nil && time.Now().Add(60*time.Second).Before(c.copilotToken.ExpiresAt) {
+	if c.tokenValid(c.copilotToken) {
 		token := c.copilotToken.Token
 		c.mu.RUnlock()
 		return token, nil
@@ -67,10 +220,18 @@ func (c *Client) getCopilotToken(ctx context.Context) (string, error) {
 	defer c.mu.Unlock()
 
 	// Double-check after acquiring write lock
-	if c.copilotToken != nil && time.Now().Add(60*time.Second).Before(c.copilotToken.ExpiresAt) {
+	if c.tokenValid(c.copilotToken) {
 		return c.copilotToken.Token, nil
 	}
 
+	// Reuse the persisted token from a previous run if it's still valid
+	if stored, err := c.loadCachedToken(); err == nil && c.tokenValid(stored) {
+		c.copilotToken = stored
+		return stored.Token, nil
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.logger.Debug("ignoring stored copilot token", "error", err)
+	}
+
 	// Get GitHub token
 	githubToken, err := c.getGitHubToken()
 	if err != nil {
@@ -78,18 +239,36 @@ func (c *Client) getCopilotToken(ctx context.Context) (string, error) {
 	}
 
 	// Exchange for Copilot token
-	token, err := c.refreshCopilotToken(ctx, githubToken)
+	refreshed = true
+	newToken, err := c.refreshCopilotToken(ctx, githubToken)
 	if err != nil {
 		return "", err
 	}
 
-	c.copilotToken = token
-	return token.Token, nil
+	c.copilotToken = newToken
+	c.logger.Info("copilot token refreshed", "expires_at", newToken.ExpiresAt)
+	if err := c.saveCachedToken(newToken); err != nil {
+		c.logger.Debug("failed to persist copilot token", "error", err) // e.g. read-only data dir
+	}
+	return newToken.Token, nil
+}
+
+// setExpiryBuffer changes the token expiry buffer for later token checks.
+func (c *Client) setExpiryBuffer(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expiryBuffer = d
+}
+
+// tokenValid reports whether t is set and not within the expiry buffer of
+// its expiry.
+func (c *Client) tokenValid(t *CopilotToken) bool {
+	return t != nil && time.Now().Add(c.expiryBuffer).Before(t.ExpiresAt)
 }
 
 // refreshCopilotToken exchanges a GitHub token for a Copilot API token.
 func (c *Client) refreshCopilotToken(ctx context.Context, githubToken string) (*CopilotToken, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", CopilotTokenURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.cfg.TokenURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -129,75 +308,301 @@ func (c *Client) refreshCopilotToken(ctx context.Context, githubToken string) (*
 	}, nil
 }
 
-// SendRequest sends a chat completion request to the Copilot API.
-func (c *Client) SendRequest(ctx context.Context, chatReq *api.ChatCompletionRequest) (*http.Response, error) {
-	// Get valid Copilot token
-	token, err := c.getCopilotToken(ctx)
-	if err != nil {
+// SendRequest sends a chat completion request to the Copilot API, retrying
+// transient failures as configured by the client's RetryConfig. The final
+// status and the time until response headers are recorded in metrics.
+// passthrough headers are added to the request unless the client sets them
+// itself or they carry credentials; it may be nil.
+func (c *Client) SendRequest(ctx context.Context, chatReq *api.ChatCompletionRequest, passthrough http.Header) (resp *http.Response, err error) {
+	if err := c.track(); err != nil {
 		return nil, err
 	}
+	// The deadline covers reading the body, so it is released on close
+	ctx, cancel := withTimeout(ctx, c.chatTimeout(chatReq.Stream))
+	defer func() {
+		if err != nil {
+			cancel()
+			c.active.Done()
+			return
+		}
+		resp.Body = &trackedBody{ReadCloser: resp.Body, done: sync.OnceFunc(func() {
+			cancel()
+			c.active.Done()
+		})}
+		httputil.RecordUpstreamTraceContext(ctx, resp.Header)
+	}()
 
-	// Serialize request
-	body, err := json.Marshal(chatReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	// Retries share one request ID so they can be correlated upstream
+	requestID := uuid.New().String()
+	start := time.Now()
+
+	ctx, span := tracer().Start(ctx, "copilot.SendRequest", trace.WithAttributes(
+		attribute.String("provider.id", ProviderID),
+		attribute.String("model", chatReq.Model),
+		attribute.Bool("stream", chatReq.Stream),
+		attribute.String("request.id", requestID),
+	))
+	defer func() {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+		}
+		endSpan(span, err)
+		metrics.ObserveRequest(ProviderID, chatReq.Model, status, time.Since(start))
+	}()
+
+	if c.cfg.Deduplication && !chatReq.Stream {
+		return c.sendDeduplicated(ctx, chatReq, passthrough, func(ctx context.Context) (*http.Response, error) {
+			return c.sendChat(ctx, chatReq, passthrough, requestID)
+		})
 	}
+	return c.sendChat(ctx, chatReq, passthrough, requestID)
+}
 
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", CopilotChatURL, bytes.NewReader(body))
+// sendChat sends chatReq with retries.
+func (c *Client) sendChat(ctx context.Context, chatReq *api.ChatCompletionRequest, passthrough http.Header, requestID string) (*http.Response, error) {
+	return c.doWithRetry(ctx, func() (*http.Response, error) {
+		// Get valid Copilot token
+		token, err := c.getCopilotToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := c.newChatRequest(ctx, token, requestID, chatReq, passthrough)
+		if err != nil {
+			return nil, err
+		}
+		if !c.cfg.CompressRequests || c.noCompression.Load() {
+			return c.do(c.chatClient, req, requestID)
+		}
+
+		compressed, err := compressRequestBody(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress request: %w", err)
+		}
+		resp, err := c.do(c.chatClient, req, requestID)
+		if err != nil || !compressed || resp.StatusCode != http.StatusUnsupportedMediaType {
+			return resp, err
+		}
+
+		_ = resp.Body.Close()
+		c.noCompression.Store(true)
+		c.logger.Warn("copilot rejected gzip request body, disabling request compression")
+		if req, err = c.newChatRequest(ctx, token, requestID, chatReq, passthrough); err != nil {
+			return nil, err
+		}
+		return c.do(c.chatClient, req, requestID)
+	})
+}
+
+// newChatRequest builds the HTTP request for chatReq, enforcing
+// MaxRequestBodyBytes on the uncompressed body.
+func (c *Client) newChatRequest(ctx context.Context, token, requestID string, chatReq *api.ChatCompletionRequest, passthrough http.Header) (*http.Request, error) {
+	req, err := api.ToHTTPRequest(ctx, chatReq, c.cfg.ChatURL, c.chatHeaders(token, requestID, chatReq))
 	if err != nil {
 		return nil, err
 	}
+	httputil.InjectTraceContext(req, httputil.TraceContextFrom(ctx))
+	if limit := c.cfg.MaxRequestBodyBytes; limit > 0 && req.ContentLength > limit {
+		return nil, api.NewUpstreamError(http.StatusRequestEntityTooLarge, fmt.Sprintf(
+			"request body of %d bytes exceeds the Copilot limit of %d bytes (%s)",
+			req.ContentLength, limit, EnvMaxRequestBytes))
+	}
+	c.addPassthroughHeaders(req.Header, passthrough)
+	return req, nil
+}
 
-	// Set required headers
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-	if chatReq.Stream {
-		req.Header.Set("Accept", "text/event-stream")
-	} else {
-		req.Header.Set("Accept", "application/json")
+// track counts a new chat request, failing once Shutdown has been called.
+func (c *Client) track() error {
+	c.shutdownMu.RLock()
+	defer c.shutdownMu.RUnlock()
+	if c.shuttingDown {
+		return &provider.LocalError{Err: api.NewUpstreamError(http.StatusServiceUnavailable, "Copilot client is shutting down")}
 	}
-	req.Header.Set("User-Agent", httputil.BuildUserAgent("GitHubCopilotChat", "0.26.7"))
-	req.Header.Set("Editor-Version", EditorVersion)
-	req.Header.Set("Editor-Plugin-Version", EditorPluginVersion)
-	req.Header.Set("Copilot-Integration-Id", CopilotIntegrationID)
-	req.Header.Set("X-GitHub-API-Version", GitHubAPIVersion)
-	req.Header.Set("X-Request-Id", uuid.New().String())
+	c.active.Add(1)
+	return nil
+}
 
-	// X-Initiator: "user" for first turn, "agent" for follow-ups (matches VS Code behavior)
-	req.Header.Set("X-Initiator", getInitiator(chatReq.Messages))
-	// Openai-Intent: "conversation-panel" for full OpenAI API capabilities
-	req.Header.Set("Openai-Intent", "conversation-panel")
+// Shutdown rejects new chat requests and waits until the response bodies
+// of those in flight are closed, or ctx ends.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.shutdownMu.Lock()
+	c.shuttingDown = true
+	c.shutdownMu.Unlock()
 
-	// Set Copilot-Vision-Request header if any message contains images (required by Copilot)
-	if hasImageContent(chatReq.Messages) {
-		req.Header.Set("Copilot-Vision-Request", "true")
+	done := make(chan struct{})
+	go func() {
+		c.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		select {
+		case <-done:
+			return nil
+		default:
+			return ctx.Err()
+		}
 	}
+}
 
-	// Send request
-	resp, err := c.httpClient.Do(req)
+// chatTimeout returns the configured timeout of a chat request.
+func (c *Client) chatTimeout(stream bool) time.Duration {
+	if stream {
+		return c.cfg.StreamTimeout
+	}
+	return c.cfg.RequestTimeout
+}
+
+// withTimeout is context.WithTimeout, except that d <= 0 sets no deadline.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// trackedBody marks its request as finished when closed.
+type trackedBody struct {
+	io.ReadCloser
+	done func()
+}
+
+func (b *trackedBody) Close() error {
+	defer b.done()
+	return b.ReadCloser.Close()
+}
+
+// SendEmbeddingsRequest sends an embeddings request to the Copilot API,
+// retrying transient failures like SendRequest.
+func (c *Client) SendEmbeddingsRequest(ctx context.Context, embReq *api.EmbeddingsRequest) (*http.Response, error) {
+	body, err := json.Marshal(embReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	requestID := uuid.New().String()
+
+	return c.doWithRetry(ctx, func() (*http.Response, error) {
+		token, err := c.getCopilotToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.EmbeddingsURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		for name, value := range c.baseHeaders(token, requestID) {
+			req.Header.Set(name, value)
+		}
+		return c.do(c.httpClient, req, requestID)
+	})
+}
 
+// do sends req with client, logging the exchange at debug level.
+func (c *Client) do(client *http.Client, req *http.Request, requestID string) (*http.Response, error) {
+	c.logger.Debug("sending copilot request",
+		"request_id", requestID,
+		"method", req.Method,
+		"url", req.URL.Redacted(),
+		headersAttr(req.Header),
+	)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		c.logger.Debug("copilot request failed", "request_id", requestID, "duration", time.Since(start), "error", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	// Copilot usually echoes X-Request-Id; logging both shows when it
+	// assigned its own
+	c.logger.Debug("copilot response received",
+		"request_id", requestID,
+		"upstream_request_id", resp.Header.Get("X-Request-Id"),
+		"status", resp.StatusCode,
+		"duration", time.Since(start),
+	)
 	return resp, nil
 }
 
-// hasImageContent checks if any message contains image content.
-func hasImageContent(messages []api.Message) bool {
+// baseHeaders returns the headers Copilot expects on every API request.
+func (c *Client) baseHeaders(token, requestID string) map[string]string {
+	return map[string]string{
+		"Authorization":          "Bearer " + token,
+		"User-Agent":             httputil.BuildUserAgent("GitHubCopilotChat", "0.26.7"),
+		"Editor-Version":         EditorVersion,
+		"Editor-Plugin-Version":  EditorPluginVersion,
+		"Copilot-Integration-Id": CopilotIntegrationID,
+		"X-GitHub-API-Version":   GitHubAPIVersion,
+		"X-Request-Id":           requestID,
+	}
+}
+
+// chatHeaders returns the Copilot-specific headers of a chat completion
+// request.
+func (c *Client) chatHeaders(token, requestID string, chatReq *api.ChatCompletionRequest) map[string]string {
+	headers := c.baseHeaders(token, requestID)
+	// "user" for first turn, "agent" for follow-ups (matches VS Code behavior)
+	headers["X-Initiator"] = getInitiator(chatReq.Messages, c.cfg.ForceInitiator)
+	// "conversation-panel" for full OpenAI API capabilities
+	headers["Openai-Intent"] = "conversation-panel"
+
+	// Flag media requests: Copilot requires Copilot-Vision-Request for images
+	hasImage, hasAudio := hasMediaContent(chatReq.Messages)
+	if hasImage {
+		headers["Copilot-Vision-Request"] = "true"
+	}
+	if hasAudio {
+		headers["Copilot-Audio-Request"] = "true"
+	}
+	return headers
+}
+
+// protectedHeaders are never taken from passthrough headers, in addition
+// to the headers the client sets itself.
+var protectedHeaders = append([]string{"Host", "Connection", "Content-Length", "Transfer-Encoding"}, sensitiveHeaders...)
+
+// addPassthroughHeaders adds passthrough to dst, skipping headers already
+// set and protected ones.
+func (c *Client) addPassthroughHeaders(dst, passthrough http.Header) {
+	for name, values := range passthrough {
+		name = http.CanonicalHeaderKey(name)
+		if dst.Get(name) != "" || slices.Contains(protectedHeaders, name) {
+			c.logger.Debug("not forwarding protected header", "header", name)
+			continue
+		}
+		for _, value := range values {
+			dst.Add(name, value)
+		}
+	}
+}
+
+// hasMediaContent reports whether any message contains image or audio content.
+func hasMediaContent(messages []api.Message) (image, audio bool) {
 	for _, msg := range messages {
 		for _, part := range msg.GetContentParts() {
-			if part.Type == "image_url" || part.Type == "image" {
-				return true
+			switch part.Type {
+			case "image_url", "image":
+				image = true
+			case "input_audio":
+				audio = true
 			}
 		}
 	}
-	return false
+	return image, audio
 }
 
 // getInitiator returns "user" for first turn or "agent" for follow-up turns.
 // Matches VS Code behavior: "user" when no assistant/tool messages exist yet.
-func getInitiator(messages []api.Message) string {
+// A non-empty force value is returned as-is regardless of message history.
+func getInitiator(messages []api.Message, force string) string {
+	if force != "" {
+		return force
+	}
 	for _, msg := range messages {
 		if msg.Role == "assistant" || msg.Role == "tool" {
 			return "agent"
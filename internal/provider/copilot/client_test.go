@@ -0,0 +1,496 @@
+package copilot
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/testutil"
+)
+
+func TestGetInitiator(t *testing.T) {
+	firstTurn := []api.Message{api.SystemMessage("be brief"), api.UserMessage("hello")}
+	followUp := []api.Message{api.UserMessage("hello"), api.AssistantMessage("hi"), api.UserMessage("more")}
+	toolResult := []api.Message{api.UserMessage("weather?"), {Role: "tool", ToolCallID: "call_1"}}
+
+	tests := []struct {
+		name     string
+		env      string // EnvForceInitiator
+		messages []api.Message
+		want     string
+	}{
+		{name: "no assistant messages", messages: firstTurn, want: "user"},
+		{name: "has assistant messages", messages: followUp, want: "agent"},
+		{name: "has tool messages", messages: toolResult, want: "agent"},
+		{name: "force user", env: "user", messages: followUp, want: "user"},
+		{name: "force agent", env: "agent", messages: firstTurn, want: "agent"},
+		{name: "invalid override ignored", env: "robot", messages: firstTurn, want: "user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(map[string]string{EnvForceInitiator: tt.env})
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if got := getInitiator(tt.messages, cfg.ForceInitiator); got != tt.want {
+				t.Errorf("getInitiator() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendRequestHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		messages      []api.Message
+		passthrough   http.Header
+		wantInitiator string
+	}{
+		{name: "first turn", messages: []api.Message{api.UserMessage("hello")}, wantInitiator: "user"},
+		{name: "follow-up", messages: []api.Message{api.UserMessage("hello"), api.AssistantMessage("hi"), api.UserMessage("more")}, wantInitiator: "agent"},
+		{
+			name:          "passthrough cannot override credentials",
+			messages:      []api.Message{api.UserMessage("hello")},
+			passthrough:   http.Header{"Authorization": {"Bearer client-key"}, "X-Custom": {"kept"}},
+			wantInitiator: "user",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, completionJSON)
+			p := newTestProvider(t, m, nil)
+
+			resp, err := p.client.SendRequest(context.Background(), &api.ChatCompletionRequest{Model: "gpt-4o", Messages: tt.messages}, tt.passthrough)
+			if err != nil {
+				t.Fatalf("SendRequest() error = %v", err)
+			}
+			_ = resp.Body.Close()
+
+			m.AssertMethod(http.MethodPost).
+				AssertURL("/chat/completions").
+				AssertHeader("Authorization", "Bearer test-token").
+				AssertHeader("Copilot-Integration-Id", CopilotIntegrationID).
+				AssertHeader("Editor-Version", EditorVersion).
+				AssertHeader("Editor-Plugin-Version", EditorPluginVersion).
+				AssertHeader("X-GitHub-Api-Version", GitHubAPIVersion).
+				AssertHeader("Openai-Intent", "conversation-panel").
+				AssertHeader("X-Initiator", tt.wantInitiator).
+				AssertHeader("Content-Type", "application/json").
+				AssertBody("model", "gpt-4o")
+			if m.Last().Header.Get("X-Request-Id") == "" {
+				t.Error("X-Request-Id missing")
+			}
+			if tt.passthrough != nil {
+				m.AssertHeader("X-Custom", "kept")
+			}
+		})
+	}
+}
+
+func TestNewChatRequest(t *testing.T) {
+	cfg, err := LoadConfig(map[string]string{EnvChatURL: "https://copilot.test/chat/completions"})
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	c := NewClient(nil, cfg, RetryConfig{MaxAttempts: 1})
+
+	chatReq := &api.ChatCompletionRequest{Model: "gpt-4o", Messages: []api.Message{api.UserMessage("hello")}, Stream: true}
+	req, err := c.newChatRequest(context.Background(), "tok", "req-1", chatReq, http.Header{"X-Custom": {"kept"}, "Host": {"evil.test"}})
+	if err != nil {
+		t.Fatalf("newChatRequest() error = %v", err)
+	}
+
+	if req.Method != http.MethodPost || req.URL.String() != "https://copilot.test/chat/completions" {
+		t.Errorf("request = %s %s, want POST to the chat URL", req.Method, req.URL)
+	}
+	for name, want := range map[string]string{
+		"Authorization":          "Bearer tok",
+		"X-Request-Id":           "req-1",
+		"Content-Type":           "application/json",
+		"Accept":                 "text/event-stream",
+		"Copilot-Integration-Id": CopilotIntegrationID,
+		"X-Initiator":            "user",
+		"Openai-Intent":          "conversation-panel",
+		"X-Custom":               "kept",
+		"Host":                   "",
+	} {
+		if got := req.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := json.Marshal(chatReq); string(body) != string(want) {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
+func TestNewChatRequestBodyLimit(t *testing.T) {
+	cfg, err := LoadConfig(map[string]string{EnvMaxRequestBytes: "64"})
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	c := NewClient(nil, cfg, RetryConfig{MaxAttempts: 1})
+
+	chatReq := &api.ChatCompletionRequest{Model: "gpt-4o", Messages: []api.Message{api.UserMessage(strings.Repeat("a", 100))}}
+	_, err = c.newChatRequest(context.Background(), "tok", "req-1", chatReq, nil)
+	var upstreamErr *api.UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("newChatRequest() error = %v, want a 413 UpstreamError", err)
+	}
+}
+
+func TestSendRequestMediaHeaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		fixture    string // request in testdata; empty for a text-only request
+		wantVision bool
+		wantAudio  bool
+	}{
+		{name: "text only"},
+		{name: "image", fixture: "image_url.json", wantVision: true},
+		{name: "audio", fixture: "input_audio.json", wantAudio: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &api.ChatCompletionRequest{Model: "gpt-4o", Messages: []api.Message{api.UserMessage("hello")}}
+			if tt.fixture != "" {
+				data, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req = &api.ChatCompletionRequest{}
+				if err := json.Unmarshal(data, req); err != nil {
+					t.Fatalf("Unmarshal(%s) error = %v", tt.fixture, err)
+				}
+			}
+
+			m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, completionJSON)
+			p := newTestProvider(t, m, nil)
+			resp, err := p.client.SendRequest(context.Background(), req, nil)
+			if err != nil {
+				t.Fatalf("SendRequest() error = %v", err)
+			}
+			_ = resp.Body.Close()
+
+			header := m.Last().Header
+			if got := header.Get("Copilot-Vision-Request") == "true"; got != tt.wantVision {
+				t.Errorf("Copilot-Vision-Request = %q, want set %v", header.Get("Copilot-Vision-Request"), tt.wantVision)
+			}
+			if got := header.Get("Copilot-Audio-Request") == "true"; got != tt.wantAudio {
+				t.Errorf("Copilot-Audio-Request = %q, want set %v", header.Get("Copilot-Audio-Request"), tt.wantAudio)
+			}
+			if tt.wantAudio {
+				// The audio part is forwarded unchanged
+				m.AssertBody("messages.0.content.1.type", "input_audio").
+					AssertBody("messages.0.content.1.input_audio.format", "wav").
+					AssertBody("messages.0.content.1.input_audio.data", req.Messages[0].GetContentParts()[1].InputAudio.Data)
+			}
+		})
+	}
+}
+
+func TestHasMediaContent(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "input_audio.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var audioReq api.ChatCompletionRequest
+	if err := json.Unmarshal(data, &audioReq); err != nil {
+		t.Fatal(err)
+	}
+	parts := audioReq.Messages[0].GetContentParts()
+	if len(parts) != 2 || parts[1].InputAudio == nil || parts[1].InputAudio.Format != "wav" {
+		t.Fatalf("content parts = %+v, want text and wav input_audio", parts)
+	}
+
+	image := api.Message{Role: "user"}
+	image.SetContentParts([]api.ContentPart{{Type: "image_url", ImageURL: &api.ImageURL{URL: "https://example.com/cat.png"}}})
+
+	tests := []struct {
+		name      string
+		messages  []api.Message
+		wantImage bool
+		wantAudio bool
+	}{
+		{name: "text", messages: []api.Message{api.UserMessage("hello")}},
+		{name: "image", messages: []api.Message{image}, wantImage: true},
+		{name: "audio", messages: audioReq.Messages, wantAudio: true},
+		{name: "image and audio", messages: append([]api.Message{image}, audioReq.Messages...), wantImage: true, wantAudio: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotImage, gotAudio := hasMediaContent(tt.messages)
+			if gotImage != tt.wantImage || gotAudio != tt.wantAudio {
+				t.Errorf("hasMediaContent() = (%v, %v), want (%v, %v)", gotImage, gotAudio, tt.wantImage, tt.wantAudio)
+			}
+		})
+	}
+}
+
+func TestCertPin(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	fingerprint := sha256.Sum256(srv.Certificate().Raw)
+	otherFingerprint := sha256.Sum256([]byte("another certificate"))
+
+	tests := []struct {
+		name    string
+		pin     []byte
+		wantErr string
+	}{
+		{name: "matching pin", pin: fingerprint[:]},
+		{name: "mismatched pin", pin: otherFingerprint[:], wantErr: "certificate pin mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(map[string]string{
+				EnvChatURL: "https://example.com/chat/completions",
+				EnvCertPin: base64.StdEncoding.EncodeToString(tt.pin),
+			})
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			// Trust the self-signed certificate, so only the pin decides
+			transport := trustTestServer(newTransport(cfg), srv)
+
+			resp, err := (&http.Client{Transport: transport}).Get("https://example.com/")
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("request failed: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCertPinIgnoresOtherHosts(t *testing.T) {
+	verify := verifyCertPin("api.githubcopilot.com", make([]byte, sha256.Size))
+	if err := verify(tls.ConnectionState{ServerName: "api.github.com"}); err != nil {
+		t.Errorf("verify() for another host = %v, want nil", err)
+	}
+	if err := verify(tls.ConnectionState{ServerName: "api.githubcopilot.com"}); err == nil {
+		t.Error("verify() without a certificate succeeded, want an error")
+	}
+}
+
+func TestInvalidCertPin(t *testing.T) {
+	if _, err := LoadConfig(map[string]string{EnvCertPin: "not-base64!"}); err == nil {
+		t.Error("LoadConfig() accepted an invalid pin")
+	}
+}
+
+// trustTestServer makes transport trust the certificate of srv and dial it
+// for every host. The certificate is for example.com; IP hosts send no
+// server name.
+func trustTestServer(rt http.RoundTripper, srv *httptest.Server) *http.Transport {
+	transport := rt.(*http.Transport)
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	transport.TLSClientConfig.RootCAs = roots
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	return transport
+}
+
+// newStreamingServer starts a TLS server offering HTTP/2 that streams events
+// chunks to every request.
+func newStreamingServer(t testing.TB, events int) *httptest.Server {
+	t.Helper()
+	chunk := []byte("data: " + completionJSON + "\n\n")
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for range events {
+			_, _ = w.Write(chunk)
+		}
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newProtocolClient returns a client for srv using the Copilot transport,
+// with HTTP/2 on or off.
+func newProtocolClient(t testing.TB, srv *httptest.Server, http2 bool) *http.Client {
+	t.Helper()
+	cfg, err := LoadConfig(map[string]string{EnvHTTP2: strconv.FormatBool(http2)})
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	return &http.Client{Transport: trustTestServer(newTransport(cfg), srv)}
+}
+
+func TestHTTP2Setting(t *testing.T) {
+	srv := newStreamingServer(t, 1)
+	for _, tt := range []struct {
+		http2     bool
+		wantMajor int
+	}{
+		{http2: true, wantMajor: 2},
+		{http2: false, wantMajor: 1},
+	} {
+		t.Run(fmt.Sprintf("http2=%v", tt.http2), func(t *testing.T) {
+			client := newProtocolClient(t, srv, tt.http2)
+			resp, err := client.Get("https://example.com/")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			if resp.ProtoMajor != tt.wantMajor {
+				t.Errorf("protocol = %s, want HTTP/%d", resp.Proto, tt.wantMajor)
+			}
+		})
+	}
+}
+
+func BenchmarkStreamingProtocol(b *testing.B) {
+	const events = 1000
+	srv := newStreamingServer(b, events)
+	for _, bb := range []struct {
+		name  string
+		http2 bool
+	}{
+		{name: "HTTP1.1", http2: false},
+		{name: "HTTP2", http2: true},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			client := newProtocolClient(b, srv, bb.http2)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for b.Loop() {
+				resp, err := client.Get("https://example.com/")
+				if err != nil {
+					b.Fatalf("Get() error = %v", err)
+				}
+				n, err := io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+				if err != nil {
+					b.Fatalf("reading stream: %v", err)
+				}
+				b.SetBytes(n)
+			}
+		})
+	}
+}
+
+// slowChatHandler sends the response headers after headerDelay and a first
+// chunk, then holds the body open until the request is cancelled or
+// release is called.
+func slowChatHandler(headerDelay time.Duration) (handler http.Handler, release func()) {
+	done := make(chan struct{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(headerDelay):
+		case <-r.Context().Done():
+			return
+		case <-done:
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {}\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}), sync.OnceFunc(func() { close(done) })
+}
+
+func TestSendRequestTimeouts(t *testing.T) {
+	handler, release := slowChatHandler(100 * time.Millisecond)
+	p := newTestProvider(t, handler, map[string]string{
+		EnvRequestTimeout: "50ms",
+		EnvStreamTimeout:  "300ms",
+	})
+	t.Cleanup(release)
+	if p.client.chatClient.Timeout != 0 {
+		t.Errorf("chat http.Client Timeout = %s, want none", p.client.chatClient.Timeout)
+	}
+
+	send := func(ctx context.Context, stream bool) (time.Duration, error) {
+		start := time.Now()
+		resp, err := p.client.SendRequest(ctx, &api.ChatCompletionRequest{Model: "gpt-4o", Stream: stream}, nil)
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+		}
+		return time.Since(start), err
+	}
+
+	t.Run("request timeout", func(t *testing.T) {
+		elapsed, err := send(context.Background(), false)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("SendRequest() error = %v, want deadline exceeded", err)
+		}
+		if elapsed >= 100*time.Millisecond {
+			t.Errorf("SendRequest() took %s, want the 50ms request timeout", elapsed)
+		}
+	})
+
+	t.Run("stream timeout", func(t *testing.T) {
+		// The headers arrive within the stream timeout; the body read
+		// then runs into it
+		elapsed, err := send(context.Background(), true)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("reading the stream error = %v, want deadline exceeded", err)
+		}
+		if elapsed < 300*time.Millisecond || elapsed > 2*time.Second {
+			t.Errorf("stream ended after %s, want the 300ms stream timeout", elapsed)
+		}
+	})
+
+	t.Run("caller deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		elapsed, err := send(ctx, true)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("SendRequest() error = %v, want deadline exceeded", err)
+		}
+		if elapsed >= 100*time.Millisecond {
+			t.Errorf("SendRequest() took %s, want the caller's 20ms deadline", elapsed)
+		}
+	})
+}
+
+func TestSendRequestNoTimeout(t *testing.T) {
+	handler, release := slowChatHandler(50 * time.Millisecond)
+	p := newTestProvider(t, handler, map[string]string{EnvRequestTimeout: "0"})
+	t.Cleanup(release)
+
+	resp, err := p.client.SendRequest(context.Background(), &api.ChatCompletionRequest{Model: "gpt-4o"}, nil)
+	if err != nil {
+		t.Fatalf("SendRequest() without a timeout error = %v", err)
+	}
+	_ = resp.Body.Close()
+}
@@ -1,10 +1,19 @@
 package copilot
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/provider/openaicompat"
 )
 
 // Provider identification
@@ -12,12 +21,94 @@ const ProviderID = "copilot"
 
 // Environment variable names for Copilot provider
 const (
-	EnvModelsRefresh = "OPENCOMPAT_COPILOT_MODELS_REFRESH"
+	EnvModelsRefresh     = "OPENCOMPAT_COPILOT_MODELS_REFRESH"
+	EnvForceInitiator    = "OPENCOMPAT_COPILOT_FORCE_INITIATOR"
+	EnvExtraModelIDs     = "OPENCOMPAT_COPILOT_EXTRA_MODEL_IDS"
+	EnvProxy             = "OPENCOMPAT_COPILOT_PROXY"
+	EnvNoProxy           = "OPENCOMPAT_COPILOT_NO_PROXY"
+	EnvCertPin           = "OPENCOMPAT_COPILOT_UPSTREAM_CERT_PIN"
+	EnvMaxConcurrent     = "OPENCOMPAT_COPILOT_MAX_CONCURRENT_REQUESTS"
+	EnvQueueOnLimit      = "OPENCOMPAT_COPILOT_QUEUE_ON_LIMIT"
+	EnvNSupport          = "OPENCOMPAT_COPILOT_N_SUPPORT"
+	EnvSystemMessages    = "OPENCOMPAT_COPILOT_SYSTEM_MESSAGES"
+	EnvMaxRequestBytes   = "OPENCOMPAT_COPILOT_MAX_REQUEST_BODY_BYTES"
+	EnvMaxResponseBytes  = "OPENCOMPAT_COPILOT_MAX_RESPONSE_BODY_BYTES"
+	EnvMaxMalformed      = "OPENCOMPAT_COPILOT_MAX_MALFORMED_EVENTS"
+	EnvCompressRequests  = "OPENCOMPAT_COPILOT_COMPRESS_REQUESTS"
+	EnvHTTP2             = "OPENCOMPAT_COPILOT_HTTP2"
+	EnvDeduplicate       = "OPENCOMPAT_COPILOT_DEDUPLICATE_REQUESTS"
+	EnvCacheSize         = "OPENCOMPAT_COPILOT_CACHE_SIZE"
+	EnvCacheTTL          = "OPENCOMPAT_COPILOT_CACHE_TTL"
+	EnvRateLimitRPM      = "OPENCOMPAT_COPILOT_RATE_LIMIT_RPM"
+	EnvRateLimitTPM      = "OPENCOMPAT_COPILOT_RATE_LIMIT_TPM"
+	EnvAutoTruncate      = "OPENCOMPAT_COPILOT_AUTO_TRUNCATE"
+	EnvTruncateThreshold = "OPENCOMPAT_COPILOT_TRUNCATE_THRESHOLD"
+	EnvAuditLog          = "OPENCOMPAT_COPILOT_AUDIT_LOG"
+	EnvAuditScrubPII     = "OPENCOMPAT_COPILOT_AUDIT_SCRUB_PII"
+	EnvRedirectMax       = "OPENCOMPAT_COPILOT_REDIRECT_MAX"
+	EnvRedirectDowngrade = "OPENCOMPAT_COPILOT_REDIRECT_ALLOW_DOWNGRADE"
+	EnvRetryMaxAttempts  = "OPENCOMPAT_COPILOT_RETRY_MAX_ATTEMPTS"
+	EnvRetryInitialDelay = "OPENCOMPAT_COPILOT_RETRY_INITIAL_DELAY"
+	EnvRetryMaxDelay     = "OPENCOMPAT_COPILOT_RETRY_MAX_DELAY"
+	EnvRetryMultiplier   = "OPENCOMPAT_COPILOT_RETRY_MULTIPLIER"
+	EnvAdaptiveTimeout   = "OPENCOMPAT_COPILOT_ADAPTIVE_TIMEOUT_MIN"
+	EnvRequestTimeout    = "OPENCOMPAT_COPILOT_REQUEST_TIMEOUT"
+	EnvStreamTimeout     = "OPENCOMPAT_COPILOT_STREAM_TIMEOUT"
+	EnvTokenURL          = "OPENCOMPAT_COPILOT_TOKEN_URL"
+	EnvChatURL           = "OPENCOMPAT_COPILOT_CHAT_URL"
+	EnvModelsURL         = "OPENCOMPAT_COPILOT_MODELS_URL"
+	EnvEmbeddingsURL     = "OPENCOMPAT_COPILOT_EMBEDDINGS_URL"
+	EnvTokenCacheFile    = "OPENCOMPAT_COPILOT_TOKEN_CACHE_FILE"
+	EnvTokenExpiryBuffer = "OPENCOMPAT_COPILOT_TOKEN_EXPIRY_BUFFER"
 )
 
 // Default values
 const (
 	DefaultModelsRefresh = 24 * 60 // 24 hours in minutes
+	DefaultMaxConcurrent = 10
+	DefaultRedirectMax   = 3
+
+	DefaultMaxRequestBodyBytes  = 8 << 20  // 8 MiB
+	DefaultMaxResponseBodyBytes = 32 << 20 // 32 MiB
+
+	DefaultRetryMaxAttempts  = 3
+	DefaultRetryInitialDelay = 500 * time.Millisecond
+	DefaultRetryMaxDelay     = 10 * time.Second
+	DefaultRetryMultiplier   = 2.0
+
+	DefaultAdaptiveTimeoutMin time.Duration = 0 // opt-in
+
+	DefaultRequestTimeout = 30 * time.Second
+	DefaultStreamTimeout  = 5 * time.Minute
+
+	DefaultTokenExpiryBuffer = 60 * time.Second
+
+	DefaultCacheTTL = 10 * time.Minute
+
+	DefaultTruncateThreshold = 0.9
+
+	// HTTP/2 health checks: a connection that receives no frame for
+	// http2PingInterval is pinged, and closed if the ping goes unanswered
+	// for http2PingTimeout.
+	http2PingInterval = 30 * time.Second
+	http2PingTimeout  = 15 * time.Second
+
+	// copilotTokenLifetime is the typical lifetime of a Copilot API token;
+	// a larger expiry buffer would refresh the token on every request.
+	copilotTokenLifetime = 30 * time.Minute
+)
+
+// Handling of requests with n > 1, which Copilot doesn't support natively
+const (
+	NSupportReject = "reject" // fail with 400
+	NSupportFanout = "fanout" // send one upstream request per completion
+)
+
+// Handling of system messages sent to Copilot
+const (
+	SystemMessagesPassthrough = "passthrough"          // send as-is, retrying as assistant on a 400
+	SystemMessagesAssistant   = "convert_to_assistant" // rewrite the role to assistant
+	SystemMessagesPrefix      = "inject_prefix"        // prepend the content to the first user message
 )
 
 // OAuth Device Flow configuration for GitHub
@@ -28,11 +119,14 @@ const (
 	GitHubScopes         = "read:user"
 )
 
-// Copilot API configuration
+// Copilot API configuration. Enterprise deployments override the URLs
+// through Config.
 const (
 	CopilotTokenURL = "https://api.github.com/copilot_internal/v2/token"
 	CopilotBaseURL  = "https://api.githubcopilot.com"
 	CopilotChatURL  = CopilotBaseURL + "/chat/completions"
+
+	CopilotEmbeddingsURL = CopilotBaseURL + "/embeddings"
 )
 
 // Required headers for Copilot API
@@ -46,14 +140,239 @@ const (
 
 // Config holds Copilot-specific configuration.
 type Config struct {
-	ModelsRefresh int // refresh interval in minutes
+	ModelsRefresh  int      // refresh interval in minutes
+	ForceInitiator string   // "user", "agent", or empty to derive from message history
+	ExtraModelIDs  []string // model IDs exposed in addition to the upstream catalog
+	ProxyURL       *url.URL // http, https or socks5 proxy for Copilot traffic; nil for direct
+	NoProxy        []string // hostnames (and their subdomains) that bypass the proxy
+	CertPin        []byte   // SHA-256 fingerprint of the expected Copilot API leaf certificate; nil disables pinning
+	MaxConcurrent  int      // maximum requests in flight; 0 for unlimited
+	QueueOnLimit   bool     // wait for a free slot at the limit instead of failing with 503
+	NSupport       string   // NSupportReject or NSupportFanout
+	SystemMessages string   // SystemMessagesPassthrough, SystemMessagesAssistant or SystemMessagesPrefix
+	RedirectMax    int      // maximum redirects followed per request
+	AllowDowngrade bool     // follow redirects from https to plain http
+
+	// Body size limits in bytes; 0 for unlimited. Requests over the limit
+	// fail with 413 before being sent, and responses over it fail the
+	// stream with openaicompat.ErrResponseTooLarge.
+	MaxRequestBodyBytes  int64
+	MaxResponseBodyBytes int64
+
+	// MaxMalformedEvents fails a stream with provider.ErrStreamCorrupted
+	// after this many consecutive undecodable events; 0 only logs them.
+	MaxMalformedEvents int
+
+	// CompressRequests gzips chat request bodies when that makes them
+	// smaller. Compression is turned off if Copilot answers 415.
+	CompressRequests bool
+
+	// HTTP2 negotiates HTTP/2 with health-check pings on idle
+	// connections; false forces HTTP/1.1.
+	HTTP2 bool
+
+	// Deduplication collapses identical concurrent non-streaming requests
+	// into a single upstream call whose response every caller receives.
+	Deduplication bool
+
+	// Response cache for non-streaming requests with temperature 0:
+	// CacheSize entries (0 disables the cache), each kept for CacheTTL.
+	CacheSize int
+	CacheTTL  time.Duration
+
+	// Client-side rate limits in requests and estimated prompt tokens per
+	// minute; 0 leaves a limit off.
+	RateLimitRPM int
+	RateLimitTPM int
+
+	// AutoTruncate drops the oldest conversation turns of requests whose
+	// estimated prompt exceeds TruncateThreshold of the model's context
+	// window.
+	AutoTruncate      bool
+	TruncateThreshold float64
+
+	// AuditLog is the file receiving one JSON audit entry per request
+	// ("" disables auditing); AuditScrubPII masks personal data in the
+	// logged messages.
+	AuditLog      string
+	AuditScrubPII bool
+
+	// AdaptiveTimeoutMin is the lower bound of the adaptive timeout for
+	// chat response headers (3x the observed p99 latency); 0 disables it.
+	AdaptiveTimeoutMin time.Duration
+
+	// RequestTimeout and StreamTimeout bound a chat request, retries and
+	// reading the response included, for non-streaming and streaming
+	// requests respectively; 0 disables the limit.
+	RequestTimeout time.Duration
+	StreamTimeout  time.Duration
+
+	// Endpoints, defaulting to CopilotTokenURL, CopilotChatURL,
+	// CopilotModelsURL and CopilotEmbeddingsURL; Copilot Enterprise may
+	// serve them elsewhere.
+	TokenURL      string
+	ChatURL       string
+	ModelsURL     string
+	EmbeddingsURL string
+
+	// TokenCacheFile stores the Copilot API token as plain JSON at this
+	// path instead of the encrypted file in the data directory.
+	TokenCacheFile string
+
+	// TokenExpiryBuffer treats Copilot API tokens as expired this long
+	// before their actual expiry, to absorb clock skew and slow refreshes.
+	TokenExpiryBuffer time.Duration
 }
 
 // LoadConfig reads Copilot configuration from environment variables.
-func LoadConfig() *Config {
+// Entries in overrides (keyed by environment variable name) take precedence
+// over the environment; overrides may be nil.
+func LoadConfig(overrides map[string]string) (*Config, error) {
+	env := envSource(overrides)
+
+	proxyURL, err := env.getProxyURL(EnvProxy)
+	if err != nil {
+		return nil, err
+	}
+	certPin, err := env.getCertPin(EnvCertPin)
+	if err != nil {
+		return nil, err
+	}
+	nSupport, err := env.getNSupport(EnvNSupport)
+	if err != nil {
+		return nil, err
+	}
+	systemMessages, err := env.getSystemMessages(EnvSystemMessages)
+	if err != nil {
+		return nil, err
+	}
+	adaptiveTimeoutMin, err := env.getDuration(EnvAdaptiveTimeout, DefaultAdaptiveTimeoutMin)
+	if err != nil {
+		return nil, err
+	}
+	requestTimeout, err := env.getDuration(EnvRequestTimeout, DefaultRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	streamTimeout, err := env.getDuration(EnvStreamTimeout, DefaultStreamTimeout)
+	if err != nil {
+		return nil, err
+	}
+	tokenURL, err := env.getURL(EnvTokenURL, CopilotTokenURL)
+	if err != nil {
+		return nil, err
+	}
+	chatURL, err := env.getURL(EnvChatURL, CopilotChatURL)
+	if err != nil {
+		return nil, err
+	}
+	modelsURL, err := env.getURL(EnvModelsURL, CopilotModelsURL)
+	if err != nil {
+		return nil, err
+	}
+	embeddingsURL, err := env.getURL(EnvEmbeddingsURL, CopilotEmbeddingsURL)
+	if err != nil {
+		return nil, err
+	}
+	cacheTTL, err := env.getDuration(EnvCacheTTL, DefaultCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+	truncateThreshold, err := env.getFloat(EnvTruncateThreshold, DefaultTruncateThreshold)
+	if err != nil {
+		return nil, err
+	}
+	if truncateThreshold <= 0 || truncateThreshold > 1 {
+		return nil, fmt.Errorf("invalid %s %v: must be greater than 0 and at most 1", EnvTruncateThreshold, truncateThreshold)
+	}
+	tokenExpiryBuffer, err := env.getDuration(EnvTokenExpiryBuffer, DefaultTokenExpiryBuffer)
+	if err != nil {
+		return nil, err
+	}
+	if tokenExpiryBuffer >= copilotTokenLifetime {
+		return nil, fmt.Errorf("invalid %s %s: must be less than the %s token lifetime",
+			EnvTokenExpiryBuffer, tokenExpiryBuffer, copilotTokenLifetime)
+	}
+
 	return &Config{
-		ModelsRefresh: getEnvInt(EnvModelsRefresh, DefaultModelsRefresh),
+		ModelsRefresh:  env.getInt(EnvModelsRefresh, DefaultModelsRefresh),
+		ForceInitiator: env.getInitiator(EnvForceInitiator),
+		ExtraModelIDs:  env.getStringList(EnvExtraModelIDs),
+		ProxyURL:       proxyURL,
+		NoProxy:        env.getCommaList(EnvNoProxy),
+		CertPin:        certPin,
+		MaxConcurrent:  max(env.getInt(EnvMaxConcurrent, DefaultMaxConcurrent), 0),
+		QueueOnLimit:   env.getBool(EnvQueueOnLimit, true),
+		NSupport:       nSupport,
+		SystemMessages: systemMessages,
+		RedirectMax:    max(env.getInt(EnvRedirectMax, DefaultRedirectMax), 0),
+		AllowDowngrade: env.getBool(EnvRedirectDowngrade, false),
+
+		MaxRequestBodyBytes:  int64(max(env.getInt(EnvMaxRequestBytes, DefaultMaxRequestBodyBytes), 0)),
+		MaxResponseBodyBytes: int64(max(env.getInt(EnvMaxResponseBytes, DefaultMaxResponseBodyBytes), 0)),
+		MaxMalformedEvents:   max(env.getInt(EnvMaxMalformed, openaicompat.DefaultMaxMalformedEvents), 0),
+		CompressRequests:     env.getBool(EnvCompressRequests, false),
+		HTTP2:                env.getBool(EnvHTTP2, true),
+		Deduplication:        env.getBool(EnvDeduplicate, false),
+		CacheSize:            max(env.getInt(EnvCacheSize, 0), 0),
+		CacheTTL:             cacheTTL,
+		RateLimitRPM:         max(env.getInt(EnvRateLimitRPM, 0), 0),
+		RateLimitTPM:         max(env.getInt(EnvRateLimitTPM, 0), 0),
+		AutoTruncate:         env.getBool(EnvAutoTruncate, false),
+		TruncateThreshold:    truncateThreshold,
+		AuditLog:             env.get(EnvAuditLog),
+		AuditScrubPII:        env.getBool(EnvAuditScrubPII, false),
+
+		AdaptiveTimeoutMin: adaptiveTimeoutMin,
+		RequestTimeout:     requestTimeout,
+		StreamTimeout:      streamTimeout,
+
+		TokenURL:      tokenURL,
+		ChatURL:       chatURL,
+		ModelsURL:     modelsURL,
+		EmbeddingsURL: embeddingsURL,
+
+		TokenCacheFile:    env.get(EnvTokenCacheFile),
+		TokenExpiryBuffer: tokenExpiryBuffer,
+	}, nil
+}
+
+// RetryConfig controls retries of chat requests that fail with a transient
+// network error or a 429, 502, 503 or 504 response.
+type RetryConfig struct {
+	MaxAttempts  int           // total attempts including the first; 1 disables retries
+	InitialDelay time.Duration // delay before the first retry
+	MaxDelay     time.Duration // cap on the computed delay (Retry-After may exceed it)
+	Multiplier   float64       // delay growth factor per attempt
+}
+
+// LoadRetryConfig reads retry configuration from environment variables,
+// with overrides taking precedence as in LoadConfig.
+func LoadRetryConfig(overrides map[string]string) (RetryConfig, error) {
+	env := envSource(overrides)
+
+	initialDelay, err := env.getDuration(EnvRetryInitialDelay, DefaultRetryInitialDelay)
+	if err != nil {
+		return RetryConfig{}, err
+	}
+	maxDelay, err := env.getDuration(EnvRetryMaxDelay, DefaultRetryMaxDelay)
+	if err != nil {
+		return RetryConfig{}, err
+	}
+	multiplier, err := env.getFloat(EnvRetryMultiplier, DefaultRetryMultiplier)
+	if err != nil {
+		return RetryConfig{}, err
 	}
+	if multiplier < 1 {
+		return RetryConfig{}, fmt.Errorf("invalid %s %v: must be at least 1", EnvRetryMultiplier, multiplier)
+	}
+
+	return RetryConfig{
+		MaxAttempts:  max(env.getInt(EnvRetryMaxAttempts, DefaultRetryMaxAttempts), 1),
+		InitialDelay: initialDelay,
+		MaxDelay:     max(maxDelay, initialDelay),
+		Multiplier:   multiplier,
+	}, nil
 }
 
 // EnvVarDoc documents an environment variable.
@@ -67,11 +386,59 @@ type EnvVarDoc struct {
 func EnvVarDocs() []EnvVarDoc {
 	return []EnvVarDoc{
 		{Name: EnvModelsRefresh, Description: "Models refresh interval in minutes", Default: strconv.Itoa(DefaultModelsRefresh)},
+		{Name: EnvForceInitiator, Description: "Always send this X-Initiator value (user, agent)", Default: "auto"},
+		{Name: EnvExtraModelIDs, Description: "JSON array of extra model IDs to expose", Default: "none"},
+		{Name: EnvProxy, Description: "Proxy URL for Copilot traffic (http, https, socks5)", Default: "none"},
+		{Name: EnvNoProxy, Description: "Comma-separated hostnames that bypass the proxy", Default: "none"},
+		{Name: EnvCertPin, Description: "Base64 SHA-256 fingerprint of the Copilot API TLS certificate", Default: "none"},
+		{Name: EnvMaxConcurrent, Description: "Maximum concurrent Copilot requests (0 for unlimited)", Default: strconv.Itoa(DefaultMaxConcurrent)},
+		{Name: EnvQueueOnLimit, Description: "Queue requests at the limit instead of returning 503", Default: "true"},
+		{Name: EnvNSupport, Description: "Handling of n > 1 (reject, fanout)", Default: NSupportReject},
+		{Name: EnvSystemMessages, Description: "Handling of system messages (passthrough, convert_to_assistant, inject_prefix)", Default: SystemMessagesPassthrough},
+		{Name: EnvMaxRequestBytes, Description: "Maximum chat request body size in bytes (0 for unlimited)", Default: strconv.Itoa(DefaultMaxRequestBodyBytes)},
+		{Name: EnvMaxResponseBytes, Description: "Maximum chat response body size in bytes (0 for unlimited)", Default: strconv.Itoa(DefaultMaxResponseBodyBytes)},
+		{Name: EnvMaxMalformed, Description: "Consecutive malformed stream events that fail the stream (0 to only log them)", Default: strconv.Itoa(openaicompat.DefaultMaxMalformedEvents)},
+		{Name: EnvCompressRequests, Description: "Gzip chat request bodies", Default: "false"},
+		{Name: EnvHTTP2, Description: "Use HTTP/2 for Copilot connections (false forces HTTP/1.1)", Default: "true"},
+		{Name: EnvDeduplicate, Description: "Share one upstream call among identical concurrent non-streaming requests", Default: "false"},
+		{Name: EnvCacheSize, Description: "Cached responses to temperature 0 non-streaming requests (0 disables)", Default: "0"},
+		{Name: EnvCacheTTL, Description: "Lifetime of cached responses", Default: DefaultCacheTTL.String()},
+		{Name: EnvRateLimitRPM, Description: "Requests per minute sent to Copilot (0 for unlimited)", Default: "0"},
+		{Name: EnvRateLimitTPM, Description: "Estimated prompt tokens per minute sent to Copilot (0 for unlimited)", Default: "0"},
+		{Name: EnvAutoTruncate, Description: "Drop the oldest conversation turns of prompts too large for the model", Default: "false"},
+		{Name: EnvTruncateThreshold, Description: "Fraction of the context window a truncated prompt may use", Default: strconv.FormatFloat(DefaultTruncateThreshold, 'g', -1, 64)},
+		{Name: EnvAuditLog, Description: "File for newline-delimited JSON audit entries of every request", Default: "none"},
+		{Name: EnvAuditScrubPII, Description: "Mask emails, phone, card and social security numbers and IP addresses in audit entries", Default: "false"},
+		{Name: EnvRedirectMax, Description: "Maximum redirects followed per request", Default: strconv.Itoa(DefaultRedirectMax)},
+		{Name: EnvRedirectDowngrade, Description: "Follow redirects from HTTPS to HTTP", Default: "false"},
+		{Name: EnvRetryMaxAttempts, Description: "Attempts per request on transient errors (1 disables retries)", Default: strconv.Itoa(DefaultRetryMaxAttempts)},
+		{Name: EnvRetryInitialDelay, Description: "Delay before the first retry", Default: DefaultRetryInitialDelay.String()},
+		{Name: EnvRetryMaxDelay, Description: "Maximum delay between retries", Default: DefaultRetryMaxDelay.String()},
+		{Name: EnvRetryMultiplier, Description: "Retry delay multiplier", Default: strconv.FormatFloat(DefaultRetryMultiplier, 'g', -1, 64)},
+		{Name: EnvAdaptiveTimeout, Description: "Minimum adaptive timeout for chat response headers (0 disables it)", Default: DefaultAdaptiveTimeoutMin.String()},
+		{Name: EnvRequestTimeout, Description: "Timeout of non-streaming chat requests (0 for none)", Default: DefaultRequestTimeout.String()},
+		{Name: EnvStreamTimeout, Description: "Timeout of streaming chat requests (0 for none)", Default: DefaultStreamTimeout.String()},
+		{Name: EnvTokenURL, Description: "Copilot token exchange URL (Copilot Enterprise)", Default: CopilotTokenURL},
+		{Name: EnvChatURL, Description: "Copilot chat completions URL (Copilot Enterprise)", Default: CopilotChatURL},
+		{Name: EnvModelsURL, Description: "Copilot models URL (Copilot Enterprise)", Default: CopilotModelsURL},
+		{Name: EnvEmbeddingsURL, Description: "Copilot embeddings URL (Copilot Enterprise)", Default: CopilotEmbeddingsURL},
+		{Name: EnvTokenCacheFile, Description: "File caching the Copilot token as JSON (unencrypted)", Default: "encrypted in data dir"},
+		{Name: EnvTokenExpiryBuffer, Description: "Refresh the Copilot token this long before it expires (under 30m)", Default: DefaultTokenExpiryBuffer.String()},
+	}
+}
+
+// envSource resolves configuration values from overrides, then the environment.
+type envSource map[string]string
+
+func (e envSource) get(key string) string {
+	if val, ok := e[key]; ok {
+		return val
 	}
+	return os.Getenv(key)
 }
 
-func getEnvInt(key string, defaultVal int) int {
-	if val := os.Getenv(key); val != "" {
+func (e envSource) getInt(key string, defaultVal int) int {
+	if val := e.get(key); val != "" {
 		if i, err := strconv.Atoi(val); err == nil {
 			return i
 		}
@@ -79,6 +446,152 @@ func getEnvInt(key string, defaultVal int) int {
 	return defaultVal
 }
 
+func (e envSource) getBool(key string, defaultVal bool) bool {
+	if val := e.get(key); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	}
+	return defaultVal
+}
+
+// getDuration reads a Go duration such as "500ms" or "2s".
+func (e envSource) getDuration(key string, defaultVal time.Duration) (time.Duration, error) {
+	val := e.get(key)
+	if val == "" {
+		return defaultVal, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a duration such as 500ms", key, val)
+	}
+	return d, nil
+}
+
+func (e envSource) getFloat(key string, defaultVal float64) (float64, error) {
+	val := e.get(key)
+	if val == "" {
+		return defaultVal, nil
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, val, err)
+	}
+	return f, nil
+}
+
+// getInitiator reads an X-Initiator override, ignoring unknown values.
+func (e envSource) getInitiator(key string) string {
+	val := e.get(key)
+	switch val {
+	case "", "user", "agent":
+		return val
+	default:
+		slog.Warn("ignoring invalid initiator override", "env", key, "value", val)
+		return ""
+	}
+}
+
+// getStringList reads a JSON array of strings, ignoring invalid values.
+func (e envSource) getStringList(key string) []string {
+	val := e.get(key)
+	if val == "" {
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal([]byte(val), &list); err != nil {
+		slog.Warn("ignoring invalid JSON array", "env", key, "error", err)
+		return nil
+	}
+	return list
+}
+
+// getNSupport reads the n > 1 handling mode.
+func (e envSource) getNSupport(key string) (string, error) {
+	switch val := e.get(key); val {
+	case "":
+		return NSupportReject, nil
+	case NSupportReject, NSupportFanout:
+		return val, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q (use %s or %s)", key, val, NSupportReject, NSupportFanout)
+	}
+}
+
+// getSystemMessages reads the system message handling mode.
+func (e envSource) getSystemMessages(key string) (string, error) {
+	switch val := e.get(key); val {
+	case "":
+		return SystemMessagesPassthrough, nil
+	case SystemMessagesPassthrough, SystemMessagesAssistant, SystemMessagesPrefix:
+		return val, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q (use %s, %s or %s)", key, val,
+			SystemMessagesPassthrough, SystemMessagesAssistant, SystemMessagesPrefix)
+	}
+}
+
+// getCommaList reads a comma-separated list, dropping empty entries.
+func (e envSource) getCommaList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(e.get(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getProxyURL reads and validates a proxy URL.
+func (e envSource) getProxyURL(key string) (*url.URL, error) {
+	val := e.get(key)
+	if val == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(val)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid %s: unsupported scheme %q (use http, https or socks5)", key, u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid %s: missing host", key)
+	}
+	return u, nil
+}
+
+// getURL reads an absolute http(s) URL.
+func (e envSource) getURL(key, defaultVal string) (string, error) {
+	val := e.get(key)
+	if val == "" {
+		return defaultVal, nil
+	}
+	u, err := url.Parse(val)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid %s %q: expected an absolute http(s) URL", key, val)
+	}
+	return val, nil
+}
+
+// getCertPin reads a base64-encoded SHA-256 certificate fingerprint.
+func (e envSource) getCertPin(key string) ([]byte, error) {
+	val := e.get(key)
+	if val == "" {
+		return nil, nil
+	}
+	pin, err := base64.StdEncoding.DecodeString(val)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if len(pin) != sha256.Size {
+		return nil, fmt.Errorf("invalid %s: expected a %d-byte SHA-256 fingerprint, got %d bytes", key, sha256.Size, len(pin))
+	}
+	return pin, nil
+}
+
 // GetDeviceFlowConfig returns the device flow configuration for GitHub Copilot.
 func GetDeviceFlowConfig() *auth.DeviceFlowConfig {
 	return &auth.DeviceFlowConfig{
@@ -0,0 +1,75 @@
+package copilot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+func TestLoadConfigOverridesEnv(t *testing.T) {
+	t.Setenv(EnvModelsRefresh, "60")
+	t.Setenv(EnvMaxConcurrent, "4")
+	t.Setenv(EnvRetryMaxAttempts, "5")
+
+	cfg, err := LoadConfig(map[string]string{EnvModelsRefresh: "5"})
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.ModelsRefresh != 5 {
+		t.Errorf("ModelsRefresh = %d, want the override 5", cfg.ModelsRefresh)
+	}
+	if cfg.MaxConcurrent != 4 {
+		t.Errorf("MaxConcurrent = %d, want 4 from the environment", cfg.MaxConcurrent)
+	}
+
+	retry, err := LoadRetryConfig(map[string]string{EnvRetryMaxAttempts: "2"})
+	if err != nil {
+		t.Fatalf("LoadRetryConfig() error = %v", err)
+	}
+	if retry.MaxAttempts != 2 {
+		t.Errorf("MaxAttempts = %d, want the override 2", retry.MaxAttempts)
+	}
+
+	// An empty override still takes precedence and selects the default
+	cfg, err = LoadConfig(map[string]string{EnvModelsRefresh: ""})
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.ModelsRefresh != DefaultModelsRefresh {
+		t.Errorf("ModelsRefresh = %d, want the default %d", cfg.ModelsRefresh, DefaultModelsRefresh)
+	}
+}
+
+func TestLoadConfigInvalidOverride(t *testing.T) {
+	t.Setenv(EnvRequestTimeout, "30s")
+	if _, err := LoadConfig(map[string]string{EnvRequestTimeout: "soon"}); err == nil {
+		t.Error("LoadConfig() error = nil, want an error for an invalid override")
+	}
+}
+
+func TestNewProviderWithOptionsOverridesEnv(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv(EnvModelsRefresh, "60")
+	t.Setenv(EnvRequestTimeout, "45s")
+
+	r := provider.NewRegistry()
+	provider.RegisterAll(r)
+
+	p, err := r.NewProviderWithOptions(ProviderID, map[string]string{EnvModelsRefresh: "5"})
+	if err != nil {
+		t.Fatalf("NewProviderWithOptions() error = %v", err)
+	}
+	cfg := p.(*Provider).cfg
+	if cfg.ModelsRefresh != 5 {
+		t.Errorf("ModelsRefresh = %d, want the override 5", cfg.ModelsRefresh)
+	}
+	if cfg.RequestTimeout != 45*time.Second {
+		t.Errorf("RequestTimeout = %v, want 45s from the environment", cfg.RequestTimeout)
+	}
+
+	if _, err := r.NewProviderWithOptions("not-a-provider", nil); err == nil {
+		t.Error("NewProviderWithOptions(unknown) error = nil, want an error")
+	}
+}
@@ -0,0 +1,97 @@
+package copilot
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// sharedResponse is a fully read upstream response shared by the callers
+// of a deduplicated request.
+type sharedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// response returns a new http.Response over the shared body, so each
+// caller decodes and mutates its own copy.
+func (s *sharedResponse) response() *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", s.status, http.StatusText(s.status)),
+		StatusCode:    s.status,
+		Header:        s.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(s.body)),
+		ContentLength: int64(len(s.body)),
+	}
+}
+
+// sendDeduplicated collapses identical concurrent non-streaming requests
+// into one upstream call made by send. The call runs detached from any
+// single caller's cancellation; each caller still stops waiting when its
+// own context ends.
+func (c *Client) sendDeduplicated(ctx context.Context, chatReq *api.ChatCompletionRequest, passthrough http.Header, send func(context.Context) (*http.Response, error)) (*http.Response, error) {
+	key, err := dedupKey(chatReq, passthrough)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := c.inflight.DoChan(key, func() (any, error) {
+		// The shared call outlives its callers but keeps their time limit
+		shared, cancel := withTimeout(context.WithoutCancel(ctx), c.cfg.RequestTimeout)
+		defer cancel()
+		resp, err := send(shared)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		// One byte past the limit lets the stream report the oversized body
+		body := io.Reader(resp.Body)
+		if limit := c.cfg.MaxResponseBodyBytes; limit > 0 {
+			body = io.LimitReader(resp.Body, limit+1)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		return &sharedResponse{status: resp.StatusCode, header: resp.Header, body: data}, nil
+	})
+
+	select {
+	case res := <-ch:
+		if res.Shared {
+			c.logger.Debug("deduplicated copilot request", "model", chatReq.Model)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*sharedResponse).response(), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// dedupKey hashes everything that is sent upstream for chatReq.
+func dedupKey(chatReq *api.ChatCompletionRequest, passthrough http.Header) (string, error) {
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(body)
+	for _, name := range slices.Sorted(maps.Keys(passthrough)) {
+		h.Write([]byte("\n" + name + ": " + strings.Join(passthrough[name], ", ")))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
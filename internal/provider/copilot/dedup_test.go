@@ -0,0 +1,124 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/testutil"
+)
+
+// sendConcurrently sends n identical requests at once and returns the
+// response bodies and errors.
+func sendConcurrently(t *testing.T, c *Client, n int, stream bool) ([][]byte, []error) {
+	t.Helper()
+	bodies := make([][]byte, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Go(func() {
+			req := &api.ChatCompletionRequest{Model: "gpt-4o", Stream: stream, Messages: []api.Message{api.UserMessage("hello")}}
+			resp, err := c.SendRequest(context.Background(), req, nil)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer func() { _ = resp.Body.Close() }()
+			bodies[i], errs[i] = io.ReadAll(resp.Body)
+		})
+	}
+	wg.Wait()
+	return bodies, errs
+}
+
+// slowly delays handler so concurrent requests overlap.
+func slowly(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func TestDeduplicationSharesOneUpstreamCall(t *testing.T) {
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, completionJSON)
+	p := newTestProvider(t, slowly(m), map[string]string{EnvDeduplicate: "true"})
+
+	bodies, errs := sendConcurrently(t, p.client, 10, false)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		var resp api.ChatCompletionResponse
+		if err := json.Unmarshal(bodies[i], &resp); err != nil || resp.ID != "1" {
+			t.Errorf("request %d: body %q, %v", i, bodies[i], err)
+		}
+	}
+	if got := len(m.Requests()); got != 1 {
+		t.Errorf("upstream calls = %d, want 1", got)
+	}
+}
+
+func TestDeduplicationSharesErrors(t *testing.T) {
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusInternalServerError, `{"error":{"message":"boom"}}`)
+	p := newTestProvider(t, slowly(m), map[string]string{EnvDeduplicate: "true"})
+
+	bodies, errs := sendConcurrently(t, p.client, 5, false)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if string(bodies[i]) != `{"error":{"message":"boom"}}` {
+			t.Errorf("request %d: body %q, want the upstream error", i, bodies[i])
+		}
+	}
+	if got := len(m.Requests()); got != 1 {
+		t.Errorf("upstream calls = %d, want 1", got)
+	}
+}
+
+func TestDeduplicationCopiesResponses(t *testing.T) {
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, completionJSON)
+	p := newTestProvider(t, slowly(m), map[string]string{EnvDeduplicate: "true"})
+
+	bodies, errs := sendConcurrently(t, p.client, 2, false)
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("errors = %v", errs)
+	}
+	// Scribbling over one caller's body must not show in the other's
+	for i := range bodies[0] {
+		bodies[0][i] = 'x'
+	}
+	if string(bodies[1]) != completionJSON {
+		t.Errorf("second body = %q, want the upstream response", bodies[1])
+	}
+}
+
+func TestDeduplicationSkipsStreams(t *testing.T) {
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, "data: [DONE]\n\n")
+	p := newTestProvider(t, slowly(m), map[string]string{EnvDeduplicate: "true"})
+
+	_, errs := sendConcurrently(t, p.client, 3, true)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if got := len(m.Requests()); got != 3 {
+		t.Errorf("upstream calls = %d, want 3", got)
+	}
+}
+
+func TestDeduplicationDisabled(t *testing.T) {
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, completionJSON)
+	p := newTestProvider(t, slowly(m), nil)
+
+	sendConcurrently(t, p.client, 3, false)
+	if got := len(m.Requests()); got != 3 {
+		t.Errorf("upstream calls = %d, want 3", got)
+	}
+}
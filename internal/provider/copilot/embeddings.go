@@ -0,0 +1,55 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// maxEmbeddingsResponseSize bounds the embeddings response read into memory.
+const maxEmbeddingsResponseSize = 64 << 20
+
+// Embeddings proxies req to Copilot's embeddings endpoint. A 404 means the
+// endpoint doesn't exist (e.g. on a Copilot Enterprise host) and is
+// reported as provider.ErrCapabilityNotSupported.
+func (p *Provider) Embeddings(ctx context.Context, req *provider.EmbeddingsRequest) (*api.EmbeddingsResponse, error) {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := p.client.SendEmbeddingsRequest(ctx, &api.EmbeddingsRequest{
+		Model:          req.Model,
+		Input:          req.Input,
+		EncodingFormat: req.EncodingFormat,
+		Dimensions:     req.Dimensions,
+		User:           req.User,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxEmbeddingsResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embeddings response: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: Copilot has no embeddings endpoint at %s", provider.ErrCapabilityNotSupported, p.cfg.EmbeddingsURL)
+	case resp.StatusCode != http.StatusOK:
+		return nil, newUpstreamError(resp.StatusCode, body)
+	}
+
+	var embResp api.EmbeddingsResponse
+	if err := json.Unmarshal(body, &embResp); err != nil {
+		return nil, fmt.Errorf("failed to parse embeddings response: %w", err)
+	}
+	return &embResp, nil
+}
@@ -0,0 +1,149 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// fanOut sends n identical requests concurrently and merges them into one
+// stream whose choice i comes from request i.
+func (p *Provider) fanOut(ctx context.Context, chatReq *api.ChatCompletionRequest, n int, headers http.Header) (provider.Stream, error) {
+	streams := make([]*releasingStream, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Go(func() {
+			streams[i], errs[i] = p.send(ctx, chatReq, headers)
+		})
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		for _, s := range streams {
+			if s != nil {
+				_ = s.Close()
+			}
+		}
+		return nil, err
+	}
+	return &fanoutStream{streams: streams}, nil
+}
+
+// fanoutStream reads its streams one after another, renumbering choices by
+// stream position. Chunks take the ID of the first chunk so clients see a
+// single completion. Usage-only chunks are summed and sent once at the end.
+type fanoutStream struct {
+	streams []*releasingStream
+	current int
+
+	id        string
+	usage     *api.Usage
+	usageSent bool
+	err       error
+}
+
+func (s *fanoutStream) Next() (*api.ChatCompletionChunk, error) {
+	for s.current < len(s.streams) {
+		chunk, err := s.streams[s.current].Next()
+		if err == io.EOF {
+			s.current++
+			continue
+		}
+		if err != nil {
+			s.err = err
+			return nil, err
+		}
+		if chunk == nil {
+			continue
+		}
+
+		if chunk.Usage != nil && len(chunk.Choices) == 0 {
+			s.usage = addUsage(s.usage, chunk.Usage)
+			continue
+		}
+
+		if s.id == "" {
+			s.id = chunk.ID
+		}
+		chunk.ID = s.id
+		for i := range chunk.Choices {
+			chunk.Choices[i].Index += s.current
+		}
+		return chunk, nil
+	}
+
+	if s.usage != nil && !s.usageSent {
+		s.usageSent = true
+		return &api.ChatCompletionChunk{
+			ID:      s.id,
+			Object:  "chat.completion.chunk",
+			Choices: []api.Choice{},
+			Usage:   s.usage,
+		}, nil
+	}
+	return nil, io.EOF
+}
+
+// Response merges the choices and usage of all non-streaming responses.
+func (s *fanoutStream) Response() *api.ChatCompletionResponse {
+	var merged *api.ChatCompletionResponse
+	for i, stream := range s.streams {
+		resp := stream.Response()
+		if resp == nil {
+			continue
+		}
+		if merged == nil {
+			base := *resp
+			base.Choices = nil
+			base.Usage = nil
+			merged = &base
+		}
+		for _, choice := range resp.Choices {
+			choice.Index += i
+			merged.Choices = append(merged.Choices, choice)
+		}
+		if resp.Usage != nil {
+			merged.Usage = addUsage(merged.Usage, resp.Usage)
+		}
+	}
+	return merged
+}
+
+func (s *fanoutStream) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, stream := range s.streams {
+		if err := stream.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fanoutStream) Close() error {
+	var errs []error
+	for _, stream := range s.streams {
+		errs = append(errs, stream.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// addUsage returns the sum of total and u, treating a nil total as zero.
+func addUsage(total, u *api.Usage) *api.Usage {
+	sum := api.Usage{}
+	if total != nil {
+		sum = *total
+	}
+	sum.PromptTokens += u.PromptTokens
+	sum.CompletionTokens += u.CompletionTokens
+	sum.TotalTokens += u.TotalTokens
+	return &sum
+}
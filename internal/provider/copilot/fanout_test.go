@@ -0,0 +1,72 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/testutil"
+)
+
+const usageCompletionJSON = `{"id":"1","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}`
+
+func TestNSupport(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		n         int
+		wantCalls int
+		wantErr   string
+	}{
+		{name: "reject", mode: NSupportReject, n: 3, wantErr: "does not support n > 1"},
+		{name: "fanout", mode: NSupportFanout, n: 3, wantCalls: 3},
+		{name: "n of 1 is sent as is", mode: NSupportReject, n: 1, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, usageCompletionJSON)
+			p := newTestProvider(t, m, map[string]string{EnvNSupport: tt.mode})
+
+			req := &provider.ChatCompletionRequest{Model: "gpt-4o", N: &tt.n, Messages: []api.Message{api.UserMessage("hello")}}
+			stream, err := p.ChatCompletion(context.Background(), req)
+			if tt.wantErr != "" {
+				var upstreamErr *api.UpstreamError
+				if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusBadRequest || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ChatCompletion() error = %v, want a 400 saying %q", err, tt.wantErr)
+				}
+				if got := len(m.Requests()); got != 0 {
+					t.Errorf("upstream calls = %d, want 0", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ChatCompletion() error = %v", err)
+			}
+			defer stream.Close()
+			if _, err := stream.Next(); err != io.EOF {
+				t.Fatalf("Next() error = %v, want io.EOF", err)
+			}
+
+			if got := len(m.Requests()); got != tt.wantCalls {
+				t.Errorf("upstream calls = %d, want %d", got, tt.wantCalls)
+			}
+			resp := stream.Response()
+			if len(resp.Choices) != tt.n {
+				t.Fatalf("choices = %d, want %d", len(resp.Choices), tt.n)
+			}
+			for i, choice := range resp.Choices {
+				if choice.Index != i {
+					t.Errorf("choice %d has index %d", i, choice.Index)
+				}
+			}
+			if resp.Usage == nil || resp.Usage.TotalTokens != 6*tt.n {
+				t.Errorf("usage = %+v, want %d total tokens", resp.Usage, 6*tt.n)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package copilot
+
+import (
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/edgard/opencompat/internal/audit"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// redacted replaces secret values in log records.
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders carry credentials and are never logged verbatim.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// Option configures NewClient and New.
+type Option func(*options)
+
+type options struct {
+	logger  *slog.Logger
+	limiter provider.RateLimiter
+	audit   audit.Logger
+}
+
+// WithLogger sets the logger used for the client, provider and their
+// streams. A nil logger keeps slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithRateLimiter sets the rate limiter for chat requests, replacing the
+// one built from RateLimitRPM and RateLimitTPM.
+func WithRateLimiter(limiter provider.RateLimiter) Option {
+	return func(o *options) {
+		o.limiter = limiter
+	}
+}
+
+// WithAuditLogger sets the audit logger for chat requests, replacing the
+// file logger configured by AuditLog.
+func WithAuditLogger(logger audit.Logger) Option {
+	return func(o *options) {
+		o.audit = logger
+	}
+}
+
+// applyOptions returns the options with defaults filled in. Log records
+// carry the provider ID.
+func applyOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	o.logger = o.logger.With("provider", ProviderID)
+	return o
+}
+
+// redact returns value, or "[REDACTED]" if the header name carries
+// credentials.
+func redact(name, value string) string {
+	if slices.ContainsFunc(sensitiveHeaders, func(s string) bool { return strings.EqualFold(s, name) }) {
+		return redacted
+	}
+	return value
+}
+
+// headersAttr returns h as a "headers" log group with credentials redacted.
+func headersAttr(h http.Header) slog.Attr {
+	attrs := make([]any, 0, len(h))
+	for _, name := range slices.Sorted(maps.Keys(h)) {
+		attrs = append(attrs, slog.String(name, redact(name, strings.Join(h[name], ", "))))
+	}
+	return slog.Group("headers", attrs...)
+}
@@ -0,0 +1,87 @@
+//go:build !nometrics
+
+package copilot
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/metrics"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// scrapeMetric returns the value of the sample named name from the
+// metrics endpoint, or 0 if there is none.
+func scrapeMetric(t *testing.T, name string) float64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	sc := bufio.NewScanner(rec.Body)
+	for sc.Scan() {
+		sample, value, ok := strings.Cut(sc.Text(), " ")
+		if !ok || sample != name {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("metric %s = %q: %v", name, value, err)
+		}
+		return v
+	}
+	return 0
+}
+
+func TestStreamFirstTokenMetric(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	const series = `{model="gpt-4o-ttft",provider="copilot"}`
+	p := newTestProvider(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, `data: {"id":"1","object":"chat.completion.chunk","model":"gpt-4o-ttft","choices":[{"index":0,"delta":{"role":"assistant"}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		time.Sleep(delay)
+		_, _ = io.WriteString(w, `data: {"id":"1","object":"chat.completion.chunk","model":"gpt-4o-ttft","choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+	}), nil)
+	count := scrapeMetric(t, "opencompat_stream_first_token_seconds_count"+series)
+	sum := scrapeMetric(t, "opencompat_stream_first_token_seconds_sum"+series)
+
+	stream, err := p.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{
+		Model:    "gpt-4o-ttft",
+		Messages: []api.Message{api.UserMessage("say hi")},
+		Stream:   true,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	for {
+		if _, err := stream.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+	}
+	// Nothing is observed until Close
+	if got := scrapeMetric(t, "opencompat_stream_first_token_seconds_count"+series); got != count {
+		t.Errorf("first token count before Close = %v, want %v", got, count)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := scrapeMetric(t, "opencompat_stream_first_token_seconds_count"+series); got != count+1 {
+		t.Fatalf("first token count after Close = %v, want %v", got, count+1)
+	}
+	// The latency runs from the request, so the token refresh and
+	// connection setup add a little to the delay
+	got := time.Duration((scrapeMetric(t, "opencompat_stream_first_token_seconds_sum"+series) - sum) * float64(time.Second))
+	if got < delay || got > delay+delay/10 {
+		t.Errorf("observed first token latency = %s, want %s within 10%%", got, delay)
+	}
+}
@@ -3,17 +3,21 @@ package copilot
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/config"
 	"github.com/edgard/opencompat/internal/httputil"
+	"github.com/edgard/opencompat/internal/provider"
 )
 
 const (
@@ -35,22 +39,64 @@ type ModelsCache struct {
 	stopRefresh    chan struct{}
 	refreshDone    chan struct{}
 	refreshStarted bool
+	intervalReset  chan time.Duration // new cacheTTL for the refresh ticker
+	extraModelIDs  []string           // synthetic models appended to the upstream catalog
+	logger         *slog.Logger
 }
 
-// NewModelsCache creates a new models cache.
-func NewModelsCache(client *Client, refreshMinutes int) *ModelsCache {
-	return &ModelsCache{
-		client:      client,
-		modelIDs:    make(map[string]bool),
-		cacheTTL:    time.Duration(refreshMinutes) * time.Minute,
-		stopRefresh: make(chan struct{}),
-		refreshDone: make(chan struct{}),
+// NewModelsCache creates a new models cache, starting from the disk cache
+// when it was fetched within the refresh interval.
+// extraModelIDs are always listed and supported in addition to the models
+// returned by the API.
+func NewModelsCache(client *Client, refreshMinutes int, extraModelIDs []string) *ModelsCache {
+	c := &ModelsCache{
+		client:        client,
+		extraModelIDs: extraModelIDs,
+		modelIDs:      make(map[string]bool),
+		cacheTTL:      time.Duration(refreshMinutes) * time.Minute,
+		stopRefresh:   make(chan struct{}),
+		refreshDone:   make(chan struct{}),
+		intervalReset: make(chan time.Duration, 1),
+		logger:        applyOptions(nil).logger,
 	}
+	if client != nil {
+		c.logger = client.logger
+	}
+	c.loadFreshFromDisk()
+	return c
 }
 
-// GetModels returns the list of available models.
-// Returns empty list if not logged in and no cache exists.
+// GetModels returns the list of available models, including any extra
+// model IDs from configuration.
 func (c *ModelsCache) GetModels() []api.Model {
+	models := c.getUpstreamModels()
+	if len(c.extraModelIDs) == 0 {
+		return models
+	}
+
+	seen := make(map[string]bool, len(models))
+	for _, m := range models {
+		seen[m.ID] = true
+	}
+	result := make([]api.Model, 0, len(models)+len(c.extraModelIDs))
+	result = append(result, models...)
+	for _, id := range c.extraModelIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, api.Model{
+			ID:      id,
+			Object:  "model",
+			OwnedBy: "unknown",
+		})
+	}
+	return result
+}
+
+// getUpstreamModels returns the cached models from the API.
+// Returns empty list if not logged in and no cache exists.
+func (c *ModelsCache) getUpstreamModels() []api.Model {
 	c.mu.RLock()
 	if len(c.models) > 0 && time.Since(c.fetchedAt) < c.cacheTTL {
 		models := c.models
@@ -70,9 +116,9 @@ func (c *ModelsCache) GetModels() []api.Model {
 
 	// If no client or not logged in, try disk cache only
 	if c.client == nil || c.client.store == nil {
-		models, err := c.loadFromDisk()
+		models, err := c.fallbackFromDisk()
 		if err == nil && len(models) > 0 {
-			c.updateCache(models)
+			c.updateCache(models, time.Now())
 			return c.models
 		}
 		// Return empty list - user needs to login
@@ -82,19 +128,20 @@ func (c *ModelsCache) GetModels() []api.Model {
 	// Try to fetch from API
 	models, err := c.fetchFromAPI()
 	if err == nil {
-		c.updateCache(models)
-		// Save to disk asynchronously
+		c.logger.Info("models list updated", "models", len(models))
+		c.updateCache(models, time.Now())
+		// Save to disk asynchronously; saveToDisk needs the lock we hold
 		go c.saveToDisk()
 		return c.models
 	}
 
-	slog.Warn("failed to fetch models from API", "provider", "copilot", "error", err)
+	c.logger.Warn("failed to fetch models from API", "error", err)
 
 	// Try disk cache as fallback
-	models, err = c.loadFromDisk()
+	models, err = c.fallbackFromDisk()
 	if err == nil && len(models) > 0 {
-		slog.Debug("using cached models from disk", "provider", "copilot")
-		c.updateCache(models)
+		c.logger.Debug("using cached models from disk")
+		c.updateCache(models, time.Now())
 		return c.models
 	}
 
@@ -104,6 +151,10 @@ func (c *ModelsCache) GetModels() []api.Model {
 
 // SupportsModel checks if a model ID is supported.
 func (c *ModelsCache) SupportsModel(modelID string) bool {
+	if slices.Contains(c.extraModelIDs, modelID) {
+		return true
+	}
+
 	c.mu.RLock()
 	if len(c.modelIDs) == 0 {
 		c.mu.RUnlock()
@@ -115,29 +166,52 @@ func (c *ModelsCache) SupportsModel(modelID string) bool {
 	return supported
 }
 
-// RefreshModels forces a refresh of the models list.
+// status returns the number of cached upstream models, when they were
+// fetched and whether that is more than twice the refresh interval ago.
+func (c *ModelsCache) status() (count int, fetchedAt time.Time, stale bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	stale = c.fetchedAt.IsZero() || c.cacheTTL > 0 && time.Since(c.fetchedAt) > 2*c.cacheTTL
+	return len(c.models), c.fetchedAt, stale
+}
+
+// StructuredOutputs reports whether the upstream catalog advertises
+// response_format json_schema support for modelID; nil when unknown.
+func (c *ModelsCache) StructuredOutputs(modelID string) *bool {
+	for _, m := range c.getUpstreamModels() {
+		if m.ID == modelID {
+			return m.StructuredOutputs
+		}
+	}
+	return nil
+}
+
+// RefreshModels forces a refresh of the models list and writes it to the
+// disk cache.
 func (c *ModelsCache) RefreshModels(ctx context.Context) error {
 	models, err := c.fetchFromAPIWithContext(ctx)
 	if err != nil {
 		return err
 	}
 
+	c.logger.Info("models list updated", "models", len(models))
 	c.mu.Lock()
-	c.updateCache(models)
+	c.updateCache(models, time.Now())
 	c.mu.Unlock()
 
-	go c.saveToDisk()
+	c.saveToDisk()
 	return nil
 }
 
 // updateCache updates the in-memory cache (must hold write lock).
-func (c *ModelsCache) updateCache(models []api.Model) {
+// fetchedAt is when the models were fetched from the API.
+func (c *ModelsCache) updateCache(models []api.Model, fetchedAt time.Time) {
 	c.models = models
 	c.modelIDs = make(map[string]bool, len(models))
 	for _, m := range models {
 		c.modelIDs[m.ID] = true
 	}
-	c.fetchedAt = time.Now()
+	c.fetchedAt = fetchedAt
 }
 
 // fetchFromAPI fetches models from the Copilot API.
@@ -145,19 +219,60 @@ func (c *ModelsCache) fetchFromAPI() ([]api.Model, error) {
 	return c.fetchFromAPIWithContext(context.Background())
 }
 
-// fetchFromAPIWithContext fetches models from the Copilot API with context.
+// fetchFromAPIWithContext fetches models from the Copilot API with context,
+// decoding the catalog one model at a time.
 func (c *ModelsCache) fetchFromAPIWithContext(ctx context.Context) ([]api.Model, error) {
 	if c.client == nil {
 		return nil, fmt.Errorf("no client configured")
 	}
 
+	body, err := c.client.openModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+
+	models := make(chan api.Model)
+	decodeErr := make(chan error, 1)
+	go func() {
+		defer close(models)
+		decodeErr <- decodeModels(ctx, body, models)
+	}()
+	collected, err := provider.CollectModels(ctx, models)
+	if err := <-decodeErr; err != nil {
+		return nil, err
+	}
+	return collected, err
+}
+
+// StreamModels streams the Copilot model catalog from the API, bypassing
+// the cache.
+func (c *Client) StreamModels(ctx context.Context) (<-chan api.Model, error) {
+	body, err := c.openModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	models := make(chan api.Model)
+	go func() {
+		defer close(models)
+		defer func() { _ = body.Close() }()
+		if err := decodeModels(ctx, body, models); err != nil && ctx.Err() == nil {
+			c.logger.Warn("models stream ended early", "error", err)
+		}
+	}()
+	return models, nil
+}
+
+// openModels requests the models list and returns the response body.
+func (c *Client) openModels(ctx context.Context) (io.ReadCloser, error) {
 	// Get valid Copilot token
-	token, err := c.client.getCopilotToken(ctx)
+	token, err := c.getCopilotToken(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get token: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", CopilotModelsURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.cfg.ModelsURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -169,49 +284,98 @@ func (c *ModelsCache) fetchFromAPIWithContext(ctx context.Context) ([]api.Model,
 	req.Header.Set("Editor-Plugin-Version", EditorPluginVersion)
 	req.Header.Set("Copilot-Integration-Id", CopilotIntegrationID)
 
-	resp, err := c.client.httpClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch models: %w", err)
 	}
-	defer func() { _ = resp.Body.Close() }()
-
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
 		return nil, fmt.Errorf("models request failed with status %d: %s", resp.StatusCode, string(body))
 	}
+	return resp.Body, nil
+}
 
-	var response struct {
-		Data []struct {
-			ID          string `json:"id"`
-			Name        string `json:"name"`
-			Version     string `json:"version"`
-			ModelFamily string `json:"model_family"`
-			Vendor      string `json:"vendor"`
-		} `json:"data"`
-	}
+// upstreamModel is one entry of the models response.
+type upstreamModel struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Version      string `json:"version"`
+	ModelFamily  string `json:"model_family"`
+	Vendor       string `json:"vendor"`
+	Capabilities struct {
+		Limits struct {
+			MaxContextWindowTokens int `json:"max_context_window_tokens"`
+		} `json:"limits"`
+		Supports struct {
+			StructuredOutputs *bool `json:"structured_outputs"`
+		} `json:"supports"`
+	} `json:"capabilities"`
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to parse models response: %w", err)
+// decodeModels sends each model of a {"data": [...]} models response as it
+// is decoded, without reading the whole list into memory.
+func decodeModels(ctx context.Context, r io.Reader, models chan<- api.Model) error {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return fmt.Errorf("failed to parse models response: %w", err)
 	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("failed to parse models response: %w", err)
+		}
+		if key != "data" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return fmt.Errorf("failed to parse models response: %w", err)
+			}
+			continue
+		}
 
-	models := make([]api.Model, 0, len(response.Data))
-	for _, m := range response.Data {
-		ownedBy := m.Vendor
-		if ownedBy == "" {
-			ownedBy = "unknown"
+		if err := expectDelim(dec, '['); err != nil {
+			return fmt.Errorf("failed to parse models response: %w", err)
 		}
-		models = append(models, api.Model{
-			ID:      m.ID,
-			Object:  "model",
-			OwnedBy: ownedBy,
-		})
-	}
+		for dec.More() {
+			var m upstreamModel
+			if err := dec.Decode(&m); err != nil {
+				return fmt.Errorf("failed to parse models response: %w", err)
+			}
+			ownedBy := m.Vendor
+			if ownedBy == "" {
+				ownedBy = "unknown"
+			}
+			model := api.Model{
+				ID:            m.ID,
+				Object:        "model",
+				OwnedBy:       ownedBy,
+				ContextWindow: m.Capabilities.Limits.MaxContextWindowTokens,
 
-	if len(models) == 0 {
-		return nil, fmt.Errorf("no models returned from API")
+				StructuredOutputs: m.Capabilities.Supports.StructuredOutputs,
+			}
+			select {
+			case models <- model:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return fmt.Errorf("failed to parse models response: %w", err)
+		}
 	}
+	return nil
+}
 
-	return models, nil
+// expectDelim reads the next token, which must be delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("expected %q, got %v", delim, tok)
+	}
+	return nil
 }
 
 // Disk cache helpers
@@ -222,21 +386,13 @@ type modelsCacheMeta struct {
 }
 
 func (c *ModelsCache) cacheDir() string {
-	base := os.Getenv("XDG_CACHE_HOME")
-	if base == "" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			home = "."
-		}
-		base = filepath.Join(home, ".cache")
-	}
-	return filepath.Join(base, "opencompat", "copilot")
+	return filepath.Join(config.CacheDir(), ProviderID)
 }
 
 func (c *ModelsCache) saveToDisk() {
 	cacheDir := c.cacheDir()
 	if err := os.MkdirAll(cacheDir, 0700); err != nil {
-		slog.Warn("failed to create cache directory", "error", err)
+		c.logger.Warn("failed to create cache directory", "error", err)
 		return
 	}
 
@@ -249,17 +405,17 @@ func (c *ModelsCache) saveToDisk() {
 
 	data, err := json.Marshal(meta)
 	if err != nil {
-		slog.Warn("failed to marshal models cache", "error", err)
+		c.logger.Warn("failed to marshal models cache", "error", err)
 		return
 	}
 
 	cachePath := filepath.Join(cacheDir, "models.json")
-	if err := os.WriteFile(cachePath, data, 0600); err != nil {
-		slog.Warn("failed to write models cache", "error", err)
+	if err := writeFileAtomic(cachePath, data); err != nil {
+		c.logger.Warn("failed to write models cache", "error", err)
 	}
 }
 
-func (c *ModelsCache) loadFromDisk() ([]api.Model, error) {
+func (c *ModelsCache) loadFromDisk() (*modelsCacheMeta, error) {
 	cachePath := filepath.Join(c.cacheDir(), "models.json")
 
 	data, err := os.ReadFile(cachePath)
@@ -271,11 +427,43 @@ func (c *ModelsCache) loadFromDisk() ([]api.Model, error) {
 	if err := json.Unmarshal(data, &meta); err != nil {
 		return nil, err
 	}
+	return &meta, nil
+}
+
+// loadFreshFromDisk fills the cache from disk if the stored models are
+// younger than the refresh interval, so a restart needs no models request.
+func (c *ModelsCache) loadFreshFromDisk() {
+	meta, err := c.loadFromDisk()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			c.logger.Warn("failed to read models cache", "error", err)
+		}
+		return
+	}
+	if len(meta.Models) == 0 || time.Since(meta.FetchedAt) >= c.cacheTTL {
+		return
+	}
+
+	c.mu.Lock()
+	c.updateCache(meta.Models, meta.FetchedAt)
+	c.mu.Unlock()
+	c.logger.Debug("loaded models from disk cache",
+		"models", len(meta.Models),
+		"age", time.Since(meta.FetchedAt),
+	)
+}
+
+// fallbackFromDisk returns the disk cache regardless of its age, for when
+// the API can't be reached.
+func (c *ModelsCache) fallbackFromDisk() ([]api.Model, error) {
+	meta, err := c.loadFromDisk()
+	if err != nil {
+		return nil, err
+	}
 
 	// Check if disk cache is too old
 	if time.Since(meta.FetchedAt) > ModelsDiskCacheTTL {
-		slog.Warn("disk cache expired",
-			"provider", "copilot",
+		c.logger.Warn("disk cache expired",
 			"age", time.Since(meta.FetchedAt),
 		)
 	}
@@ -285,41 +473,60 @@ func (c *ModelsCache) loadFromDisk() ([]api.Model, error) {
 
 // StartBackgroundRefresh starts a goroutine that periodically refreshes the models.
 func (c *ModelsCache) StartBackgroundRefresh() {
-	if c.cacheTTL <= 0 {
-		return
-	}
-
 	c.mu.Lock()
-	if c.refreshStarted {
+	interval := c.cacheTTL
+	if interval <= 0 || c.refreshStarted {
 		c.mu.Unlock()
 		return
 	}
 	c.refreshStarted = true
 	c.mu.Unlock()
 
-	slog.Debug("background models refresh started", "provider", "copilot", "interval", c.cacheTTL)
+	c.logger.Debug("background models refresh started", "interval", interval)
 
 	go func() {
 		defer close(c.refreshDone)
 
-		ticker := time.NewTicker(c.cacheTTL)
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-c.stopRefresh:
-				slog.Debug("background models refresh stopped", "provider", "copilot")
+				c.logger.Debug("background models refresh stopped")
 				return
+			case interval := <-c.intervalReset:
+				ticker.Reset(interval)
 			case <-ticker.C:
-				slog.Debug("background models refresh triggered", "provider", "copilot")
+				c.logger.Debug("background models refresh triggered")
 				if err := c.RefreshModels(context.Background()); err != nil {
-					slog.Warn("failed to refresh models", "provider", "copilot", "error", err)
+					c.logger.Warn("failed to refresh models", "error", err)
 				}
 			}
 		}
 	}()
 }
 
+// setRefreshInterval changes the cache TTL and reschedules a running
+// background refresh. A background refresh that was not started, because
+// the interval was 0, stays off.
+func (c *ModelsCache) setRefreshInterval(minutes int) {
+	interval := time.Duration(minutes) * time.Minute
+	c.mu.Lock()
+	c.cacheTTL = interval
+	c.mu.Unlock()
+	if interval <= 0 {
+		return // tickers need a positive interval
+	}
+
+	// Keep only the latest interval if the refresher hasn't caught up
+	select {
+	case <-c.intervalReset:
+	default:
+	}
+	c.intervalReset <- interval
+}
+
 // StopBackgroundRefresh stops the background refresh goroutine.
 func (c *ModelsCache) StopBackgroundRefresh() {
 	c.mu.Lock()
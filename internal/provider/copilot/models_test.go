@@ -0,0 +1,94 @@
+package copilot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// modelsJSON returns a models response listing n models.
+func modelsJSON(n int) string {
+	var b strings.Builder
+	b.WriteString(`{"object":"list","data":[`)
+	for i := range n {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"id":"model-%d","vendor":"Azure OpenAI","capabilities":{"limits":{"max_context_window_tokens":128000}}}`, i)
+	}
+	b.WriteString(`]}`)
+	return b.String()
+}
+
+func modelsHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, body)
+	})
+}
+
+func TestStreamModels(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	p := newTestProvider(t, modelsHandler(modelsJSON(1000)), nil)
+
+	stream, err := p.StreamModels(context.Background())
+	if err != nil {
+		t.Fatalf("StreamModels() error = %v", err)
+	}
+	models, err := provider.CollectModels(context.Background(), stream)
+	if err != nil {
+		t.Fatalf("CollectModels() error = %v", err)
+	}
+	if len(models) != 1000 {
+		t.Fatalf("streamed %d models, want 1000", len(models))
+	}
+	want := api.Model{ID: "model-999", Object: "model", OwnedBy: "Azure OpenAI", ContextWindow: 128000}
+	if got := models[999]; got.ID != want.ID || got.OwnedBy != want.OwnedBy || got.ContextWindow != want.ContextWindow {
+		t.Errorf("models[999] = %+v, want %+v", got, want)
+	}
+}
+
+func TestRefreshModelsFromStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    int
+		wantErr string
+	}{
+		{name: "catalog", body: modelsJSON(3), want: 3},
+		{name: "truncated catalog", body: strings.TrimSuffix(modelsJSON(3), "]}")[:200], wantErr: "failed to parse models response"},
+		{name: "empty catalog", body: `{"data":[]}`, wantErr: "no models returned from API"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			p := newTestProvider(t, modelsHandler(tt.body), nil)
+
+			err := p.RefreshModels(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RefreshModels() error = %v, want %q", err, tt.wantErr)
+				}
+				if count, _, _ := p.modelsCache.status(); count != 0 {
+					t.Errorf("cached %d models from a failed refresh", count)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RefreshModels() error = %v", err)
+			}
+			if count, _, _ := p.modelsCache.status(); count != tt.want {
+				t.Errorf("cached %d models, want %d", count, tt.want)
+			}
+			if !p.SupportsModel("model-2") {
+				t.Error("SupportsModel(model-2) = false after refresh")
+			}
+		})
+	}
+}
@@ -2,26 +2,52 @@
 package copilot
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/audit"
 	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/cache"
+	"github.com/edgard/opencompat/internal/httputil"
+	"github.com/edgard/opencompat/internal/metrics"
 	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/tokencount"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func init() {
 	provider.AddRegistration(func(r *provider.Registry) {
 		r.RegisterMeta(provider.ProviderMeta{
-			ID:            ProviderID,
-			Name:          "GitHub Copilot",
-			AuthMethod:    auth.AuthMethodDeviceFlow,
-			DeviceFlowCfg: GetDeviceFlowConfig(),
-			EnvVars:       convertEnvVarDocs(EnvVarDocs()),
-			Factory:       New,
+			ID:              ProviderID,
+			Name:            "GitHub Copilot",
+			AuthMethod:      auth.AuthMethodDeviceFlow,
+			DeviceFlowCfg:   GetDeviceFlowConfig(),
+			EnvVars:         convertEnvVarDocs(EnvVarDocs()),
+			Factory:         func(store *auth.Store) (provider.Provider, error) { return New(store) },
+			OptionsFactory:  NewWithOptions,
+			Capabilities:    capabilities,
+			ParameterBounds: provider.OpenAIParameterBounds,
 		})
 	})
 }
 
+// capabilities are the API features Copilot supports, though not on every
+// model.
+const capabilities = provider.CapabilityStreaming | provider.CapabilityToolCalls | provider.CapabilityVision |
+	provider.CapabilityStructuredOutput | provider.CapabilityLogprobs | provider.CapabilityEmbeddings
+
 // convertEnvVarDocs converts copilot.EnvVarDoc to provider.EnvVarDoc.
 func convertEnvVarDocs(docs []EnvVarDoc) []provider.EnvVarDoc {
 	result := make([]provider.EnvVarDoc, len(docs))
@@ -40,17 +66,59 @@ type Provider struct {
 	client      *Client
 	modelsCache *ModelsCache
 	cfg         *Config
+	sem         chan struct{}          // request slots; nil when unlimited
+	cache       provider.ResponseCache // nil when CacheSize is 0
+	limiter     provider.RateLimiter   // nil when unlimited
+	audit       audit.Logger
+	auditFile   *audit.FileLogger // nil unless opened from AuditLog
+	logger      *slog.Logger
+}
+
+// New creates a new Copilot provider configured from environment variables.
+func New(store *auth.Store, opts ...Option) (provider.Provider, error) {
+	return newProvider(store, nil, opts)
+}
+
+// NewWithOptions creates a new Copilot provider. opts maps environment
+// variable names to values that override the environment for this instance.
+func NewWithOptions(store *auth.Store, opts map[string]string) (provider.Provider, error) {
+	return newProvider(store, opts, nil)
 }
 
-// New creates a new Copilot provider.
-func New(store *auth.Store) (provider.Provider, error) {
-	cfg := LoadConfig()
-	client := NewClient(store)
-	return &Provider{
+func newProvider(store *auth.Store, env map[string]string, opts []Option) (*Provider, error) {
+	cfg, err := LoadConfig(env)
+	if err != nil {
+		return nil, err
+	}
+	retry, err := LoadRetryConfig(env)
+	if err != nil {
+		return nil, err
+	}
+	o := applyOptions(opts)
+	auditLogger, auditFile, err := newAuditLogger(cfg, o.audit)
+	if err != nil {
+		return nil, err
+	}
+	client := NewClient(store, cfg, retry, opts...)
+	p := &Provider{
 		client:      client,
-		modelsCache: NewModelsCache(client, cfg.ModelsRefresh),
+		modelsCache: NewModelsCache(client, cfg.ModelsRefresh, cfg.ExtraModelIDs),
 		cfg:         cfg,
-	}, nil
+		limiter:     o.limiter,
+		audit:       auditLogger,
+		auditFile:   auditFile,
+		logger:      client.logger,
+	}
+	if p.limiter == nil {
+		p.limiter = newRateLimiter(cfg)
+	}
+	if cfg.MaxConcurrent > 0 {
+		p.sem = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	if cfg.CacheSize > 0 {
+		p.cache = cache.NewLRU(cfg.CacheSize)
+	}
+	return p, nil
 }
 
 // ID returns the provider identifier.
@@ -63,6 +131,49 @@ func (p *Provider) Models() []api.Model {
 	return p.modelsCache.GetModels()
 }
 
+// StreamModels streams the model catalog from the Copilot API, bypassing
+// the cache and the extra model IDs.
+func (p *Provider) StreamModels(ctx context.Context) (<-chan api.Model, error) {
+	return p.client.StreamModels(ctx)
+}
+
+// SupportedParameters returns the optional request parameters forwarded to Copilot.
+func (p *Provider) SupportedParameters() []string {
+	return []string{
+		"n",
+		"temperature",
+		"top_p",
+		"stop",
+		"max_tokens",
+		"max_completion_tokens",
+		"presence_penalty",
+		"frequency_penalty",
+		"seed",
+		"logprobs",
+		"top_logprobs",
+		"response_format",
+		"parallel_tool_calls",
+		"modalities",
+		"audio",
+	}
+}
+
+// SupportedToolTypes returns the tool types accepted by the upstream API.
+func (p *Provider) SupportedToolTypes() []string {
+	return []string{"function"}
+}
+
+// MaxConcurrentRequests returns the configured request limit, 0 for unlimited.
+func (p *Provider) MaxConcurrentRequests() int {
+	return p.cfg.MaxConcurrent
+}
+
+// AuditEnabled reports whether the audit log is configured.
+func (p *Provider) AuditEnabled() bool {
+	_, noop := p.audit.(audit.NoopLogger)
+	return !noop
+}
+
 // SupportsModel checks if a model ID is supported.
 func (p *Provider) SupportsModel(modelID string) bool {
 	return p.modelsCache.SupportsModel(modelID)
@@ -70,8 +181,18 @@ func (p *Provider) SupportsModel(modelID string) bool {
 
 // ChatCompletion sends a chat completion request.
 func (p *Provider) ChatCompletion(ctx context.Context, req *provider.ChatCompletionRequest) (provider.Stream, error) {
-	// Transform messages: convert system role to assistant (Copilot compatibility)
-	messages := transformMessages(req.Messages)
+	if req.TraceContext != nil && httputil.TraceContextFrom(ctx) == nil {
+		ctx = httputil.WithTraceContext(ctx, req.TraceContext)
+	}
+	messages := req.Messages
+	if p.cfg.AutoTruncate {
+		truncated, err := p.truncate(ctx, req.Model, messages)
+		if err != nil {
+			return nil, err
+		}
+		messages = truncated
+	}
+	messages = transformMessages(messages, p.cfg.SystemMessages)
 
 	// Convert provider request to API request for Copilot
 	chatReq := &api.ChatCompletionRequest{
@@ -88,31 +209,415 @@ func (p *Provider) ChatCompletion(ctx context.Context, req *provider.ChatComplet
 		Stop:                req.Stop,
 		PresencePenalty:     req.PresencePenalty,
 		FrequencyPenalty:    req.FrequencyPenalty,
+		Seed:                req.Seed,
+		Logprobs:            req.Logprobs,
+		TopLogprobs:         req.TopLogprobs,
 		ResponseFormat:      req.ResponseFormat,
 		ParallelToolCalls:   req.ParallelToolCalls,
+		Modalities:          req.Modalities,
+		AudioConfig:         req.AudioConfig,
+	}
+
+	// Drop audio output settings for models that can't produce audio
+	if (chatReq.AudioConfig != nil || slices.Contains(chatReq.Modalities, "audio")) && !supportsAudioOutput(chatReq.Model) {
+		p.logger.Warn("model does not support audio output, ignoring modalities and audio",
+			"model", chatReq.Model,
+		)
+		chatReq.Modalities = nil
+		chatReq.AudioConfig = nil
+	}
+
+	// Reconcile token limit parameters with what the model accepts
+	normalizeTokenLimits(chatReq)
+
+	// Structured outputs can't be dropped without breaking the caller
+	if rf := chatReq.ResponseFormat; rf != nil && rf.Type == "json_schema" {
+		if supported := p.modelsCache.StructuredOutputs(chatReq.Model); supported != nil && !*supported {
+			return nil, &provider.ParameterNotSupportedError{
+				Param:  "response_format",
+				Reason: fmt.Sprintf("Copilot model %s does not support json_schema structured outputs", chatReq.Model),
+			}
+		}
 	}
 
-	// Send request
-	resp, err := p.client.SendRequest(ctx, chatReq)
+	// Copilot returns a single choice, so n > 1 is rejected or fanned out
+	if req.N != nil && *req.N > 1 {
+		if p.cfg.NSupport != NSupportFanout {
+			return nil, api.NewUpstreamError(http.StatusBadRequest, fmt.Sprintf(
+				"Copilot does not support n > 1 (got n=%d). Request one completion at a time, or set %s=%s to send one upstream request per completion.",
+				*req.N, EnvNSupport, NSupportFanout))
+		}
+		return p.fanOut(ctx, chatReq, *req.N, req.PassthroughHeaders)
+	}
+
+	if p.cache != nil && cacheable(chatReq) {
+		return p.sendCached(ctx, chatReq, req.PassthroughHeaders)
+	}
+	return p.send(ctx, chatReq, req.PassthroughHeaders)
+}
+
+// sendCached serves chatReq from the response cache, or sends it and
+// caches the response. Cache hits are fresh copies stamped with the
+// current time.
+func (p *Provider) sendCached(ctx context.Context, chatReq *api.ChatCompletionRequest, headers http.Header) (provider.Stream, error) {
+	key, err := responseCacheKey(chatReq)
+	if err != nil {
+		return nil, err
+	}
+	if cached, ok := p.cache.Get(key); ok {
+		resp, err := cloneResponse(cached)
+		if err != nil {
+			return nil, err
+		}
+		resp.Created = time.Now().Unix()
+		p.logger.Debug("serving copilot response from cache", "model", chatReq.Model)
+		return &cachedStream{resp: resp}, nil
+	}
+
+	stream, err := p.send(ctx, chatReq, headers)
+	if err != nil {
+		return nil, err
+	}
+	return &cachingStream{Stream: stream, cache: p.cache, key: key, ttl: p.cfg.CacheTTL}, nil
+}
+
+// send sends a single upstream request, holding a slot until the stream is
+// closed. The request is traced as a "copilot.ChatCompletion" span that
+// ends with the stream.
+func (p *Provider) send(ctx context.Context, chatReq *api.ChatCompletionRequest, headers http.Header) (*releasingStream, error) {
+	ctx, span := tracer().Start(ctx, "copilot.ChatCompletion", trace.WithAttributes(
+		attribute.String("provider.id", ProviderID),
+		attribute.String("model", chatReq.Model),
+		attribute.Bool("stream", chatReq.Stream),
+	))
+	start := time.Now()
+
+	if err := p.waitRateLimit(ctx, chatReq); err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+	release, err := p.acquire(ctx)
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+
+	resp, err := p.client.SendRequest(ctx, chatReq, headers)
+	if err == nil && resp.StatusCode == http.StatusBadRequest &&
+		p.cfg.SystemMessages == SystemMessagesPassthrough && hasSystemMessage(chatReq.Messages) {
+		// Not every Copilot model accepts the system role, but only retry
+		// when that is what the error is about
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		_ = resp.Body.Close()
+		if rejectsSystemRole(body) {
+			p.logger.Warn("copilot rejected request with system messages, retrying as assistant",
+				"model", chatReq.Model,
+				"error", string(body),
+			)
+			fallback := *chatReq
+			fallback.Messages = transformMessages(chatReq.Messages, SystemMessagesAssistant)
+			resp, err = p.client.SendRequest(ctx, &fallback, headers)
+		} else {
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+		}
+	}
 	if err != nil {
+		release()
+		endSpan(span, err)
 		return nil, err
 	}
+	p.backoffRateLimit(resp)
 
-	return NewStream(resp, req.Stream), nil
+	return &releasingStream{
+		Stream:    NewStream(resp, chatReq.Stream, p.cfg, p.logger).WithStartTime(start),
+		audit:     p.newAuditRecord(ctx, chatReq, start),
+		release:   release,
+		span:      span,
+		streaming: chatReq.Stream,
+		model:     chatReq.Model,
+		start:     start,
+		logger:    p.logger,
+	}, nil
 }
 
-// transformMessages converts system messages to assistant role for Copilot compatibility.
-func transformMessages(messages []api.Message) []api.Message {
-	result := make([]api.Message, len(messages))
-	for i, msg := range messages {
-		result[i] = msg
-		if msg.Role == "system" {
-			result[i].Role = "assistant"
+// acquire takes a request slot, waiting for one when QueueOnLimit is set
+// and failing with 503 otherwise. The returned func frees the slot.
+func (p *Provider) acquire(ctx context.Context) (func(), error) {
+	if p.sem == nil {
+		return func() {}, nil
+	}
+
+	release := sync.OnceFunc(func() { <-p.sem })
+	if !p.cfg.QueueOnLimit {
+		select {
+		case p.sem <- struct{}{}:
+			return release, nil
+		default:
+			return nil, &provider.LocalError{Err: api.NewUpstreamError(http.StatusServiceUnavailable,
+				fmt.Sprintf("too many concurrent Copilot requests (limit %d)", p.cfg.MaxConcurrent))}
+		}
+	}
+
+	select {
+	case p.sem <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// releasingStream frees its request slot and ends its span when closed.
+type releasingStream struct {
+	*Stream
+	audit     *auditRecord // nil when auditing is off
+	release   func()
+	span      trace.Span
+	streaming bool
+	chunks    int
+	model     string
+	start     time.Time
+	logger    *slog.Logger
+}
+
+// Next records first-chunk and final-chunk span events when streaming,
+// and token usage and time to first chunk in metrics.
+func (s *releasingStream) Next() (*api.ChatCompletionChunk, error) {
+	chunk, err := s.Stream.Next()
+	s.audit.observe(s.Stream, chunk, err)
+	if err != nil && err != io.EOF && !errors.Is(err, context.Canceled) {
+		s.logger.Error("copilot upstream error", "model", s.model, "chunks", s.chunks, "error", err)
+	}
+	if !s.streaming {
+		if err == io.EOF && s.Response() != nil {
+			metrics.ObserveUsage(ProviderID, s.model, s.Response().Usage)
 		}
+		return chunk, err
 	}
+	switch {
+	case err == nil:
+		s.chunks++
+		if s.chunks == 1 {
+			s.span.AddEvent("first_chunk")
+			metrics.ObserveFirstByte(ProviderID, s.model, time.Since(s.start))
+		}
+		metrics.ObserveUsage(ProviderID, s.model, chunk.Usage)
+	case err == io.EOF:
+		s.span.AddEvent("final_chunk", trace.WithAttributes(attribute.Int("chunks", s.chunks)))
+	}
+	return chunk, err
+}
+
+// WriteTo copies the raw stream to w like Stream.WriteTo, recording the
+// same span events and metrics as Next. It is not audited; the handler
+// doesn't pass audited streams through (see AuditEnabled).
+func (s *releasingStream) WriteTo(w io.Writer) (int64, error) {
+	n, err := s.Stream.WriteTo(&firstWriteObserver{Writer: w, onFirst: func() {
+		s.span.AddEvent("first_chunk")
+		metrics.ObserveFirstByte(ProviderID, s.model, time.Since(s.start))
+	}})
+	switch {
+	case err == nil:
+		s.span.AddEvent("final_chunk")
+		metrics.ObserveUsage(ProviderID, s.model, s.Usage())
+	case errors.Is(err, s.Err()) && !errors.Is(err, context.Canceled):
+		// Upstream failure, as opposed to the client going away
+		s.logger.Error("copilot upstream error", "model", s.model, "bytes", n, "error", err)
+	}
+	return n, err
+}
+
+// firstWriteObserver calls onFirst before the first write to Writer.
+type firstWriteObserver struct {
+	io.Writer
+	onFirst func()
+}
+
+func (w *firstWriteObserver) Write(p []byte) (int, error) {
+	if w.onFirst != nil {
+		w.onFirst()
+		w.onFirst = nil
+	}
+	return w.Writer.Write(p)
+}
+
+// Close records the time to first token in metrics when streaming.
+func (s *releasingStream) Close() error {
+	s.logger.Debug("copilot stream closed", "model", s.model, "chunks", s.chunks, "duration", time.Since(s.start))
+	if d, ok := s.FirstTokenLatency(); ok && s.streaming {
+		metrics.ObserveFirstToken(ProviderID, s.model, d)
+	}
+	defer s.release()
+	defer func() { endSpan(s.span, s.Err()) }()
+	s.audit.finish(nil, errAuditIncomplete)
+	return s.Stream.Close()
+}
+
+// supportsAudioOutput reports whether a model can produce audio output.
+// Copilot's models endpoint doesn't advertise output modalities, so this
+// relies on the OpenAI naming convention (e.g. gpt-4o-audio-preview).
+func supportsAudioOutput(model string) bool {
+	return strings.Contains(model, "audio")
+}
+
+// Model families by the output token limit parameter they accept, matched
+// by prefix. Reasoning models reject max_tokens and require
+// max_completion_tokens; the older chat models only accept max_tokens.
+var (
+	maxCompletionTokensModels = []string{"o1", "o3", "o4", "gpt-5"}
+	maxTokensModels           = []string{"gpt-3.5", "gpt-4", "claude", "gemini"}
+)
+
+// normalizeTokenLimits moves the output token limit into the parameter the
+// model accepts. Models in neither family are left untouched.
+func normalizeTokenLimits(req *api.ChatCompletionRequest) {
+	switch {
+	case hasModelPrefix(req.Model, maxCompletionTokensModels):
+		if req.MaxTokens != nil && req.MaxCompletionTokens == nil {
+			req.MaxCompletionTokens = req.MaxTokens
+		}
+		req.MaxTokens = nil
+	case hasModelPrefix(req.Model, maxTokensModels):
+		if req.MaxCompletionTokens != nil && req.MaxTokens == nil {
+			req.MaxTokens = req.MaxCompletionTokens
+		}
+		req.MaxCompletionTokens = nil
+	}
+}
+
+func hasModelPrefix(model string, prefixes []string) bool {
+	return slices.ContainsFunc(prefixes, func(prefix string) bool {
+		return strings.HasPrefix(model, prefix)
+	})
+}
+
+// truncate drops old conversation turns so messages fit TruncateThreshold
+// of the model's context window, reporting the count via ctx. Models with
+// an unknown context window are left alone.
+func (p *Provider) truncate(ctx context.Context, model string, messages []api.Message) ([]api.Message, error) {
+	window, err := tokencount.MaxContextTokens(ProviderID + "/" + model)
+	if err != nil {
+		p.logger.Debug("not truncating messages", "model", model, "error", err)
+		return messages, nil
+	}
+	limit := int(float64(window) * p.cfg.TruncateThreshold)
+
+	kept, dropped, err := provider.TruncateMessages(model, messages, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(dropped) > 0 {
+		roles := make([]string, len(dropped))
+		for i, msg := range dropped {
+			roles[i] = msg.Role
+		}
+		p.logger.Debug("truncated messages to fit context window",
+			"model", model,
+			"limit", limit,
+			"dropped", len(dropped),
+			"dropped_roles", roles,
+		)
+		provider.ReportTruncation(ctx, len(dropped))
+	}
+	return kept, nil
+}
+
+// transformMessages applies the system message handling mode. Passthrough
+// returns messages unchanged.
+func transformMessages(messages []api.Message, mode string) []api.Message {
+	switch mode {
+	case SystemMessagesAssistant:
+		result := make([]api.Message, len(messages))
+		for i, msg := range messages {
+			result[i] = msg
+			if msg.Role == "system" {
+				result[i].Role = "assistant"
+			}
+		}
+		return result
+	case SystemMessagesPrefix:
+		return injectSystemPrefix(messages)
+	default:
+		return messages
+	}
+}
+
+// injectSystemPrefix removes system messages and prepends their text to
+// the first user message. Without a user message, the system text becomes
+// one.
+func injectSystemPrefix(messages []api.Message) []api.Message {
+	var prompts []string
+	result := make([]api.Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role != "system" {
+			result = append(result, msg)
+			continue
+		}
+		var text []string
+		for _, part := range msg.GetContentParts() {
+			if part.Type == "text" && part.Text != "" {
+				text = append(text, part.Text)
+			}
+		}
+		if len(text) > 0 {
+			prompts = append(prompts, strings.Join(text, "\n"))
+		}
+	}
+	if len(prompts) == 0 {
+		return result
+	}
+	prefix := strings.Join(prompts, "\n\n")
+
+	i := slices.IndexFunc(result, func(msg api.Message) bool { return msg.Role == "user" })
+	if i < 0 {
+		return append([]api.Message{api.UserMessage(prefix)}, result...)
+	}
+	user := result[i]
+	var text string
+	if user.Content == nil || json.Unmarshal(user.Content, &text) == nil {
+		if text != "" {
+			prefix += "\n\n" + text
+		}
+		user.SetContentString(prefix)
+	} else {
+		user.SetContentParts(append([]api.ContentPart{{Type: "text", Text: prefix}}, user.GetContentParts()...))
+	}
+	result[i] = user
 	return result
 }
 
+// maxErrorBodyBytes limits how much of an error response is read to decide
+// on a retry.
+const maxErrorBodyBytes = 64 << 10
+
+// systemRoleErrors are the messages, lowercased, that upstream returns when
+// a model does not accept the system role.
+var systemRoleErrors = []string{
+	"system messages are not supported",
+	"invalid value for 'role'",
+}
+
+// rejectsSystemRole reports whether a 400 response body blames the system
+// role: one of systemRoleErrors, or an invalid_value error whose param is
+// a message role.
+func rejectsSystemRole(body []byte) bool {
+	var errResp api.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return false
+	}
+	detail := errResp.Error
+	msg := strings.ToLower(detail.Message)
+	if slices.ContainsFunc(systemRoleErrors, func(prefix string) bool { return strings.HasPrefix(msg, prefix) }) {
+		return true
+	}
+	return detail.Code != nil && *detail.Code == "invalid_value" &&
+		detail.Param != nil && strings.HasPrefix(*detail.Param, "messages[") && strings.HasSuffix(*detail.Param, "].role")
+}
+
+// hasSystemMessage reports whether messages include a system message.
+func hasSystemMessage(messages []api.Message) bool {
+	return slices.ContainsFunc(messages, func(msg api.Message) bool { return msg.Role == "system" })
+}
+
 // Init performs initialization - fetches models list.
 func (p *Provider) Init() error {
 	// Trigger initial models fetch
@@ -130,6 +635,70 @@ func (p *Provider) Close() {
 	p.modelsCache.StopBackgroundRefresh()
 }
 
+// Shutdown stops background tasks, waits for in-flight requests (see
+// Client.Shutdown) and closes the audit log.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	p.Close()
+	err := p.client.Shutdown(ctx)
+	if p.auditFile != nil {
+		err = errors.Join(err, p.auditFile.Close())
+	}
+	return err
+}
+
+// healthTokenTimeout bounds the token exchange of a health check; a cached
+// token answers immediately.
+const healthTokenTimeout = 5 * time.Second
+
+// Health reports Unhealthy when no valid Copilot token can be obtained and
+// Degraded when the models were last refreshed more than twice the refresh
+// interval ago.
+func (p *Provider) Health() provider.HealthStatus {
+	count, fetchedAt, stale := p.modelsCache.status()
+	status := provider.HealthStatus{
+		State:       provider.Healthy,
+		ModelsCount: count + len(p.cfg.ExtraModelIDs),
+		LastRefresh: fetchedAt,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthTokenTimeout)
+	defer cancel()
+	if _, err := p.client.getCopilotToken(ctx); err != nil {
+		status.State = provider.Unhealthy
+		status.Message = err.Error()
+		return status
+	}
+	if stale {
+		status.State = provider.Degraded
+		status.Message = "models list is stale"
+	}
+	return status
+}
+
+// Reload applies the models refresh interval, token expiry buffer and
+// adaptive timeout floor of opts. Other settings need a restart, as does
+// switching between the adaptive and the fixed timeout.
+func (p *Provider) Reload(opts map[string]string) error {
+	cfg, err := LoadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	p.modelsCache.setRefreshInterval(cfg.ModelsRefresh)
+	p.client.setExpiryBuffer(cfg.TokenExpiryBuffer)
+	if p.client.adaptive != nil {
+		p.client.adaptive.SetMinTimeout(cfg.AdaptiveTimeoutMin)
+	} else if cfg.AdaptiveTimeoutMin > 0 {
+		p.logger.Warn("adaptive timeout requires a restart to enable", "env", EnvAdaptiveTimeout)
+	}
+	p.logger.Info("configuration reloaded",
+		"models_refresh", time.Duration(cfg.ModelsRefresh)*time.Minute,
+		"token_expiry_buffer", cfg.TokenExpiryBuffer,
+		"adaptive_timeout_min", cfg.AdaptiveTimeoutMin,
+	)
+	return nil
+}
+
 // RefreshModels forces a refresh of the models list.
 func (p *Provider) RefreshModels(ctx context.Context) error {
 	return p.modelsCache.RefreshModels(ctx)
@@ -0,0 +1,251 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/httputil"
+	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/testutil"
+)
+
+// newTestProvider returns a provider whose chat requests go to handler,
+// with a valid Copilot token so no token exchange happens. env overrides
+// environment variables.
+func newTestProvider(t *testing.T, handler http.Handler, env map[string]string) *Provider {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	opts := map[string]string{
+		EnvChatURL:          srv.URL + "/chat/completions",
+		EnvModelsURL:        srv.URL + "/models",
+		EnvTokenURL:         srv.URL + "/token",
+		EnvRetryMaxAttempts: "1",
+	}
+	for k, v := range env {
+		opts[k] = v
+	}
+	p, err := newProvider(nil, opts, nil)
+	if err != nil {
+		t.Fatalf("newProvider() error = %v", err)
+	}
+	p.client.copilotToken = &CopilotToken{Token: "test-token", ExpiresAt: time.Now().Add(time.Hour)}
+	return p
+}
+
+const completionJSON = `{"id":"1","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`
+
+func intPtr(v int) *int { return &v }
+
+func TestNormalizeTokenLimits(t *testing.T) {
+	tests := []struct {
+		name                     string
+		model                    string
+		maxTokens, maxCompletion *int
+		wantMaxTokens            *int
+		wantMaxCompletionTokens  *int
+	}{
+		{name: "o1 max_tokens moves", model: "o1-mini", maxTokens: intPtr(100), wantMaxCompletionTokens: intPtr(100)},
+		{name: "o3 keeps max_completion_tokens", model: "o3", maxCompletion: intPtr(50), wantMaxCompletionTokens: intPtr(50)},
+		{name: "gpt-5 prefers max_completion_tokens", model: "gpt-5.1", maxTokens: intPtr(100), maxCompletion: intPtr(50), wantMaxCompletionTokens: intPtr(50)},
+		{name: "gpt-4o max_completion_tokens moves", model: "gpt-4o", maxCompletion: intPtr(50), wantMaxTokens: intPtr(50)},
+		{name: "claude keeps max_tokens", model: "claude-sonnet-4", maxTokens: intPtr(100), wantMaxTokens: intPtr(100)},
+		{name: "gpt-4.1 prefers max_tokens", model: "gpt-4.1", maxTokens: intPtr(100), maxCompletion: intPtr(50), wantMaxTokens: intPtr(100)},
+		{name: "unknown model untouched", model: "grok-code-fast-1", maxTokens: intPtr(100), maxCompletion: intPtr(50), wantMaxTokens: intPtr(100), wantMaxCompletionTokens: intPtr(50)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &api.ChatCompletionRequest{Model: tt.model, MaxTokens: tt.maxTokens, MaxCompletionTokens: tt.maxCompletion}
+			normalizeTokenLimits(req)
+			if !equalIntPtr(req.MaxTokens, tt.wantMaxTokens) {
+				t.Errorf("MaxTokens = %v, want %v", fmtIntPtr(req.MaxTokens), fmtIntPtr(tt.wantMaxTokens))
+			}
+			if !equalIntPtr(req.MaxCompletionTokens, tt.wantMaxCompletionTokens) {
+				t.Errorf("MaxCompletionTokens = %v, want %v", fmtIntPtr(req.MaxCompletionTokens), fmtIntPtr(tt.wantMaxCompletionTokens))
+			}
+		})
+	}
+}
+
+func equalIntPtr(a, b *int) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func fmtIntPtr(p *int) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func TestSendSystemMessageFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		errorBody string
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "system role rejected", errorBody: `{"error":{"message":"System messages are not supported for this model"}}`, wantCalls: 2},
+		{name: "invalid role", errorBody: `{"error":{"message":"Invalid value for 'role'"}}`, wantCalls: 2},
+		{name: "invalid role param", errorBody: `{"error":{"message":"Invalid value: 'system'.","param":"messages[0].role","code":"invalid_value"}}`, wantCalls: 2},
+		{name: "unrelated 400", errorBody: `{"error":{"message":"temperature must be at most 2"}}`, wantCalls: 1, wantErr: true},
+		{name: "unrelated 400 mentioning system", errorBody: `{"error":{"message":"temperature must be at most 2 for system_fingerprint models"}}`, wantCalls: 1, wantErr: true},
+		{name: "unrelated 400 mentioning role", errorBody: `{"error":{"message":"temperature is not supported with tools of role function","param":"temperature"}}`, wantCalls: 1, wantErr: true},
+		{name: "invalid value of another param", errorBody: `{"error":{"message":"Invalid value: 'system'. temperature must be a number","param":"messages[0].temperature","code":"invalid_value"}}`, wantCalls: 1, wantErr: true},
+		{name: "plain text 400", errorBody: `system overloaded, invalid role temperature`, wantCalls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testutil.NewRequestMatcher(t).Respond(http.StatusBadRequest, tt.errorBody)
+			p := newTestProvider(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				m.ServeHTTP(w, r)
+				m.Respond(http.StatusOK, completionJSON)
+			}), nil)
+
+			req := &api.ChatCompletionRequest{Model: "gpt-4o", Messages: []api.Message{
+				api.SystemMessage("be brief"),
+				api.UserMessage("hello"),
+			}}
+			stream, err := p.send(context.Background(), req, nil)
+			if err != nil {
+				t.Fatalf("send() error = %v", err)
+			}
+			_, err = stream.Next()
+			_ = stream.Close()
+			if gotErr := err != io.EOF; gotErr != tt.wantErr {
+				t.Errorf("Next() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "temperature") {
+				t.Errorf("Next() error = %v, want the upstream message", err)
+			}
+
+			if got := len(m.Requests()); got != tt.wantCalls {
+				t.Fatalf("upstream calls = %d, want %d", got, tt.wantCalls)
+			}
+			m.AssertURL("/chat/completions")
+			if tt.wantCalls == 2 {
+				m.AssertBody("messages.0.role", "assistant")
+			}
+		})
+	}
+}
+
+func TestAudioOutputStripped(t *testing.T) {
+	tests := []struct {
+		model     string
+		wantAudio bool
+	}{
+		{model: "gpt-4o"},
+		{model: "gpt-4o-audio-preview", wantAudio: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, completionJSON)
+			p := newTestProvider(t, m, nil)
+
+			stream, err := p.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{
+				Model:       tt.model,
+				Messages:    []api.Message{api.UserMessage("say hi")},
+				Modalities:  []string{"text", "audio"},
+				AudioConfig: &api.AudioOutputConfig{Voice: "alloy", Format: "wav"},
+			})
+			if err != nil {
+				t.Fatalf("ChatCompletion() error = %v", err)
+			}
+			_ = stream.Close()
+
+			body := m.Last().Body
+			for _, field := range []string{"modalities", "audio"} {
+				if got := gjson.GetBytes(body, field).Exists(); got != tt.wantAudio {
+					t.Errorf("%s sent = %v, want %v; body: %s", field, got, tt.wantAudio, body)
+				}
+			}
+			if tt.wantAudio {
+				m.AssertBody("modalities", []string{"text", "audio"}).
+					AssertBody("audio", map[string]string{"voice": "alloy", "format": "wav"})
+			}
+		})
+	}
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	const (
+		traceParent    = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		upstreamParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-b7ad6b7169203331-01"
+	)
+	tc := map[string]string{httputil.TraceParentHeader: traceParent, httputil.TraceStateHeader: "congo=t61rcWkgMzE"}
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, completionJSON).
+		RespondHeader("Traceparent", upstreamParent)
+	p := newTestProvider(t, m, nil)
+
+	// The handler carries the request's trace context in ctx
+	ctx := httputil.WithTraceContext(context.Background(), tc)
+	stream, err := p.ChatCompletion(ctx, &provider.ChatCompletionRequest{
+		Model:        "gpt-4o",
+		Messages:     []api.Message{api.UserMessage("hi")},
+		TraceContext: tc,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	_ = stream.Close()
+
+	m.AssertHeader("Traceparent", traceParent).AssertHeader("Tracestate", "congo=t61rcWkgMzE")
+	if got := httputil.UpstreamTraceContext(ctx)[httputil.TraceParentHeader]; got != upstreamParent {
+		t.Errorf("upstream traceparent = %q, want %q", got, upstreamParent)
+	}
+
+	// Without a trace context in ctx the request field is used
+	stream, err = p.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{
+		Model:        "gpt-4o",
+		Messages:     []api.Message{api.UserMessage("hi")},
+		TraceContext: map[string]string{httputil.TraceParentHeader: traceParent},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	_ = stream.Close()
+	m.AssertHeader("Traceparent", traceParent)
+	if got := m.Last().Header.Get("Tracestate"); got != "" {
+		t.Errorf("tracestate = %q, want none", got)
+	}
+}
+
+func TestToolChoicePassthrough(t *testing.T) {
+	for _, choice := range []string{
+		`"none"`,
+		`"auto"`,
+		`"required"`,
+		`{"type":"function","function":{"name":"get_weather"}}`,
+	} {
+		t.Run(choice, func(t *testing.T) {
+			m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, completionJSON)
+			p := newTestProvider(t, m, nil)
+
+			stream, err := p.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{
+				Model:      "gpt-4o",
+				Messages:   []api.Message{api.UserMessage("weather?")},
+				Tools:      []api.Tool{{Type: "function", Function: api.Function{Name: "get_weather"}}},
+				ToolChoice: json.RawMessage(choice),
+			})
+			if err != nil {
+				t.Fatalf("ChatCompletion() error = %v", err)
+			}
+			_ = stream.Close()
+
+			if got := gjson.GetBytes(m.Last().Body, "tool_choice").Raw; got != choice {
+				t.Errorf("tool_choice sent = %s, want %s", got, choice)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package copilot
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// newRateLimiter returns the limiter for cfg, or nil when both limits are
+// off.
+func newRateLimiter(cfg *Config) provider.RateLimiter {
+	if cfg.RateLimitRPM == 0 && cfg.RateLimitTPM == 0 {
+		return nil
+	}
+	return provider.NewTokenBucketLimiter(cfg.RateLimitRPM, cfg.RateLimitTPM)
+}
+
+// waitRateLimit blocks until the rate limiter lets chatReq through. Token
+// limits are charged with the locally estimated prompt size.
+func (p *Provider) waitRateLimit(ctx context.Context, chatReq *api.ChatCompletionRequest) error {
+	if p.limiter == nil {
+		return nil
+	}
+	if err := p.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	tl, ok := p.limiter.(provider.TokenRateLimiter)
+	if !ok {
+		return nil
+	}
+	count, err := provider.DefaultTokenEstimator.CountTokens(ctx, &provider.ChatCompletionRequest{
+		Model:    chatReq.Model,
+		Messages: chatReq.Messages,
+		Tools:    chatReq.Tools,
+	})
+	if err != nil {
+		return err
+	}
+	return tl.WaitTokens(ctx, count.PromptTokens)
+}
+
+// backoffRateLimit pauses the rate limiter for the Retry-After of a 429
+// that outlasted the client's retries, so queued requests don't hit the
+// same limit.
+func (p *Provider) backoffRateLimit(resp *http.Response) {
+	bl, ok := p.limiter.(provider.BackoffRateLimiter)
+	if !ok || resp.StatusCode != http.StatusTooManyRequests {
+		return
+	}
+	wait := retryAfter(resp.Header.Get("Retry-After"))
+	if wait == 0 {
+		// Copilot also reports token limit resets as a Go-style duration
+		wait, _ = time.ParseDuration(resp.Header.Get("X-Ratelimit-Reset-Tokens"))
+	}
+	if wait > 0 {
+		p.logger.Warn("copilot rate limited, pausing requests", "retry_after", wait)
+		bl.Backoff(wait)
+	}
+}
@@ -0,0 +1,120 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/testutil"
+)
+
+// recordingLimiter records the tokens charged to it and fails with err.
+type recordingLimiter struct {
+	err error
+
+	mu       sync.Mutex
+	requests int
+	tokens   []int
+}
+
+func (l *recordingLimiter) Wait(context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requests++
+	return l.err
+}
+
+func (l *recordingLimiter) WaitTokens(_ context.Context, tokens int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = append(l.tokens, tokens)
+	return nil
+}
+
+func TestRateLimitChargesEstimatedPromptTokens(t *testing.T) {
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, completionJSON)
+	p := newTestProvider(t, m, nil)
+	limiter := &recordingLimiter{}
+	p.limiter = limiter
+
+	req := &api.ChatCompletionRequest{Model: "gpt-4o", Messages: []api.Message{
+		api.SystemMessage("You are a helpful assistant."),
+		api.UserMessage("Summarize the plot of Hamlet in three sentences."),
+	}}
+	stream, err := p.send(context.Background(), req, nil)
+	if err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	_ = stream.Close()
+
+	want, err := provider.DefaultTokenEstimator.CountTokens(context.Background(), &provider.ChatCompletionRequest{
+		Model:    req.Model,
+		Messages: req.Messages,
+	})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if limiter.requests != 1 {
+		t.Errorf("Wait() calls = %d, want 1", limiter.requests)
+	}
+	if len(limiter.tokens) != 1 || limiter.tokens[0] != want.PromptTokens {
+		t.Errorf("WaitTokens() calls = %v, want [%d]", limiter.tokens, want.PromptTokens)
+	}
+}
+
+func TestRateLimitErrorSkipsUpstream(t *testing.T) {
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, completionJSON)
+	p := newTestProvider(t, m, nil)
+	limitErr := errors.New("rate limit wait canceled")
+	p.limiter = &recordingLimiter{err: limitErr}
+
+	_, err := p.send(context.Background(), &api.ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []api.Message{api.UserMessage("hello")},
+	}, nil)
+	if !errors.Is(err, limitErr) {
+		t.Fatalf("send() error = %v, want %v", err, limitErr)
+	}
+	if got := len(m.Requests()); got != 0 {
+		t.Errorf("upstream calls = %d, want 0", got)
+	}
+}
+
+func TestRateLimitTPMFromConfig(t *testing.T) {
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, completionJSON)
+	// The first request spends the whole minute's budget
+	p := newTestProvider(t, m, map[string]string{EnvRateLimitTPM: "10"})
+	if _, ok := p.limiter.(*provider.TokenBucketLimiter); !ok {
+		t.Fatalf("limiter = %T, want *provider.TokenBucketLimiter", p.limiter)
+	}
+
+	req := &api.ChatCompletionRequest{Model: "gpt-4o", Messages: []api.Message{
+		api.UserMessage("This prompt is estimated at well over ten tokens by the local estimator."),
+	}}
+	stream, err := p.send(context.Background(), req, nil)
+	if err != nil {
+		t.Fatalf("first send() error = %v", err)
+	}
+	_ = stream.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.send(ctx, req, nil); err == nil {
+		t.Fatal("second send() error = nil, want the token budget to be exhausted")
+	}
+	if got := len(m.Requests()); got != 1 {
+		t.Errorf("upstream calls = %d, want 1", got)
+	}
+}
+
+func TestRateLimitUnlimitedByDefault(t *testing.T) {
+	p := newTestProvider(t, http.NotFoundHandler(), nil)
+	if p.limiter != nil {
+		t.Errorf("limiter = %T, want nil without RPM or TPM", p.limiter)
+	}
+}
@@ -0,0 +1,130 @@
+package copilot
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// redirectChain redirects the chat endpoint through hops redirects before
+// answering, counting the requests it serves.
+func redirectChain(hops int, served *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*served++
+		hop := 0
+		if n, ok := strings.CutPrefix(r.URL.Path, "/hop/"); ok {
+			hop, _ = strconv.Atoi(n)
+		}
+		if hop < hops {
+			// 307 keeps the method and body of the chat request
+			http.Redirect(w, r, "/hop/"+strconv.Itoa(hop+1), http.StatusTemporaryRedirect)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(completionJSON))
+	})
+}
+
+func TestRedirectLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		max     string // EnvRedirectMax; empty for the default
+		hops    int
+		wantErr string
+	}{
+		{name: "no redirect", hops: 0},
+		{name: "default limit", hops: DefaultRedirectMax},
+		{name: "over default limit", hops: DefaultRedirectMax + 1, wantErr: "stopped after 3 redirects"},
+		{name: "custom limit", max: "5", hops: 5},
+		{name: "redirects disabled", max: "0", hops: 1, wantErr: "stopped after 0 redirects"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			served := 0
+			env := map[string]string{}
+			if tt.max != "" {
+				env[EnvRedirectMax] = tt.max
+			}
+			p := newTestProvider(t, redirectChain(tt.hops, &served), env)
+
+			resp, err := p.client.SendRequest(context.Background(), &api.ChatCompletionRequest{
+				Model:    "gpt-4o",
+				Messages: []api.Message{api.UserMessage("hello")},
+			}, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SendRequest() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendRequest() error = %v", err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want 200", resp.StatusCode)
+			}
+			if served != tt.hops+1 {
+				t.Errorf("requests served = %d, want %d", served, tt.hops+1)
+			}
+		})
+	}
+}
+
+func TestRedirectDowngrade(t *testing.T) {
+	insecure := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(insecure.Close)
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, insecure.URL+"/landing", http.StatusFound)
+	}))
+	t.Cleanup(secure.Close)
+
+	tests := []struct {
+		name    string
+		allow   string
+		wantErr bool
+	}{
+		{name: "refused by default", wantErr: true},
+		{name: "allowed", allow: "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(map[string]string{EnvRedirectDowngrade: tt.allow})
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			client := secure.Client()
+			client.CheckRedirect = checkRedirect(cfg, logger)
+
+			resp, err := client.Get(secure.URL + "/start")
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "refusing redirect from "+secure.URL+"/start to insecure "+insecure.URL+"/landing") {
+					t.Fatalf("Get() error = %v, want the downgrade refused", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				_ = resp.Body.Close()
+			}
+
+			// Every redirect is logged, including refused ones
+			for _, want := range []string{"following upstream redirect", "from=" + secure.URL + "/start", "to=" + insecure.URL + "/landing"} {
+				if !strings.Contains(logs.String(), want) {
+					t.Errorf("debug log missing %q:\n%s", want, logs.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package copilot
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/config"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// newReloadTestProvider returns a provider that fetches its models from
+// the API, counting the fetches, registered in a registry.
+func newReloadTestProvider(t *testing.T, env map[string]string) (*Provider, *provider.Registry, *atomic.Int32) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	var fetches atomic.Int32
+	models := modelsHandler(modelsJSON(2))
+	p := newTestProvider(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		models.ServeHTTP(w, r)
+	}), env)
+	// Without a store the cache only reads the disk
+	p.client.store = auth.NewStore()
+
+	registry := provider.NewRegistry()
+	registry.RegisterMeta(provider.ProviderMeta{
+		ID:         ProviderID,
+		AuthMethod: auth.AuthMethodNone,
+		Factory:    func(*auth.Store) (provider.Provider, error) { return p, nil },
+	})
+	if err := registry.Initialize(nil); err != nil {
+		t.Fatal(err)
+	}
+	return p, registry, &fetches
+}
+
+// watchProviderConfig reloads registry from path on every change, as the
+// serve command does, signalling each reload on the returned channel.
+func watchProviderConfig(t *testing.T, registry *provider.Registry, path string) <-chan error {
+	t.Helper()
+	reloaded := make(chan error, 10)
+	w, err := config.NewWatcher(path, func() {
+		opts, err := config.ReadEnvFile(path)
+		if err == nil {
+			err = registry.Reload(opts)
+		}
+		reloaded <- err
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	return reloaded
+}
+
+func writeProviderConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadModelsRefreshFromConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.env")
+	writeProviderConfig(t, path, EnvModelsRefresh+"=60\n")
+	opts, err := config.ReadEnvFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, registry, fetches := newReloadTestProvider(t, opts)
+	reloaded := watchProviderConfig(t, registry, path)
+
+	// A 60 minute interval serves the second call from the cache
+	for range 2 {
+		if got := len(p.Models()); got != 2 {
+			t.Fatalf("Models() returned %d models, want 2", got)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Fatalf("models fetched %d times with a 60m interval, want 1", got)
+	}
+
+	// A 0 interval disables the cache
+	writeProviderConfig(t, path, EnvModelsRefresh+"=0\n")
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatalf("reload error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("config file change was not reloaded")
+	}
+	for range 2 {
+		p.Models()
+	}
+	if got := fetches.Load(); got != 3 {
+		t.Errorf("models fetched %d times after reloading a 0 interval, want 3", got)
+	}
+}
+
+func TestReloadTokenExpiryBuffer(t *testing.T) {
+	p := newTestProvider(t, http.NotFoundHandler(), nil)
+	token := &CopilotToken{Token: "test-token", ExpiresAt: time.Now().Add(10 * time.Minute)}
+	if !p.client.tokenValid(token) {
+		t.Fatal("token expiring in 10m is not valid with the default buffer")
+	}
+
+	if err := p.Reload(map[string]string{EnvTokenExpiryBuffer: "20m"}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if p.client.tokenValid(token) {
+		t.Error("token expiring in 10m is still valid with a 20m buffer")
+	}
+}
+
+func TestReloadAdaptiveTimeout(t *testing.T) {
+	p := newTestProvider(t, http.NotFoundHandler(), map[string]string{EnvAdaptiveTimeout: "5s"})
+	for range 20 {
+		p.client.adaptive.Observe(time.Millisecond)
+	}
+	if got := p.client.adaptive.Timeout(); got != 5*time.Second {
+		t.Fatalf("Timeout() = %s, want the 5s floor", got)
+	}
+
+	if err := p.Reload(map[string]string{EnvAdaptiveTimeout: "15s"}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := p.client.adaptive.Timeout(); got != 15*time.Second {
+		t.Errorf("Timeout() after reload = %s, want the 15s floor", got)
+	}
+}
+
+func TestReloadInvalidConfig(t *testing.T) {
+	p := newTestProvider(t, http.NotFoundHandler(), nil)
+	if err := p.Reload(map[string]string{EnvTokenExpiryBuffer: "forever"}); err == nil {
+		t.Error("Reload() error = nil, want the invalid duration reported")
+	}
+	if got := p.client.expiryBuffer; got != DefaultTokenExpiryBuffer {
+		t.Errorf("expiry buffer after a failed reload = %s, want the default %s", got, DefaultTokenExpiryBuffer)
+	}
+}
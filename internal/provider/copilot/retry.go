@@ -0,0 +1,104 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryJitter is the fraction of the computed delay added or removed at
+// random, so clients rate limited together don't retry in lockstep.
+const retryJitter = 0.1
+
+// doWithRetry calls send until it succeeds, fails permanently, or the
+// configured attempts are used up. The last response or error is returned
+// unchanged, so callers see the same failure as without retries.
+func (c *Client) doWithRetry(ctx context.Context, send func() (*http.Response, error)) (*http.Response, error) {
+	delay := c.retry.InitialDelay
+	for attempt := 1; ; attempt++ {
+		resp, err := send()
+		if attempt >= c.retry.MaxAttempts || ctx.Err() != nil {
+			return resp, err
+		}
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if !isRetryableError(err) {
+				return nil, err
+			}
+			wait = withJitter(delay)
+			c.logger.Debug("copilot request failed, retrying",
+				"attempt", attempt, "backoff", wait, "error", err)
+
+		case isRetryableStatus(resp.StatusCode):
+			wait = withJitter(delay)
+			if resp.StatusCode == http.StatusTooManyRequests {
+				wait = max(wait, retryAfter(resp.Header.Get("Retry-After")))
+			}
+			c.logger.Debug("copilot request returned retryable status, retrying",
+				"attempt", attempt, "status", resp.StatusCode, "backoff", wait)
+			_ = resp.Body.Close()
+
+		default:
+			return resp, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		delay = min(time.Duration(float64(delay)*c.retry.Multiplier), c.retry.MaxDelay)
+	}
+}
+
+// isRetryableError reports whether err is a transient transport failure:
+// a connection closed before the response arrived, or a network timeout.
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isRetryableStatus reports whether an upstream status is worth retrying.
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP
+// date. It returns 0 when the header is missing or invalid.
+func retryAfter(val string) time.Duration {
+	if val == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(val); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(val); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// withJitter returns d adjusted by a random amount within ±retryJitter.
+func withJitter(d time.Duration) time.Duration {
+	factor := 1 + retryJitter*(2*rand.Float64()-1)
+	return time.Duration(float64(d) * factor)
+}
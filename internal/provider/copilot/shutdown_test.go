@@ -0,0 +1,104 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// slowStreamHandler streams one chunk, then holds the response open until
+// release is closed.
+func slowStreamHandler(release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, `data: {"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"h"}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = io.WriteString(w, `data: {"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"content":"i"},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+	})
+}
+
+// startSlowStream opens a streaming completion and reads its first chunk.
+func startSlowStream(t *testing.T, p *Provider) provider.Stream {
+	t.Helper()
+	stream, err := p.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []api.Message{api.UserMessage("say hi")},
+		Stream:   true,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if _, err := stream.Next(); err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	return stream
+}
+
+func TestShutdownDrainsStream(t *testing.T) {
+	release := make(chan struct{})
+	p := newTestProvider(t, slowStreamHandler(release), nil)
+	stream := startSlowStream(t, p)
+
+	done := make(chan error, 1)
+	go func() { done <- p.Shutdown(context.Background()) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown() = %v before the stream was closed", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// New requests are rejected while draining
+	_, err := p.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []api.Message{api.UserMessage("say hi")},
+	})
+	var upstreamErr *api.UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("ChatCompletion() during shutdown error = %v, want a 503", err)
+	}
+
+	close(release)
+	drainStream(t, stream)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Shutdown() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown() did not return after the stream was closed")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	p := newTestProvider(t, slowStreamHandler(release), nil)
+	stream := startSlowStream(t, p)
+	defer stream.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestShutdownIdle(t *testing.T) {
+	p := newTestProvider(t, http.NotFoundHandler(), nil)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
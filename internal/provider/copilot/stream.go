@@ -1,180 +1,62 @@
 package copilot
 
 import (
-	"encoding/json"
-	"io"
+	"log/slog"
 	"net/http"
 	"strings"
-	"time"
 
-	"github.com/edgard/opencompat/internal/api"
-	"github.com/edgard/opencompat/internal/sse"
+	"github.com/edgard/opencompat/internal/provider/openaicompat"
 )
 
-// Stream implements the provider.Stream interface for Copilot responses.
-// Copilot uses standard OpenAI format, so this is a thin pass-through wrapper.
-type Stream struct {
-	resp          *http.Response
-	reader        *sse.Reader
-	streaming     bool
-	done          bool
-	statusChecked bool
-	response      *api.ChatCompletionResponse
-	err           error
+// Stream is the Copilot response stream. Copilot uses standard OpenAI
+// format, so the shared OpenAI-compatible stream is used as is.
+type Stream = openaicompat.Stream
+
+// NewStream creates a new stream from an HTTP response, limited by
+// cfg.MaxResponseBodyBytes and cfg.MaxMalformedEvents. Skipped events are
+// logged to logger; nil uses slog.Default().
+func NewStream(resp *http.Response, streaming bool, cfg *Config, logger *slog.Logger) *Stream {
+	return openaicompat.NewStream(resp, streaming, newUpstreamError).
+		WithMaxBodyBytes(cfg.MaxResponseBodyBytes).
+		WithMaxMalformedEvents(cfg.MaxMalformedEvents).
+		WithLogger(logger)
 }
 
-// NewStream creates a new stream from an HTTP response.
-func NewStream(resp *http.Response, streaming bool) *Stream {
-	s := &Stream{
-		resp:      resp,
-		streaming: streaming,
-	}
-	if streaming {
-		s.reader = sse.NewReader(resp.Body)
-	}
-	return s
-}
-
-// Next returns the next chunk from the stream.
-// For non-streaming requests, returns io.EOF immediately (use Response() to get the result).
-func (s *Stream) Next() (*api.ChatCompletionChunk, error) {
-	if s.done {
-		return nil, io.EOF
-	}
-
-	// Check HTTP status once
-	if !s.statusChecked {
-		s.statusChecked = true
-		if s.resp.StatusCode != http.StatusOK {
-			s.done = true
-			body, _ := io.ReadAll(s.resp.Body)
-			s.err = api.NewUpstreamError(s.resp.StatusCode, parseUpstreamError(body))
-			return nil, s.err
-		}
-
-		// For non-streaming: read response immediately and return EOF
-		if !s.streaming {
-			s.done = true
-			return nil, s.readNonStreaming()
-		}
-	}
-
-	// Streaming: read next SSE event
-	for {
-		event, err := s.reader.ReadEvent()
-		if err != nil {
-			s.done = true
-			if err != io.EOF {
-				s.err = err
-			}
-			return nil, err
-		}
-
-		// Skip empty events
-		if len(event.Data) == 0 {
-			continue
-		}
-
-		// Parse chunk
-		var chunk api.ChatCompletionChunk
-		if err := json.Unmarshal(event.Data, &chunk); err != nil {
-			continue // Skip malformed events
-		}
-
-		normalizeChunk(&chunk)
-		return &chunk, nil
-	}
+// newUpstreamError builds the error for a non-200 response, adding hints
+// for known Copilot errors.
+func newUpstreamError(statusCode int, body []byte) error {
+	return openaicompat.UpstreamError(statusCode, enhanceErrorMessage(openaicompat.ErrorMessage(body)))
 }
 
-// readNonStreaming reads and parses a non-streaming response.
-// Returns io.EOF on success (response available via Response()), or error on failure.
-func (s *Stream) readNonStreaming() error {
-	body, err := io.ReadAll(s.resp.Body)
-	if err != nil {
-		s.err = err
-		return err
-	}
-
-	var resp api.ChatCompletionResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
-		s.err = err
-		return err
-	}
-
-	normalizeResponse(&resp)
-	s.response = &resp
-	return io.EOF
+// errorHint is a hint appended to upstream errors matching any of its patterns.
+type errorHint struct {
+	patterns []string // lowercase substrings
+	hint     string
 }
 
-// Response returns the non-streaming response.
-func (s *Stream) Response() *api.ChatCompletionResponse {
-	return s.response
-}
-
-// Err returns any error that occurred during streaming.
-func (s *Stream) Err() error {
-	return s.err
-}
-
-// Close releases resources associated with the stream.
-func (s *Stream) Close() error {
-	if s.resp != nil && s.resp.Body != nil {
-		return s.resp.Body.Close()
-	}
-	return nil
-}
-
-// normalizeChunk ensures OpenAI-required fields are set on streaming chunks.
-func normalizeChunk(chunk *api.ChatCompletionChunk) {
-	if chunk.Object == "" {
-		chunk.Object = "chat.completion.chunk"
-	}
-	if chunk.Created == 0 {
-		chunk.Created = time.Now().Unix()
-	}
-}
-
-// normalizeResponse ensures OpenAI-required fields are set on non-streaming responses.
-func normalizeResponse(resp *api.ChatCompletionResponse) {
-	if resp.Object == "" {
-		resp.Object = "chat.completion"
-	}
-	if resp.Created == 0 {
-		resp.Created = time.Now().Unix()
-	}
-}
-
-// parseUpstreamError extracts a meaningful error message from upstream response.
-func parseUpstreamError(body []byte) string {
-	var errResp struct {
-		Error struct {
-			Message string `json:"message"`
-		} `json:"error"`
-		Message string `json:"message"`
-	}
-
-	message := ""
-	if err := json.Unmarshal(body, &errResp); err == nil {
-		if errResp.Error.Message != "" {
-			message = errResp.Error.Message
-		} else if errResp.Message != "" {
-			message = errResp.Message
-		}
-	}
-
-	if message == "" {
-		bodyStr := string(body)
-		if len(bodyStr) > 500 {
-			bodyStr = bodyStr[:500] + "..."
-		}
-		if bodyStr == "" {
-			return "unknown error"
-		}
-		message = bodyStr
-	}
-
-	// Enhance error messages with helpful hints
-	return enhanceErrorMessage(message)
+// errorHints are checked in order; the first match wins. Context length
+// comes before token limit because context errors often mention tokens too.
+var errorHints = []errorHint{
+	{
+		patterns: []string{"context length", "context_length_exceeded", "context window", "maximum context"},
+		hint:     "The conversation exceeds the model's context window. Shorten the conversation or use a model with a larger context.",
+	},
+	{
+		patterns: []string{"token limit", "too many tokens", "tokens_limit_reached", "prompt is too long"},
+		hint:     "The request exceeds the token limit. Try splitting the prompt into smaller requests.",
+	},
+	{
+		patterns: []string{"rate limit", "rate_limit", "too many requests"},
+		hint:     "Copilot rate limit exceeded. Back off before retrying; see https://docs.github.com/en/copilot/concepts/rate-limits",
+	},
+	{
+		patterns: []string{"billing", "quota", "payment", "subscription"},
+		hint:     "Check your Copilot plan and billing: https://github.com/settings/billing",
+	},
+	{
+		patterns: []string{"service unavailable", "temporarily unavailable", "overloaded", "try again later"},
+		hint:     "The Copilot service is temporarily unavailable. Retry the request in a few moments.",
+	},
 }
 
 // enhanceErrorMessage adds helpful context to known error messages.
@@ -186,5 +68,13 @@ func enhanceErrorMessage(message string) string {
 		(strings.Contains(lower, "not supported") || strings.Contains(lower, "not available")) {
 		return message + "\n\nMake sure the model is enabled in your Copilot settings: https://github.com/settings/copilot"
 	}
+
+	for _, h := range errorHints {
+		for _, pattern := range h.patterns {
+			if strings.Contains(lower, pattern) {
+				return message + "\n\n" + h.hint
+			}
+		}
+	}
 	return message
 }
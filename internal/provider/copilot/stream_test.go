@@ -0,0 +1,36 @@
+package copilot
+
+import "testing"
+
+func TestEnhanceErrorMessage(t *testing.T) {
+	const (
+		modelHint       = "\n\nMake sure the model is enabled in your Copilot settings: https://github.com/settings/copilot"
+		contextHint     = "\n\nThe conversation exceeds the model's context window. Shorten the conversation or use a model with a larger context."
+		tokenHint       = "\n\nThe request exceeds the token limit. Try splitting the prompt into smaller requests."
+		rateLimitHint   = "\n\nCopilot rate limit exceeded. Back off before retrying; see https://docs.github.com/en/copilot/concepts/rate-limits"
+		billingHint     = "\n\nCheck your Copilot plan and billing: https://github.com/settings/billing"
+		unavailableHint = "\n\nThe Copilot service is temporarily unavailable. Retry the request in a few moments."
+	)
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"The requested model is not supported", "The requested model is not supported" + modelHint},
+		{"Model gpt-9 is not available for your account", "Model gpt-9 is not available for your account" + modelHint},
+		{"This model's maximum context length is 128000 tokens", "This model's maximum context length is 128000 tokens" + contextHint},
+		{"prompt token count of 140000 exceeds the limit (context_length_exceeded)", "prompt token count of 140000 exceeds the limit (context_length_exceeded)" + contextHint},
+		{"Request exceeds token limit", "Request exceeds token limit" + tokenHint},
+		{"prompt is too long: 210000 tokens > 200000 maximum", "prompt is too long: 210000 tokens > 200000 maximum" + tokenHint},
+		{"Rate limit exceeded, please slow down", "Rate limit exceeded, please slow down" + rateLimitHint},
+		{"You have exceeded your monthly quota", "You have exceeded your monthly quota" + billingHint},
+		{"Service Unavailable", "Service Unavailable" + unavailableHint},
+		{"streaming is not supported for this request", "streaming is not supported for this request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			if got := enhanceErrorMessage(tt.message); got != tt.want {
+				t.Errorf("enhanceErrorMessage(%q) =\n%q\nwant\n%q", tt.message, got, tt.want)
+			}
+		})
+	}
+}
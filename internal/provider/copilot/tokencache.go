@@ -0,0 +1,64 @@
+package copilot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// loadCachedToken returns the persisted Copilot token: from
+// Config.TokenCacheFile when set, otherwise from the encrypted store. The
+// error wraps os.ErrNotExist when nothing is cached.
+func (c *Client) loadCachedToken() (*CopilotToken, error) {
+	if c.cfg.TokenCacheFile == "" {
+		return c.store.GetCopilotToken(ProviderID)
+	}
+
+	data, err := os.ReadFile(c.cfg.TokenCacheFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token cache: %w", err)
+	}
+	var token CopilotToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse token cache %s: %w", c.cfg.TokenCacheFile, err)
+	}
+	return &token, nil
+}
+
+// saveCachedToken persists token where loadCachedToken looks for it.
+func (c *Client) saveCachedToken(token *CopilotToken) error {
+	if c.cfg.TokenCacheFile == "" {
+		return c.store.SetCopilotToken(ProviderID, token)
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %w", err)
+	}
+	return writeFileAtomic(c.cfg.TokenCacheFile, data)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Base(path), err)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
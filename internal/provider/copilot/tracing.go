@@ -0,0 +1,26 @@
+package copilot
+
+import (
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// tracerName is the instrumentation scope of Copilot spans.
+const tracerName = "github.com/edgard/opencompat/internal/provider/copilot"
+
+// tracer returns the Copilot tracer. It is looked up on each use so a
+// tracer provider set after startup takes effect.
+func tracer() trace.Tracer {
+	return provider.Tracer(tracerName)
+}
+
+// endSpan records err (if any) on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
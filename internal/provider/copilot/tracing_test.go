@@ -0,0 +1,178 @@
+package copilot
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/testutil"
+)
+
+const streamSSE = `data: {"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"h"}}]}
+
+data: {"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"content":"i"},"finish_reason":"stop"}]}
+
+data: [DONE]
+
+`
+
+// recordSpans routes provider spans to a recorder for the rest of the test.
+func recordSpans(t *testing.T) *testutil.SpanRecorder {
+	t.Helper()
+	rec := testutil.NewSpanRecorder()
+	provider.SetTracerProvider(rec)
+	t.Cleanup(func() { provider.SetTracerProvider(otel.GetTracerProvider()) })
+	return rec
+}
+
+// drainStream reads stream to the end and closes it.
+func drainStream(t *testing.T, stream provider.Stream) {
+	t.Helper()
+	for {
+		_, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestChatCompletionSpans(t *testing.T) {
+	tests := []struct {
+		name       string
+		stream     bool
+		body       string
+		wantEvents []string
+	}{
+		{name: "non-streaming", body: completionJSON},
+		{name: "streaming", stream: true, body: streamSSE, wantEvents: []string{"first_chunk", "final_chunk"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := recordSpans(t)
+			m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, tt.body)
+			if tt.stream {
+				m.RespondHeader("Content-Type", "text/event-stream")
+			}
+			p := newTestProvider(t, m, nil)
+
+			stream, err := p.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{
+				Model:    "gpt-4o",
+				Messages: []api.Message{api.UserMessage("say hi")},
+				Stream:   tt.stream,
+			})
+			if err != nil {
+				t.Fatalf("ChatCompletion() error = %v", err)
+			}
+			if root, _ := rec.Span("copilot.ChatCompletion"); root.Ended {
+				t.Error("copilot.ChatCompletion ended before the stream was closed")
+			}
+			drainStream(t, stream)
+
+			root, ok := rec.Span("copilot.ChatCompletion")
+			if !ok {
+				t.Fatalf("no copilot.ChatCompletion span; got %+v", rec.Spans())
+			}
+			if root.Parent != "" || !root.Ended || root.Status == codes.Error {
+				t.Errorf("copilot.ChatCompletion = %+v, want an ended root span without error", root)
+			}
+			if got := root.Attributes["provider.id"].AsString(); got != ProviderID {
+				t.Errorf("provider.id = %q, want %q", got, ProviderID)
+			}
+			if got := root.Attributes["model"].AsString(); got != "gpt-4o" {
+				t.Errorf("model = %q, want gpt-4o", got)
+			}
+			if got := root.Attributes["stream"].AsBool(); got != tt.stream {
+				t.Errorf("stream = %v, want %v", got, tt.stream)
+			}
+			if !slices.Equal(root.Events, tt.wantEvents) {
+				t.Errorf("events = %v, want %v", root.Events, tt.wantEvents)
+			}
+
+			send, ok := rec.Span("copilot.SendRequest")
+			if !ok {
+				t.Fatalf("no copilot.SendRequest span; got %+v", rec.Spans())
+			}
+			if send.Parent != "copilot.ChatCompletion" || !send.Ended {
+				t.Errorf("copilot.SendRequest = %+v, want an ended child of copilot.ChatCompletion", send)
+			}
+			if got, want := send.Attributes["request.id"].AsString(), m.Last().Header.Get("X-Request-Id"); got == "" || got != want {
+				t.Errorf("request.id = %q, want X-Request-Id %q", got, want)
+			}
+			if got := send.Attributes["http.response.status_code"].AsInt64(); got != http.StatusOK {
+				t.Errorf("http.response.status_code = %d, want %d", got, http.StatusOK)
+			}
+
+			token, ok := rec.Span("copilot.getCopilotToken")
+			if !ok {
+				t.Fatalf("no copilot.getCopilotToken span; got %+v", rec.Spans())
+			}
+			if token.Parent != "copilot.SendRequest" {
+				t.Errorf("copilot.getCopilotToken parent = %q, want copilot.SendRequest", token.Parent)
+			}
+			if token.Attributes["token.refreshed"].AsBool() {
+				t.Error("token.refreshed = true, want false for a cached token")
+			}
+		})
+	}
+}
+
+func TestChatCompletionSpanError(t *testing.T) {
+	rec := recordSpans(t)
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusBadRequest, `{"error":{"message":"bad request"}}`)
+	p := newTestProvider(t, m, nil)
+
+	stream, err := p.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []api.Message{api.UserMessage("say hi")},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if _, err := stream.Next(); err == nil || err == io.EOF {
+		t.Fatalf("Next() error = %v, want upstream error", err)
+	}
+	_ = stream.Close()
+
+	root, ok := rec.Span("copilot.ChatCompletion")
+	if !ok {
+		t.Fatalf("no copilot.ChatCompletion span; got %+v", rec.Spans())
+	}
+	if !root.Ended || root.Status != codes.Error || !slices.Contains(root.Events, "exception") {
+		t.Errorf("copilot.ChatCompletion = %+v, want an ended span with the error recorded", root)
+	}
+	if send, _ := rec.Span("copilot.SendRequest"); send.Attributes["http.response.status_code"].AsInt64() != http.StatusBadRequest {
+		t.Errorf("http.response.status_code = %v, want %d", send.Attributes["http.response.status_code"], http.StatusBadRequest)
+	}
+}
+
+func TestChatCompletionNoopTracer(t *testing.T) {
+	provider.SetTracerProvider(noop.NewTracerProvider())
+	t.Cleanup(func() { provider.SetTracerProvider(otel.GetTracerProvider()) })
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, streamSSE).
+		RespondHeader("Content-Type", "text/event-stream")
+	p := newTestProvider(t, m, nil)
+
+	stream, err := p.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []api.Message{api.UserMessage("say hi")},
+		Stream:   true,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	drainStream(t, stream)
+}
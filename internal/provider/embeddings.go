@@ -0,0 +1,29 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// ErrCapabilityNotSupported is returned (wrapped) by optional provider
+// methods when the upstream turns out not to offer the feature.
+var ErrCapabilityNotSupported = errors.New("capability not supported")
+
+// EmbeddingsRequest is the provider-facing embeddings request.
+type EmbeddingsRequest struct {
+	Model          string          // without the provider prefix
+	Input          json.RawMessage // string, []string, []int or [][]int
+	EncodingFormat string
+	Dimensions     *int
+	User           string
+}
+
+// Embedder is an optional interface for providers that can create
+// embeddings.
+type Embedder interface {
+	// Embeddings returns the embeddings of req.Input.
+	Embeddings(ctx context.Context, req *EmbeddingsRequest) (*api.EmbeddingsResponse, error)
+}
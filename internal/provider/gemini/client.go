@@ -0,0 +1,142 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
+)
+
+// HTTPTimeout is long enough for streaming responses.
+const HTTPTimeout = 5 * time.Minute
+
+// Client handles communication with the Gemini API.
+type Client struct {
+	httpClient *http.Client
+	store      *auth.Store
+}
+
+// NewClient creates a new Gemini client.
+func NewClient(store *auth.Store) *Client {
+	return &Client{
+		httpClient: &http.Client{
+			Timeout: HTTPTimeout,
+		},
+		store: store,
+	}
+}
+
+// newRequest creates an authenticated Gemini API request.
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	creds, err := c.store.GetAPIKeyCredentials(ProviderID)
+	if err != nil {
+		return nil, fmt.Errorf("auth error: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-goog-api-key", creds.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// SendRequest sends a streamGenerateContent request and returns the SSE response.
+func (c *Client) SendRequest(ctx context.Context, model string, genReq *GenerateContentRequest) (*http.Response, error) {
+	body, err := json.Marshal(genReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := GeminiModelsURL + "/" + url.PathEscape(model) + ":streamGenerateContent?alt=sse"
+	req, err := c.newRequest(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	return c.httpClient.Do(req)
+}
+
+// FetchModels lists the models that support generateContent, following
+// pagination.
+func (c *Client) FetchModels(ctx context.Context) ([]api.Model, error) {
+	var models []api.Model
+	pageToken := ""
+	for {
+		endpoint := GeminiModelsURL + "?pageSize=1000"
+		if pageToken != "" {
+			endpoint += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+		page, next, err := c.fetchModelsPage(ctx, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, page...)
+		if next == "" {
+			break
+		}
+		pageToken = next
+	}
+
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no models returned from API")
+	}
+	return models, nil
+}
+
+// fetchModelsPage fetches one page of models and returns the next page token.
+func (c *Client) fetchModelsPage(ctx context.Context, endpoint string) ([]api.Model, string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch models: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, "", fmt.Errorf("models request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var response struct {
+		Models []struct {
+			Name                       string   `json:"name"`
+			InputTokenLimit            int      `json:"inputTokenLimit"`
+			SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+		} `json:"models"`
+		NextPageToken string `json:"nextPageToken"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, "", fmt.Errorf("failed to parse models response: %w", err)
+	}
+
+	models := make([]api.Model, 0, len(response.Models))
+	for _, m := range response.Models {
+		// Skip embedding and other models that can't chat
+		if !slices.Contains(m.SupportedGenerationMethods, "generateContent") {
+			continue
+		}
+		models = append(models, api.Model{
+			ID:            strings.TrimPrefix(m.Name, "models/"),
+			Object:        "model",
+			OwnedBy:       "google",
+			ContextWindow: m.InputTokenLimit,
+		})
+	}
+	return models, response.NextPageToken, nil
+}
@@ -0,0 +1,60 @@
+package gemini
+
+import (
+	"os"
+	"strconv"
+)
+
+// Provider identification
+const ProviderID = "gemini"
+
+// Environment variable names for Gemini provider
+const (
+	EnvModelsRefresh = "OPENCOMPAT_GEMINI_MODELS_REFRESH"
+)
+
+// Default values
+const (
+	DefaultModelsRefresh = 24 * 60 // 24 hours in minutes
+)
+
+// Gemini API configuration
+const (
+	GeminiBaseURL   = "https://generativelanguage.googleapis.com/v1beta"
+	GeminiModelsURL = GeminiBaseURL + "/models"
+)
+
+// Config holds Gemini-specific configuration.
+type Config struct {
+	ModelsRefresh int // refresh interval in minutes
+}
+
+// LoadConfig reads Gemini configuration from environment variables.
+func LoadConfig() *Config {
+	return &Config{
+		ModelsRefresh: getEnvInt(EnvModelsRefresh, DefaultModelsRefresh),
+	}
+}
+
+// EnvVarDoc documents an environment variable.
+type EnvVarDoc struct {
+	Name        string
+	Description string
+	Default     string
+}
+
+// EnvVarDocs returns documentation for environment variables.
+func EnvVarDocs() []EnvVarDoc {
+	return []EnvVarDoc{
+		{Name: EnvModelsRefresh, Description: "Models refresh interval in minutes", Default: strconv.Itoa(DefaultModelsRefresh)},
+	}
+}
+
+func getEnvInt(key string, defaultVal int) int {
+	if val := os.Getenv(key); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			return i
+		}
+	}
+	return defaultVal
+}
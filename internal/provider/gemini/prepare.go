@@ -0,0 +1,99 @@
+package gemini
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// maxImageBytes caps fetched images. Gemini rejects requests with more than
+// 20 MB of inline data.
+const maxImageBytes = 20 << 20
+
+// PrepareRequest downloads http(s) image URLs and replaces them with base64
+// data URLs. The Gemini API only reads fileData URIs from its own Files API
+// and Cloud Storage, so remote images must be sent inline.
+func (p *Provider) PrepareRequest(ctx context.Context, req *provider.ChatCompletionRequest) (*provider.ChatCompletionRequest, error) {
+	var messages []api.Message
+	for i, msg := range req.Messages {
+		parts := msg.GetContentParts()
+		var newParts []api.ContentPart
+		for j, part := range parts {
+			if part.Type != "image_url" || part.ImageURL == nil || !isRemoteURL(part.ImageURL.URL) {
+				continue
+			}
+			dataURL, err := p.client.FetchImage(ctx, part.ImageURL.URL)
+			if err != nil {
+				return nil, api.NewUpstreamError(http.StatusBadRequest,
+					fmt.Sprintf("messages[%d]: failed to fetch image: %v", i, err))
+			}
+			if newParts == nil {
+				newParts = append([]api.ContentPart(nil), parts...)
+			}
+			imageURL := *part.ImageURL
+			imageURL.URL = dataURL
+			newParts[j].ImageURL = &imageURL
+		}
+		if newParts == nil {
+			continue
+		}
+
+		// Copy on first change so the caller's request is left untouched
+		if messages == nil {
+			messages = append([]api.Message(nil), req.Messages...)
+		}
+		messages[i].SetContentParts(newParts)
+	}
+
+	if messages == nil {
+		return req, nil
+	}
+	prepared := *req
+	prepared.Messages = messages
+	return &prepared, nil
+}
+
+// isRemoteURL reports whether url is an http(s) URL.
+func isRemoteURL(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// FetchImage downloads an image and returns it as a base64 data URL.
+func (c *Client) FetchImage(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	mimeType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxImageBytes {
+		return "", fmt.Errorf("image exceeds %d MB", maxImageBytes>>20)
+	}
+
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,125 @@
+// Package gemini implements the Google Gemini provider.
+package gemini
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+func init() {
+	provider.AddRegistration(func(r *provider.Registry) {
+		r.RegisterMeta(provider.ProviderMeta{
+			ID:              ProviderID,
+			Name:            "Google Gemini",
+			AuthMethod:      auth.AuthMethodAPIKey,
+			EnvVars:         convertEnvVarDocs(EnvVarDocs()),
+			Factory:         New,
+			Capabilities:    provider.CapabilityStreaming | provider.CapabilityToolCalls | provider.CapabilityVision,
+			ParameterBounds: provider.OpenAIParameterBounds,
+		})
+	})
+}
+
+// convertEnvVarDocs converts gemini.EnvVarDoc to provider.EnvVarDoc.
+func convertEnvVarDocs(docs []EnvVarDoc) []provider.EnvVarDoc {
+	result := make([]provider.EnvVarDoc, len(docs))
+	for i, d := range docs {
+		result[i] = provider.EnvVarDoc{
+			Name:        d.Name,
+			Description: d.Description,
+			Default:     d.Default,
+		}
+	}
+	return result
+}
+
+// Provider implements the Gemini provider.
+type Provider struct {
+	client      *Client
+	modelsCache *provider.ModelsCache
+}
+
+// New creates a new Gemini provider.
+func New(store *auth.Store) (provider.Provider, error) {
+	cfg := LoadConfig()
+	client := NewClient(store)
+	return &Provider{
+		client:      client,
+		modelsCache: provider.NewModelsCache(ProviderID, client.FetchModels, cfg.ModelsRefresh),
+	}, nil
+}
+
+// ID returns the provider identifier.
+func (p *Provider) ID() string {
+	return ProviderID
+}
+
+// Models returns the list of supported models.
+func (p *Provider) Models() []api.Model {
+	return p.modelsCache.GetModels()
+}
+
+// SupportedParameters returns the optional request parameters mapped to generateContent.
+func (p *Provider) SupportedParameters() []string {
+	return []string{
+		"temperature",
+		"top_p",
+		"stop",
+		"max_tokens",
+		"max_completion_tokens",
+		"presence_penalty",
+		"frequency_penalty",
+		"response_format",
+	}
+}
+
+// SupportedToolTypes returns the tool types accepted by the upstream API.
+func (p *Provider) SupportedToolTypes() []string {
+	return []string{"function"}
+}
+
+// SupportsModel checks if a model ID is supported.
+func (p *Provider) SupportsModel(modelID string) bool {
+	return p.modelsCache.SupportsModel(modelID)
+}
+
+// ChatCompletion sends a chat completion request.
+func (p *Provider) ChatCompletion(ctx context.Context, req *provider.ChatCompletionRequest) (provider.Stream, error) {
+	genReq, err := TransformRequest(req)
+	if err != nil {
+		return nil, api.NewUpstreamError(http.StatusBadRequest, err.Error())
+	}
+
+	resp, err := p.client.SendRequest(ctx, req.Model, genReq)
+	if err != nil {
+		return nil, err
+	}
+
+	includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+	return NewStream(resp, req.Model, includeUsage), nil
+}
+
+// Init performs initialization - fetches models list.
+func (p *Provider) Init() error {
+	_ = p.modelsCache.GetModels()
+	return nil
+}
+
+// Start begins background tasks.
+func (p *Provider) Start() {
+	p.modelsCache.StartBackgroundRefresh()
+}
+
+// Close stops background tasks.
+func (p *Provider) Close() {
+	p.modelsCache.StopBackgroundRefresh()
+}
+
+// RefreshModels forces a refresh of the models list.
+func (p *Provider) RefreshModels(ctx context.Context) error {
+	return p.modelsCache.RefreshModels(ctx)
+}
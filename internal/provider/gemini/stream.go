@@ -0,0 +1,295 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/sse"
+)
+
+// Stream implements the provider.Stream interface for Gemini responses,
+// converting streamed GenerateContentResponse chunks into chat completion
+// chunks. The upstream request always streams; for non-streaming requests
+// the chunks are merged into the response returned by Response().
+type Stream struct {
+	resp          *http.Response
+	reader        *sse.Reader
+	includeUsage  bool
+	statusChecked bool
+	sentRole      bool
+	done          bool
+	err           error
+
+	id      string
+	model   string
+	created int64
+	usage   *UsageMetadata
+
+	toolCalls int // function calls emitted so far
+	chunks    []api.ChatCompletionChunk
+	response  *api.ChatCompletionResponse
+}
+
+// NewStream creates a new stream from an HTTP response.
+func NewStream(resp *http.Response, model string, includeUsage bool) *Stream {
+	return &Stream{
+		resp:         resp,
+		reader:       sse.NewReader(resp.Body),
+		includeUsage: includeUsage,
+		id:           "chatcmpl-" + uuid.New().String(),
+		model:        model,
+		created:      time.Now().Unix(),
+	}
+}
+
+// Next returns the next chunk from the stream.
+func (s *Stream) Next() (*api.ChatCompletionChunk, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+
+	// Check HTTP status once
+	if !s.statusChecked {
+		s.statusChecked = true
+		if s.resp.StatusCode != http.StatusOK {
+			s.done = true
+			body, _ := io.ReadAll(s.resp.Body)
+			s.err = newUpstreamError(s.resp.StatusCode, body)
+			return nil, s.err
+		}
+	}
+
+	for {
+		event, err := s.reader.ReadEvent()
+		if err == io.EOF {
+			return s.finish()
+		}
+		if err != nil {
+			s.done = true
+			s.err = err
+			return nil, err
+		}
+
+		chunk, err := s.processEvent(event)
+		if err != nil {
+			s.done = true
+			s.err = err
+			return nil, err
+		}
+		if chunk != nil {
+			s.chunks = append(s.chunks, *chunk)
+			return chunk, nil
+		}
+	}
+}
+
+// processEvent converts one SSE event, returning nil if it carries nothing
+// to forward.
+func (s *Stream) processEvent(event *sse.Event) (*api.ChatCompletionChunk, error) {
+	var e struct {
+		GenerateContentResponse
+		Error *ErrorBody `json:"error,omitempty"`
+	}
+	if err := json.Unmarshal(event.Data, &e); err != nil {
+		return nil, fmt.Errorf("invalid stream event: %w", err)
+	}
+	if e.Error != nil {
+		return nil, api.NewUpstreamError(e.Error.statusCode(), e.Error.Message)
+	}
+
+	if e.UsageMetadata != nil {
+		// Usage is cumulative; the last report wins
+		s.usage = e.UsageMetadata
+	}
+	if e.ModelVersion != "" {
+		s.model = e.ModelVersion
+	}
+	if e.PromptFeedback != nil && e.PromptFeedback.BlockReason != "" && len(e.Candidates) == 0 {
+		reason := "content_filter"
+		return s.chunk(s.withRole(api.Delta{}), &reason), nil
+	}
+	if len(e.Candidates) == 0 {
+		return nil, nil
+	}
+
+	// Only a single candidate is requested
+	cand := e.Candidates[0]
+	delta := api.Delta{}
+	var texts []string
+	for _, part := range cand.Content.Parts {
+		switch {
+		case part.Thought:
+			// Thought summaries are not part of the answer
+		case part.FunctionCall != nil:
+			index := s.toolCalls
+			s.toolCalls++
+			args := string(part.FunctionCall.Args)
+			if args == "" {
+				args = "{}"
+			}
+			id := part.FunctionCall.ID
+			if id == "" {
+				id = "call_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+			}
+			delta.ToolCalls = append(delta.ToolCalls, api.ToolCall{
+				Index:    &index,
+				ID:       id,
+				Type:     "function",
+				Function: api.FunctionCall{Name: part.FunctionCall.Name, Arguments: args},
+			})
+		case part.Text != "":
+			texts = append(texts, part.Text)
+		}
+	}
+	delta.Content = strings.Join(texts, "")
+
+	var reason *string
+	if cand.FinishReason != "" && cand.FinishReason != "FINISH_REASON_UNSPECIFIED" {
+		r := finishReason(cand.FinishReason, s.toolCalls > 0)
+		reason = &r
+	}
+
+	if delta.Content == "" && len(delta.ToolCalls) == 0 && reason == nil {
+		return nil, nil
+	}
+	return s.chunk(s.withRole(delta), reason), nil
+}
+
+// withRole sets the assistant role on the first emitted delta.
+func (s *Stream) withRole(delta api.Delta) api.Delta {
+	if !s.sentRole {
+		s.sentRole = true
+		delta.Role = "assistant"
+	}
+	return delta
+}
+
+// chunk builds a single-choice chunk.
+func (s *Stream) chunk(delta api.Delta, finishReason *string) *api.ChatCompletionChunk {
+	return &api.ChatCompletionChunk{
+		ID:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []api.Choice{{
+			Index:        0,
+			Delta:        &delta,
+			FinishReason: finishReason,
+		}},
+	}
+}
+
+// finish ends the stream, builds the merged response and returns the usage
+// chunk if the client asked for one.
+func (s *Stream) finish() (*api.ChatCompletionChunk, error) {
+	s.done = true
+
+	usageChunk := &api.ChatCompletionChunk{
+		ID:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []api.Choice{},
+		Usage:   s.openAIUsage(),
+	}
+	if resp, err := api.MergeChunks(append(s.chunks, *usageChunk)); err == nil {
+		resp.ID = s.id
+		resp.Created = s.created
+		resp.Model = s.model
+		s.response = resp
+	}
+
+	if s.includeUsage {
+		return usageChunk, nil
+	}
+	return nil, io.EOF
+}
+
+// openAIUsage converts the last reported usage. Thinking tokens are billed
+// as output, so they count toward completion tokens.
+func (s *Stream) openAIUsage() *api.Usage {
+	if s.usage == nil {
+		return &api.Usage{}
+	}
+	completion := s.usage.CandidatesTokenCount + s.usage.ThoughtsTokenCount
+	usage := &api.Usage{
+		PromptTokens:     s.usage.PromptTokenCount,
+		CompletionTokens: completion,
+		TotalTokens:      s.usage.PromptTokenCount + completion,
+	}
+	if s.usage.CachedContentTokenCount > 0 {
+		usage.PromptTokensDetails = &api.PromptTokenDetails{CachedTokens: s.usage.CachedContentTokenCount}
+	}
+	return usage
+}
+
+// Response returns the accumulated response. Call after Next() returns io.EOF.
+func (s *Stream) Response() *api.ChatCompletionResponse {
+	return s.response
+}
+
+// Err returns any error that occurred during streaming.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Close releases resources associated with the stream.
+func (s *Stream) Close() error {
+	if s.resp != nil && s.resp.Body != nil {
+		return s.resp.Body.Close()
+	}
+	return nil
+}
+
+// ErrorBody is the error object in Gemini error responses.
+type ErrorBody struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// statusCode returns the HTTP status for the error, falling back to 502.
+func (e *ErrorBody) statusCode() int {
+	if e.Code >= 400 && e.Code < 600 {
+		return e.Code
+	}
+	return http.StatusBadGateway
+}
+
+// newUpstreamError builds the error for a non-200 response, recognizing
+// context length errors so the handler can suggest larger models.
+func newUpstreamError(statusCode int, body []byte) error {
+	message := parseUpstreamError(body)
+	if statusCode == http.StatusBadRequest {
+		if ctxErr := api.ParseContextLengthExceeded(message); ctxErr != nil {
+			return ctxErr
+		}
+	}
+	return api.NewUpstreamError(statusCode, message)
+}
+
+// parseUpstreamError extracts the message from a Gemini error body.
+func parseUpstreamError(body []byte) string {
+	var errResp struct {
+		Error ErrorBody `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+		return errResp.Error.Message
+	}
+
+	bodyStr := string(body)
+	if len(bodyStr) > 500 {
+		bodyStr = bodyStr[:500] + "..."
+	}
+	if bodyStr == "" {
+		return "unknown error"
+	}
+	return bodyStr
+}
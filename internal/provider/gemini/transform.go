@@ -0,0 +1,256 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// TransformRequest converts a chat completion request to a generateContent request.
+func TransformRequest(req *provider.ChatCompletionRequest) (*GenerateContentRequest, error) {
+	system, contents, err := transformMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	stop, err := parseStop(req.Stop)
+	if err != nil {
+		return nil, err
+	}
+
+	toolConfig, err := transformToolChoice(req.ToolChoice)
+	if err != nil {
+		return nil, err
+	}
+
+	genCfg := &GenerationConfig{
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		MaxOutputTokens:  req.MaxTokens,
+		StopSequences:    stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+	}
+	if req.MaxCompletionTokens != nil {
+		genCfg.MaxOutputTokens = req.MaxCompletionTokens
+	}
+	if req.ResponseFormat != nil && req.ResponseFormat.Type == "json_object" {
+		genCfg.ResponseMimeType = "application/json"
+	}
+
+	out := &GenerateContentRequest{
+		Contents:          contents,
+		SystemInstruction: system,
+		ToolConfig:        toolConfig,
+		GenerationConfig:  genCfg,
+	}
+	if len(req.Tools) > 0 {
+		decls := make([]FunctionDeclaration, 0, len(req.Tools))
+		for _, tool := range req.Tools {
+			decls = append(decls, FunctionDeclaration{
+				Name:                 tool.Function.Name,
+				Description:          tool.Function.Description,
+				ParametersJSONSchema: tool.Function.Parameters,
+			})
+		}
+		out.Tools = []Tool{{FunctionDeclarations: decls}}
+	}
+	return out, nil
+}
+
+// transformMessages moves system messages into the system instruction and
+// converts the rest to Gemini contents. Function responses are matched to
+// their call by tool_call_id, since Gemini identifies them by function
+// name. Consecutive contents with the same role are merged so that parallel
+// function responses are sent in a single turn.
+func transformMessages(messages []api.Message) (*Content, []Content, error) {
+	var system *Content
+	var contents []Content
+	callNames := make(map[string]string) // tool call ID -> function name
+
+	appendParts := func(role string, parts []Part) {
+		if len(parts) == 0 {
+			return
+		}
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+			return
+		}
+		contents = append(contents, Content{Role: role, Parts: parts})
+	}
+
+	for i, msg := range messages {
+		switch msg.Role {
+		case "system":
+			if text := textContent(&msg); text != "" {
+				if system == nil {
+					system = &Content{}
+				}
+				system.Parts = append(system.Parts, Part{Text: text})
+			}
+
+		case "user":
+			parts, err := userParts(&msg)
+			if err != nil {
+				return nil, nil, fmt.Errorf("messages[%d]: %w", i, err)
+			}
+			appendParts("user", parts)
+
+		case "assistant":
+			var parts []Part
+			if text := textContent(&msg); text != "" {
+				parts = append(parts, Part{Text: text})
+			}
+			for _, tc := range msg.ToolCalls {
+				args := json.RawMessage(tc.Function.Arguments)
+				if len(strings.TrimSpace(tc.Function.Arguments)) == 0 {
+					args = json.RawMessage(`{}`)
+				} else if !json.Valid(args) {
+					return nil, nil, fmt.Errorf("messages[%d]: tool call %s has invalid JSON arguments", i, tc.ID)
+				}
+				callNames[tc.ID] = tc.Function.Name
+				parts = append(parts, Part{FunctionCall: &FunctionCall{Name: tc.Function.Name, Args: args}})
+			}
+			appendParts("model", parts)
+
+		case "tool":
+			name, ok := callNames[msg.ToolCallID]
+			if !ok {
+				return nil, nil, fmt.Errorf("messages[%d]: tool_call_id %q does not match an earlier tool call", i, msg.ToolCallID)
+			}
+			appendParts("user", []Part{{FunctionResponse: &FunctionResponse{
+				Name:     name,
+				Response: functionResult(textContent(&msg)),
+			}}})
+		}
+	}
+
+	return system, contents, nil
+}
+
+// functionResult wraps a tool result in the JSON object Gemini requires.
+// Results that already are JSON objects are passed through.
+func functionResult(content string) json.RawMessage {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return json.RawMessage(trimmed)
+	}
+	data, _ := json.Marshal(map[string]string{"result": content})
+	return data
+}
+
+// textContent joins the text parts of a message.
+func textContent(msg *api.Message) string {
+	var texts []string
+	for _, part := range msg.GetContentParts() {
+		if part.Type == "text" && part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// userParts converts user content parts to text, inline data and file parts.
+func userParts(msg *api.Message) ([]Part, error) {
+	var parts []Part
+	for _, part := range msg.GetContentParts() {
+		switch part.Type {
+		case "text":
+			if part.Text != "" {
+				parts = append(parts, Part{Text: part.Text})
+			}
+		case "image_url":
+			if part.ImageURL == nil {
+				continue
+			}
+			p, err := imagePart(part.ImageURL.URL)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, p)
+		case "input_audio":
+			if part.InputAudio == nil {
+				continue
+			}
+			parts = append(parts, Part{InlineData: &Blob{
+				MimeType: "audio/" + part.InputAudio.Format,
+				Data:     part.InputAudio.Data,
+			}})
+		}
+	}
+	return parts, nil
+}
+
+// imagePart converts an image URL, which may be a base64 data URL.
+func imagePart(url string) (Part, error) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return Part{FileData: &FileData{FileURI: url}}, nil
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	mimeType, isBase64 := strings.CutSuffix(meta, ";base64")
+	if !ok || !isBase64 {
+		return Part{}, fmt.Errorf("unsupported image data URL; expected data:<media type>;base64,<data>")
+	}
+	return Part{InlineData: &Blob{MimeType: mimeType, Data: data}}, nil
+}
+
+// parseStop converts stop (a string or array of strings) to stop sequences.
+func parseStop(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("stop must be a string or an array of strings")
+	}
+	return list, nil
+}
+
+// transformToolChoice maps tool_choice ("none", "auto", "required" or a
+// named function) to a function calling config.
+func transformToolChoice(raw json.RawMessage) (*ToolConfig, error) {
+	choice, err := api.ParseToolChoice(raw)
+	if err != nil || choice == nil {
+		return nil, err
+	}
+
+	switch choice.Mode {
+	case api.ToolChoiceNone:
+		return &ToolConfig{FunctionCallingConfig: FunctionCallingConfig{Mode: "NONE"}}, nil
+	case api.ToolChoiceRequired:
+		return &ToolConfig{FunctionCallingConfig: FunctionCallingConfig{Mode: "ANY"}}, nil
+	case api.ToolChoiceFunction:
+		return &ToolConfig{FunctionCallingConfig: FunctionCallingConfig{
+			Mode:                 "ANY",
+			AllowedFunctionNames: []string{choice.Function},
+		}}, nil
+	default:
+		return &ToolConfig{FunctionCallingConfig: FunctionCallingConfig{Mode: "AUTO"}}, nil
+	}
+}
+
+// finishReason maps a Gemini finish reason to an OpenAI finish reason.
+// STOP becomes "tool_calls" when the candidate called a function.
+func finishReason(reason string, calledFunction bool) string {
+	switch reason {
+	case "STOP":
+		if calledFunction {
+			return "tool_calls"
+		}
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
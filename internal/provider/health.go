@@ -0,0 +1,100 @@
+package provider
+
+import "time"
+
+// HealthState is the coarse health of a provider.
+type HealthState int
+
+const (
+	// Healthy providers can serve requests.
+	Healthy HealthState = iota
+	// Degraded providers still serve requests, but with stale data or a
+	// tripped circuit breaker.
+	Degraded
+	// Unhealthy providers cannot serve requests, e.g. without credentials.
+	Unhealthy
+)
+
+// String returns the state name.
+func (s HealthState) String() string {
+	switch s {
+	case Degraded:
+		return "degraded"
+	case Unhealthy:
+		return "unhealthy"
+	default:
+		return "ok"
+	}
+}
+
+// MarshalText encodes the state as its name.
+func (s HealthState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// HealthStatus is a provider's report of its own health.
+type HealthStatus struct {
+	State       HealthState
+	Message     string    // reason for a state other than Healthy
+	ModelsCount int       // models currently served
+	LastRefresh time.Time // last models refresh; zero when unknown
+}
+
+// HealthChecker is an optional interface for providers that can report
+// their health. Providers that don't implement it are considered healthy.
+type HealthChecker interface {
+	Health() HealthStatus
+}
+
+// ProviderHealth is the health of one active provider.
+type ProviderHealth struct {
+	ID          string      `json:"id"`
+	Status      HealthState `json:"status"`
+	Message     string      `json:"message,omitempty"`
+	Circuit     string      `json:"circuit"`
+	ModelsCount int         `json:"models_count"`
+	LastRefresh *time.Time  `json:"last_refresh,omitempty"`
+}
+
+// RegistryHealth aggregates the health of all active providers. Status is
+// Healthy only when every provider is.
+type RegistryHealth struct {
+	Status    HealthState      `json:"status"`
+	Providers []ProviderHealth `json:"providers"`
+}
+
+// HealthSummary reports the health of the active providers, sorted by ID.
+// A provider whose circuit breaker is open is at least Degraded.
+func (r *Registry) HealthSummary() RegistryHealth {
+	summary := RegistryHealth{Status: Healthy, Providers: []ProviderHealth{}}
+	for _, p := range r.ActiveProviders() {
+		var status HealthStatus
+		if hc, ok := p.(HealthChecker); ok {
+			status = hc.Health()
+		} else {
+			status = HealthStatus{State: Healthy, ModelsCount: len(p.Models())}
+		}
+
+		circuit := r.ProviderStatus(p.ID())
+		if circuit == CircuitOpen && status.State == Healthy {
+			status.State = Degraded
+			status.Message = "circuit breaker open"
+		}
+
+		health := ProviderHealth{
+			ID:          p.ID(),
+			Status:      status.State,
+			Message:     status.Message,
+			Circuit:     circuit.String(),
+			ModelsCount: status.ModelsCount,
+		}
+		if !status.LastRefresh.IsZero() {
+			health.LastRefresh = &status.LastRefresh
+		}
+		summary.Providers = append(summary.Providers, health)
+		if status.State != Healthy {
+			summary.Status = Degraded
+		}
+	}
+	return summary
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// DefaultMaxAgentTurns bounds the tool-calling turns of one request when
+// AgentConfig.MaxAgentTurns is unset.
+const DefaultMaxAgentTurns = 5
+
+// ToolHandler executes a tool call and returns the content of its tool
+// message. A returned error is reported to the model as the result.
+type ToolHandler func(ctx context.Context, call api.ToolCall) (string, error)
+
+// AgentConfig configures an AgentMiddleware.
+type AgentConfig struct {
+	// AutoExecuteTools enables tool execution; when false the middleware
+	// passes requests through unchanged.
+	AutoExecuteTools bool
+	// MaxAgentTurns is the number of model calls made for one request
+	// before the pending tool calls are returned to the client; 0 uses
+	// DefaultMaxAgentTurns.
+	MaxAgentTurns int
+}
+
+// AgentMiddleware wraps a provider and completes the tool-calling loop
+// itself: when a response ends with finish_reason "tool_calls" and every
+// call has a registered handler, the calls are executed and the request is
+// re-sent with the assistant message and the tool results appended. Calls
+// to unregistered tools are returned to the client as usual.
+//
+// While tools are enabled the whole response is buffered before it is
+// returned, so streaming clients only receive the final turn.
+type AgentMiddleware struct {
+	provider.Provider
+	cfg AgentConfig
+
+	mu       sync.RWMutex
+	handlers map[string]ToolHandler // by function name
+}
+
+// NewAgentMiddleware wraps p.
+func NewAgentMiddleware(p provider.Provider, cfg AgentConfig) *AgentMiddleware {
+	if cfg.MaxAgentTurns <= 0 {
+		cfg.MaxAgentTurns = DefaultMaxAgentTurns
+	}
+	return &AgentMiddleware{Provider: p, cfg: cfg, handlers: make(map[string]ToolHandler)}
+}
+
+// Register sets the handler for the function called name, replacing any
+// previous one.
+func (m *AgentMiddleware) Register(name string, handler ToolHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[name] = handler
+}
+
+// handler returns the handler for name, or nil.
+func (m *AgentMiddleware) handler(name string) ToolHandler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.handlers[name]
+}
+
+// ChatCompletion sends req, executing tool calls with the registered
+// handlers for up to MaxAgentTurns turns.
+func (m *AgentMiddleware) ChatCompletion(ctx context.Context, req *provider.ChatCompletionRequest) (provider.Stream, error) {
+	if !m.cfg.AutoExecuteTools {
+		return m.Provider.ChatCompletion(ctx, req)
+	}
+
+	turnReq := req
+	for turn := 1; ; turn++ {
+		stream, err := m.Provider.ChatCompletion(ctx, turnReq)
+		if err != nil {
+			return nil, err
+		}
+		buffered := bufferStream(stream)
+		_ = stream.Close()
+		if buffered.err != nil {
+			return buffered, nil
+		}
+
+		calls := m.pendingToolCalls(buffered)
+		if len(calls) == 0 {
+			return buffered, nil
+		}
+		if turn >= m.cfg.MaxAgentTurns {
+			slog.Warn("agent turn limit reached, returning tool calls to client",
+				"provider", m.ID(),
+				"turns", turn,
+			)
+			return buffered, nil
+		}
+
+		messages := append([]api.Message(nil), turnReq.Messages...)
+		assistant := api.AssistantMessage("")
+		assistant.ToolCalls = calls
+		messages = append(messages, assistant)
+		for _, call := range calls {
+			messages = append(messages, api.ToolResultMessage(call.ID, m.execute(ctx, call)))
+		}
+		next := *turnReq
+		next.Messages = messages
+		turnReq = &next
+	}
+}
+
+// pendingToolCalls returns the tool calls of a response that stopped for
+// them, or nil unless all of them can be executed here. Only the first
+// choice is considered.
+func (m *AgentMiddleware) pendingToolCalls(b *bufferedStream) []api.ToolCall {
+	resp := b.resp
+	if (resp == nil || len(resp.Choices) == 0) && len(b.chunks) > 0 {
+		merged, err := api.MergeChunks(b.chunks)
+		if err != nil {
+			return nil
+		}
+		resp = merged
+	}
+	if resp == nil || len(resp.Choices) == 0 {
+		return nil
+	}
+
+	choice := resp.Choices[0]
+	if choice.FinishReason == nil || *choice.FinishReason != "tool_calls" || choice.Message == nil {
+		return nil
+	}
+	if len(choice.Message.ToolCalls) == 0 {
+		return nil
+	}
+	calls := make([]api.ToolCall, len(choice.Message.ToolCalls))
+	for i, call := range choice.Message.ToolCalls {
+		if m.handler(call.Function.Name) == nil {
+			return nil
+		}
+		// Indexes only belong in streaming deltas
+		call.Index = nil
+		if call.Type == "" {
+			call.Type = "function"
+		}
+		calls[i] = call
+	}
+	return calls
+}
+
+// execute runs one tool call, turning errors and panics into the result
+// text so the model can react to them.
+func (m *AgentMiddleware) execute(ctx context.Context, call api.ToolCall) (result string) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("tool handler panicked", "tool", call.Function.Name, "panic", r)
+			result = fmt.Sprintf("error: tool %s failed", call.Function.Name)
+		}
+	}()
+
+	out, err := m.handler(call.Function.Name)(ctx, call)
+	if err != nil {
+		slog.Debug("tool handler failed", "tool", call.Function.Name, "error", err)
+		return "error: " + err.Error()
+	}
+	return out
+}
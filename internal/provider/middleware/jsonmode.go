@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// JSONModeEnforcement controls how json_object responses are checked.
+type JSONModeEnforcement string
+
+// JSON mode enforcement levels
+const (
+	// JSONModePassthrough returns responses unchecked.
+	JSONModePassthrough JSONModeEnforcement = "passthrough"
+	// JSONModeStrict fails the request when the content isn't valid JSON.
+	JSONModeStrict JSONModeEnforcement = "strict"
+	// JSONModeRetry re-sends the request with an extra instruction when the
+	// content isn't valid JSON, failing after MaxJSONRetries retries.
+	JSONModeRetry JSONModeEnforcement = "retry"
+)
+
+// MaxJSONRetries is the number of retries in JSONModeRetry.
+const MaxJSONRetries = 3
+
+// jsonRetryInstruction is appended as a user message when retrying.
+const jsonRetryInstruction = "Respond only with valid JSON."
+
+// ParseJSONModeEnforcement parses an enforcement level. Empty means passthrough.
+func ParseJSONModeEnforcement(s string) (JSONModeEnforcement, error) {
+	switch mode := JSONModeEnforcement(s); mode {
+	case "":
+		return JSONModePassthrough, nil
+	case JSONModePassthrough, JSONModeStrict, JSONModeRetry:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid JSON mode enforcement %q (use passthrough, strict or retry)", s)
+	}
+}
+
+// JSONModeMiddleware validates the content of response_format json_object
+// responses according to its enforcement level.
+type JSONModeMiddleware struct {
+	mode JSONModeEnforcement
+}
+
+// NewJSONModeMiddleware creates a middleware with the given enforcement level.
+func NewJSONModeMiddleware(mode JSONModeEnforcement) *JSONModeMiddleware {
+	return &JSONModeMiddleware{mode: mode}
+}
+
+// ChatCompletion sends req to p, enforcing JSON output when the request asks
+// for json_object. In retry mode the whole response is buffered before it is
+// returned, so streaming clients receive it only once it has been validated.
+func (m *JSONModeMiddleware) ChatCompletion(ctx context.Context, p provider.Provider, req *provider.ChatCompletionRequest) (provider.Stream, error) {
+	if m.mode == JSONModePassthrough || req.ResponseFormat == nil || req.ResponseFormat.Type != "json_object" {
+		return p.ChatCompletion(ctx, req)
+	}
+
+	if m.mode == JSONModeStrict {
+		stream, err := p.ChatCompletion(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonValidatingStream{Stream: stream}, nil
+	}
+
+	attemptReq := req
+	for attempt := 0; ; attempt++ {
+		stream, err := p.ChatCompletion(ctx, attemptReq)
+		if err != nil {
+			return nil, err
+		}
+
+		buffered := bufferStream(stream)
+		_ = stream.Close()
+
+		// Upstream errors are returned as-is rather than retried
+		if buffered.err != nil {
+			return buffered, nil
+		}
+
+		err = validateJSONContent(buffered.resp, buffered.chunks)
+		if err == nil {
+			return buffered, nil
+		}
+		if attempt >= MaxJSONRetries {
+			buffered.err = err
+			return buffered, nil
+		}
+
+		slog.Debug("response is not valid JSON, retrying",
+			"provider", p.ID(),
+			"attempt", attempt+1,
+			"error", err,
+		)
+
+		retryReq := *req
+		retryReq.Messages = append(append([]api.Message(nil), req.Messages...), api.UserMessage(jsonRetryInstruction))
+		attemptReq = &retryReq
+	}
+}
+
+// validateJSONContent checks that every choice's content is valid JSON.
+// Choices that only carry tool calls are skipped.
+func validateJSONContent(resp *api.ChatCompletionResponse, chunks []api.ChatCompletionChunk) error {
+	// Streaming providers may not accumulate a response; rebuild it from chunks
+	if (resp == nil || len(resp.Choices) == 0) && len(chunks) > 0 {
+		merged, err := api.MergeChunks(chunks)
+		if err != nil {
+			return err
+		}
+		resp = merged
+	}
+	if resp == nil {
+		return nil
+	}
+
+	for _, choice := range resp.Choices {
+		if choice.Message == nil {
+			continue
+		}
+		content := choice.Message.GetContentString()
+		if content == "" && len(choice.Message.ToolCalls) > 0 {
+			continue
+		}
+		if !json.Valid([]byte(content)) {
+			return api.NewUpstreamError(http.StatusBadGateway,
+				fmt.Sprintf("model response for choice %d is not valid JSON", choice.Index))
+		}
+	}
+	return nil
+}
+
+// jsonValidatingStream passes chunks through and validates the content once
+// the stream ends, reporting invalid JSON via Err().
+type jsonValidatingStream struct {
+	provider.Stream
+
+	chunks []api.ChatCompletionChunk
+	err    error
+}
+
+func (s *jsonValidatingStream) Next() (*api.ChatCompletionChunk, error) {
+	chunk, err := s.Stream.Next()
+	if err == io.EOF && s.err == nil && s.Stream.Err() == nil {
+		s.err = validateJSONContent(s.Stream.Response(), s.chunks)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if chunk != nil {
+		s.chunks = append(s.chunks, *chunk)
+	}
+	return chunk, nil
+}
+
+func (s *jsonValidatingStream) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.Stream.Err()
+}
+
+// bufferedStream replays a fully consumed stream.
+type bufferedStream struct {
+	chunks []api.ChatCompletionChunk
+	pos    int
+	resp   *api.ChatCompletionResponse
+	err    error
+}
+
+// bufferStream consumes stream into memory. The caller closes stream.
+func bufferStream(stream provider.Stream) *bufferedStream {
+	b := &bufferedStream{}
+	for {
+		chunk, err := stream.Next()
+		if err != nil {
+			if err != io.EOF {
+				b.err = err
+			}
+			break
+		}
+		if chunk != nil {
+			b.chunks = append(b.chunks, *chunk)
+		}
+	}
+	if b.err == nil {
+		b.err = stream.Err()
+	}
+	b.resp = stream.Response()
+	return b
+}
+
+func (b *bufferedStream) Next() (*api.ChatCompletionChunk, error) {
+	if b.pos >= len(b.chunks) {
+		// Mirror the original stream: errors are reported from Next once chunks run out
+		if b.err != nil {
+			return nil, b.err
+		}
+		return nil, io.EOF
+	}
+	chunk := &b.chunks[b.pos]
+	b.pos++
+	return chunk, nil
+}
+
+func (b *bufferedStream) Response() *api.ChatCompletionResponse {
+	return b.resp
+}
+
+func (b *bufferedStream) Err() error {
+	return b.err
+}
+
+func (b *bufferedStream) Close() error {
+	return nil
+}
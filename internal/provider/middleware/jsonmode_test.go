@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/provider/mock"
+)
+
+const jsonModel = "json-model"
+
+// contentChunks streams content as a single assistant message.
+func contentChunks(content string) []*api.ChatCompletionChunk {
+	stop := "stop"
+	return []*api.ChatCompletionChunk{
+		{ID: "1", Choices: []api.Choice{{Delta: &api.Delta{Role: "assistant", Content: content}}}},
+		{ID: "1", Choices: []api.Choice{{Delta: &api.Delta{}, FinishReason: &stop}}},
+	}
+}
+
+// fixedAfter is a mock provider that starts streaming fixed after its
+// first failed call.
+type fixedAfter struct {
+	*mock.Provider
+	fixed string
+}
+
+func (p *fixedAfter) ChatCompletion(ctx context.Context, req *provider.ChatCompletionRequest) (provider.Stream, error) {
+	stream, err := p.Provider.ChatCompletion(ctx, req)
+	p.SetChunks(jsonModel, contentChunks(p.fixed))
+	return stream, err
+}
+
+// drain reads stream to the end and returns its content and error.
+func drain(stream provider.Stream) (string, error) {
+	var content strings.Builder
+	for {
+		chunk, err := stream.Next()
+		if err == io.EOF {
+			return content.String(), stream.Err()
+		}
+		if err != nil {
+			return content.String(), err
+		}
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+		}
+	}
+}
+
+func TestJSONModeMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      JSONModeEnforcement
+		content   string
+		fixed     string // content after the first call, if set
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "passthrough invalid", mode: JSONModePassthrough, content: "not json", wantCalls: 1},
+		{name: "strict valid", mode: JSONModeStrict, content: `{"ok":true}`, wantCalls: 1},
+		{name: "strict invalid", mode: JSONModeStrict, content: "not json", wantCalls: 1, wantErr: true},
+		{name: "retry valid", mode: JSONModeRetry, content: `{"ok":true}`, wantCalls: 1},
+		{name: "retry fixed", mode: JSONModeRetry, content: "not json", fixed: `{"ok":true}`, wantCalls: 2},
+		{name: "retry gives up", mode: JSONModeRetry, content: "not json", wantCalls: MaxJSONRetries + 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.New()
+			m.SetChunks(jsonModel, contentChunks(tt.content))
+			var p provider.Provider = m
+			if tt.fixed != "" {
+				p = &fixedAfter{Provider: m, fixed: tt.fixed}
+			}
+
+			req := &provider.ChatCompletionRequest{
+				Model:          jsonModel,
+				Messages:       []api.Message{api.UserMessage("give me json")},
+				Stream:         true,
+				ResponseFormat: &api.ResponseFormat{Type: "json_object"},
+			}
+			stream, err := NewJSONModeMiddleware(tt.mode).ChatCompletion(context.Background(), p, req)
+			if err != nil {
+				t.Fatalf("ChatCompletion() error = %v", err)
+			}
+			_, err = drain(stream)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("stream error = %v, want error %v", err, tt.wantErr)
+			}
+
+			calls := m.Invocations()
+			if len(calls) != tt.wantCalls {
+				t.Fatalf("upstream calls = %d, want %d", len(calls), tt.wantCalls)
+			}
+			if tt.wantCalls > 1 {
+				retried := calls[1].Messages
+				if last := retried[len(retried)-1]; last.GetContentString() != jsonRetryInstruction {
+					t.Errorf("retry message = %q, want %q", last.GetContentString(), jsonRetryInstruction)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONModeIgnoresOtherFormats(t *testing.T) {
+	m := mock.New()
+	m.SetChunks(jsonModel, contentChunks("plain text"))
+	req := &provider.ChatCompletionRequest{Model: jsonModel, Stream: true}
+
+	stream, err := NewJSONModeMiddleware(JSONModeStrict).ChatCompletion(context.Background(), m, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err := drain(stream); err != nil || content != "plain text" {
+		t.Errorf("drain() = %q, %v; want the content unchecked", content, err)
+	}
+}
+
+func TestParseJSONModeEnforcement(t *testing.T) {
+	for in, want := range map[string]JSONModeEnforcement{"": JSONModePassthrough, "strict": JSONModeStrict, "retry": JSONModeRetry} {
+		if got, err := ParseJSONModeEnforcement(in); err != nil || got != want {
+			t.Errorf("ParseJSONModeEnforcement(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseJSONModeEnforcement("lenient"); err == nil {
+		t.Error("ParseJSONModeEnforcement(\"lenient\") succeeded, want an error")
+	}
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// RaceProvider sends each request to every provider that supports the
+// model and returns whichever answers first, canceling the others. A
+// provider has answered once its stream yields a first chunk (or, for
+// non-streaming requests, the complete response) without error; that
+// first result is buffered and replayed ahead of the rest of the stream.
+type RaceProvider struct {
+	providers []provider.Provider
+	wasted    atomic.Int64
+}
+
+// NewRaceProvider creates a race over providers.
+func NewRaceProvider(providers []provider.Provider) *RaceProvider {
+	return &RaceProvider{providers: providers}
+}
+
+// ID returns the provider identifier.
+func (r *RaceProvider) ID() string {
+	return "race"
+}
+
+// Models returns the models of all providers. IDs are not prefixed, so models
+// served by several providers appear once per provider.
+func (r *RaceProvider) Models() []api.Model {
+	var models []api.Model
+	for _, p := range r.providers {
+		models = append(models, p.Models()...)
+	}
+	return models
+}
+
+// SupportsModel reports whether any provider supports modelID.
+func (r *RaceProvider) SupportsModel(modelID string) bool {
+	return len(r.Competitors(modelID)) > 0
+}
+
+// Competitors returns the providers that would race for modelID.
+func (r *RaceProvider) Competitors(modelID string) []provider.Provider {
+	var competitors []provider.Provider
+	for _, p := range r.providers {
+		if p.SupportsModel(modelID) {
+			competitors = append(competitors, p)
+		}
+	}
+	return competitors
+}
+
+// WastedRequests returns the number of requests sent to providers that lost
+// a race, i.e. upstream calls whose result was discarded.
+func (r *RaceProvider) WastedRequests() int64 {
+	return r.wasted.Load()
+}
+
+// raceResult is one competitor's outcome.
+type raceResult struct {
+	index    int
+	provider provider.Provider
+	stream   provider.Stream
+	first    *api.ChatCompletionChunk
+	err      error
+	cancel   context.CancelFunc
+}
+
+// ChatCompletion races req across the competitors for req.Model. If every
+// competitor fails, the first error is returned.
+func (r *RaceProvider) ChatCompletion(ctx context.Context, req *provider.ChatCompletionRequest) (provider.Stream, error) {
+	competitors := r.Competitors(req.Model)
+	switch len(competitors) {
+	case 0:
+		return nil, fmt.Errorf("no provider supports model %s", req.Model)
+	case 1:
+		return competitors[0].ChatCompletion(ctx, req)
+	}
+
+	results := make(chan raceResult, len(competitors))
+	cancels := make([]context.CancelFunc, len(competitors))
+	for i, p := range competitors {
+		pctx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		go func() {
+			res := raceResult{index: i, provider: p, cancel: cancel}
+			res.stream, res.err = p.ChatCompletion(pctx, req)
+			if res.err == nil {
+				res.first, res.err = res.stream.Next()
+				if errors.Is(res.err, io.EOF) && res.stream.Err() == nil {
+					res.err = nil // non-streaming: the response is complete
+				}
+			}
+			results <- res
+		}()
+	}
+
+	var firstErr error
+	for received := 1; received <= len(competitors); received++ {
+		res := <-results
+		if res.err == nil {
+			// Cancel the others now; their streams are closed as they report back
+			for i, cancel := range cancels {
+				if i != res.index {
+					cancel()
+				}
+			}
+			pending := len(competitors) - received
+			wasted := r.wasted.Add(int64(pending))
+			slog.Debug("provider won race",
+				"provider", res.provider.ID(),
+				"model", req.Model,
+				"canceled", pending,
+				"wasted_requests_total", wasted,
+			)
+			go discard(results, pending)
+			return &raceStream{Stream: res.stream, first: res.first, cancel: res.cancel}, nil
+		}
+		if firstErr == nil {
+			firstErr = res.err
+		}
+		res.close()
+	}
+	return nil, firstErr
+}
+
+// discard closes the n competitors still running once the race is decided.
+func discard(results <-chan raceResult, n int) {
+	for range n {
+		res := <-results
+		res.close()
+	}
+}
+
+// close cancels the competitor's context and closes its stream.
+func (res *raceResult) close() {
+	res.cancel()
+	if res.stream != nil {
+		_ = res.stream.Close()
+	}
+}
+
+// raceStream replays the winner's first chunk, then reads the rest of its
+// stream.
+type raceStream struct {
+	provider.Stream
+	first    *api.ChatCompletionChunk
+	replayed bool
+	cancel   context.CancelFunc
+}
+
+func (s *raceStream) Next() (*api.ChatCompletionChunk, error) {
+	if !s.replayed {
+		s.replayed = true
+		if s.first != nil {
+			return s.first, nil
+		}
+		return nil, io.EOF
+	}
+	return s.Stream.Next()
+}
+
+func (s *raceStream) Close() error {
+	defer s.cancel()
+	return s.Stream.Close()
+}
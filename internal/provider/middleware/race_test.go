@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/provider/mock"
+)
+
+const raceModel = "race-model"
+
+// latencyProvider is a mock provider that takes latency to respond and
+// records whether it was canceled while waiting.
+type latencyProvider struct {
+	*mock.Provider
+	id       string
+	latency  time.Duration
+	canceled atomic.Bool
+}
+
+func newLatencyProvider(id string, latency time.Duration, content string, err error) *latencyProvider {
+	m := mock.New()
+	m.SetChunks(raceModel, contentChunks(content))
+	if err != nil {
+		m.SetError(raceModel, err)
+	}
+	return &latencyProvider{Provider: m, id: id, latency: latency}
+}
+
+func (p *latencyProvider) ID() string { return p.id }
+
+func (p *latencyProvider) ChatCompletion(ctx context.Context, req *provider.ChatCompletionRequest) (provider.Stream, error) {
+	select {
+	case <-time.After(p.latency):
+	case <-ctx.Done():
+		p.canceled.Store(true)
+		return nil, ctx.Err()
+	}
+	return p.Provider.ChatCompletion(ctx, req)
+}
+
+func TestRaceProvider(t *testing.T) {
+	errDown := errors.New("provider down")
+	tests := []struct {
+		name        string
+		fastErr     error
+		slowErr     error
+		wantContent string
+		wantErr     error
+		wantWasted  int64
+	}{
+		{name: "fast provider wins", wantContent: "fast", wantWasted: 1},
+		{name: "slow provider wins when fast fails", fastErr: errDown, wantContent: "slow"},
+		{name: "all fail", fastErr: errDown, slowErr: errors.New("also down"), wantErr: errDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fast := newLatencyProvider("fast", 10*time.Millisecond, "fast", tt.fastErr)
+			slow := newLatencyProvider("slow", 200*time.Millisecond, "slow", tt.slowErr)
+			race := NewRaceProvider([]provider.Provider{slow, fast})
+
+			req := &provider.ChatCompletionRequest{Model: raceModel, Stream: true, Messages: []api.Message{api.UserMessage("hi")}}
+			start := time.Now()
+			stream, err := race.ChatCompletion(context.Background(), req)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ChatCompletion() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ChatCompletion() error = %v", err)
+			}
+			defer stream.Close()
+			content, err := drain(stream)
+			if err != nil || content != tt.wantContent {
+				t.Errorf("drain() = %q, %v; want %q", content, err, tt.wantContent)
+			}
+			if tt.wantContent == "fast" {
+				if elapsed := time.Since(start); elapsed >= slow.latency {
+					t.Errorf("race took %s, want less than the slow provider's %s", elapsed, slow.latency)
+				}
+				// The loser is canceled, not waited for
+				deadline := time.Now().Add(time.Second)
+				for !slow.canceled.Load() && time.Now().Before(deadline) {
+					time.Sleep(time.Millisecond)
+				}
+				if !slow.canceled.Load() {
+					t.Error("slow provider was not canceled")
+				}
+			}
+			if got := race.WastedRequests(); got != tt.wantWasted {
+				t.Errorf("WastedRequests() = %d, want %d", got, tt.wantWasted)
+			}
+		})
+	}
+}
+
+// stalledProvider returns a stream at once, but its first chunk never
+// arrives before the request is canceled.
+type stalledProvider struct {
+	*mock.Provider
+	canceled atomic.Bool
+	closed   atomic.Bool
+}
+
+func (p *stalledProvider) ID() string { return "stalled" }
+
+func (p *stalledProvider) ChatCompletion(ctx context.Context, req *provider.ChatCompletionRequest) (provider.Stream, error) {
+	return &stalledStream{ctx: ctx, p: p}, nil
+}
+
+type stalledStream struct {
+	provider.Stream
+	ctx context.Context
+	p   *stalledProvider
+}
+
+func (s *stalledStream) Next() (*api.ChatCompletionChunk, error) {
+	<-s.ctx.Done()
+	s.p.canceled.Store(true)
+	return nil, s.ctx.Err()
+}
+
+func (s *stalledStream) Err() error   { return s.ctx.Err() }
+func (s *stalledStream) Close() error { s.p.closed.Store(true); return nil }
+
+func TestRaceProviderClosesLosingStreams(t *testing.T) {
+	fast := newLatencyProvider("fast", 0, "fast", nil)
+	m := mock.New()
+	m.SetChunks(raceModel, nil)
+	stalled := &stalledProvider{Provider: m}
+	race := NewRaceProvider([]provider.Provider{stalled, fast})
+
+	stream, err := race.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{Model: raceModel, Stream: true})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	defer stream.Close()
+	if content, err := drain(stream); err != nil || content != "fast" {
+		t.Errorf("drain() = %q, %v; want %q", content, err, "fast")
+	}
+
+	// The loser had a stream open: it must be canceled and closed
+	deadline := time.Now().Add(time.Second)
+	for !(stalled.canceled.Load() && stalled.closed.Load()) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !stalled.canceled.Load() || !stalled.closed.Load() {
+		t.Errorf("losing stream canceled = %v, closed = %v; want both", stalled.canceled.Load(), stalled.closed.Load())
+	}
+	if got := race.WastedRequests(); got != 1 {
+		t.Errorf("WastedRequests() = %d, want 1", got)
+	}
+}
+
+func TestRaceProviderSingleCompetitor(t *testing.T) {
+	only := newLatencyProvider("only", 0, "only", nil)
+	other := mock.New() // serves no models
+	race := NewRaceProvider([]provider.Provider{only, other})
+
+	if got := race.Competitors(raceModel); len(got) != 1 {
+		t.Fatalf("Competitors() = %d providers, want 1", len(got))
+	}
+	stream, err := race.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{Model: raceModel, Stream: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if content, _ := drain(stream); content != "only" {
+		t.Errorf("content = %q, want %q", content, "only")
+	}
+	if got := race.WastedRequests(); got != 0 {
+		t.Errorf("WastedRequests() = %d, want 0", got)
+	}
+}
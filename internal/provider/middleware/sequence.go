@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// ErrChunkSequenceGap is returned when a stream skips a choice or tool call index.
+var ErrChunkSequenceGap = errors.New("chunk sequence gap")
+
+// ValidationMode controls what ChunkSequenceValidator does on a gap.
+type ValidationMode int
+
+// Validation modes
+const (
+	// ValidationWarn logs gaps and passes chunks through.
+	ValidationWarn ValidationMode = iota
+	// ValidationStrict fails the stream with ErrChunkSequenceGap.
+	ValidationStrict
+)
+
+// ChunkSequenceValidator wraps a stream and detects lost chunks.
+//
+// Choice indexes must appear in order (choice 2 can't start before choice 1)
+// and, within a choice, each new tool call index must be exactly one past the
+// highest seen so far. Repeated indexes are normal: content and argument
+// deltas for the same choice or tool call span many chunks.
+type ChunkSequenceValidator struct {
+	provider.Stream
+	mode ValidationMode
+
+	maxChoice int         // highest choice index seen, -1 before the first
+	toolCalls map[int]int // choice index -> highest tool call index seen
+	err       error
+}
+
+// NewChunkSequenceValidator wraps stream with sequence validation.
+func NewChunkSequenceValidator(stream provider.Stream, mode ValidationMode) *ChunkSequenceValidator {
+	return &ChunkSequenceValidator{
+		Stream:    stream,
+		mode:      mode,
+		maxChoice: -1,
+		toolCalls: make(map[int]int),
+	}
+}
+
+// Next returns the next chunk, checking it for gaps first.
+func (v *ChunkSequenceValidator) Next() (*api.ChatCompletionChunk, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+
+	chunk, err := v.Stream.Next()
+	if err != nil || chunk == nil {
+		return chunk, err
+	}
+
+	if gapErr := v.check(chunk); gapErr != nil {
+		if v.mode == ValidationStrict {
+			v.err = gapErr
+			return nil, gapErr
+		}
+		slog.Warn("detected chunk sequence gap", "chunk_id", chunk.ID, "error", gapErr)
+	}
+	return chunk, nil
+}
+
+// Err returns the gap error in strict mode, or the underlying stream error.
+func (v *ChunkSequenceValidator) Err() error {
+	if v.err != nil {
+		return v.err
+	}
+	return v.Stream.Err()
+}
+
+// check records the indexes in chunk and reports the first gap found.
+func (v *ChunkSequenceValidator) check(chunk *api.ChatCompletionChunk) error {
+	var gap error
+	for _, choice := range chunk.Choices {
+		if choice.Index > v.maxChoice+1 && gap == nil {
+			gap = fmt.Errorf("%w: choice %d arrived after choice %d", ErrChunkSequenceGap, choice.Index, v.maxChoice)
+		}
+		v.maxChoice = max(v.maxChoice, choice.Index)
+
+		if choice.Delta == nil {
+			continue
+		}
+		for _, tc := range choice.Delta.ToolCalls {
+			if tc.Index == nil {
+				continue
+			}
+			last, seen := v.toolCalls[choice.Index]
+			if !seen {
+				last = -1
+			}
+			if *tc.Index > last+1 && gap == nil {
+				gap = fmt.Errorf("%w: choice %d tool call %d arrived after tool call %d",
+					ErrChunkSequenceGap, choice.Index, *tc.Index, last)
+			}
+			v.toolCalls[choice.Index] = max(last, *tc.Index)
+		}
+	}
+	return gap
+}
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ResponseFieldStripper removes fields from responses and streaming chunks
+// before they are written to the client.
+//
+// Paths are dot-separated object keys or array indexes, and "*" matches any
+// key or index:
+//
+//	usage,system_fingerprint,choices.*.logprobs
+//
+// Stripping works on the encoded JSON rather than on api types, so fields
+// the types always emit (such as logprobs) are removed as well.
+type ResponseFieldStripper struct {
+	paths [][]string
+}
+
+// NewResponseFieldStripper parses a comma-separated list of paths.
+func NewResponseFieldStripper(fields string) (*ResponseFieldStripper, error) {
+	s := &ResponseFieldStripper{}
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		path := strings.Split(field, ".")
+		for _, seg := range path {
+			if seg == "" {
+				return nil, fmt.Errorf("invalid strip path %q: empty segment", field)
+			}
+		}
+		s.paths = append(s.paths, path)
+	}
+	if len(s.paths) == 0 {
+		return nil, fmt.Errorf("no strip paths in %q", fields)
+	}
+	return s, nil
+}
+
+// Strip returns data with the configured paths removed. A nil stripper
+// returns data unchanged.
+func (s *ResponseFieldStripper) Strip(data []byte) ([]byte, error) {
+	if s == nil {
+		return data, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out bytes.Buffer
+	out.Grow(len(data))
+	if err := stripValue(dec, &out, s.paths); err != nil {
+		return nil, fmt.Errorf("failed to strip response fields: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// Marshal encodes v as JSON and strips it.
+func (s *ResponseFieldStripper) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return s.Strip(data)
+}
+
+// stripValue copies the next value from dec to out, dropping members that
+// complete one of paths. paths are relative to this value; once none remain
+// the value is copied verbatim.
+func stripValue(dec *json.Decoder, out *bytes.Buffer, paths [][]string) error {
+	if len(paths) == 0 {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		out.Write(raw)
+		return nil
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		out.WriteByte('{')
+		written := 0
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := tok.(string)
+			sub, drop := descend(paths, key)
+			if drop {
+				if err := skipValue(dec); err != nil {
+					return err
+				}
+				continue
+			}
+			if written > 0 {
+				out.WriteByte(',')
+			}
+			written++
+			encoded, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			out.Write(encoded)
+			out.WriteByte(':')
+			if err := stripValue(dec, out, sub); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil { // closing '}'
+			return err
+		}
+		out.WriteByte('}')
+
+	case json.Delim('['):
+		out.WriteByte('[')
+		written := 0
+		for i := 0; dec.More(); i++ {
+			sub, drop := descend(paths, strconv.Itoa(i))
+			if drop {
+				if err := skipValue(dec); err != nil {
+					return err
+				}
+				continue
+			}
+			if written > 0 {
+				out.WriteByte(',')
+			}
+			written++
+			if err := stripValue(dec, out, sub); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil { // closing ']'
+			return err
+		}
+		out.WriteByte(']')
+
+	default:
+		// Scalars: strings are re-escaped, json.Number keeps its original text
+		encoded, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		out.Write(encoded)
+	}
+	return nil
+}
+
+// descend returns the paths that continue below the member named seg, and
+// whether some path ends at it (so the member is dropped).
+func descend(paths [][]string, seg string) ([][]string, bool) {
+	var sub [][]string
+	for _, path := range paths {
+		if path[0] != seg && path[0] != "*" {
+			continue
+		}
+		if len(path) == 1 {
+			return nil, true
+		}
+		sub = append(sub, path[1:])
+	}
+	return sub, false
+}
+
+// skipValue consumes the next value from dec.
+func skipValue(dec *json.Decoder) error {
+	var raw json.RawMessage
+	return dec.Decode(&raw)
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+const stripInput = `{
+	"id": "chatcmpl-1",
+	"object": "chat.completion",
+	"model": "gpt-4o",
+	"system_fingerprint": "fp_1",
+	"x_vendor": {"region": "eu"},
+	"choices": [
+		{"index": 0, "message": {"role": "assistant", "content": "a"}, "logprobs": null, "finish_reason": "stop"},
+		{"index": 1, "message": {"role": "assistant", "content": "b"}, "logprobs": {"content": []}, "finish_reason": "length"}
+	],
+	"usage": {"prompt_tokens": 1, "completion_tokens": 2, "total_tokens": 3}
+}`
+
+func TestResponseFieldStripper(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		want   string
+	}{
+		{
+			name:   "usage",
+			fields: "usage",
+			want:   `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","system_fingerprint":"fp_1","x_vendor":{"region":"eu"},"choices":[{"index":0,"message":{"role":"assistant","content":"a"},"logprobs":null,"finish_reason":"stop"},{"index":1,"message":{"role":"assistant","content":"b"},"logprobs":{"content":[]},"finish_reason":"length"}]}`,
+		},
+		{
+			name:   "system_fingerprint",
+			fields: "system_fingerprint",
+			want:   `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","x_vendor":{"region":"eu"},"choices":[{"index":0,"message":{"role":"assistant","content":"a"},"logprobs":null,"finish_reason":"stop"},{"index":1,"message":{"role":"assistant","content":"b"},"logprobs":{"content":[]},"finish_reason":"length"}],"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}}`,
+		},
+		{
+			name:   "vendor extension",
+			fields: "x_vendor",
+			want:   `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","system_fingerprint":"fp_1","choices":[{"index":0,"message":{"role":"assistant","content":"a"},"logprobs":null,"finish_reason":"stop"},{"index":1,"message":{"role":"assistant","content":"b"},"logprobs":{"content":[]},"finish_reason":"length"}],"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}}`,
+		},
+		{
+			name:   "wildcard logprobs",
+			fields: "choices.*.logprobs",
+			want:   `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","system_fingerprint":"fp_1","x_vendor":{"region":"eu"},"choices":[{"index":0,"message":{"role":"assistant","content":"a"},"finish_reason":"stop"},{"index":1,"message":{"role":"assistant","content":"b"},"finish_reason":"length"}],"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}}`,
+		},
+		{
+			name:   "array index",
+			fields: "choices.1.finish_reason",
+			want:   `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","system_fingerprint":"fp_1","x_vendor":{"region":"eu"},"choices":[{"index":0,"message":{"role":"assistant","content":"a"},"logprobs":null,"finish_reason":"stop"},{"index":1,"message":{"role":"assistant","content":"b"},"logprobs":{"content":[]}}],"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}}`,
+		},
+		{
+			name:   "nested field",
+			fields: "x_vendor.region, usage.total_tokens",
+			want:   `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","system_fingerprint":"fp_1","x_vendor":{},"choices":[{"index":0,"message":{"role":"assistant","content":"a"},"logprobs":null,"finish_reason":"stop"},{"index":1,"message":{"role":"assistant","content":"b"},"logprobs":{"content":[]},"finish_reason":"length"}],"usage":{"prompt_tokens":1,"completion_tokens":2}}`,
+		},
+		{
+			name:   "missing field",
+			fields: "choices.*.message.audio",
+			want:   stripInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewResponseFieldStripper(tt.fields)
+			if err != nil {
+				t.Fatalf("NewResponseFieldStripper(%q) error = %v", tt.fields, err)
+			}
+			got, err := s.Strip([]byte(stripInput))
+			if err != nil {
+				t.Fatalf("Strip() error = %v", err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestResponseFieldStripperChunk(t *testing.T) {
+	s, err := NewResponseFieldStripper("usage,choices.*.logprobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := &api.ChatCompletionChunk{
+		ID:      "chatcmpl-1",
+		Object:  "chat.completion.chunk",
+		Choices: []api.Choice{{Delta: &api.Delta{Content: "hi"}}},
+		Usage:   &api.Usage{TotalTokens: 3},
+	}
+	got, err := s.Marshal(chunk)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	assertJSONEqual(t, got, `{"id":"chatcmpl-1","object":"chat.completion.chunk","created":0,"model":"","choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":null}]}`)
+}
+
+func TestNewResponseFieldStripperErrors(t *testing.T) {
+	for _, fields := range []string{"", " , ", "choices..logprobs"} {
+		if _, err := NewResponseFieldStripper(fields); err == nil {
+			t.Errorf("NewResponseFieldStripper(%q) succeeded, want an error", fields)
+		}
+	}
+}
+
+func TestNilResponseFieldStripper(t *testing.T) {
+	var s *ResponseFieldStripper
+	got, err := s.Strip([]byte(stripInput))
+	if err != nil || string(got) != stripInput {
+		t.Errorf("nil Strip() = %s, %v; want the input unchanged", got, err)
+	}
+}
+
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, got)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+// Package middleware provides wrappers that post-process provider streams.
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/go-jsonnet"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// TransformTimeout bounds a single script evaluation.
+const TransformTimeout = 100 * time.Millisecond
+
+// ResponseTransformMiddleware applies a Jsonnet script to every response
+// and streaming chunk produced by a provider.
+//
+// The script is an object that is merged onto the response, so it sees the
+// response as self (and the original fields as super):
+//
+//	{
+//	  choices: [
+//	    c + { message+: { tool_calls:: null } }
+//	    for c in super.choices
+//	  ],
+//	}
+//
+// The original value is also available as std.extVar("response"). Imports
+// are disabled and each evaluation is limited to TransformTimeout.
+type ResponseTransformMiddleware struct {
+	name    string
+	snippet string
+	timeout time.Duration
+}
+
+// NewResponseTransformMiddleware loads and parses the script at path.
+func NewResponseTransformMiddleware(path string) (*ResponseTransformMiddleware, error) {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transform script: %w", err)
+	}
+
+	m := &ResponseTransformMiddleware{
+		name:    path,
+		snippet: "std.extVar(\"response\") + (\n" + string(script) + "\n)",
+		timeout: TransformTimeout,
+	}
+	if _, err := jsonnet.SnippetToAST(m.name, m.snippet); err != nil {
+		return nil, fmt.Errorf("invalid transform script %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Wrap returns a stream whose chunks and final response pass through the script.
+func (m *ResponseTransformMiddleware) Wrap(stream provider.Stream) provider.Stream {
+	return &transformStream{Stream: stream, m: m}
+}
+
+// TransformResponse applies the script to a complete response.
+func (m *ResponseTransformMiddleware) TransformResponse(resp *api.ChatCompletionResponse) (*api.ChatCompletionResponse, error) {
+	var out api.ChatCompletionResponse
+	if err := m.apply(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TransformChunk applies the script to a single streaming chunk.
+func (m *ResponseTransformMiddleware) TransformChunk(chunk *api.ChatCompletionChunk) (*api.ChatCompletionChunk, error) {
+	var out api.ChatCompletionChunk
+	if err := m.apply(chunk, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// apply evaluates the script with in as the response and decodes the result into out.
+func (m *ResponseTransformMiddleware) apply(in, out any) error {
+	input, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode transform input: %w", err)
+	}
+
+	result, err := m.evaluate(string(input))
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal([]byte(result), out); err != nil {
+		return fmt.Errorf("transform script returned an invalid response: %w", err)
+	}
+	return nil
+}
+
+// evaluate runs the script in a fresh VM. The jsonnet VM cannot be
+// interrupted, so on timeout the evaluation is abandoned and left to finish
+// in the background.
+func (m *ResponseTransformMiddleware) evaluate(input string) (string, error) {
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		vm := jsonnet.MakeVM()
+		vm.Importer(&jsonnet.MemoryImporter{Data: map[string]jsonnet.Contents{}})
+		vm.ExtCode("response", input)
+		out, err := vm.EvaluateAnonymousSnippet(m.name, m.snippet)
+		done <- result{out, err}
+	}()
+
+	timer := time.NewTimer(m.timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return "", fmt.Errorf("transform script failed: %w", r.err)
+		}
+		return r.out, nil
+	case <-timer.C:
+		return "", fmt.Errorf("transform script timed out after %s", m.timeout)
+	}
+}
+
+// transformStream applies the transform to each chunk and to the final response.
+type transformStream struct {
+	provider.Stream
+	m *ResponseTransformMiddleware
+
+	response *api.ChatCompletionResponse
+	err      error
+}
+
+func (s *transformStream) Next() (*api.ChatCompletionChunk, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	chunk, err := s.Stream.Next()
+	if err != nil {
+		if err == io.EOF {
+			// Transform the accumulated response once, so errors surface via Err()
+			if resp := s.Stream.Response(); resp != nil {
+				s.response, s.err = s.m.TransformResponse(resp)
+			}
+		}
+		return nil, err
+	}
+
+	out, err := s.m.TransformChunk(chunk)
+	if err != nil {
+		s.err = err
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *transformStream) Response() *api.ChatCompletionResponse {
+	return s.response
+}
+
+func (s *transformStream) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.Stream.Err()
+}
@@ -0,0 +1,130 @@
+package mistral
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
+)
+
+// HTTPTimeout is long enough for streaming responses.
+const HTTPTimeout = 5 * time.Minute
+
+// Client handles communication with the Mistral API.
+type Client struct {
+	httpClient *http.Client
+	store      *auth.Store
+}
+
+// NewClient creates a new Mistral client.
+func NewClient(store *auth.Store) *Client {
+	return &Client{
+		httpClient: &http.Client{
+			Timeout: HTTPTimeout,
+		},
+		store: store,
+	}
+}
+
+// newRequest creates an authenticated Mistral API request.
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	creds, err := c.store.GetAPIKeyCredentials(ProviderID)
+	if err != nil {
+		return nil, fmt.Errorf("auth error: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+creds.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// SendRequest sends a chat completion request.
+func (c *Client) SendRequest(ctx context.Context, chatReq *ChatRequest) (*http.Response, error) {
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, MistralChatURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if chatReq.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
+
+// FetchModels lists the chat models available to the API key.
+func (c *Client) FetchModels(ctx context.Context) ([]api.Model, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, MistralModelsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch models: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("models request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var response struct {
+		Data []struct {
+			ID               string `json:"id"`
+			Created          int64  `json:"created"`
+			OwnedBy          string `json:"owned_by"`
+			MaxContextLength int    `json:"max_context_length"`
+			Capabilities     struct {
+				CompletionChat bool `json:"completion_chat"`
+			} `json:"capabilities"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to parse models response: %w", err)
+	}
+
+	models := make([]api.Model, 0, len(response.Data))
+	for _, m := range response.Data {
+		// Skip embedding, OCR and moderation models
+		if !m.Capabilities.CompletionChat {
+			continue
+		}
+		ownedBy := m.OwnedBy
+		if ownedBy == "" {
+			ownedBy = "mistralai"
+		}
+		models = append(models, api.Model{
+			ID:            m.ID,
+			Object:        "model",
+			Created:       m.Created,
+			OwnedBy:       ownedBy,
+			ContextWindow: m.MaxContextLength,
+		})
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no models returned from API")
+	}
+	return models, nil
+}
@@ -0,0 +1,74 @@
+package mistral
+
+import (
+	"os"
+	"strconv"
+)
+
+// Provider identification
+const ProviderID = "mistral"
+
+// Environment variable names for Mistral provider
+const (
+	EnvModelsRefresh = "OPENCOMPAT_MISTRAL_MODELS_REFRESH"
+	EnvSafePrompt    = "OPENCOMPAT_MISTRAL_SAFE_PROMPT"
+)
+
+// Default values
+const (
+	DefaultModelsRefresh = 24 * 60 // 24 hours in minutes
+)
+
+// Mistral API configuration
+const (
+	MistralBaseURL   = "https://api.mistral.ai/v1"
+	MistralChatURL   = MistralBaseURL + "/chat/completions"
+	MistralModelsURL = MistralBaseURL + "/models"
+)
+
+// Config holds Mistral-specific configuration.
+type Config struct {
+	ModelsRefresh int  // refresh interval in minutes
+	SafePrompt    bool // prepend Mistral's safety system prompt
+}
+
+// LoadConfig reads Mistral configuration from environment variables.
+func LoadConfig() *Config {
+	return &Config{
+		ModelsRefresh: getEnvInt(EnvModelsRefresh, DefaultModelsRefresh),
+		SafePrompt:    getEnvBool(EnvSafePrompt, false),
+	}
+}
+
+// EnvVarDoc documents an environment variable.
+type EnvVarDoc struct {
+	Name        string
+	Description string
+	Default     string
+}
+
+// EnvVarDocs returns documentation for environment variables.
+func EnvVarDocs() []EnvVarDoc {
+	return []EnvVarDoc{
+		{Name: EnvModelsRefresh, Description: "Models refresh interval in minutes", Default: strconv.Itoa(DefaultModelsRefresh)},
+		{Name: EnvSafePrompt, Description: "Send safe_prompt to inject Mistral's guardrail system prompt", Default: "false"},
+	}
+}
+
+func getEnvInt(key string, defaultVal int) int {
+	if val := os.Getenv(key); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			return i
+		}
+	}
+	return defaultVal
+}
+
+func getEnvBool(key string, defaultVal bool) bool {
+	if val := os.Getenv(key); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	}
+	return defaultVal
+}
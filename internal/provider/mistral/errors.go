@@ -0,0 +1,58 @@
+package mistral
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/edgard/opencompat/internal/provider/openaicompat"
+)
+
+// errorCodeStatus maps Mistral error codes to the HTTP status returned to
+// clients. Mistral reports some failures, such as rate limiting, with a
+// generic status and a numeric code in the body.
+var errorCodeStatus = map[string]int{
+	"1622": http.StatusTooManyRequests, // rate limit exceeded
+}
+
+// errorBody is a Mistral error response. The message is a string for most
+// errors and an object for request validation errors; the code may be a
+// string or a number.
+type errorBody struct {
+	Message json.RawMessage `json:"message"`
+	Type    string          `json:"type"`
+	Code    json.RawMessage `json:"code"`
+}
+
+// newUpstreamError builds the error for a non-200 response, mapping
+// Mistral error codes to a meaningful status.
+func newUpstreamError(statusCode int, body []byte) error {
+	var errResp errorBody
+	if err := json.Unmarshal(body, &errResp); err != nil || len(errResp.Message) == 0 {
+		return openaicompat.NewUpstreamError(statusCode, body)
+	}
+
+	message := rawString(errResp.Message)
+	code := rawString(errResp.Code)
+	if status, ok := errorCodeStatus[code]; ok {
+		statusCode = status
+	} else if strings.Contains(strings.ToLower(message), "rate limit") {
+		statusCode = http.StatusTooManyRequests
+	}
+	if code != "" {
+		message += " (Mistral error code " + code + ")"
+	}
+	return openaicompat.UpstreamError(statusCode, message)
+}
+
+// rawString returns a JSON string's value, or the raw JSON for other values.
+func rawString(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
@@ -0,0 +1,74 @@
+package mistral
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+func TestNewUpstreamError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus int
+		wantMsg    string
+	}{
+		{
+			name:       "rate limit code",
+			status:     http.StatusBadRequest,
+			body:       `{"object":"error","message":"Requests rate limit exceeded","type":"rate_limited","code":"1622"}`,
+			wantStatus: http.StatusTooManyRequests,
+			wantMsg:    "Requests rate limit exceeded (Mistral error code 1622)",
+		},
+		{
+			name:       "numeric code",
+			status:     http.StatusBadRequest,
+			body:       `{"message":"Too many tokens","type":"invalid_request","code":1622}`,
+			wantStatus: http.StatusTooManyRequests,
+			wantMsg:    "Too many tokens (Mistral error code 1622)",
+		},
+		{
+			name:       "rate limit message",
+			status:     http.StatusServiceUnavailable,
+			body:       `{"message":"Service tier capacity exceeded, rate limit reached"}`,
+			wantStatus: http.StatusTooManyRequests,
+			wantMsg:    "Service tier capacity exceeded, rate limit reached",
+		},
+		{
+			name:       "validation error",
+			status:     http.StatusUnprocessableEntity,
+			body:       `{"object":"error","message":{"detail":[{"loc":["body","model"],"msg":"field required"}]},"type":"invalid_request_error","code":null}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantMsg:    `{"detail":[{"loc":["body","model"],"msg":"field required"}]}`,
+		},
+		{
+			name:       "other code",
+			status:     http.StatusUnauthorized,
+			body:       `{"message":"Unauthorized","code":"3000"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantMsg:    "Unauthorized (Mistral error code 3000)",
+		},
+		{
+			name:       "not json",
+			status:     http.StatusBadGateway,
+			body:       `upstream unavailable`,
+			wantStatus: http.StatusBadGateway,
+			wantMsg:    "upstream unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newUpstreamError(tt.status, []byte(tt.body))
+			var upstreamErr *api.UpstreamError
+			if !errors.As(err, &upstreamErr) {
+				t.Fatalf("newUpstreamError() = %v, want an UpstreamError", err)
+			}
+			if upstreamErr.StatusCode != tt.wantStatus || upstreamErr.Message != tt.wantMsg {
+				t.Errorf("newUpstreamError() = %d %q, want %d %q", upstreamErr.StatusCode, upstreamErr.Message, tt.wantStatus, tt.wantMsg)
+			}
+		})
+	}
+}
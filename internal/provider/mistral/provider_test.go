@@ -0,0 +1,101 @@
+package mistral
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/testutil"
+)
+
+// rewriteTransport sends every request to target instead of the Mistral API.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (rt rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newTestProvider returns a logged-in provider whose requests go to handler.
+func newTestProvider(t *testing.T, handler http.Handler) *Provider {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	store := auth.NewStore()
+	if err := store.SaveAPIKeyCredentials(ProviderID, &auth.APIKeyCredentials{APIKey: "mistral-test-key"}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+
+	p, err := New(store)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	mp := p.(*Provider)
+	mp.client.httpClient.Transport = rewriteTransport{target: target}
+	return mp
+}
+
+func TestChatCompletion(t *testing.T) {
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusOK,
+		`{"id":"1","object":"chat.completion","model":"mistral-small-latest","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`)
+	p := newTestProvider(t, m)
+
+	stream, err := p.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{
+		Model:    "mistral-small-latest",
+		Messages: []api.Message{api.UserMessage("hello")},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	defer func() { _ = stream.Close() }()
+	if _, err := stream.Next(); err != io.EOF {
+		t.Fatalf("Next() error = %v, want io.EOF", err)
+	}
+	if resp := stream.Response(); resp == nil || len(resp.Choices) != 1 {
+		t.Fatalf("Response() = %+v, want one choice", resp)
+	}
+
+	m.AssertMethod(http.MethodPost).
+		AssertURL("/v1/chat/completions").
+		AssertHeader("Authorization", "Bearer mistral-test-key").
+		AssertHeader("Accept", "application/json").
+		AssertBody("model", "mistral-small-latest")
+}
+
+func TestChatCompletionRateLimited(t *testing.T) {
+	m := testutil.NewRequestMatcher(t).Respond(http.StatusBadRequest,
+		`{"object":"error","message":"Requests rate limit exceeded","type":"rate_limited","code":"1622"}`)
+	p := newTestProvider(t, m)
+
+	stream, err := p.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{
+		Model:    "mistral-small-latest",
+		Messages: []api.Message{api.UserMessage("hello")},
+		Stream:   true,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	_, err = stream.Next()
+	var upstreamErr *api.UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Next() error = %v, want a 429 UpstreamError", err)
+	}
+	m.AssertHeader("Accept", "text/event-stream")
+}
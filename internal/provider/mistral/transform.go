@@ -0,0 +1,100 @@
+package mistral
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// toolCallIDLength is the tool call ID length Mistral requires.
+const toolCallIDLength = 9
+
+// ChatRequest adds Mistral-only fields to the OpenAI request body.
+type ChatRequest struct {
+	*api.ChatCompletionRequest
+	SafePrompt bool `json:"safe_prompt,omitempty"`
+}
+
+// TransformRequest converts a chat completion request for the Mistral API.
+// The body is OpenAI-compatible apart from tool call IDs, which must be
+// nine alphanumeric characters, and tool_choice "required", which Mistral
+// calls "any".
+func TransformRequest(req *provider.ChatCompletionRequest, cfg *Config) *ChatRequest {
+	chatReq := &api.ChatCompletionRequest{
+		Model:             req.Model,
+		Messages:          transformMessages(req.Messages),
+		Tools:             req.Tools,
+		ToolChoice:        transformToolChoice(req.ToolChoice),
+		Stream:            req.Stream,
+		StreamOptions:     req.StreamOptions,
+		N:                 req.N,
+		Temperature:       req.Temperature,
+		TopP:              req.TopP,
+		MaxTokens:         req.MaxTokens,
+		Stop:              req.Stop,
+		PresencePenalty:   req.PresencePenalty,
+		FrequencyPenalty:  req.FrequencyPenalty,
+		ResponseFormat:    req.ResponseFormat,
+		ParallelToolCalls: req.ParallelToolCalls,
+	}
+	// Mistral only accepts max_tokens
+	if req.MaxCompletionTokens != nil {
+		chatReq.MaxTokens = req.MaxCompletionTokens
+	}
+
+	return &ChatRequest{ChatCompletionRequest: chatReq, SafePrompt: cfg.SafePrompt}
+}
+
+// transformMessages rewrites tool call IDs into Mistral's format. Messages
+// are copied so the caller's request is left untouched.
+func transformMessages(messages []api.Message) []api.Message {
+	result := make([]api.Message, len(messages))
+	for i, msg := range messages {
+		result[i] = msg
+		if msg.ToolCallID != "" {
+			result[i].ToolCallID = toolCallID(msg.ToolCallID)
+		}
+		if len(msg.ToolCalls) == 0 {
+			continue
+		}
+		calls := make([]api.ToolCall, len(msg.ToolCalls))
+		for j, tc := range msg.ToolCalls {
+			calls[j] = tc
+			calls[j].ID = toolCallID(tc.ID)
+			calls[j].Index = nil // only meaningful in streamed chunks
+		}
+		result[i].ToolCalls = calls
+	}
+	return result
+}
+
+// toolCallID maps an ID to nine alphanumeric characters. IDs issued by
+// Mistral pass through; others (e.g., "call_..." from another provider)
+// are hashed, so a call and its result still map to the same ID.
+func toolCallID(id string) string {
+	if len(id) == toolCallIDLength && isAlphanumeric(id) {
+		return id
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])[:toolCallIDLength]
+}
+
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// transformToolChoice maps "required" to Mistral's "any".
+func transformToolChoice(raw json.RawMessage) json.RawMessage {
+	if choice, err := api.ParseToolChoice(raw); err == nil && choice != nil && choice.Mode == api.ToolChoiceRequired {
+		return json.RawMessage(`"any"`)
+	}
+	return raw
+}
@@ -0,0 +1,106 @@
+package mistral
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+func TestToolCallID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		keep bool
+	}{
+		{name: "mistral id", id: "D681PevKs", keep: true},
+		{name: "openai id", id: "call_abc123def456"},
+		{name: "short id", id: "call1"},
+		{name: "nine with symbols", id: "call_1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toolCallID(tt.id)
+			if len(got) != toolCallIDLength || !isAlphanumeric(got) {
+				t.Errorf("toolCallID(%q) = %q, want nine alphanumeric characters", tt.id, got)
+			}
+			if (got == tt.id) != tt.keep {
+				t.Errorf("toolCallID(%q) = %q, want kept %v", tt.id, got, tt.keep)
+			}
+			if again := toolCallID(tt.id); again != got {
+				t.Errorf("toolCallID(%q) is not stable: %q then %q", tt.id, got, again)
+			}
+		})
+	}
+}
+
+func TestTransformRequest(t *testing.T) {
+	assistant := api.AssistantMessage("")
+	index := 0
+	assistant.ToolCalls = []api.ToolCall{{Index: &index, ID: "call_abc123def456", Type: "function", Function: api.FunctionCall{Name: "get_weather", Arguments: `{}`}}}
+	messages := []api.Message{
+		api.UserMessage("Weather?"),
+		assistant,
+		{Role: "tool", ToolCallID: "call_abc123def456", Content: json.RawMessage(`"Sunny"`)},
+	}
+	maxCompletion := 256
+
+	got := TransformRequest(&provider.ChatCompletionRequest{
+		Model:               "mistral-large-latest",
+		Messages:            messages,
+		ToolChoice:          json.RawMessage(`"required"`),
+		MaxCompletionTokens: &maxCompletion,
+	}, &Config{SafePrompt: true})
+
+	call := got.Messages[1].ToolCalls[0]
+	if call.ID != toolCallID("call_abc123def456") || call.Index != nil {
+		t.Errorf("tool call = %+v, want a Mistral ID and no index", call)
+	}
+	if got.Messages[2].ToolCallID != call.ID {
+		t.Errorf("tool result ID = %q, want it to match the call %q", got.Messages[2].ToolCallID, call.ID)
+	}
+	// The caller's messages are left untouched
+	if messages[1].ToolCalls[0].ID != "call_abc123def456" || messages[2].ToolCallID != "call_abc123def456" {
+		t.Error("TransformRequest() modified the caller's messages")
+	}
+
+	body, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for field, want := range map[string]string{
+		"tool_choice": `"any"`,
+		"max_tokens":  `256`,
+		"safe_prompt": `true`,
+	} {
+		if string(fields[field]) != want {
+			t.Errorf("%s = %s, want %s", field, fields[field], want)
+		}
+	}
+	if _, ok := fields["max_completion_tokens"]; ok {
+		t.Error("max_completion_tokens sent, want only max_tokens")
+	}
+}
+
+func TestTransformToolChoice(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: ``, want: ``},
+		{in: `"auto"`, want: `"auto"`},
+		{in: `"none"`, want: `"none"`},
+		{in: `"required"`, want: `"any"`},
+		{in: `{"type":"function","function":{"name":"f"}}`, want: `{"type":"function","function":{"name":"f"}}`},
+	}
+	for _, tt := range tests {
+		if got := transformToolChoice(json.RawMessage(tt.in)); string(got) != tt.want {
+			t.Errorf("transformToolChoice(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// ProviderID identifies Provider.
+const ProviderID = "mock"
+
+// Option configures New.
+type Option func(*Provider)
+
+// DelayBetweenChunks pauses before each streamed chunk after the first, to
+// simulate network latency.
+func DelayBetweenChunks(d time.Duration) Option {
+	return func(p *Provider) {
+		p.chunkDelay = d
+	}
+}
+
+// WithoutStreaming makes the provider report that it cannot stream, like
+// an upstream that only returns complete responses.
+func WithoutStreaming() Option {
+	return func(p *Provider) {
+		p.noStreaming = true
+	}
+}
+
+// outcome is what ChatCompletion returns for a model.
+type outcome struct {
+	chunks   []*api.ChatCompletionChunk
+	response *api.ChatCompletionResponse
+	err      error
+}
+
+// Provider serves pre-programmed responses per model, for testing code
+// that calls a provider without network I/O. Models without a programmed
+// outcome are not supported.
+type Provider struct {
+	chunkDelay  time.Duration
+	noStreaming bool
+
+	mu          sync.Mutex
+	outcomes    map[string]*outcome
+	catalog     []api.Model // listed models; nil lists the programmed ones
+	invocations []provider.ChatCompletionRequest
+}
+
+// New creates a provider with nothing programmed.
+func New(opts ...Option) *Provider {
+	p := &Provider{outcomes: make(map[string]*outcome)}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// SetResponse programs the non-streaming response for modelID, returned
+// by the stream's Response method.
+func (p *Provider) SetResponse(modelID string, resp *api.ChatCompletionResponse) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.outcome(modelID).response = resp
+}
+
+// SetChunks programs the chunks streamed for modelID.
+func (p *Provider) SetChunks(modelID string, chunks []*api.ChatCompletionChunk) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.outcome(modelID).chunks = chunks
+}
+
+// SetError makes ChatCompletion fail with err for modelID.
+func (p *Provider) SetError(modelID string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.outcome(modelID).err = err
+}
+
+// SetModels replaces the models listed by Models and StreamModels. Only
+// models with a programmed outcome are supported.
+func (p *Provider) SetModels(models []api.Model) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.catalog = slices.Clone(models)
+}
+
+// outcome returns the outcome for modelID, creating it. Callers hold p.mu.
+func (p *Provider) outcome(modelID string) *outcome {
+	o, ok := p.outcomes[modelID]
+	if !ok {
+		o = &outcome{}
+		p.outcomes[modelID] = o
+	}
+	return o
+}
+
+// Invocations returns a copy of every request received, in order.
+func (p *Provider) Invocations() []provider.ChatCompletionRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.invocations)
+}
+
+// Reset clears the programmed outcomes, models and recorded invocations.
+func (p *Provider) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.outcomes = make(map[string]*outcome)
+	p.catalog = nil
+	p.invocations = nil
+}
+
+// ID returns the provider identifier.
+func (p *Provider) ID() string {
+	return ProviderID
+}
+
+// Models returns the models set by SetModels, or else the programmed
+// models sorted by ID.
+func (p *Provider) Models() []api.Model {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.catalog != nil {
+		return slices.Clone(p.catalog)
+	}
+	models := make([]api.Model, 0, len(p.outcomes))
+	for _, id := range slices.Sorted(maps.Keys(p.outcomes)) {
+		models = append(models, api.Model{ID: id, Object: "model", OwnedBy: ProviderID})
+	}
+	return models
+}
+
+// StreamModels sends the models returned by Models one at a time.
+func (p *Provider) StreamModels(ctx context.Context) (<-chan api.Model, error) {
+	models := p.Models()
+	ch := make(chan api.Model)
+	go func() {
+		defer close(ch)
+		for _, m := range models {
+			select {
+			case ch <- m:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// StreamingSupported reports whether the provider streams; see
+// WithoutStreaming.
+func (p *Provider) StreamingSupported() bool {
+	return !p.noStreaming
+}
+
+// SupportsModel reports whether an outcome is programmed for modelID.
+func (p *Provider) SupportsModel(modelID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.outcomes[modelID]
+	return ok
+}
+
+// ChatCompletion records req and returns the outcome programmed for its
+// model.
+func (p *Provider) ChatCompletion(ctx context.Context, req *provider.ChatCompletionRequest) (provider.Stream, error) {
+	p.mu.Lock()
+	p.invocations = append(p.invocations, *req)
+	o, ok := p.outcomes[req.Model]
+	var programmed outcome
+	if ok {
+		programmed = *o
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("mock: no response programmed for model %q", req.Model)
+	}
+	if programmed.err != nil {
+		return nil, programmed.err
+	}
+	return &stream{ctx: ctx, outcome: programmed, delay: p.chunkDelay}, nil
+}
+
+// stream yields the programmed chunks, then io.EOF.
+type stream struct {
+	ctx     context.Context
+	outcome outcome
+	delay   time.Duration
+	pos     int
+	err     error
+}
+
+func (s *stream) Next() (*api.ChatCompletionChunk, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.pos >= len(s.outcome.chunks) {
+		return nil, io.EOF
+	}
+	if s.pos > 0 && s.delay > 0 {
+		timer := time.NewTimer(s.delay)
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			timer.Stop()
+			s.err = s.ctx.Err()
+			return nil, s.err
+		}
+	}
+	chunk := *s.outcome.chunks[s.pos]
+	s.pos++
+	return &chunk, nil
+}
+
+func (s *stream) Response() *api.ChatCompletionResponse {
+	return s.outcome.response
+}
+
+func (s *stream) Err() error {
+	return s.err
+}
+
+func (s *stream) Close() error {
+	return nil
+}
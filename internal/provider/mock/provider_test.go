@@ -0,0 +1,58 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// catalog returns n models named model-0 to model-<n-1>.
+func catalog(n int) []api.Model {
+	models := make([]api.Model, n)
+	for i := range models {
+		models[i] = api.Model{ID: fmt.Sprintf("model-%d", i), Object: "model", OwnedBy: ProviderID}
+	}
+	return models
+}
+
+func TestStreamModelsFillsCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	m := New()
+	m.SetModels(catalog(1000))
+
+	cache := provider.NewModelsCache(ProviderID, provider.StreamingModelsFetcher(m), 60)
+	models := cache.GetModels()
+	if len(models) != 1000 {
+		t.Fatalf("GetModels() returned %d models, want 1000", len(models))
+	}
+	for i, model := range models {
+		if want := fmt.Sprintf("model-%d", i); model.ID != want {
+			t.Fatalf("models[%d] = %q, want %q", i, model.ID, want)
+		}
+	}
+	if !cache.SupportsModel("model-999") {
+		t.Error("SupportsModel(model-999) = false")
+	}
+}
+
+func TestStreamModelsCanceled(t *testing.T) {
+	m := New()
+	m.SetModels(catalog(1000))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	models, err := m.StreamModels(ctx)
+	if err != nil {
+		t.Fatalf("StreamModels() error = %v", err)
+	}
+	<-models
+	cancel()
+	// The stream must close instead of blocking on the remaining models
+	for range models {
+	}
+	if _, err := provider.CollectModels(ctx, models); err == nil {
+		t.Error("CollectModels() succeeded after cancellation")
+	}
+}
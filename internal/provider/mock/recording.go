@@ -0,0 +1,171 @@
+// Package mock provides providers that serve programmed responses, or record
+// and replay upstream interactions, for testing without network I/O.
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// Fixture is the on-disk format of a recording.
+type Fixture struct {
+	Provider     string        `json:"provider"`
+	Models       []api.Model   `json:"models,omitempty"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded ChatCompletion call.
+type Interaction struct {
+	Request  *provider.ChatCompletionRequest `json:"request"`
+	Chunks   []api.ChatCompletionChunk       `json:"chunks,omitempty"`
+	Response *api.ChatCompletionResponse     `json:"response,omitempty"`
+	Error    *RecordedError                  `json:"error,omitempty"`
+}
+
+// RecordedError is an error returned by the provider or its stream.
+type RecordedError struct {
+	Message    string `json:"message"`
+	StatusCode int    `json:"status_code,omitempty"` // set for api.UpstreamError
+}
+
+func newRecordedError(err error) *RecordedError {
+	if err == nil {
+		return nil
+	}
+	rec := &RecordedError{Message: err.Error()}
+	var upstreamErr *api.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		rec.StatusCode = upstreamErr.StatusCode
+	}
+	return rec
+}
+
+// Err converts the recorded error back into an error value.
+func (e *RecordedError) Err() error {
+	if e == nil {
+		return nil
+	}
+	if e.StatusCode != 0 {
+		return api.NewUpstreamError(e.StatusCode, e.Message)
+	}
+	return errors.New(e.Message)
+}
+
+// RecordingProvider wraps a provider and records every ChatCompletion
+// request with its chunks, final response and error. The fixture file is
+// rewritten each time a stream is closed, so it is usable even if the
+// process exits early.
+type RecordingProvider struct {
+	provider.Provider
+	path string
+
+	mu      sync.Mutex
+	fixture Fixture
+}
+
+// NewRecordingProvider wraps p, writing the fixture to path.
+func NewRecordingProvider(p provider.Provider, path string) *RecordingProvider {
+	return &RecordingProvider{
+		Provider: p,
+		path:     path,
+		fixture: Fixture{
+			Provider: p.ID(),
+			Models:   p.Models(),
+		},
+	}
+}
+
+// ChatCompletion forwards the request and records the interaction.
+func (r *RecordingProvider) ChatCompletion(ctx context.Context, req *provider.ChatCompletionRequest) (provider.Stream, error) {
+	stream, err := r.Provider.ChatCompletion(ctx, req)
+	if err != nil {
+		if saveErr := r.record(Interaction{Request: req, Error: newRecordedError(err)}); saveErr != nil {
+			return nil, errors.Join(err, saveErr)
+		}
+		return nil, err
+	}
+	return &recordingStream{Stream: stream, recorder: r, interaction: Interaction{Request: req}}, nil
+}
+
+// Fixture returns a copy of the interactions recorded so far.
+func (r *RecordingProvider) Fixture() Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := r.fixture
+	f.Interactions = append([]Interaction(nil), r.fixture.Interactions...)
+	return f
+}
+
+// record appends an interaction and rewrites the fixture file.
+func (r *RecordingProvider) record(interaction Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixture.Interactions = append(r.fixture.Interactions, interaction)
+	return writeFixture(r.path, &r.fixture)
+}
+
+// writeFixture writes the fixture atomically via a temp file and rename.
+func writeFixture(path string, f *Fixture) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal fixture: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".fixture-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create fixture: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// recordingStream captures chunks as they are read.
+type recordingStream struct {
+	provider.Stream
+	recorder    *RecordingProvider
+	interaction Interaction
+	closed      bool
+}
+
+func (s *recordingStream) Next() (*api.ChatCompletionChunk, error) {
+	chunk, err := s.Stream.Next()
+	if err == nil && chunk != nil {
+		s.interaction.Chunks = append(s.interaction.Chunks, *chunk)
+	}
+	if err != nil && err != io.EOF && s.interaction.Error == nil {
+		s.interaction.Error = newRecordedError(err)
+	}
+	return chunk, err
+}
+
+// Close records the interaction and closes the underlying stream.
+func (s *recordingStream) Close() error {
+	closeErr := s.Stream.Close()
+	if s.closed {
+		return closeErr
+	}
+	s.closed = true
+
+	s.interaction.Response = s.Stream.Response()
+	if s.interaction.Error == nil {
+		s.interaction.Error = newRecordedError(s.Stream.Err())
+	}
+	return errors.Join(closeErr, s.recorder.record(s.interaction))
+}
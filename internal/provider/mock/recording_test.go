@@ -0,0 +1,122 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// drain reads every chunk of a stream, then closes it.
+func drain(t *testing.T, stream provider.Stream) ([]api.ChatCompletionChunk, error) {
+	t.Helper()
+	var chunks []api.ChatCompletionChunk
+	var err error
+	for {
+		var chunk *api.ChatCompletionChunk
+		chunk, err = stream.Next()
+		if err != nil {
+			break
+		}
+		chunks = append(chunks, *chunk)
+	}
+	if closeErr := stream.Close(); closeErr != nil {
+		t.Fatalf("Close() error = %v", closeErr)
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return chunks, err
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	stop := "stop"
+	chunks := []*api.ChatCompletionChunk{
+		{ID: "1", Model: "ok", Choices: []api.Choice{{Delta: &api.Delta{Role: "assistant", Content: "hi"}}}},
+		{ID: "1", Model: "ok", Choices: []api.Choice{{Delta: &api.Delta{}, FinishReason: &stop}}},
+	}
+	resp := &api.ChatCompletionResponse{ID: "1", Object: "chat.completion", Model: "ok"}
+	m := New()
+	m.SetChunks("ok", chunks)
+	m.SetResponse("ok", resp)
+	m.SetError("limited", api.NewUpstreamError(http.StatusTooManyRequests, "rate limited"))
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	recorder := NewRecordingProvider(m, path)
+	stream, err := recorder.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{Model: "ok"})
+	if err != nil {
+		t.Fatalf("ChatCompletion(ok) error = %v", err)
+	}
+	recorded, err := drain(t, stream)
+	if err != nil {
+		t.Fatalf("stream error = %v", err)
+	}
+	if _, err := recorder.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{Model: "limited"}); err == nil {
+		t.Fatal("ChatCompletion(limited) succeeded")
+	}
+	if got := len(recorder.Fixture().Interactions); got != 2 {
+		t.Fatalf("recorded %d interactions, want 2", got)
+	}
+
+	replay, err := NewReplayProvider(path)
+	if err != nil {
+		t.Fatalf("NewReplayProvider() error = %v", err)
+	}
+	if replay.ID() != ProviderID || !replay.SupportsModel("ok") || !replay.SupportsModel("limited") {
+		t.Errorf("replay ID = %q, models = %v", replay.ID(), replay.Models())
+	}
+
+	stream, err = replay.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{Model: "ok"})
+	if err != nil {
+		t.Fatalf("replayed ChatCompletion(ok) error = %v", err)
+	}
+	replayed, err := drain(t, stream)
+	if err != nil {
+		t.Fatalf("replayed stream error = %v", err)
+	}
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed chunks = %+v, want %+v", replayed, recorded)
+	}
+	if !reflect.DeepEqual(stream.Response(), resp) {
+		t.Errorf("replayed response = %+v, want %+v", stream.Response(), resp)
+	}
+
+	_, err = replay.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{Model: "limited"})
+	var upstreamErr *api.UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("replayed error = %v, want the 429 UpstreamError", err)
+	}
+
+	_, err = replay.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{Model: "ok"})
+	if err == nil || !strings.Contains(err.Error(), "replay exhausted") {
+		t.Errorf("ChatCompletion() after the last interaction error = %v", err)
+	}
+}
+
+func TestReplayModelMismatch(t *testing.T) {
+	m := New()
+	m.SetChunks("ok", nil)
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	recorder := NewRecordingProvider(m, path)
+	stream, err := recorder.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{Model: "ok"})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	_, _ = drain(t, stream)
+
+	replay, err := NewReplayProvider(path)
+	if err != nil {
+		t.Fatalf("NewReplayProvider() error = %v", err)
+	}
+	_, err = replay.ChatCompletion(context.Background(), &provider.ChatCompletionRequest{Model: "other"})
+	if err == nil || !strings.Contains(err.Error(), "replay mismatch") {
+		t.Errorf("ChatCompletion(other) error = %v, want a mismatch", err)
+	}
+}
@@ -0,0 +1,113 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// ReplayProvider serves the interactions of a recorded fixture in order,
+// without contacting any upstream.
+type ReplayProvider struct {
+	fixture Fixture
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewReplayProvider loads a fixture written by RecordingProvider.
+func NewReplayProvider(path string) (*ReplayProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	return &ReplayProvider{fixture: f}, nil
+}
+
+// ID returns the ID of the recorded provider.
+func (p *ReplayProvider) ID() string {
+	return p.fixture.Provider
+}
+
+// Models returns the models recorded from the provider.
+func (p *ReplayProvider) Models() []api.Model {
+	return p.fixture.Models
+}
+
+// SupportsModel reports whether the model was listed or used in the recording.
+func (p *ReplayProvider) SupportsModel(modelID string) bool {
+	for _, m := range p.fixture.Models {
+		if m.ID == modelID {
+			return true
+		}
+	}
+	for _, in := range p.fixture.Interactions {
+		if in.Request != nil && in.Request.Model == modelID {
+			return true
+		}
+	}
+	return false
+}
+
+// ChatCompletion returns the next recorded interaction. The request must
+// be for the same model as the recorded one.
+func (p *ReplayProvider) ChatCompletion(_ context.Context, req *provider.ChatCompletionRequest) (provider.Stream, error) {
+	p.mu.Lock()
+	if p.next >= len(p.fixture.Interactions) {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("replay exhausted: fixture has %d interactions", len(p.fixture.Interactions))
+	}
+	in := p.fixture.Interactions[p.next]
+	p.next++
+	p.mu.Unlock()
+
+	if in.Request != nil && in.Request.Model != req.Model {
+		return nil, fmt.Errorf("replay mismatch: recorded request for model %q, got %q", in.Request.Model, req.Model)
+	}
+
+	// Errors returned by ChatCompletion itself were recorded without a stream
+	if in.Error != nil && in.Chunks == nil && in.Response == nil {
+		return nil, in.Error.Err()
+	}
+	return &replayStream{interaction: in}, nil
+}
+
+// replayStream replays recorded chunks, then the recorded error or io.EOF.
+type replayStream struct {
+	interaction Interaction
+	pos         int
+}
+
+func (s *replayStream) Next() (*api.ChatCompletionChunk, error) {
+	if s.pos < len(s.interaction.Chunks) {
+		chunk := s.interaction.Chunks[s.pos]
+		s.pos++
+		return &chunk, nil
+	}
+	if err := s.interaction.Error.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (s *replayStream) Response() *api.ChatCompletionResponse {
+	return s.interaction.Response
+}
+
+func (s *replayStream) Err() error {
+	return s.interaction.Error.Err()
+}
+
+func (s *replayStream) Close() error {
+	return nil
+}
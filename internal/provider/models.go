@@ -0,0 +1,300 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/config"
+)
+
+// modelsFetchTimeout bounds each models fetch.
+const modelsFetchTimeout = 30 * time.Second
+
+// ModelsFetcher fetches a provider's models from its API.
+type ModelsFetcher func(ctx context.Context) ([]api.Model, error)
+
+// StreamingModelsFetcher returns a ModelsFetcher that collects the models
+// s streams, so a refresh never holds the provider's raw catalog alongside
+// the decoded models.
+func StreamingModelsFetcher(s ModelStreamer) ModelsFetcher {
+	return func(ctx context.Context) ([]api.Model, error) {
+		models, err := s.StreamModels(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return CollectModels(ctx, models)
+	}
+}
+
+// CollectModels reads models until the channel is closed. It fails when
+// ctx ends first, since the catalog is then incomplete, or when no models
+// were sent.
+func CollectModels(ctx context.Context, models <-chan api.Model) ([]api.Model, error) {
+	var collected []api.Model
+	for m := range models {
+		collected = append(collected, m)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("models stream interrupted: %w", err)
+	}
+	if len(collected) == 0 {
+		return nil, errors.New("no models returned from API")
+	}
+	return collected, nil
+}
+
+// ModelsCache caches a provider's models in memory, refetching them when
+// they are older than the refresh interval. Each fetch is also written to
+// <cache dir>/<providerID>/models.json, so a restarted process can start
+// from it instead of fetching again.
+type ModelsCache struct {
+	providerID     string
+	fetcher        ModelsFetcher
+	mu             sync.RWMutex
+	models         []api.Model
+	modelIDs       map[string]bool
+	fetchedAt      time.Time
+	cacheTTL       time.Duration
+	stopRefresh    chan struct{}
+	refreshDone    chan struct{}
+	refreshStarted bool
+}
+
+// NewModelsCache creates a new models cache for providerID, starting from
+// the disk cache when it was fetched within the refresh interval.
+func NewModelsCache(providerID string, fetcher ModelsFetcher, refreshMinutes int) *ModelsCache {
+	c := &ModelsCache{
+		providerID:  providerID,
+		fetcher:     fetcher,
+		modelIDs:    make(map[string]bool),
+		cacheTTL:    time.Duration(refreshMinutes) * time.Minute,
+		stopRefresh: make(chan struct{}),
+		refreshDone: make(chan struct{}),
+	}
+	c.loadFromDisk()
+	return c
+}
+
+// GetModels returns the cached models, fetching them when the cache is
+// empty or stale. Returns the stale list (or nil) if the fetch fails.
+func (c *ModelsCache) GetModels() []api.Model {
+	c.mu.RLock()
+	if len(c.models) > 0 && time.Since(c.fetchedAt) < c.cacheTTL {
+		models := c.models
+		c.mu.RUnlock()
+		return models
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Double-check after acquiring write lock
+	if len(c.models) > 0 && time.Since(c.fetchedAt) < c.cacheTTL {
+		return c.models
+	}
+
+	models, err := c.fetch(context.Background())
+	if err != nil {
+		slog.Warn("failed to fetch models from API", "provider", c.providerID, "error", err)
+		return c.models
+	}
+	c.updateCache(models, time.Now())
+	// Save to disk asynchronously; saveToDisk needs the lock we hold
+	go c.saveToDisk()
+	return c.models
+}
+
+// SupportsModel checks if a model ID is supported.
+func (c *ModelsCache) SupportsModel(modelID string) bool {
+	c.mu.RLock()
+	if len(c.modelIDs) == 0 {
+		c.mu.RUnlock()
+		c.GetModels() // Populate cache
+		c.mu.RLock()
+	}
+	supported := c.modelIDs[modelID]
+	c.mu.RUnlock()
+	return supported
+}
+
+// RefreshModels forces a refresh of the models list and writes it to the
+// disk cache.
+func (c *ModelsCache) RefreshModels(ctx context.Context) error {
+	models, err := c.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.updateCache(models, time.Now())
+	c.mu.Unlock()
+
+	c.saveToDisk()
+	return nil
+}
+
+// updateCache updates the in-memory cache (must hold write lock).
+// fetchedAt is when the models were fetched from the API.
+func (c *ModelsCache) updateCache(models []api.Model, fetchedAt time.Time) {
+	c.models = models
+	c.modelIDs = make(map[string]bool, len(models))
+	for _, m := range models {
+		c.modelIDs[m.ID] = true
+	}
+	c.fetchedAt = fetchedAt
+}
+
+// fetch fetches models from the provider API.
+func (c *ModelsCache) fetch(ctx context.Context) ([]api.Model, error) {
+	ctx, cancel := context.WithTimeout(ctx, modelsFetchTimeout)
+	defer cancel()
+	return c.fetcher(ctx)
+}
+
+// Disk cache helpers
+
+type modelsCacheFile struct {
+	FetchedAt time.Time   `json:"fetched_at"`
+	Models    []api.Model `json:"models"`
+}
+
+func (c *ModelsCache) cachePath() string {
+	return filepath.Join(config.CacheDir(), c.providerID, "models.json")
+}
+
+// loadFromDisk fills the cache from disk if the stored models are younger
+// than the refresh interval. Errors are logged and otherwise ignored.
+func (c *ModelsCache) loadFromDisk() {
+	data, err := os.ReadFile(c.cachePath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("failed to read models cache", "provider", c.providerID, "error", err)
+		}
+		return
+	}
+
+	var file modelsCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		slog.Warn("failed to parse models cache", "provider", c.providerID, "error", err)
+		return
+	}
+	if len(file.Models) == 0 || time.Since(file.FetchedAt) >= c.cacheTTL {
+		return
+	}
+
+	c.mu.Lock()
+	c.updateCache(file.Models, file.FetchedAt)
+	c.mu.Unlock()
+	slog.Debug("loaded models from disk cache",
+		"provider", c.providerID,
+		"models", len(file.Models),
+		"age", time.Since(file.FetchedAt),
+	)
+}
+
+// saveToDisk writes the cached models to disk. Errors are logged only: the
+// models are still served from memory.
+func (c *ModelsCache) saveToDisk() {
+	c.mu.RLock()
+	file := modelsCacheFile{FetchedAt: c.fetchedAt, Models: c.models}
+	c.mu.RUnlock()
+
+	if err := writeModelsCache(c.cachePath(), &file); err != nil {
+		slog.Warn("failed to write models cache", "provider", c.providerID, "error", err)
+	}
+}
+
+// writeModelsCache writes file to a temporary file next to path and renames
+// it into place, so a concurrent reader never sees a partial cache.
+func writeModelsCache(path string, file *modelsCacheFile) error {
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "models-*.json.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// StartBackgroundRefresh starts a goroutine that periodically refreshes the models.
+func (c *ModelsCache) StartBackgroundRefresh() {
+	if c.cacheTTL <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	if c.refreshStarted {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshStarted = true
+	c.mu.Unlock()
+
+	slog.Debug("background models refresh started", "provider", c.providerID, "interval", c.cacheTTL)
+
+	go func() {
+		defer close(c.refreshDone)
+
+		ticker := time.NewTicker(c.cacheTTL)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stopRefresh:
+				slog.Debug("background models refresh stopped", "provider", c.providerID)
+				return
+			case <-ticker.C:
+				slog.Debug("background models refresh triggered", "provider", c.providerID)
+				if err := c.RefreshModels(context.Background()); err != nil {
+					slog.Warn("failed to refresh models", "provider", c.providerID, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// StopBackgroundRefresh stops the background refresh goroutine.
+func (c *ModelsCache) StopBackgroundRefresh() {
+	c.mu.Lock()
+	if !c.refreshStarted {
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.stopRefresh:
+		// Already closed
+	default:
+		close(c.stopRefresh)
+	}
+	<-c.refreshDone
+}
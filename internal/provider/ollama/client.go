@@ -0,0 +1,96 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// HTTPTimeout is long enough for local models, which may need to load
+// before they respond.
+const HTTPTimeout = 10 * time.Minute
+
+// Client handles communication with an Ollama server.
+type Client struct {
+	httpClient *http.Client
+	cfg        *Config
+}
+
+// NewClient creates a new Ollama client.
+func NewClient(cfg *Config) *Client {
+	return &Client{
+		httpClient: &http.Client{
+			Timeout: HTTPTimeout,
+		},
+		cfg: cfg,
+	}
+}
+
+// SendRequest sends a chat request and returns the streaming response.
+func (c *Client) SendRequest(ctx context.Context, chatReq *ChatRequest) (*http.Response, error) {
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.BaseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
+
+// FetchModels lists the locally available models.
+func (c *Client) FetchModels(ctx context.Context) ([]api.Model, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.BaseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch models: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("models request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var response struct {
+		Models []struct {
+			Name       string    `json:"name"`
+			ModifiedAt time.Time `json:"modified_at"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to parse models response: %w", err)
+	}
+
+	models := make([]api.Model, 0, len(response.Models))
+	for _, m := range response.Models {
+		models = append(models, api.Model{
+			ID:      m.Name,
+			Object:  "model",
+			Created: m.ModifiedAt.Unix(),
+			OwnedBy: "ollama",
+		})
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no models installed (run: ollama pull <model>)")
+	}
+	return models, nil
+}
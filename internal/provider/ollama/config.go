@@ -0,0 +1,64 @@
+package ollama
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Provider identification
+const ProviderID = "ollama"
+
+// Environment variable names for Ollama provider
+const (
+	EnvBaseURL       = "OPENCOMPAT_OLLAMA_BASE_URL"
+	EnvModelsRefresh = "OPENCOMPAT_OLLAMA_MODELS_REFRESH"
+)
+
+// Default values
+const (
+	DefaultBaseURL       = "http://localhost:11434"
+	DefaultModelsRefresh = 5 // minutes; local models are pulled and removed often
+)
+
+// Config holds Ollama-specific configuration.
+type Config struct {
+	BaseURL       string // Ollama server URL, without trailing slash
+	ModelsRefresh int    // refresh interval in minutes
+}
+
+// LoadConfig reads Ollama configuration from environment variables.
+func LoadConfig() *Config {
+	baseURL := DefaultBaseURL
+	if val := os.Getenv(EnvBaseURL); val != "" {
+		baseURL = val
+	}
+	return &Config{
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		ModelsRefresh: getEnvInt(EnvModelsRefresh, DefaultModelsRefresh),
+	}
+}
+
+// EnvVarDoc documents an environment variable.
+type EnvVarDoc struct {
+	Name        string
+	Description string
+	Default     string
+}
+
+// EnvVarDocs returns documentation for environment variables.
+func EnvVarDocs() []EnvVarDoc {
+	return []EnvVarDoc{
+		{Name: EnvBaseURL, Description: "Ollama server URL", Default: DefaultBaseURL},
+		{Name: EnvModelsRefresh, Description: "Models refresh interval in minutes", Default: strconv.Itoa(DefaultModelsRefresh)},
+	}
+}
+
+func getEnvInt(key string, defaultVal int) int {
+	if val := os.Getenv(key); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			return i
+		}
+	}
+	return defaultVal
+}
@@ -0,0 +1,216 @@
+package ollama
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// Stream implements the provider.Stream interface for Ollama responses.
+// Ollama streams newline-delimited JSON objects rather than SSE; each one
+// is converted into a chat completion chunk. The upstream request always
+// streams; for non-streaming requests the chunks are merged into the
+// response returned by Response().
+type Stream struct {
+	resp          *http.Response
+	decoder       *json.Decoder
+	includeUsage  bool
+	statusChecked bool
+	sentRole      bool
+	done          bool
+	err           error
+
+	id        string
+	model     string
+	created   int64
+	usage     *api.Usage
+	toolCalls int // tool calls emitted so far
+
+	chunks   []api.ChatCompletionChunk
+	response *api.ChatCompletionResponse
+}
+
+// NewStream creates a new stream from an HTTP response.
+func NewStream(resp *http.Response, model string, includeUsage bool) *Stream {
+	return &Stream{
+		resp:         resp,
+		decoder:      json.NewDecoder(resp.Body),
+		includeUsage: includeUsage,
+		id:           "chatcmpl-" + uuid.New().String(),
+		model:        model,
+		created:      time.Now().Unix(),
+	}
+}
+
+// Next returns the next chunk from the stream.
+func (s *Stream) Next() (*api.ChatCompletionChunk, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+
+	// Check HTTP status once
+	if !s.statusChecked {
+		s.statusChecked = true
+		if s.resp.StatusCode != http.StatusOK {
+			s.done = true
+			body, _ := io.ReadAll(s.resp.Body)
+			s.err = newUpstreamError(s.resp.StatusCode, body)
+			return nil, s.err
+		}
+	}
+
+	for {
+		var line ChatResponse
+		if err := s.decoder.Decode(&line); err != nil {
+			if errors.Is(err, io.EOF) {
+				// Streams normally end with a done object; treat a bare EOF the same
+				return s.finish()
+			}
+			s.done = true
+			s.err = fmt.Errorf("invalid stream line: %w", err)
+			return nil, s.err
+		}
+		if line.Error != "" {
+			s.done = true
+			s.err = api.NewUpstreamError(http.StatusBadGateway, line.Error)
+			return nil, s.err
+		}
+
+		chunk := s.processLine(&line)
+		if line.Done {
+			s.usage = &api.Usage{
+				PromptTokens:     line.PromptEvalCount,
+				CompletionTokens: line.EvalCount,
+				TotalTokens:      line.PromptEvalCount + line.EvalCount,
+			}
+		}
+		if chunk != nil {
+			s.chunks = append(s.chunks, *chunk)
+			return chunk, nil
+		}
+		if line.Done {
+			return s.finish()
+		}
+	}
+}
+
+// processLine converts one response object, returning nil if it carries
+// nothing to forward. The done object carries the finish reason.
+func (s *Stream) processLine(line *ChatResponse) *api.ChatCompletionChunk {
+	delta := api.Delta{Content: line.Message.Content}
+	for _, tc := range line.Message.ToolCalls {
+		index := s.toolCalls
+		s.toolCalls++
+		args := string(tc.Function.Arguments)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		delta.ToolCalls = append(delta.ToolCalls, api.ToolCall{
+			Index:    &index,
+			ID:       "call_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+			Type:     "function",
+			Function: api.FunctionCall{Name: tc.Function.Name, Arguments: args},
+		})
+	}
+
+	var reason *string
+	if line.Done {
+		r := finishReason(line.DoneReason, s.toolCalls > 0)
+		reason = &r
+	}
+	if delta.Content == "" && len(delta.ToolCalls) == 0 && reason == nil {
+		return nil
+	}
+
+	if !s.sentRole {
+		s.sentRole = true
+		delta.Role = "assistant"
+	}
+	return &api.ChatCompletionChunk{
+		ID:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []api.Choice{{
+			Index:        0,
+			Delta:        &delta,
+			FinishReason: reason,
+		}},
+	}
+}
+
+// finish ends the stream, builds the merged response and returns the usage
+// chunk if the client asked for one.
+func (s *Stream) finish() (*api.ChatCompletionChunk, error) {
+	s.done = true
+
+	usage := s.usage
+	if usage == nil {
+		usage = &api.Usage{}
+	}
+	usageChunk := &api.ChatCompletionChunk{
+		ID:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []api.Choice{},
+		Usage:   usage,
+	}
+	if resp, err := api.MergeChunks(append(s.chunks, *usageChunk)); err == nil {
+		resp.ID = s.id
+		resp.Created = s.created
+		resp.Model = s.model
+		s.response = resp
+	}
+
+	if s.includeUsage {
+		return usageChunk, nil
+	}
+	return nil, io.EOF
+}
+
+// Response returns the accumulated response. Call after Next() returns io.EOF.
+func (s *Stream) Response() *api.ChatCompletionResponse {
+	return s.response
+}
+
+// Err returns any error that occurred during streaming.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Close releases resources associated with the stream.
+func (s *Stream) Close() error {
+	if s.resp != nil && s.resp.Body != nil {
+		return s.resp.Body.Close()
+	}
+	return nil
+}
+
+// newUpstreamError builds the error for a non-200 response. Ollama error
+// bodies are {"error": "..."}.
+func newUpstreamError(statusCode int, body []byte) error {
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+		return api.NewUpstreamError(statusCode, errResp.Error)
+	}
+
+	bodyStr := string(body)
+	if len(bodyStr) > 500 {
+		bodyStr = bodyStr[:500] + "..."
+	}
+	if bodyStr == "" {
+		bodyStr = "unknown error"
+	}
+	return api.NewUpstreamError(statusCode, bodyStr)
+}
@@ -0,0 +1,163 @@
+package ollama
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// TransformRequest converts a chat completion request to an /api/chat
+// request. The upstream request always streams.
+func TransformRequest(req *provider.ChatCompletionRequest) (*ChatRequest, error) {
+	messages, err := transformMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	stop, err := parseStop(req.Stop)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &Options{
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		NumPredict:       req.MaxTokens,
+		Stop:             stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+	}
+	if req.MaxCompletionTokens != nil {
+		opts.NumPredict = req.MaxCompletionTokens
+	}
+
+	tools, err := transformTools(req.Tools, req.ToolChoice)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &ChatRequest{
+		Model:    req.Model,
+		Messages: messages,
+		Tools:    tools,
+		Options:  opts,
+		Stream:   true,
+	}
+	if req.ResponseFormat != nil && req.ResponseFormat.Type == "json_object" {
+		out.Format = json.RawMessage(`"json"`)
+	}
+	return out, nil
+}
+
+// transformTools applies tool_choice to the tools. Ollama always lets the
+// model decide, so "none" drops the tools and forcing a tool call is
+// rejected.
+func transformTools(tools []api.Tool, raw json.RawMessage) ([]api.Tool, error) {
+	choice, err := api.ParseToolChoice(raw)
+	if err != nil || choice == nil {
+		return tools, err
+	}
+	switch choice.Mode {
+	case api.ToolChoiceNone:
+		return nil, nil
+	case api.ToolChoiceRequired, api.ToolChoiceFunction:
+		return nil, &provider.ParameterNotSupportedError{
+			Param:  "tool_choice",
+			Reason: "Ollama cannot force a tool call; use \"auto\" or \"none\"",
+		}
+	}
+	return tools, nil
+}
+
+// transformMessages converts messages to Ollama's format. Tool results are
+// labeled with the function name, found by tool_call_id, since Ollama tool
+// calls have no IDs.
+func transformMessages(messages []api.Message) ([]Message, error) {
+	result := make([]Message, 0, len(messages))
+	callNames := make(map[string]string) // tool call ID -> function name
+
+	for i, msg := range messages {
+		out := Message{Role: msg.Role}
+		var texts []string
+		for _, part := range msg.GetContentParts() {
+			switch part.Type {
+			case "text":
+				texts = append(texts, part.Text)
+			case "image_url":
+				if part.ImageURL == nil {
+					continue
+				}
+				data, err := imageData(part.ImageURL.URL)
+				if err != nil {
+					return nil, fmt.Errorf("messages[%d]: %w", i, err)
+				}
+				out.Images = append(out.Images, data)
+			}
+		}
+		out.Content = strings.Join(texts, "\n")
+
+		for _, tc := range msg.ToolCalls {
+			args := json.RawMessage(tc.Function.Arguments)
+			if len(strings.TrimSpace(tc.Function.Arguments)) == 0 {
+				args = json.RawMessage(`{}`)
+			} else if !json.Valid(args) {
+				return nil, fmt.Errorf("messages[%d]: tool call %s has invalid JSON arguments", i, tc.ID)
+			}
+			callNames[tc.ID] = tc.Function.Name
+			out.ToolCalls = append(out.ToolCalls, ToolCall{Function: ToolCallFunction{
+				Name:      tc.Function.Name,
+				Arguments: args,
+			}})
+		}
+		if msg.Role == "tool" {
+			out.ToolName = callNames[msg.ToolCallID]
+		}
+
+		result = append(result, out)
+	}
+	return result, nil
+}
+
+// imageData extracts the base64 payload of an image data URL. Ollama only
+// accepts inline images.
+func imageData(url string) (string, error) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return "", fmt.Errorf("only base64 data URL images are supported")
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return "", fmt.Errorf("unsupported image data URL; expected data:<media type>;base64,<data>")
+	}
+	return data, nil
+}
+
+// parseStop converts stop (a string or array of strings) to stop sequences.
+func parseStop(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("stop must be a string or an array of strings")
+	}
+	return list, nil
+}
+
+// finishReason maps an Ollama done reason to an OpenAI finish reason.
+func finishReason(reason string, calledTool bool) string {
+	if reason == "length" {
+		return "length"
+	}
+	if calledTool {
+		return "tool_calls"
+	}
+	return "stop"
+}
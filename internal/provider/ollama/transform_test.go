@@ -0,0 +1,57 @@
+package ollama
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+func TestTransformToolChoice(t *testing.T) {
+	tools := []api.Tool{{Type: "function", Function: api.Function{Name: "get_weather"}}}
+	tests := []struct {
+		name          string
+		toolChoice    string
+		wantTools     int
+		wantParamErr  bool
+		wantParseFail bool
+	}{
+		{name: "unset", wantTools: 1},
+		{name: "auto", toolChoice: `"auto"`, wantTools: 1},
+		{name: "none", toolChoice: `"none"`, wantTools: 0},
+		{name: "required", toolChoice: `"required"`, wantParamErr: true},
+		{name: "named function", toolChoice: `{"type":"function","function":{"name":"get_weather"}}`, wantParamErr: true},
+		{name: "invalid", toolChoice: `"sometimes"`, wantParseFail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &provider.ChatCompletionRequest{
+				Model:    "llama3",
+				Messages: []api.Message{api.UserMessage("weather?")},
+				Tools:    tools,
+			}
+			if tt.toolChoice != "" {
+				req.ToolChoice = json.RawMessage(tt.toolChoice)
+			}
+
+			out, err := TransformRequest(req)
+			var paramErr *provider.ParameterNotSupportedError
+			switch {
+			case tt.wantParamErr:
+				if !errors.As(err, &paramErr) || paramErr.Param != "tool_choice" {
+					t.Errorf("TransformRequest() error = %v, want a tool_choice ParameterNotSupportedError", err)
+				}
+			case tt.wantParseFail:
+				if err == nil || errors.As(err, &paramErr) {
+					t.Errorf("TransformRequest() error = %v, want a parse error", err)
+				}
+			case err != nil:
+				t.Fatalf("TransformRequest() error = %v", err)
+			case len(out.Tools) != tt.wantTools:
+				t.Errorf("tools = %d, want %d", len(out.Tools), tt.wantTools)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package ollama
+
+import (
+	"encoding/json"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// ChatRequest is the Ollama /api/chat request body.
+type ChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []Message       `json:"messages"`
+	Tools    []api.Tool      `json:"tools,omitempty"` // same shape as OpenAI tools
+	Format   json.RawMessage `json:"format,omitempty"`
+	Options  *Options        `json:"options,omitempty"`
+	Stream   bool            `json:"stream"`
+}
+
+// Message is an Ollama chat message. Images are base64 without a data URL prefix.
+type Message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Thinking  string     `json:"thinking,omitempty"`
+	Images    []string   `json:"images,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+}
+
+// ToolCall is a function call. Unlike OpenAI, arguments are a JSON object
+// rather than a string, and calls carry no ID.
+type ToolCall struct {
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction names the function and its arguments.
+type ToolCallFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// Options holds sampling parameters.
+type Options struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	NumPredict       *int     `json:"num_predict,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+}
+
+// ChatResponse is one line of the newline-delimited /api/chat response.
+// The final line has Done set and carries the token counts.
+type ChatResponse struct {
+	Model           string  `json:"model"`
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	DoneReason      string  `json:"done_reason,omitempty"`
+	PromptEvalCount int     `json:"prompt_eval_count,omitempty"`
+	EvalCount       int     `json:"eval_count,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
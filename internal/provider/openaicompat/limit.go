@@ -0,0 +1,39 @@
+package openaicompat
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrResponseTooLarge is returned when an upstream response body exceeds
+// the limit set with Stream.WithMaxBodyBytes.
+var ErrResponseTooLarge = errors.New("upstream response body too large")
+
+// limitedBody fails reads once more than limit bytes have been read.
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.tooLarge()
+	}
+	// Read one byte past the limit to tell a body of exactly limit bytes
+	// from a larger one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), b.tooLarge()
+	}
+	return n, err
+}
+
+func (b *limitedBody) tooLarge() error {
+	return fmt.Errorf("%w (limit %d bytes)", ErrResponseTooLarge, b.limit)
+}
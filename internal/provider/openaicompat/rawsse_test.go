@@ -0,0 +1,101 @@
+package openaicompat
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// upstreamSSE is a stream as an upstream sends it, with fields the API types
+// don't model and default fields left out.
+const upstreamSSE = `data: {"id":"1","object":"chat.completion.chunk","created":1700000000,"model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}],"prompt_filter_results":[]}
+
+data: {"id":"1","object":"chat.completion.chunk","created":1700000000,"model":"m","choices":[{"index":0,"delta":{"content":"Hello"}}]}
+
+data: {"id":"1","object":"chat.completion.chunk","created":1700000000,"model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}
+
+data: {"id":"1","object":"chat.completion.chunk","created":1700000000,"model":"m","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":3,"completion_tokens":7,"total_tokens":10}}
+
+data: [DONE]
+
+`
+
+// normalizedSSE is upstreamSSE re-serialized: unknown fields and empty
+// content are dropped, finish_reason and logprobs are always present.
+const normalizedSSE = `data: {"id":"1","object":"chat.completion.chunk","created":1700000000,"model":"m","choices":[{"index":0,"delta":{"role":"assistant"},"finish_reason":null,"logprobs":null}]}
+
+data: {"id":"1","object":"chat.completion.chunk","created":1700000000,"model":"m","choices":[{"index":0,"delta":{"content":"Hello"},"finish_reason":null,"logprobs":null}]}
+
+data: {"id":"1","object":"chat.completion.chunk","created":1700000000,"model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]},"finish_reason":null,"logprobs":null}]}
+
+data: {"id":"1","object":"chat.completion.chunk","created":1700000000,"model":"m","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls","logprobs":null}],"usage":{"prompt_tokens":3,"completion_tokens":7,"total_tokens":10}}
+
+data: [DONE]
+
+`
+
+func reserialize(t *testing.T, sse string) string {
+	t.Helper()
+	resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(sse))}
+	out, err := io.ReadAll(provider.RawSSEReader(NewStream(resp, true, nil)))
+	if err != nil {
+		t.Fatalf("reading RawSSEReader: %v", err)
+	}
+	return string(out)
+}
+
+func TestRawSSERoundTrip(t *testing.T) {
+	if got := reserialize(t, upstreamSSE); got != normalizedSSE {
+		t.Errorf("re-serialized stream:\n%s\nwant:\n%s", got, normalizedSSE)
+	}
+	// Normalized output survives another round trip byte for byte
+	if got := reserialize(t, normalizedSSE); got != normalizedSSE {
+		t.Errorf("second round trip changed the stream:\n%s", got)
+	}
+
+	// RawSSEWriter writes the same bytes
+	var buf bytes.Buffer
+	resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(upstreamSSE))}
+	if err := provider.NewRawSSEWriter(&buf).WriteStream(NewStream(resp, true, nil)); err != nil {
+		t.Fatalf("WriteStream() error = %v", err)
+	}
+	if buf.String() != normalizedSSE {
+		t.Errorf("WriteStream() wrote:\n%s\nwant:\n%s", buf.String(), normalizedSSE)
+	}
+}
+
+// failingReader returns err once its data is consumed.
+type failingReader struct {
+	data io.Reader
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+func TestRawSSEStreamError(t *testing.T) {
+	first := `data: {"id":"1","object":"chat.completion.chunk","created":1700000000,"model":"m","choices":[{"index":0,"delta":{"content":"Hel"},"finish_reason":null,"logprobs":null}]}` + "\n\n"
+	body := &failingReader{data: strings.NewReader(first), err: errors.New("connection reset by peer")}
+	resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(body)}
+
+	out, err := io.ReadAll(provider.RawSSEReader(NewStream(resp, true, nil)))
+	if err != nil {
+		t.Fatalf("reading RawSSEReader: %v", err)
+	}
+	want := first +
+		`data: {"error":{"message":"connection reset by peer","type":"server_error","param":null,"code":null}}` + "\n\n" +
+		"data: [DONE]\n\n"
+	if string(out) != want {
+		t.Errorf("re-serialized stream:\n%s\nwant:\n%s", out, want)
+	}
+}
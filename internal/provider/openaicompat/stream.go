@@ -0,0 +1,455 @@
+// Package openaicompat provides the response stream shared by providers
+// whose upstream speaks the OpenAI chat completions wire format.
+package openaicompat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/sse"
+)
+
+// DefaultMaxMalformedEvents is the number of consecutive malformed events
+// after which a stream fails with provider.ErrStreamCorrupted.
+const DefaultMaxMalformedEvents = 5
+
+// maxLoggedEventBytes truncates the data of malformed events in logs.
+const maxLoggedEventBytes = 512
+
+// ErrorFunc builds the error returned for a non-200 upstream response.
+type ErrorFunc func(statusCode int, body []byte) error
+
+// Stream implements the provider.Stream interface for upstreams that speak
+// the OpenAI chat completions wire format. It is a thin pass-through wrapper.
+type Stream struct {
+	resp          *http.Response
+	reader        *sse.Reader
+	streaming     bool
+	done          bool
+	statusChecked bool
+	response      *api.ChatCompletionResponse
+	err           error
+	newError      ErrorFunc
+	logger        *slog.Logger
+
+	startTime    time.Time  // see WithStartTime
+	firstTokenAt time.Time  // zero until a chunk with content is returned
+	usage        *api.Usage // last usage seen in a streamed chunk
+
+	maxMalformed         int // see WithMaxMalformedEvents
+	malformed            int // malformed events seen
+	consecutiveMalformed int
+}
+
+// NewStream creates a new stream from an HTTP response. newError builds
+// the error for non-200 responses; nil uses NewUpstreamError.
+func NewStream(resp *http.Response, streaming bool, newError ErrorFunc) *Stream {
+	if newError == nil {
+		newError = NewUpstreamError
+	}
+	s := &Stream{
+		resp:         resp,
+		streaming:    streaming,
+		newError:     newError,
+		maxMalformed: DefaultMaxMalformedEvents,
+		startTime:    time.Now(),
+	}
+	if streaming {
+		s.reader = sse.NewReader(resp.Body)
+	}
+	return s
+}
+
+// WithLogger sets the logger for skipped events; nil (the default) uses
+// slog.Default(). It returns s.
+func (s *Stream) WithLogger(logger *slog.Logger) *Stream {
+	s.logger = logger
+	return s
+}
+
+// WithMaxBodyBytes fails the stream with ErrResponseTooLarge once more
+// than n bytes of the response body have been read, in both streaming and
+// non-streaming mode; n <= 0 (the default) means no limit. It must be
+// called before the stream is read, and returns s.
+func (s *Stream) WithMaxBodyBytes(n int64) *Stream {
+	if n <= 0 {
+		return s
+	}
+	s.resp.Body = &limitedBody{ReadCloser: s.resp.Body, limit: n, remaining: n}
+	if s.streaming {
+		s.reader = sse.NewReader(s.resp.Body)
+	}
+	return s
+}
+
+// WithMaxMalformedEvents fails the stream with provider.ErrStreamCorrupted
+// after n consecutive events whose data isn't a valid chunk; n <= 0 only
+// logs them. The default is DefaultMaxMalformedEvents. It returns s.
+func (s *Stream) WithMaxMalformedEvents(n int) *Stream {
+	s.maxMalformed = n
+	return s
+}
+
+// WithStartTime measures FirstTokenLatency from t, typically when the
+// request was sent, instead of from NewStream. It returns s.
+func (s *Stream) WithStartTime(t time.Time) *Stream {
+	s.startTime = t
+	return s
+}
+
+// FirstTokenLatency implements provider.FirstTokenReporter.
+func (s *Stream) FirstTokenLatency() (time.Duration, bool) {
+	if s.firstTokenAt.IsZero() {
+		return 0, false
+	}
+	return s.firstTokenAt.Sub(s.startTime), true
+}
+
+// ResponseHeader returns a header of the upstream response, e.g.
+// "X-Request-Id", or "" if it is missing.
+func (s *Stream) ResponseHeader(key string) string {
+	return s.resp.Header.Get(key)
+}
+
+// Metadata implements provider.MetadataReporter, reporting the X-Request-Id
+// of the upstream response.
+func (s *Stream) Metadata() map[string]string {
+	if id := s.ResponseHeader("X-Request-Id"); id != "" {
+		return map[string]string{provider.MetadataUpstreamRequestID: id}
+	}
+	return nil
+}
+
+// MalformedEvents returns the number of malformed events skipped so far.
+func (s *Stream) MalformedEvents() int {
+	return s.malformed
+}
+
+func (s *Stream) log() *slog.Logger {
+	if s.logger == nil {
+		return slog.Default()
+	}
+	return s.logger
+}
+
+// Next returns the next chunk from the stream.
+// For non-streaming requests, returns io.EOF immediately (use Response() to get the result).
+func (s *Stream) Next() (*api.ChatCompletionChunk, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+
+	// Check HTTP status once
+	if !s.statusChecked {
+		s.statusChecked = true
+		if s.resp.StatusCode != http.StatusOK {
+			s.done = true
+			body, _ := io.ReadAll(s.resp.Body)
+			s.err = s.newError(s.resp.StatusCode, body)
+			return nil, s.err
+		}
+
+		// For non-streaming: read response immediately and return EOF
+		if !s.streaming {
+			s.done = true
+			return nil, s.readNonStreaming()
+		}
+	}
+
+	// Streaming: read next SSE event
+	for {
+		event, err := s.reader.ReadEvent()
+		if err != nil {
+			s.done = true
+			if err != io.EOF {
+				s.err = err
+			}
+			return nil, err
+		}
+
+		// Skip empty events: named keep-alives such as "event: ping" are
+		// expected, a data field without a value is not
+		if len(event.Data) == 0 {
+			if event.Event == "" {
+				s.log().Warn("skipping SSE event with empty data")
+			}
+			continue
+		}
+
+		// Parse chunk
+		var chunk api.ChatCompletionChunk
+		if err := json.Unmarshal(event.Data, &chunk); err != nil {
+			s.malformed++
+			s.consecutiveMalformed++
+			data := event.Data
+			if len(data) > maxLoggedEventBytes {
+				data = data[:maxLoggedEventBytes]
+			}
+			s.log().Warn("skipping malformed SSE event",
+				"event", event.Event,
+				"bytes", len(event.Data),
+				"data", string(data),
+				"malformed_events", s.malformed,
+				"error", err,
+			)
+			if s.maxMalformed > 0 && s.consecutiveMalformed >= s.maxMalformed {
+				s.done = true
+				s.err = fmt.Errorf("%w: %d consecutive malformed events", provider.ErrStreamCorrupted, s.consecutiveMalformed)
+				return nil, s.err
+			}
+			continue
+		}
+		s.consecutiveMalformed = 0
+
+		// Drop intermediate chunks that carry nothing (e.g., empty choices)
+		if isEmptyChunk(&chunk) {
+			continue
+		}
+
+		s.observe(&chunk)
+		normalizeChunk(&chunk)
+		return &chunk, nil
+	}
+}
+
+// WriteTo implements io.WriterTo for streaming responses, copying the raw
+// upstream SSE events to w without decoding and re-encoding each chunk. It
+// must be called instead of Next, never after it. Chunks are not normalized
+// in this mode, so it is only suitable when the response isn't inspected.
+func (s *Stream) WriteTo(w io.Writer) (int64, error) {
+	if !s.streaming || s.statusChecked {
+		return 0, errors.New("WriteTo requires an unread streaming response")
+	}
+	s.statusChecked = true
+	defer func() { s.done = true }()
+
+	if s.resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(s.resp.Body)
+		s.err = s.newError(s.resp.StatusCode, body)
+		return 0, s.err
+	}
+
+	var written int64
+	write := func(p []byte) error {
+		n, err := w.Write(p)
+		written += int64(n)
+		return err
+	}
+
+	// Forward one complete event per write, so each is flushed as a unit
+	reader := bufio.NewReader(s.resp.Body)
+	var event bytes.Buffer
+	sawDone := false
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if len(bytes.TrimRight(line, "\r\n")) == 0 {
+				if event.Len() > 0 {
+					event.Write(line)
+					if bytes.Contains(event.Bytes(), []byte("data: [DONE]")) {
+						sawDone = true
+					} else {
+						s.observeRaw(event.Bytes())
+					}
+					if werr := write(event.Bytes()); werr != nil {
+						return written, werr
+					}
+					event.Reset()
+				}
+			} else {
+				event.Write(line)
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+				return written, err
+			}
+			break
+		}
+	}
+
+	// Terminate a truncated final event and make sure the client sees [DONE]
+	if event.Len() > 0 {
+		if bytes.Contains(event.Bytes(), []byte("data: [DONE]")) {
+			sawDone = true
+		} else {
+			s.observeRaw(event.Bytes())
+		}
+		event.WriteString("\n\n")
+		if err := write(event.Bytes()); err != nil {
+			return written, err
+		}
+	}
+	if !sawDone {
+		if err := write([]byte("data: [DONE]\n\n")); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// observe records the first token time and usage of a streamed chunk.
+func (s *Stream) observe(chunk *api.ChatCompletionChunk) {
+	if s.firstTokenAt.IsZero() && chunk.HasContent() {
+		s.firstTokenAt = time.Now()
+	}
+	if chunk.Usage != nil {
+		s.usage = chunk.Usage
+	}
+}
+
+// observeRaw is observe for a raw SSE event in WriteTo. It only decodes
+// events that can matter: those until the first token, and usage events.
+func (s *Stream) observeRaw(event []byte) {
+	if !s.firstTokenAt.IsZero() && !bytes.Contains(event, []byte(`"usage":{`)) {
+		return
+	}
+	var data []byte
+	for line := range bytes.Lines(event) {
+		if value, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = append(data, bytes.TrimSpace(value)...)
+		}
+	}
+	var chunk api.ChatCompletionChunk
+	if json.Unmarshal(data, &chunk) == nil {
+		s.observe(&chunk)
+	}
+}
+
+// Usage returns the token usage reported in the streamed chunks read so
+// far, by Next or WriteTo, or nil if there was none.
+func (s *Stream) Usage() *api.Usage {
+	return s.usage
+}
+
+// readNonStreaming reads and parses a non-streaming response.
+// Returns io.EOF on success (response available via Response()), or error on failure.
+func (s *Stream) readNonStreaming() error {
+	body, err := io.ReadAll(s.resp.Body)
+	if err != nil {
+		s.err = err
+		return err
+	}
+
+	var resp api.ChatCompletionResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		s.err = err
+		return err
+	}
+
+	normalizeResponse(&resp)
+	s.response = &resp
+	return io.EOF
+}
+
+// Response returns the non-streaming response.
+func (s *Stream) Response() *api.ChatCompletionResponse {
+	return s.response
+}
+
+// Err returns any error that occurred during streaming.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Close releases resources associated with the stream.
+func (s *Stream) Close() error {
+	if s.resp != nil && s.resp.Body != nil {
+		return s.resp.Body.Close()
+	}
+	return nil
+}
+
+// isEmptyChunk reports whether a chunk has no content, usage, role, finish
+// reason, refusal or reasoning, so forwarding it would only confuse clients
+// that expect choices[0] to exist.
+func isEmptyChunk(chunk *api.ChatCompletionChunk) bool {
+	if chunk.HasContent() || chunk.Usage != nil {
+		return false
+	}
+	for _, choice := range chunk.Choices {
+		if choice.FinishReason != nil {
+			return false
+		}
+		if d := choice.Delta; d != nil && (d.Role != "" || d.Refusal != "" || d.Reasoning != nil || d.ReasoningSummary != "") {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeChunk ensures OpenAI-required fields are set on streaming chunks.
+func normalizeChunk(chunk *api.ChatCompletionChunk) {
+	if chunk.Object == "" {
+		chunk.Object = "chat.completion.chunk"
+	}
+	if chunk.Created == 0 {
+		chunk.Created = time.Now().Unix()
+	}
+}
+
+// normalizeResponse ensures OpenAI-required fields are set on non-streaming responses.
+func normalizeResponse(resp *api.ChatCompletionResponse) {
+	if resp.Object == "" {
+		resp.Object = "chat.completion"
+	}
+	if resp.Created == 0 {
+		resp.Created = time.Now().Unix()
+	}
+}
+
+// NewUpstreamError builds the error for a non-200 response, recognizing
+// context length errors so the handler can suggest larger models.
+func NewUpstreamError(statusCode int, body []byte) error {
+	return UpstreamError(statusCode, ErrorMessage(body))
+}
+
+// UpstreamError builds the error for a non-200 response with an already
+// extracted message, recognizing context length errors.
+func UpstreamError(statusCode int, message string) error {
+	if statusCode == http.StatusBadRequest {
+		if ctxErr := api.ParseContextLengthExceeded(message); ctxErr != nil {
+			return ctxErr
+		}
+	}
+	return api.NewUpstreamError(statusCode, message)
+}
+
+// ErrorMessage extracts the message from an OpenAI-style error body,
+// falling back to the (truncated) body itself.
+func ErrorMessage(body []byte) string {
+	var errResp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Message string `json:"message"`
+	}
+
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		if errResp.Error.Message != "" {
+			return errResp.Error.Message
+		}
+		if errResp.Message != "" {
+			return errResp.Message
+		}
+	}
+
+	bodyStr := string(body)
+	if len(bodyStr) > 500 {
+		bodyStr = bodyStr[:500] + "..."
+	}
+	if bodyStr == "" {
+		return "unknown error"
+	}
+	return bodyStr
+}
@@ -0,0 +1,179 @@
+package openaicompat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// sseBody builds a streamed response of n content chunks, a usage chunk
+// and [DONE].
+func sseBody(n int) string {
+	var b strings.Builder
+	b.WriteString(`data: {"id":"1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"role":"assistant"}}]}` + "\n\n")
+	for i := range n {
+		fmt.Fprintf(&b, `data: {"id":"1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"content":"token %d "}}]}`+"\n\n", i)
+	}
+	b.WriteString(`data: {"id":"1","object":"chat.completion.chunk","model":"m","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":7,"total_tokens":10}}` + "\n\n")
+	b.WriteString("data: [DONE]\n\n")
+	return b.String()
+}
+
+func newTestStream(body string) *Stream {
+	resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}
+	return NewStream(resp, true, nil)
+}
+
+func TestWriteToObservesFirstTokenAndUsage(t *testing.T) {
+	body := sseBody(3)
+	s := newTestStream(body).WithStartTime(time.Now().Add(-time.Second))
+
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if buf.String() != body {
+		t.Errorf("WriteTo() changed the stream:\n%s", buf.String())
+	}
+	if d, ok := s.FirstTokenLatency(); !ok || d < time.Second {
+		t.Errorf("FirstTokenLatency() = %s, %v; want at least 1s", d, ok)
+	}
+	if u := s.Usage(); u == nil || u.TotalTokens != 10 {
+		t.Errorf("Usage() = %+v, want 10 total tokens", u)
+	}
+}
+
+func TestNextObservesUsage(t *testing.T) {
+	s := newTestStream(sseBody(1))
+	for {
+		if _, err := s.Next(); err != nil {
+			break
+		}
+	}
+	if u := s.Usage(); u == nil || u.CompletionTokens != 7 {
+		t.Errorf("Usage() = %+v, want 7 completion tokens", u)
+	}
+}
+
+func TestNextSkipsEmptyEvents(t *testing.T) {
+	body := ": keep-alive\n\nevent: ping\n\ndata:\n\n" + sseBody(1)
+	var logs bytes.Buffer
+	s := newTestStream(body).WithLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	var content strings.Builder
+	for {
+		chunk, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta != nil {
+				content.WriteString(choice.Delta.Content)
+			}
+		}
+	}
+	if content.String() != "token 0 " {
+		t.Errorf("content = %q, want %q", content.String(), "token 0 ")
+	}
+	// Only the data field without a value is unexpected
+	if got := strings.Count(logs.String(), "level=WARN"); got != 1 || !strings.Contains(logs.String(), "empty data") {
+		t.Errorf("logged %d warnings, want 1 about empty data:\n%s", got, logs.String())
+	}
+}
+
+func BenchmarkStreamPassthrough(b *testing.B) {
+	body := sseBody(500)
+	b.SetBytes(int64(len(body)))
+	for b.Loop() {
+		if _, err := newTestStream(body).WriteTo(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStreamPerChunk(b *testing.B) {
+	body := sseBody(500)
+	b.SetBytes(int64(len(body)))
+	for b.Loop() {
+		if err := provider.NewRawSSEWriter(io.Discard).WriteStream(newTestStream(body)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// delayedBody is an SSE body that sends its role-only chunk right away and
+// its first content chunk after delay.
+func delayedBody(delay time.Duration) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = io.WriteString(pw, `data: {"id":"1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"role":"assistant"}}]}`+"\n\n")
+		time.Sleep(delay)
+		_, _ = io.WriteString(pw, `data: {"id":"1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"content":"hi"}}]}`+"\n\n")
+		_, _ = io.WriteString(pw, "data: [DONE]\n\n")
+		_ = pw.Close()
+	}()
+	return pr
+}
+
+func TestFirstTokenLatency(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	tests := []struct {
+		name  string
+		early time.Duration // WithStartTime this long before NewStream
+		want  time.Duration
+	}{
+		{name: "from NewStream", want: delay},
+		{name: "from WithStartTime", early: 100 * time.Millisecond, want: delay + 100*time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now().Add(-tt.early)
+			resp := &http.Response{StatusCode: http.StatusOK, Body: delayedBody(delay)}
+			s := NewStream(resp, true, nil)
+			if tt.early > 0 {
+				s.WithStartTime(start)
+			}
+			defer s.Close()
+
+			if _, ok := s.FirstTokenLatency(); ok {
+				t.Error("FirstTokenLatency() reported before any chunk")
+			}
+			// The role-only chunk carries no token
+			if _, err := s.Next(); err != nil {
+				t.Fatalf("Next() error = %v", err)
+			}
+			if _, ok := s.FirstTokenLatency(); ok {
+				t.Error("FirstTokenLatency() reported after a role-only chunk")
+			}
+			if _, err := s.Next(); err != nil {
+				t.Fatalf("Next() error = %v", err)
+			}
+
+			got, ok := s.FirstTokenLatency()
+			if !ok {
+				t.Fatal("FirstTokenLatency() not reported after a content chunk")
+			}
+			if diff := got - tt.want; diff < -tt.want/10 || diff > tt.want/10 {
+				t.Errorf("FirstTokenLatency() = %s, want %s within 10%%", got, tt.want)
+			}
+
+			// Later chunks don't move it
+			if _, err := s.Next(); err != io.EOF {
+				t.Fatalf("Next() error = %v, want EOF", err)
+			}
+			if again, _ := s.FirstTokenLatency(); again != got {
+				t.Errorf("FirstTokenLatency() changed from %s to %s", got, again)
+			}
+		})
+	}
+}
@@ -4,6 +4,11 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
 
 	"github.com/edgard/opencompat/internal/api"
 	"github.com/edgard/opencompat/internal/auth"
@@ -40,6 +45,7 @@ type ChatCompletionRequest struct {
 	TextVerbosity    string // Override via X-Text-Verbosity header
 
 	// Optional parameters (supported by some providers like Copilot)
+	N                   *int
 	Temperature         *float64
 	TopP                *float64
 	MaxTokens           *int
@@ -47,8 +53,23 @@ type ChatCompletionRequest struct {
 	Stop                json.RawMessage
 	PresencePenalty     *float64
 	FrequencyPenalty    *float64
+	Seed                *int
+	Logprobs            *bool
+	TopLogprobs         *int
 	ResponseFormat      *api.ResponseFormat
 	ParallelToolCalls   *bool
+	Modalities          []string
+	AudioConfig         *api.AudioOutputConfig
+	ToolResources       json.RawMessage // Only set for providers listing "tool_resources" in SupportedParameters
+
+	// PassthroughHeaders are incoming request headers allowlisted by
+	// OPENCOMPAT_PASSTHROUGH_HEADERS, for providers that forward them.
+	PassthroughHeaders http.Header
+
+	// TraceContext holds the W3C traceparent and tracestate of the incoming
+	// request, keyed by header name, for providers that propagate them
+	// (see httputil.ExtractTraceContext). Nil when the client sent none.
+	TraceContext map[string]string
 }
 
 // Stream represents a streaming/non-streaming response.
@@ -67,6 +88,31 @@ type Stream interface {
 	Close() error
 }
 
+// FirstTokenReporter is an optional interface for streams that measure the
+// time to first token.
+type FirstTokenReporter interface {
+	// FirstTokenLatency returns the time from the start of the request
+	// until the first chunk with content, and false until that chunk has
+	// been returned.
+	FirstTokenLatency() (time.Duration, bool)
+}
+
+// MetadataReporter is an optional interface for streams that carry metadata
+// returned by the upstream, such as its request ID.
+type MetadataReporter interface {
+	// Metadata returns the metadata keyed by name, e.g.
+	// MetadataUpstreamRequestID. It may be nil.
+	Metadata() map[string]string
+}
+
+// MetadataUpstreamRequestID is the Metadata key of the ID the upstream
+// assigned to the request, useful for support requests.
+const MetadataUpstreamRequestID = "upstream_request_id"
+
+// ErrStreamCorrupted is returned (wrapped) by Stream.Next when the upstream
+// keeps sending events that can't be decoded.
+var ErrStreamCorrupted = errors.New("upstream stream corrupted")
+
 // Authenticator is implemented by provider packages to handle login.
 type Authenticator interface {
 	// ProviderID returns the provider this authenticator is for.
@@ -94,8 +140,113 @@ type LifecycleProvider interface {
 	Close()
 }
 
+// ShutdownProvider is an optional interface for lifecycle providers that
+// can wait for their in-flight requests before stopping.
+type ShutdownProvider interface {
+	LifecycleProvider
+
+	// Shutdown stops background tasks like Close, then waits for in-flight
+	// requests to finish or ctx to end, whichever comes first.
+	Shutdown(ctx context.Context) error
+}
+
 // Refresher is an optional interface for providers that support forced refresh.
 type Refresher interface {
 	// RefreshModels forces a refresh of the provider's models or data.
 	RefreshModels(ctx context.Context) error
 }
+
+// Reloadable is an optional interface for providers that can apply
+// configuration changes while running, see Registry.Reload.
+type Reloadable interface {
+	// Reload applies opts (values keyed by environment variable name, over
+	// the environment) to the settings the provider can change live.
+	// Requests in flight are not affected.
+	Reload(opts map[string]string) error
+}
+
+// ModelStreamer is an optional interface for providers with catalogs too
+// large to hold in memory at once. StreamModels sends each model on the
+// returned channel and closes it when the catalog is exhausted or ctx is
+// done. Failures before the first model are returned; later ones end the
+// stream early and are logged. StreamingModelsFetcher feeds a stream to
+// ModelsCache.
+type ModelStreamer interface {
+	StreamModels(ctx context.Context) (<-chan api.Model, error)
+}
+
+// RequestPreparer is an optional interface for providers that need
+// asynchronous pre-flight work (e.g., fetching or uploading media) before a
+// request is sent. PrepareRequest returns the request to send, which may be
+// req itself or a modified copy; req must not be mutated. Errors are
+// returned to the client.
+type RequestPreparer interface {
+	PrepareRequest(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionRequest, error)
+}
+
+// StreamingCapability is an optional interface for providers whose streaming
+// support may be unavailable. Providers that don't implement it are assumed
+// to support streaming.
+type StreamingCapability interface {
+	// StreamingSupported reports whether the provider can stream responses.
+	StreamingSupported() bool
+}
+
+// ToolTypeCapability is an optional interface for providers that accept only
+// some tool types (e.g., "function" but not "code_interpreter"). Tools of
+// other types are dropped before the request reaches the provider. Providers
+// that don't implement it receive all tools.
+type ToolTypeCapability interface {
+	// SupportedToolTypes returns the tool types the provider accepts.
+	SupportedToolTypes() []string
+}
+
+// ParameterCapability is an optional interface for providers that declare
+// which optional request parameters (by JSON name, e.g. "temperature") they
+// forward upstream. Other parameters are accepted but ignored with a warning.
+type ParameterCapability interface {
+	// SupportedParameters returns the optional parameters the provider uses.
+	SupportedParameters() []string
+}
+
+// ConcurrencyLimiter is an optional interface for providers that cap the
+// number of requests in flight to their upstream.
+type ConcurrencyLimiter interface {
+	// MaxConcurrentRequests returns the cap, or 0 for unlimited.
+	MaxConcurrentRequests() int
+}
+
+// Auditor is an optional interface for providers that write an audit log
+// of the decoded responses. Streams of an auditing provider are never
+// copied to the client raw, since the audit log would miss them.
+type Auditor interface {
+	// AuditEnabled reports whether audit logging is on.
+	AuditEnabled() bool
+}
+
+// ErrParameterNotSupported matches ParameterNotSupportedError with
+// errors.Is.
+var ErrParameterNotSupported = errors.New("parameter not supported")
+
+// ParameterNotSupportedError is returned by ChatCompletion when the model
+// can't honor a request parameter that must not be silently dropped.
+type ParameterNotSupportedError struct {
+	Param  string // request field, e.g. "response_format"
+	Reason string
+}
+
+func (e *ParameterNotSupportedError) Error() string {
+	return fmt.Sprintf("parameter %s not supported: %s", e.Param, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrParameterNotSupported) match.
+func (e *ParameterNotSupportedError) Is(target error) bool {
+	return target == ErrParameterNotSupported
+}
+
+// SupportsParameter reports whether p declares support for param.
+// Providers without ParameterCapability are assumed to support nothing optional.
+func SupportsParameter(p Provider, param string) bool {
+	pc, ok := p.(ParameterCapability)
+	return ok && slices.Contains(pc.SupportedParameters(), param)
+}
@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter paces requests sent to a provider.
+type RateLimiter interface {
+	// Wait blocks until a request may be sent or ctx ends.
+	Wait(ctx context.Context) error
+}
+
+// TokenRateLimiter is an optional RateLimiter extension that also paces
+// the (estimated) tokens of each request.
+type TokenRateLimiter interface {
+	RateLimiter
+	// WaitTokens blocks until tokens may be spent or ctx ends.
+	WaitTokens(ctx context.Context, tokens int) error
+}
+
+// BackoffRateLimiter is an optional RateLimiter extension for upstream
+// back-pressure, such as a 429 with Retry-After.
+type BackoffRateLimiter interface {
+	RateLimiter
+	// Backoff holds all requests for d.
+	Backoff(d time.Duration)
+}
+
+// TokenBucketLimiter limits requests and tokens per minute with token
+// buckets that allow bursts of up to one minute's budget.
+type TokenBucketLimiter struct {
+	requests *rate.Limiter // nil when unlimited
+	tokens   *rate.Limiter // nil when unlimited
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// NewTokenBucketLimiter creates a limiter for rpm requests and tpm tokens
+// per minute; 0 leaves that dimension unlimited.
+func NewTokenBucketLimiter(rpm, tpm int) *TokenBucketLimiter {
+	l := &TokenBucketLimiter{}
+	if rpm > 0 {
+		l.requests = rate.NewLimiter(rate.Limit(float64(rpm)/60), rpm)
+	}
+	if tpm > 0 {
+		l.tokens = rate.NewLimiter(rate.Limit(float64(tpm)/60), tpm)
+	}
+	return l
+}
+
+// Wait blocks until any backoff has passed and a request is allowed.
+func (l *TokenBucketLimiter) Wait(ctx context.Context) error {
+	if err := l.waitPause(ctx); err != nil {
+		return err
+	}
+	if l.requests == nil {
+		return nil
+	}
+	return l.requests.Wait(ctx)
+}
+
+// WaitTokens blocks until tokens fit the token budget. Requests larger
+// than a minute's budget only wait for the full bucket.
+func (l *TokenBucketLimiter) WaitTokens(ctx context.Context, tokens int) error {
+	if l.tokens == nil || tokens <= 0 {
+		return nil
+	}
+	return l.tokens.WaitN(ctx, min(tokens, l.tokens.Burst()))
+}
+
+// Backoff holds requests for d, extending any backoff already in effect.
+func (l *TokenBucketLimiter) Backoff(d time.Duration) {
+	until := time.Now().Add(d)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// waitPause sleeps until the current backoff ends.
+func (l *TokenBucketLimiter) waitPause(ctx context.Context) error {
+	l.mu.Lock()
+	wait := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
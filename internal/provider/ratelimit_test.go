@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// drain spends n requests, which must all fit the bucket.
+func drain(t *testing.T, l *TokenBucketLimiter, n int) {
+	t.Helper()
+	for i := range n {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() #%d error = %v", i, err)
+		}
+	}
+}
+
+func TestTokenBucketLimiterRequests(t *testing.T) {
+	// 600 rpm refills one request every 100ms after a burst of 600
+	l := NewTokenBucketLimiter(600, 0)
+	drain(t, l, 600)
+
+	start := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Wait() on an empty bucket returned after %v, want it to block for the refill", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); err == nil {
+		t.Error("Wait() error = nil, want an error when the deadline is before the refill")
+	}
+}
+
+func TestTokenBucketLimiterTokens(t *testing.T) {
+	// 600 tpm refills 10 tokens per second after a burst of 600
+	l := NewTokenBucketLimiter(0, 600)
+	if err := l.WaitTokens(context.Background(), 600); err != nil {
+		t.Fatalf("WaitTokens(600) error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := l.WaitTokens(ctx, 10); err == nil {
+		t.Error("WaitTokens(10) error = nil, want an error once the budget is spent")
+	}
+	if err := l.Wait(ctx); err != nil {
+		t.Errorf("Wait() error = %v, want requests unlimited", err)
+	}
+}
+
+func TestTokenBucketLimiterOversizedRequest(t *testing.T) {
+	l := NewTokenBucketLimiter(0, 100)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := l.WaitTokens(ctx, 1000); err != nil {
+		t.Errorf("WaitTokens(1000) error = %v, want it to wait only for the full bucket", err)
+	}
+}
+
+func TestTokenBucketLimiterUnlimited(t *testing.T) {
+	l := NewTokenBucketLimiter(0, 0)
+	for range 1000 {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+		if err := l.WaitTokens(context.Background(), 1_000_000); err != nil {
+			t.Fatalf("WaitTokens() error = %v", err)
+		}
+	}
+}
+
+func TestTokenBucketLimiterBackoff(t *testing.T) {
+	l := NewTokenBucketLimiter(0, 0)
+	l.Backoff(time.Hour)
+	// A shorter backoff must not end the one in effect
+	l.Backoff(time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	l = NewTokenBucketLimiter(0, 0)
+	l.Backoff(30 * time.Millisecond)
+	start := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Wait() returned after %v, want it to hold for the backoff", elapsed)
+	}
+}
+
+func BenchmarkTokenBucketLimiter(b *testing.B) {
+	// Limits high enough that the benchmark measures overhead, not waiting
+	l := NewTokenBucketLimiter(1<<30, 1<<30)
+	ctx := context.Background()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := l.Wait(ctx); err != nil {
+				b.Fatal(err)
+			}
+			if err := l.WaitTokens(ctx, 100); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// RawSSEWriter serializes chat completion chunks in SSE wire format
+// ("data: {...}\n\n") to an io.Writer. If the writer is an http.Flusher it
+// is flushed after every event.
+type RawSSEWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+// NewRawSSEWriter creates a writer that emits SSE events to w.
+func NewRawSSEWriter(w io.Writer) *RawSSEWriter {
+	flusher, _ := w.(http.Flusher)
+	return &RawSSEWriter{w: w, flusher: flusher}
+}
+
+// WriteChunk writes a chunk as an SSE data event.
+func (s *RawSSEWriter) WriteChunk(chunk *api.ChatCompletionChunk) error {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	return s.writeData(data)
+}
+
+// WriteError writes an OpenAI-style error object as an SSE data event.
+func (s *RawSSEWriter) WriteError(message string) error {
+	data, err := json.Marshal(api.ErrorResponse{
+		Error: api.ErrorDetail{
+			Message: message,
+			Type:    api.ErrorTypeServer,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return s.writeData(data)
+}
+
+// WriteDone writes the [DONE] marker.
+func (s *RawSSEWriter) WriteDone() error {
+	return s.writeData([]byte("[DONE]"))
+}
+
+// WriteStream drains stream, writing every chunk followed by [DONE]. Stream
+// errors are written as an error event before [DONE] and also returned.
+func (s *RawSSEWriter) WriteStream(stream Stream) error {
+	var streamErr error
+	for {
+		chunk, err := stream.Next()
+		if err != nil {
+			if err != io.EOF {
+				streamErr = err
+			}
+			break
+		}
+		if err := s.WriteChunk(chunk); err != nil {
+			return err
+		}
+	}
+
+	if streamErr == nil {
+		streamErr = stream.Err()
+	}
+	if streamErr != nil {
+		if err := s.WriteError(streamErr.Error()); err != nil {
+			return err
+		}
+	}
+	if err := s.WriteDone(); err != nil {
+		return err
+	}
+	return streamErr
+}
+
+func (s *RawSSEWriter) writeData(data []byte) error {
+	if _, err := fmt.Fprintf(s.w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return nil
+}
+
+// RawSSEReader returns a reader producing the SSE wire format of stream.
+// Chunks are serialized lazily as they arrive from Next(). The output ends
+// with "data: [DONE]"; a stream error is emitted as an error event first.
+func RawSSEReader(stream Stream) io.Reader {
+	r := &rawSSEReader{stream: stream}
+	r.writer = NewRawSSEWriter(&r.buf)
+	return r
+}
+
+type rawSSEReader struct {
+	stream Stream
+	buf    bytes.Buffer
+	writer *RawSSEWriter
+	done   bool
+}
+
+func (r *rawSSEReader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+	return r.buf.Read(p)
+}
+
+// fill serializes the next event into the buffer.
+func (r *rawSSEReader) fill() error {
+	chunk, err := r.stream.Next()
+	if err == nil {
+		return r.writer.WriteChunk(chunk)
+	}
+
+	r.done = true
+	if err == io.EOF {
+		err = r.stream.Err()
+	}
+	if err != nil {
+		if werr := r.writer.WriteError(err.Error()); werr != nil {
+			return werr
+		}
+	}
+	return r.writer.WriteDone()
+}
@@ -1,17 +1,24 @@
 package provider
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
 
 	"github.com/edgard/opencompat/internal/api"
 	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/modelalias"
 )
 
 // ProviderFactory creates a provider instance.
 type ProviderFactory func(store *auth.Store) (Provider, error)
 
+// ProviderOptionsFactory creates a provider instance whose configuration is
+// overridden by opts (keyed by environment variable name).
+type ProviderOptionsFactory func(store *auth.Store, opts map[string]string) (Provider, error)
+
 // EnvVarDoc documents an environment variable.
 type EnvVarDoc struct {
 	Name        string
@@ -28,12 +35,26 @@ type ProviderMeta struct {
 	DeviceFlowCfg *auth.DeviceFlowConfig // Device flow config (for device flow providers)
 	EnvVars       []EnvVarDoc            // Environment variable documentation
 	Factory       ProviderFactory
+	// OptionsFactory is optional; providers without it accept no runtime options.
+	OptionsFactory ProviderOptionsFactory
+	// Circuit overrides the registry's circuit breaker settings; optional.
+	Circuit *CircuitConfig
+	// Capabilities lists the API features the provider supports.
+	Capabilities CapabilitySet
+	// ParameterBounds holds the valid ranges of numeric request parameters,
+	// keyed by JSON name; optional.
+	ParameterBounds map[string]Bounds
 }
 
 // Registry manages providers.
 type Registry struct {
 	metas     map[string]ProviderMeta // All known providers
 	providers map[string]Provider     // Active providers (logged in)
+	store     *auth.Store             // Credentials store from Initialize
+	enabled   map[string]bool         // Enabled provider IDs; nil enables all
+	circuit   CircuitConfig           // Default circuit breaker settings
+	breakers  map[string]*circuitBreaker
+	aliases   modelalias.AliasMap // Short model names; nil for none
 }
 
 // NewRegistry creates a new registry.
@@ -41,6 +62,8 @@ func NewRegistry() *Registry {
 	return &Registry{
 		metas:     make(map[string]ProviderMeta),
 		providers: make(map[string]Provider),
+		circuit:   DefaultCircuitConfig(),
+		breakers:  make(map[string]*circuitBreaker),
 	}
 }
 
@@ -67,10 +90,60 @@ func (r *Registry) RegisterMeta(meta ProviderMeta) {
 	r.metas[meta.ID] = meta
 }
 
-// Initialize creates provider instances for all logged-in providers.
+// SetEnabledProviders restricts the registry to the given provider IDs.
+// Disabled providers are not initialized and their models are not served.
+// An empty list enables all providers. Call before Initialize.
+func (r *Registry) SetEnabledProviders(ids []string) error {
+	if len(ids) == 0 {
+		r.enabled = nil
+		return nil
+	}
+	enabled := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := r.metas[id]; !ok {
+			return fmt.Errorf("unknown provider in enabled providers: %s", id)
+		}
+		enabled[id] = true
+	}
+	r.enabled = enabled
+	return nil
+}
+
+// IsEnabled reports whether a provider is enabled.
+func (r *Registry) IsEnabled(providerID string) bool {
+	return r.enabled == nil || r.enabled[providerID]
+}
+
+// EnabledProviders returns the IDs of enabled providers, sorted.
+func (r *Registry) EnabledProviders() []string {
+	return r.providerIDs(true)
+}
+
+// DisabledProviderIDs returns the IDs of known but disabled providers, sorted.
+func (r *Registry) DisabledProviderIDs() []string {
+	return r.providerIDs(false)
+}
+
+func (r *Registry) providerIDs(enabled bool) []string {
+	var ids []string
+	for id := range r.metas {
+		if r.IsEnabled(id) == enabled {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Initialize creates provider instances for all enabled providers that are
+// logged in or need no credentials.
 func (r *Registry) Initialize(store *auth.Store) error {
+	r.store = store
 	for id, meta := range r.metas {
-		if !store.IsLoggedIn(id) {
+		if !r.IsEnabled(id) {
+			continue
+		}
+		if meta.AuthMethod != auth.AuthMethodNone && !store.IsLoggedIn(id) {
 			continue // Silent skip - provider not logged in
 		}
 
@@ -80,11 +153,78 @@ func (r *Registry) Initialize(store *auth.Store) error {
 		}
 		if p != nil {
 			r.providers[id] = p
+			if cfg := r.circuitConfig(meta); cfg.FailureThreshold > 0 {
+				r.breakers[id] = newCircuitBreaker(id, cfg)
+			}
 		}
 	}
 	return nil
 }
 
+// SetCircuitConfig sets the circuit breaker settings for providers whose
+// metadata has none. Call before Initialize.
+func (r *Registry) SetCircuitConfig(cfg CircuitConfig) {
+	r.circuit = cfg
+}
+
+func (r *Registry) circuitConfig(meta ProviderMeta) CircuitConfig {
+	if meta.Circuit != nil {
+		return *meta.Circuit
+	}
+	return r.circuit
+}
+
+// WithCircuitBreaker returns p guarded by its circuit breaker: while the
+// circuit is open, ChatCompletion fails with ErrCircuitOpen without
+// contacting the provider. p is returned as is when it has no breaker.
+func (r *Registry) WithCircuitBreaker(p Provider) Provider {
+	breaker, ok := r.breakers[p.ID()]
+	if !ok {
+		return p
+	}
+	return &circuitProvider{Provider: p, breaker: breaker}
+}
+
+// ProviderStatus returns the circuit breaker state of an active provider.
+// Providers without a breaker are always closed.
+func (r *Registry) ProviderStatus(id string) CircuitState {
+	if breaker, ok := r.breakers[id]; ok {
+		return breaker.status()
+	}
+	return CircuitClosed
+}
+
+// ResetCircuit closes a provider's circuit, e.g. after manual recovery.
+func (r *Registry) ResetCircuit(id string) {
+	if breaker, ok := r.breakers[id]; ok {
+		breaker.reset()
+	}
+}
+
+// NewProviderWithOptions creates a standalone provider instance whose
+// configuration is overridden by opts (keyed by environment variable name,
+// e.g. "OPENCOMPAT_COPILOT_MODELS_REFRESH"). The instance is not added to the
+// active providers; the caller owns its lifecycle.
+func (r *Registry) NewProviderWithOptions(id string, opts map[string]string) (Provider, error) {
+	meta, ok := r.metas[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", id)
+	}
+
+	store := r.store
+	if store == nil {
+		store = auth.NewStore()
+	}
+
+	if meta.OptionsFactory == nil {
+		if len(opts) > 0 {
+			return nil, fmt.Errorf("provider %s does not support runtime options", id)
+		}
+		return meta.Factory(store)
+	}
+	return meta.OptionsFactory(store, opts)
+}
+
 // GetMeta returns metadata for a provider (for login command).
 func (r *Registry) GetMeta(providerID string) (ProviderMeta, bool) {
 	meta, ok := r.metas[providerID]
@@ -113,16 +253,33 @@ func ParseModel(model string) (providerID, modelID string, err error) {
 	return model[:idx], model[idx+1:], nil
 }
 
-// GetProvider returns the provider for a model string.
+// SetAliases installs the model aliases resolved by ResolveModel.
+func (r *Registry) SetAliases(aliases modelalias.AliasMap) {
+	r.aliases = aliases
+}
+
+// ResolveModel splits a model string into provider and model IDs, first
+// replacing a registered alias with its target.
+func (r *Registry) ResolveModel(model string) (providerID, modelID string, err error) {
+	if target, ok := r.aliases.Resolve(model); ok {
+		model = target
+	}
+	return ParseModel(model)
+}
+
+// GetProvider returns the provider for a model string or alias.
 func (r *Registry) GetProvider(model string) (Provider, string, error) {
-	providerID, modelID, err := ParseModel(model)
+	providerID, modelID, err := r.ResolveModel(model)
 	if err != nil {
 		return nil, "", err
 	}
 
 	p, ok := r.providers[providerID]
 	if !ok {
-		// Check if provider is known but not logged in
+		// Check if provider is known but disabled or not logged in
+		if _, known := r.metas[providerID]; known && !r.IsEnabled(providerID) {
+			return nil, "", fmt.Errorf("provider '%s' is disabled", providerID)
+		}
 		if _, known := r.metas[providerID]; known {
 			return nil, "", fmt.Errorf("provider '%s' requires login (run: opencompat login %s)", providerID, providerID)
 		}
@@ -143,6 +300,7 @@ func (r *Registry) AllModels() []api.Model {
 			models = append(models, prefixed)
 		}
 	}
+	models = append(models, r.aliasModels()...)
 	// Sort for consistent ordering
 	sort.Slice(models, func(i, j int) bool {
 		return models[i].ID < models[j].ID
@@ -150,9 +308,75 @@ func (r *Registry) AllModels() []api.Model {
 	return models
 }
 
-// IsModelSupported checks if a model (with prefix) is supported.
+// aliasModels lists the aliases whose target is served by an active
+// provider, copying the target's metadata.
+func (r *Registry) aliasModels() []api.Model {
+	var models []api.Model
+	for alias, target := range r.aliases {
+		providerID, modelID, err := ParseModel(target)
+		if err != nil {
+			continue
+		}
+		p, ok := r.providers[providerID]
+		if !ok || !p.SupportsModel(modelID) {
+			continue
+		}
+		m := api.Model{ID: alias, Object: "model", OwnedBy: providerID}
+		for _, candidate := range p.Models() {
+			if candidate.ID == modelID {
+				m = candidate
+				m.ID = alias
+				break
+			}
+		}
+		models = append(models, m)
+	}
+	return models
+}
+
+// ModelsWithContextWindow returns the prefixed IDs of models whose context
+// window is larger than minTokens, smallest window first. Models with an
+// unknown context window are excluded.
+func (r *Registry) ModelsWithContextWindow(minTokens int) []string {
+	var fits []api.Model
+	for _, m := range r.AllModels() {
+		if _, isAlias := r.aliases[m.ID]; isAlias {
+			continue
+		}
+		if m.ContextWindow > minTokens {
+			fits = append(fits, m)
+		}
+	}
+	sort.SliceStable(fits, func(i, j int) bool {
+		return fits[i].ContextWindow < fits[j].ContextWindow
+	})
+
+	ids := make([]string, len(fits))
+	for i, m := range fits {
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+// SupportsResponseFormatSchema reports whether the provider's model
+// metadata advertises response_format json_schema support for modelID
+// (without the provider prefix). Models that don't report it return false.
+func (r *Registry) SupportsResponseFormatSchema(providerID, modelID string) bool {
+	p, ok := r.providers[providerID]
+	if !ok {
+		return false
+	}
+	for _, m := range p.Models() {
+		if m.ID == modelID {
+			return m.StructuredOutputs != nil && *m.StructuredOutputs
+		}
+	}
+	return false
+}
+
+// IsModelSupported checks if a model (with prefix) or alias is supported.
 func (r *Registry) IsModelSupported(model string) bool {
-	providerID, modelID, err := ParseModel(model)
+	providerID, modelID, err := r.ResolveModel(model)
 	if err != nil {
 		return false
 	}
@@ -176,6 +400,58 @@ func (r *Registry) GetActiveProvider(providerID string) (Provider, bool) {
 	return p, ok
 }
 
+// NewShadow returns a ShadowProvider that answers from the active provider
+// primaryID and mirrors requests to shadowID. Both are guarded by their
+// circuit breakers.
+func (r *Registry) NewShadow(primaryID, shadowID string, cfg ShadowConfig) (Provider, error) {
+	primary, err := r.activeProvider(primaryID)
+	if err != nil {
+		return nil, err
+	}
+	shadow, err := r.activeProvider(shadowID)
+	if err != nil {
+		return nil, err
+	}
+	return NewShadowProvider(r.WithCircuitBreaker(primary), r.WithCircuitBreaker(shadow), cfg), nil
+}
+
+// activeProvider returns the active provider with the given ID.
+func (r *Registry) activeProvider(id string) (Provider, error) {
+	p, ok := r.providers[id]
+	if !ok {
+		if _, known := r.metas[id]; known {
+			return nil, fmt.Errorf("provider '%s' is not active", id)
+		}
+		return nil, fmt.Errorf("unknown provider: %s", id)
+	}
+	return p, nil
+}
+
+// ActiveProviders returns all active providers, sorted by ID.
+func (r *Registry) ActiveProviders() []Provider {
+	providers := make([]Provider, 0, len(r.providers))
+	for _, p := range r.providers {
+		providers = append(providers, p)
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].ID() < providers[j].ID()
+	})
+	return providers
+}
+
+// Reload passes opts to every active provider implementing Reloadable.
+func (r *Registry) Reload(opts map[string]string) error {
+	var errs []error
+	for _, p := range r.ActiveProviders() {
+		if rp, ok := p.(Reloadable); ok {
+			if err := rp.Reload(opts); err != nil {
+				errs = append(errs, fmt.Errorf("provider %s: %w", p.ID(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // CloseAll closes all active providers that implement LifecycleProvider.
 func (r *Registry) CloseAll() {
 	for _, p := range r.providers {
@@ -184,3 +460,21 @@ func (r *Registry) CloseAll() {
 		}
 	}
 }
+
+// ShutdownAll shuts down all active providers, waiting until ctx ends for
+// those implementing ShutdownProvider to drain their in-flight requests.
+// Other lifecycle providers are closed.
+func (r *Registry) ShutdownAll(ctx context.Context) error {
+	var errs []error
+	for _, p := range r.ActiveProviders() {
+		switch lp := p.(type) {
+		case ShutdownProvider:
+			if err := lp.Shutdown(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("provider %s: %w", p.ID(), err))
+			}
+		case LifecycleProvider:
+			lp.Close()
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,225 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/config"
+)
+
+func TestNewProviderWithOptions(t *testing.T) {
+	var gotOpts map[string]string
+	r := NewRegistry()
+	r.RegisterMeta(ProviderMeta{
+		ID:      "configurable",
+		Factory: func(*auth.Store) (Provider, error) { return &fakeProvider{}, nil },
+		OptionsFactory: func(_ *auth.Store, opts map[string]string) (Provider, error) {
+			gotOpts = opts
+			return &fakeProvider{}, nil
+		},
+	})
+	r.RegisterMeta(ProviderMeta{
+		ID:      "fixed",
+		Factory: func(*auth.Store) (Provider, error) { return &fakeProvider{}, nil },
+	})
+
+	opts := map[string]string{"OPENCOMPAT_TEST_OPTION": "override"}
+
+	tests := []struct {
+		name     string
+		id       string
+		opts     map[string]string
+		wantErr  string
+		wantOpts map[string]string
+	}{
+		{name: "options passed to the factory", id: "configurable", opts: opts, wantOpts: opts},
+		{name: "no options", id: "fixed"},
+		{name: "options without an options factory", id: "fixed", opts: opts, wantErr: "provider fixed does not support runtime options"},
+		{name: "unknown provider", id: "missing", opts: opts, wantErr: "unknown provider: missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotOpts = nil
+			p, err := r.NewProviderWithOptions(tt.id, tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewProviderWithOptions() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewProviderWithOptions() error = %v", err)
+			}
+			if p == nil {
+				t.Fatal("NewProviderWithOptions() = nil provider")
+			}
+			if !maps.Equal(gotOpts, tt.wantOpts) {
+				t.Errorf("factory options = %v, want %v", gotOpts, tt.wantOpts)
+			}
+			// Standalone instances are not registered as active providers
+			if got := r.ActiveProviders(); len(got) != 0 {
+				t.Errorf("active providers = %d, want 0", len(got))
+			}
+		})
+	}
+}
+
+// catalogProvider serves a fixed model list.
+type catalogProvider struct {
+	fakeProvider
+	id     string
+	models []string
+}
+
+func (p *catalogProvider) ID() string { return p.id }
+
+func (p *catalogProvider) Models() []api.Model {
+	models := make([]api.Model, len(p.models))
+	for i, id := range p.models {
+		models[i] = api.Model{ID: id}
+	}
+	return models
+}
+
+// newCatalogRegistry registers copilot and mock providers that need no
+// login, restricted to the providers in OPENCOMPAT_ENABLED_PROVIDERS.
+func newCatalogRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r := NewRegistry()
+	for _, p := range []*catalogProvider{
+		{id: "copilot", models: []string{"gpt-4o", "claude-sonnet-4"}},
+		{id: "mock", models: []string{"echo"}},
+	} {
+		r.RegisterMeta(ProviderMeta{
+			ID:         p.id,
+			AuthMethod: auth.AuthMethodNone,
+			Factory:    func(*auth.Store) (Provider, error) { return p, nil },
+		})
+	}
+	if err := r.SetEnabledProviders(config.Load().EnabledProviders); err != nil {
+		t.Fatalf("SetEnabledProviders() error = %v", err)
+	}
+	if err := r.Initialize(nil); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	return r
+}
+
+func TestDisabledProviders(t *testing.T) {
+	t.Setenv("OPENCOMPAT_ENABLED_PROVIDERS", " Mock ,")
+	r := newCatalogRegistry(t)
+
+	if got := r.EnabledProviders(); !slices.Equal(got, []string{"mock"}) {
+		t.Errorf("EnabledProviders() = %v, want [mock]", got)
+	}
+	if got := r.DisabledProviderIDs(); !slices.Equal(got, []string{"copilot"}) {
+		t.Errorf("DisabledProviderIDs() = %v, want [copilot]", got)
+	}
+
+	_, _, err := r.GetProvider("copilot/gpt-4o")
+	if err == nil || err.Error() != "provider 'copilot' is disabled" {
+		t.Errorf("GetProvider(copilot/gpt-4o) error = %v, want disabled", err)
+	}
+	if r.IsModelSupported("copilot/gpt-4o") {
+		t.Error("IsModelSupported(copilot/gpt-4o) = true, want false")
+	}
+	for _, m := range r.AllModels() {
+		if strings.HasPrefix(m.ID, "copilot/") {
+			t.Errorf("AllModels() lists %s from the disabled provider", m.ID)
+		}
+	}
+
+	p, model, err := r.GetProvider("mock/echo")
+	if err != nil || p.ID() != "mock" || model != "echo" {
+		t.Errorf("GetProvider(mock/echo) = %v, %q, %v, want the mock provider", p, model, err)
+	}
+}
+
+func TestEnabledProvidersDefaultsToAll(t *testing.T) {
+	t.Setenv("OPENCOMPAT_ENABLED_PROVIDERS", "")
+	r := newCatalogRegistry(t)
+
+	if got := r.EnabledProviders(); !slices.Equal(got, []string{"copilot", "mock"}) {
+		t.Errorf("EnabledProviders() = %v, want [copilot mock]", got)
+	}
+	if got := r.DisabledProviderIDs(); len(got) != 0 {
+		t.Errorf("DisabledProviderIDs() = %v, want none", got)
+	}
+	if _, _, err := r.GetProvider("copilot/gpt-4o"); err != nil {
+		t.Errorf("GetProvider(copilot/gpt-4o) error = %v", err)
+	}
+}
+
+func TestSetEnabledProvidersUnknown(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterMeta(ProviderMeta{ID: "copilot"})
+	err := r.SetEnabledProviders([]string{"copilot", "typo"})
+	if err == nil || err.Error() != "unknown provider in enabled providers: typo" {
+		t.Errorf("SetEnabledProviders() error = %v, want the unknown ID reported", err)
+	}
+}
+
+// lifecycleProvider records Close calls.
+type lifecycleProvider struct {
+	catalogProvider
+	closed bool
+}
+
+func (p *lifecycleProvider) Init() error { return nil }
+func (p *lifecycleProvider) Start()      {}
+func (p *lifecycleProvider) Close()      { p.closed = true }
+
+// drainingProvider is a ShutdownProvider whose Shutdown returns err.
+type drainingProvider struct {
+	lifecycleProvider
+	shutdownCtx context.Context
+	err         error
+}
+
+func (p *drainingProvider) Shutdown(ctx context.Context) error {
+	p.shutdownCtx = ctx
+	return p.err
+}
+
+func TestShutdownAll(t *testing.T) {
+	closer := &lifecycleProvider{catalogProvider: catalogProvider{id: "closer"}}
+	drainer := &drainingProvider{
+		lifecycleProvider: lifecycleProvider{catalogProvider: catalogProvider{id: "drainer"}},
+		err:               context.DeadlineExceeded,
+	}
+	r := NewRegistry()
+	for _, p := range []Provider{closer, drainer, &catalogProvider{id: "plain"}} {
+		r.RegisterMeta(ProviderMeta{
+			ID:         p.ID(),
+			AuthMethod: auth.AuthMethodNone,
+			Factory:    func(*auth.Store) (Provider, error) { return p, nil },
+		})
+	}
+	if err := r.Initialize(nil); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), shutdownTestKey{}, "shutdown")
+	err := r.ShutdownAll(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "provider drainer") {
+		t.Errorf("ShutdownAll() error = %v, want the drainer error", err)
+	}
+	if drainer.shutdownCtx != ctx {
+		t.Error("ShutdownProvider was not shut down with the caller's context")
+	}
+	if drainer.closed {
+		t.Error("ShutdownProvider was closed instead of shut down")
+	}
+	if !closer.closed {
+		t.Error("LifecycleProvider was not closed")
+	}
+}
+
+// shutdownTestKey is a context key identifying the context passed to Shutdown.
+type shutdownTestKey struct{}
@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// DefaultShadowTimeout bounds a shadow request when ShadowConfig.Timeout is
+// zero.
+const DefaultShadowTimeout = 2 * time.Minute
+
+// ShadowConfig configures a ShadowProvider.
+type ShadowConfig struct {
+	Timeout time.Duration // per shadow request; 0 uses DefaultShadowTimeout
+	Output  io.Writer     // receives one JSON record per shadow request; nil discards results
+}
+
+// ShadowProvider serves requests from a primary provider and mirrors each
+// one to a secondary provider in the background, for comparing providers
+// on live traffic. The secondary's outcome never reaches the caller.
+type ShadowProvider struct {
+	Provider // primary
+	shadow   Provider
+	cfg      ShadowConfig
+
+	mu sync.Mutex // serializes writes to cfg.Output
+}
+
+// NewShadowProvider creates a provider that answers from primary and
+// mirrors requests to shadow.
+func NewShadowProvider(primary, shadow Provider, cfg ShadowConfig) *ShadowProvider {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultShadowTimeout
+	}
+	return &ShadowProvider{Provider: primary, shadow: shadow, cfg: cfg}
+}
+
+// shadowRecord is the JSON line written to ShadowConfig.Output.
+type shadowRecord struct {
+	Time     time.Time                   `json:"time"`
+	Provider string                      `json:"provider"`
+	Model    string                      `json:"model"`
+	Duration float64                     `json:"duration_seconds"`
+	Response *api.ChatCompletionResponse `json:"response,omitempty"`
+	Error    string                      `json:"error,omitempty"`
+}
+
+// ChatCompletion returns the primary's stream and starts the shadow
+// request, which runs detached from ctx's cancellation under its own
+// timeout.
+func (s *ShadowProvider) ChatCompletion(ctx context.Context, req *ChatCompletionRequest) (Stream, error) {
+	// Providers may adjust the request they're given
+	shadowReq := *req
+	if s.shadow.SupportsModel(req.Model) {
+		go s.mirror(context.WithoutCancel(ctx), &shadowReq)
+	} else {
+		slog.Debug("shadow provider does not support model, not mirroring",
+			"provider", s.shadow.ID(),
+			"model", req.Model,
+		)
+	}
+	return s.Provider.ChatCompletion(ctx, req)
+}
+
+// mirror sends req to the shadow provider and records the outcome.
+func (s *ShadowProvider) mirror(ctx context.Context, req *ChatCompletionRequest) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("shadow request panicked", "provider", s.shadow.ID(), "model", req.Model, "panic", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := s.complete(ctx, req)
+	if err != nil {
+		slog.Warn("shadow request failed", "provider", s.shadow.ID(), "model", req.Model, "error", err)
+	}
+	if s.cfg.Output == nil {
+		return
+	}
+
+	record := shadowRecord{
+		Time:     start,
+		Provider: s.shadow.ID(),
+		Model:    req.Model,
+		Duration: time.Since(start).Seconds(),
+		Response: resp,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		slog.Error("failed to encode shadow record", "error", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.cfg.Output.Write(append(line, '\n')); err != nil {
+		slog.Error("failed to write shadow record", "error", err)
+	}
+}
+
+// complete runs req against the shadow provider to the end.
+func (s *ShadowProvider) complete(ctx context.Context, req *ChatCompletionRequest) (*api.ChatCompletionResponse, error) {
+	stream, err := s.shadow.ChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := AccumulateStream(ctx, stream)
+	if err != nil {
+		return nil, fmt.Errorf("read shadow stream: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// StreamMiddleware wraps a Stream to observe or alter what flows through
+// it. Middleware are applied by callers to the stream a provider returns,
+// so providers need no changes.
+type StreamMiddleware func(Stream) Stream
+
+// MiddlewareChain composes middleware. The first entry wraps the stream
+// first, so the last entry sees chunks after all the others.
+type MiddlewareChain []StreamMiddleware
+
+// Wrap applies the chain to s.
+func (c MiddlewareChain) Wrap(s Stream) Stream {
+	for _, m := range c {
+		s = m(s)
+	}
+	return s
+}
+
+// WrapStream applies middlewares to s in order.
+func WrapStream(s Stream, middlewares ...StreamMiddleware) Stream {
+	return MiddlewareChain(middlewares).Wrap(s)
+}
+
+// loggingStream logs every chunk read from a stream.
+type loggingStream struct {
+	Stream
+	logger *slog.Logger
+	start  time.Time
+	chunks int
+}
+
+// NewLoggingStream returns a stream that logs each chunk at debug level,
+// and a summary when the stream ends.
+func NewLoggingStream(s Stream, logger *slog.Logger) Stream {
+	return &loggingStream{Stream: s, logger: logger, start: time.Now()}
+}
+
+// LoggingMiddleware returns a StreamMiddleware around NewLoggingStream.
+func LoggingMiddleware(logger *slog.Logger) StreamMiddleware {
+	return func(s Stream) Stream {
+		return NewLoggingStream(s, logger)
+	}
+}
+
+func (s *loggingStream) Next() (*api.ChatCompletionChunk, error) {
+	chunk, err := s.Stream.Next()
+	if err != nil {
+		if err == io.EOF {
+			s.logger.Debug("stream finished", "chunks", s.chunks, "duration", time.Since(s.start))
+		} else {
+			s.logger.Debug("stream failed", "chunks", s.chunks, "error", err)
+		}
+		return chunk, err
+	}
+
+	s.chunks++
+	attrs := []any{"index", s.chunks - 1, "id", chunk.ID}
+	for _, choice := range chunk.Choices {
+		if choice.Delta != nil {
+			attrs = append(attrs, "content_len", len(choice.Delta.Content), "tool_calls", len(choice.Delta.ToolCalls))
+		}
+		if choice.FinishReason != nil {
+			attrs = append(attrs, "finish_reason", *choice.FinishReason)
+		}
+	}
+	if chunk.Usage != nil {
+		attrs = append(attrs, "total_tokens", chunk.Usage.TotalTokens)
+	}
+	s.logger.Debug("stream chunk", attrs...)
+	return chunk, nil
+}
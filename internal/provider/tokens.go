@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// TokenCount is the result of counting a request's prompt tokens.
+type TokenCount struct {
+	PromptTokens int    `json:"prompt_tokens"`
+	CachedTokens int    `json:"cached_tokens"`
+	TotalTokens  int    `json:"total_tokens"`
+	Model        string `json:"model"`
+}
+
+// TokenCounter is an optional interface for providers that can count the
+// tokens of a request without sending it (e.g., a countTokens API).
+type TokenCounter interface {
+	// CountTokens returns the prompt token count for req.
+	CountTokens(ctx context.Context, req *ChatCompletionRequest) (*TokenCount, error)
+}
+
+// TokenEstimator approximates token counts locally from text length. It is
+// used when a provider has no TokenCounter and is accurate to within roughly
+// 10-20% for English text with GPT-style tokenizers.
+type TokenEstimator struct {
+	CharsPerToken    int // average characters per token
+	TokensPerMessage int // fixed overhead per message (role, separators)
+	TokensPerReply   int // tokens priming the assistant reply
+}
+
+// DefaultTokenEstimator uses OpenAI's rule of thumb of ~4 characters per
+// token and the per-message overhead of the chat format.
+var DefaultTokenEstimator = TokenEstimator{
+	CharsPerToken:    4,
+	TokensPerMessage: 3,
+	TokensPerReply:   3,
+}
+
+// CountTokens estimates the prompt token count for req.
+func (e TokenEstimator) CountTokens(_ context.Context, req *ChatCompletionRequest) (*TokenCount, error) {
+	tokens := e.TokensPerReply
+	for _, msg := range req.Messages {
+		tokens += e.TokensPerMessage
+		tokens += e.textTokens(msg.Role)
+		tokens += e.textTokens(msg.Name)
+		for _, part := range msg.GetContentParts() {
+			tokens += e.textTokens(part.Text)
+		}
+		for _, tc := range msg.ToolCalls {
+			tokens += e.textTokens(tc.Function.Name)
+			tokens += e.textTokens(tc.Function.Arguments)
+		}
+	}
+
+	// Tool definitions are sent to the model as part of the prompt
+	if len(req.Tools) > 0 {
+		if data, err := json.Marshal(req.Tools); err == nil {
+			tokens += e.textTokens(string(data))
+		}
+	}
+
+	return &TokenCount{
+		PromptTokens: tokens,
+		TotalTokens:  tokens,
+		Model:        req.Model,
+	}, nil
+}
+
+// textTokens estimates the tokens of s, rounding up.
+func (e TokenEstimator) textTokens(s string) int {
+	if s == "" {
+		return 0
+	}
+	charsPerToken := max(e.CharsPerToken, 1)
+	n := len([]rune(s))
+	return (n + charsPerToken - 1) / charsPerToken
+}
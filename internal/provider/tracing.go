@@ -0,0 +1,27 @@
+package provider
+
+import (
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerProvider overrides the global OpenTelemetry tracer provider when set.
+var tracerProvider atomic.Pointer[trace.TracerProvider]
+
+// SetTracerProvider sets the tracer provider used for provider spans. By
+// default the global OpenTelemetry provider is used, which records nothing
+// until an SDK is installed.
+func SetTracerProvider(tp trace.TracerProvider) {
+	tracerProvider.Store(&tp)
+}
+
+// Tracer returns a tracer for the named instrumentation scope, e.g. a
+// provider package import path.
+func Tracer(name string) trace.Tracer {
+	if tp := tracerProvider.Load(); tp != nil {
+		return (*tp).Tracer(name)
+	}
+	return otel.GetTracerProvider().Tracer(name)
+}
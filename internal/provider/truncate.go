@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/tokencount"
+)
+
+// ErrMessageTooLarge is matched by MessageTooLargeError with errors.Is.
+var ErrMessageTooLarge = errors.New("message too large for context window")
+
+// MessageTooLargeError is returned when the messages that truncation must
+// keep, such as the latest user message, don't fit the token budget on
+// their own.
+type MessageTooLargeError struct {
+	Tokens int // estimated tokens of the kept messages
+	Limit  int // token budget
+}
+
+func (e *MessageTooLargeError) Error() string {
+	return fmt.Sprintf("%s: the latest messages need about %d tokens, the limit is %d", ErrMessageTooLarge, e.Tokens, e.Limit)
+}
+
+// Is makes errors.Is(err, ErrMessageTooLarge) match.
+func (e *MessageTooLargeError) Is(target error) bool {
+	return target == ErrMessageTooLarge
+}
+
+// Unwrap exposes the error as a context length error, so clients get the
+// usual context_length_exceeded response.
+func (e *MessageTooLargeError) Unwrap() error {
+	return &api.ErrContextLengthExceeded{Message: e.Error(), PromptTokens: e.Tokens, ModelLimit: e.Limit}
+}
+
+// TruncateMessages drops the oldest conversation turns from messages until
+// their token count for model fits within limit. System and developer
+// messages, a leading assistant message and everything from the last user
+// message on are always kept; a dropped turn is a user message together
+// with the assistant and tool messages answering it. It returns the kept
+// and the dropped messages, or a MessageTooLargeError when the kept
+// messages alone exceed limit.
+func TruncateMessages(model string, messages []api.Message, limit int) (kept, dropped []api.Message, err error) {
+	tokens, err := tokencount.Count(model, messages)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tokens <= limit {
+		return messages, nil, nil
+	}
+
+	last := len(messages)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			last = i
+			break
+		}
+	}
+	pinned := func(i int) bool {
+		role := messages[i].Role
+		return i >= last || role == "system" || role == "developer" || (i == 0 && role == "assistant")
+	}
+
+	drop := make([]bool, len(messages))
+	for i := 0; i < last && tokens > limit; {
+		if pinned(i) {
+			i++
+			continue
+		}
+		// Drop the whole turn so no tool result loses its tool call
+		for {
+			drop[i] = true
+			i++
+			if i >= last || messages[i].Role == "user" || pinned(i) {
+				break
+			}
+		}
+
+		kept = kept[:0]
+		for j, msg := range messages {
+			if !drop[j] {
+				kept = append(kept, msg)
+			}
+		}
+		if tokens, err = tokencount.Count(model, kept); err != nil {
+			return nil, nil, err
+		}
+	}
+	if tokens > limit {
+		return nil, nil, &MessageTooLargeError{Tokens: tokens, Limit: limit}
+	}
+
+	for j, msg := range messages {
+		if drop[j] {
+			dropped = append(dropped, msg)
+		}
+	}
+	return kept, dropped, nil
+}
+
+// TruncationReport collects the number of messages providers dropped to
+// fit a request into the model's context window.
+type TruncationReport struct {
+	dropped atomic.Int64
+}
+
+// Dropped returns the number of messages dropped.
+func (r *TruncationReport) Dropped() int {
+	return int(r.dropped.Load())
+}
+
+type truncationReportKey struct{}
+
+// WithTruncationReport returns a context in which ReportTruncation records
+// into the returned report.
+func WithTruncationReport(ctx context.Context) (context.Context, *TruncationReport) {
+	report := &TruncationReport{}
+	return context.WithValue(ctx, truncationReportKey{}, report), report
+}
+
+// ReportTruncation records that dropped messages were removed from the
+// request of ctx. It does nothing if ctx carries no report.
+func ReportTruncation(ctx context.Context, dropped int) {
+	if report, ok := ctx.Value(truncationReportKey{}).(*TruncationReport); ok {
+		report.dropped.Add(int64(dropped))
+	}
+}
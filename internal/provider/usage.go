@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// UsageReporter is an optional interface for providers (or server-wide sinks)
+// that record token usage for external billing.
+type UsageReporter interface {
+	// ReportUsage records usage for one completed request.
+	ReportUsage(ctx context.Context, event UsageEvent) error
+}
+
+// UsageEvent describes the token usage of a single completed request.
+type UsageEvent struct {
+	Provider         string        `json:"provider"`
+	Model            string        `json:"model"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	User             string        `json:"user,omitempty"`
+	ConversationID   string        `json:"conversation_id,omitempty"`
+	RequestID        string        `json:"request_id,omitempty"`
+	Latency          time.Duration `json:"-"`
+	LatencyMs        int64         `json:"latency_ms"`
+	Timestamp        time.Time     `json:"timestamp"`
+}
+
+// HTTPUsageReporter settings
+const (
+	usageBufferSize     = 1000
+	usageMaxAttempts    = 5
+	usageInitialBackoff = 1 * time.Second
+	usageMaxBackoff     = 30 * time.Second
+	usageHTTPTimeout    = 10 * time.Second
+)
+
+// ErrUsageBufferFull is returned when the reporter cannot accept more events.
+var ErrUsageBufferFull = errors.New("usage buffer full")
+
+// HTTPUsageReporter POSTs usage events as JSON to a webhook URL.
+// Events are queued in a bounded buffer and delivered by a background
+// worker, which retries failed deliveries with exponential backoff.
+type HTTPUsageReporter struct {
+	url        string
+	httpClient *http.Client
+	events     chan UsageEvent
+	stop       chan struct{}
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+// NewHTTPUsageReporter creates a reporter for the given webhook URL and
+// starts its delivery worker.
+func NewHTTPUsageReporter(url string) *HTTPUsageReporter {
+	r := &HTTPUsageReporter{
+		url: url,
+		httpClient: &http.Client{
+			Timeout: usageHTTPTimeout,
+		},
+		events: make(chan UsageEvent, usageBufferSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// ReportUsage queues an event for delivery. It never blocks; when the
+// buffer is full the event is dropped and ErrUsageBufferFull is returned.
+func (r *HTTPUsageReporter) ReportUsage(_ context.Context, event UsageEvent) error {
+	if event.LatencyMs == 0 && event.Latency > 0 {
+		event.LatencyMs = event.Latency.Milliseconds()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	select {
+	case <-r.stop:
+		return errors.New("usage reporter closed")
+	default:
+	}
+
+	select {
+	case r.events <- event:
+		return nil
+	default:
+		return ErrUsageBufferFull
+	}
+}
+
+// Close stops accepting events, attempts one final delivery of any queued
+// events and waits for the worker to exit.
+func (r *HTTPUsageReporter) Close() {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+		<-r.done
+	})
+}
+
+func (r *HTTPUsageReporter) run() {
+	defer close(r.done)
+
+	for {
+		select {
+		case event := <-r.events:
+			r.deliver(event)
+		case <-r.stop:
+			r.drain()
+			return
+		}
+	}
+}
+
+// drain makes a single delivery attempt for each queued event on shutdown.
+func (r *HTTPUsageReporter) drain() {
+	for {
+		select {
+		case event := <-r.events:
+			if err := r.post(context.Background(), event); err != nil {
+				slog.Warn("dropping usage event on shutdown", "request_id", event.RequestID, "error", err)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// deliver posts an event, retrying with exponential backoff.
+func (r *HTTPUsageReporter) deliver(event UsageEvent) {
+	backoff := usageInitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := r.post(context.Background(), event)
+		if err == nil {
+			return
+		}
+		if attempt >= usageMaxAttempts {
+			slog.Warn("dropping usage event after retries",
+				"request_id", event.RequestID,
+				"attempts", attempt,
+				"error", err,
+			)
+			return
+		}
+
+		slog.Debug("usage webhook failed, retrying",
+			"request_id", event.RequestID,
+			"attempt", attempt,
+			"backoff", backoff,
+			"error", err,
+		)
+
+		select {
+		case <-time.After(backoff):
+		case <-r.stop:
+			// Shutting down: give up on this event, drain handles the rest
+			slog.Warn("dropping usage event on shutdown", "request_id", event.RequestID, "error", err)
+			return
+		}
+		backoff = min(backoff*2, usageMaxBackoff)
+	}
+}
+
+func (r *HTTPUsageReporter) post(ctx context.Context, event UsageEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
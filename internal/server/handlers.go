@@ -1,22 +1,39 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/audit"
+	"github.com/edgard/opencompat/internal/auth"
 	"github.com/edgard/opencompat/internal/config"
+	"github.com/edgard/opencompat/internal/httputil"
 	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/provider/middleware"
 )
 
 // Maximum request body size (10MB)
 const maxRequestBodySize = 10 * 1024 * 1024
 
+// truncatedMessagesHeader reports how many messages a provider dropped to
+// fit the prompt into the model's context window.
+const truncatedMessagesHeader = "X-OpenCompat-Truncated-Messages"
+
+// upstreamRequestIDHeader carries the upstream's ID for the request, which
+// its support may ask for. x-request-id remains the proxy's own ID.
+const upstreamRequestIDHeader = "X-OpenCompat-Upstream-Request-Id"
+
 // validRoles defines the valid message roles for OpenAI API
 var validRoles = map[string]bool{
 	"system":    true,
@@ -25,61 +42,47 @@ var validRoles = map[string]bool{
 	"tool":      true,
 }
 
-// logIgnoredParameters logs warnings for parameters that are accepted but ignored.
-// providerID is used to determine which parameters are actually ignored (some providers support them).
-func logIgnoredParameters(requestID string, req *api.ChatCompletionRequest, providerID string) {
-	var ignored []string
+// validModalities defines the valid output modalities for OpenAI API
+var validModalities = map[string]bool{
+	"text":  true,
+	"audio": true,
+}
 
-	// These parameters are ignored by all providers
-	if req.N != nil && *req.N != 1 {
-		ignored = append(ignored, "n")
-	}
-	if req.LogitBias != nil {
-		ignored = append(ignored, "logit_bias")
-	}
-	if req.Seed != nil {
-		ignored = append(ignored, "seed")
-	}
-	if req.User != "" {
-		ignored = append(ignored, "user")
+// logIgnoredParameters logs warnings for parameters that are accepted but ignored.
+// A parameter is ignored unless the provider lists it in SupportedParameters.
+func logIgnoredParameters(requestID string, req *api.ChatCompletionRequest, p provider.Provider) {
+	params := []struct {
+		name string
+		set  bool
+	}{
+		{"n", req.N != nil && *req.N != 1},
+		{"logit_bias", req.LogitBias != nil},
+		{"seed", req.Seed != nil},
+		{"logprobs", req.Logprobs != nil && *req.Logprobs},
+		{"top_logprobs", req.TopLogprobs != nil},
+		{"user", req.User != ""},
+		{"temperature", req.Temperature != nil},
+		{"top_p", req.TopP != nil},
+		{"stop", req.Stop != nil},
+		{"max_tokens", req.MaxTokens != nil},
+		{"max_completion_tokens", req.MaxCompletionTokens != nil},
+		{"presence_penalty", req.PresencePenalty != nil},
+		{"frequency_penalty", req.FrequencyPenalty != nil},
+		{"response_format", req.ResponseFormat != nil},
+		{"parallel_tool_calls", req.ParallelToolCalls != nil},
+		{"modalities", req.Modalities != nil},
+		{"audio", req.AudioConfig != nil},
+		{"tool_resources", req.ToolResources != nil},
+		{"reasoning_effort", req.ReasoningEffort != ""},
 	}
 
-	// These parameters are only ignored by ChatGPT (Copilot supports them)
-	if providerID != "copilot" {
-		if req.Temperature != nil {
-			ignored = append(ignored, "temperature")
-		}
-		if req.TopP != nil {
-			ignored = append(ignored, "top_p")
-		}
-		if req.Stop != nil {
-			ignored = append(ignored, "stop")
-		}
-		if req.MaxTokens != nil {
-			ignored = append(ignored, "max_tokens")
-		}
-		if req.MaxCompletionTokens != nil {
-			ignored = append(ignored, "max_completion_tokens")
-		}
-		if req.PresencePenalty != nil {
-			ignored = append(ignored, "presence_penalty")
-		}
-		if req.FrequencyPenalty != nil {
-			ignored = append(ignored, "frequency_penalty")
-		}
-		if req.ResponseFormat != nil {
-			ignored = append(ignored, "response_format")
-		}
-		if req.ParallelToolCalls != nil {
-			ignored = append(ignored, "parallel_tool_calls")
+	var ignored []string
+	for _, param := range params {
+		if param.set && !provider.SupportsParameter(p, param.name) {
+			ignored = append(ignored, param.name)
 		}
 	}
 
-	// reasoning_effort is only supported by ChatGPT (ignored by Copilot)
-	if providerID == "copilot" && req.ReasoningEffort != "" {
-		ignored = append(ignored, "reasoning_effort")
-	}
-
 	if len(ignored) > 0 {
 		slog.Warn("ignoring unsupported parameters",
 			"request_id", requestID,
@@ -88,16 +91,48 @@ func logIgnoredParameters(requestID string, req *api.ChatCompletionRequest, prov
 	}
 }
 
-// writeStreamError writes an appropriate error response, checking for UpstreamError first.
-func writeStreamError(w http.ResponseWriter, err error, prefix string) {
+// writeStreamError writes an appropriate error response, checking for
+// context length and upstream errors first.
+func (h *Handlers) writeStreamError(w http.ResponseWriter, err error, prefix string) {
+	var ctxErr *api.ErrContextLengthExceeded
+	if errors.As(err, &ctxErr) {
+		h.suggestModels(ctxErr)
+		api.WriteContextLengthExceeded(w, ctxErr)
+		return
+	}
 	var upstreamErr *api.UpstreamError
 	if errors.As(err, &upstreamErr) {
 		api.WriteUpstreamError(w, upstreamErr)
 		return
 	}
+	var paramErr *provider.ParameterNotSupportedError
+	if errors.As(err, &paramErr) {
+		api.WriteBadRequestWithParam(w, paramErr.Error(), paramErr.Param)
+		return
+	}
+	if errors.Is(err, provider.ErrCircuitOpen) {
+		api.WriteError(w, http.StatusServiceUnavailable, api.ErrorTypeServiceUnavailable, err.Error(), nil, nil)
+		return
+	}
+	if errors.Is(err, auth.ErrCredentialExpired) {
+		api.WriteError(w, http.StatusUnauthorized, api.ErrorTypeAuthentication, err.Error(), nil, nil)
+		return
+	}
 	api.WriteServerError(w, prefix+err.Error())
 }
 
+// suggestModels fills in the models whose context window fits the prompt.
+func (h *Handlers) suggestModels(err *api.ErrContextLengthExceeded) {
+	minTokens := err.PromptTokens
+	if minTokens == 0 {
+		minTokens = err.ModelLimit
+	}
+	if minTokens == 0 || err.SuggestedModels != nil {
+		return
+	}
+	err.SuggestedModels = h.registry.ModelsWithContextWindow(minTokens)
+}
+
 // formatErrorForSSE formats an error message for SSE streams, including status code if available.
 func formatErrorForSSE(err error, prefix string) string {
 	var upstreamErr *api.UpstreamError
@@ -109,15 +144,59 @@ func formatErrorForSSE(err error, prefix string) string {
 
 // Handlers holds the HTTP handlers and their dependencies.
 type Handlers struct {
-	registry *provider.Registry
-	cfg      *config.Config
+	registry      *provider.Registry
+	cfg           *config.Config
+	usageReporter *provider.HTTPUsageReporter       // nil when no webhook is configured
+	wrap          provider.MiddlewareChain          // applied to every provider stream, e.g. response transforms
+	strip         *middleware.ResponseFieldStripper // nil when no fields are stripped
+	jsonMode      *middleware.JSONModeMiddleware
+	race          *middleware.RaceProvider // nil unless provider racing is enabled
+	streams       StreamTracker
 }
 
 // NewHandlers creates a new handlers instance.
-func NewHandlers(registry *provider.Registry, cfg *config.Config) *Handlers {
-	return &Handlers{
+func NewHandlers(registry *provider.Registry, cfg *config.Config) (*Handlers, error) {
+	jsonMode, err := middleware.ParseJSONModeEnforcement(cfg.JSONModeEnforcement)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Handlers{
 		registry: registry,
 		cfg:      cfg,
+		jsonMode: middleware.NewJSONModeMiddleware(jsonMode),
+	}
+	if cfg.ResponseTransformScript != "" {
+		transform, err := middleware.NewResponseTransformMiddleware(cfg.ResponseTransformScript)
+		if err != nil {
+			return nil, err
+		}
+		h.wrap = append(h.wrap, transform.Wrap)
+	}
+	if cfg.ResponseStripFields != "" {
+		strip, err := middleware.NewResponseFieldStripper(cfg.ResponseStripFields)
+		if err != nil {
+			return nil, err
+		}
+		h.strip = strip
+	}
+	if cfg.RaceProviders {
+		var competitors []provider.Provider
+		for _, p := range registry.ActiveProviders() {
+			competitors = append(competitors, registry.WithCircuitBreaker(p))
+		}
+		h.race = middleware.NewRaceProvider(competitors)
+	}
+	if cfg.UsageWebhookURL != "" {
+		h.usageReporter = provider.NewHTTPUsageReporter(cfg.UsageWebhookURL)
+	}
+	return h, nil
+}
+
+// Close flushes pending usage events.
+func (h *Handlers) Close() {
+	if h.usageReporter != nil {
+		h.usageReporter.Close()
 	}
 }
 
@@ -132,6 +211,22 @@ func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+// Healthz handles GET /healthz with the status of every active provider.
+// It returns 503 when any provider is degraded, for readiness probes.
+func (h *Handlers) Healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.WriteMethodNotAllowed(w)
+		return
+	}
+
+	summary := h.registry.HealthSummary()
+	w.Header().Set("Content-Type", "application/json")
+	if summary.Status != provider.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(summary)
+}
+
 // Models handles GET /v1/models
 func (h *Handlers) Models(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -158,6 +253,7 @@ func (h *Handlers) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 
 	// Get request ID from context (set by middleware)
 	requestID := GetRequestID(r.Context())
+	start := time.Now()
 
 	// Limit request body size to prevent DoS
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
@@ -173,6 +269,12 @@ func (h *Handlers) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Translate legacy functions/function_call into tools
+	if err := req.NormalizeFunctions(); err != nil {
+		api.WriteBadRequest(w, err.Error())
+		return
+	}
+
 	// Validate model
 	if req.Model == "" {
 		api.WriteBadRequestWithParam(w, "model is required", "model")
@@ -197,7 +299,29 @@ func (h *Handlers) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Log warnings for ignored parameters (after we know the provider)
-	logIgnoredParameters(requestID, &req, p.ID())
+	logIgnoredParameters(requestID, &req, p)
+
+	// Keep sampling parameters within the provider's ranges; upstream
+	// errors for these are vague
+	if bounds := h.registry.ParameterBounds(p.ID()); bounds != nil {
+		if h.cfg.RejectOutOfBoundsParameters {
+			if violations := provider.CheckParameterBounds(&req, bounds); len(violations) > 0 {
+				api.WriteBadRequestWithParam(w, violations[0].Error(), violations[0].Param)
+				return
+			}
+		} else if h.cfg.ClampParameters {
+			for _, v := range provider.ClampParameters(&req, bounds) {
+				slog.Warn("clamping out-of-range parameter",
+					"request_id", requestID,
+					"provider", p.ID(),
+					"param", v.Param,
+					"value", v.Value,
+					"min", v.Bounds.Min,
+					"max", v.Bounds.Max,
+				)
+			}
+		}
+	}
 
 	// Check if model is supported by the provider
 	if !h.registry.IsModelSupported(req.Model) {
@@ -211,6 +335,10 @@ func (h *Handlers) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Image inputs fail with a clear error on providers declaring no vision
+	caps := h.registry.ProviderCapabilities(p.ID())
+	vision := caps == 0 || caps.Has(provider.CapabilityVision)
+
 	// Validate each message
 	for i, msg := range req.Messages {
 		// Validate role
@@ -228,6 +356,65 @@ func (h *Handlers) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 				fmt.Sprintf("messages[%d].tool_call_id", i))
 			return
 		}
+
+		if !vision && slices.ContainsFunc(msg.GetContentParts(), func(part api.ContentPart) bool { return part.Type == "image_url" }) {
+			api.WriteBadRequestWithParam(w,
+				fmt.Sprintf("Provider '%s' does not support vision (image inputs)", p.ID()),
+				fmt.Sprintf("messages[%d].content", i))
+			return
+		}
+	}
+
+	// Validate strict function schemas up front; upstream errors for these are vague
+	for i, tool := range req.Tools {
+		if tool.Function.Strict == nil || !*tool.Function.Strict {
+			continue
+		}
+		if violations := api.StrictSchemaViolations(tool.Function.Parameters); len(violations) > 0 {
+			api.WriteBadRequestWithParam(w,
+				fmt.Sprintf("Invalid schema for strict function '%s': %s", tool.Function.Name, strings.Join(violations, "; ")),
+				fmt.Sprintf("tools[%d].function.parameters", i))
+			return
+		}
+	}
+
+	// Drop tool types the provider can't handle instead of letting upstream reject them
+	req.Tools = filterToolTypes(requestID, p, req.Tools)
+
+	// Validate output modalities
+	for i, modality := range req.Modalities {
+		if !validModalities[modality] {
+			api.WriteBadRequestWithParam(w,
+				fmt.Sprintf("Invalid modality '%s'. Must be one of: text, audio", modality),
+				fmt.Sprintf("modalities[%d]", i))
+			return
+		}
+	}
+	if slices.Contains(req.Modalities, "audio") && req.AudioConfig == nil {
+		api.WriteBadRequestWithParam(w, "audio is required when modalities includes 'audio'", "audio")
+		return
+	}
+
+	// Providers that cannot stream get a buffered request; the stream is then
+	// simulated from the full response unless the fallback is disabled
+	simulateStream := false
+	if req.Stream && !streamingSupported(p) {
+		if h.cfg.DisableStreamingFallback {
+			param := "stream"
+			api.WriteError(w, http.StatusNotImplemented, api.ErrorTypeInvalidRequest,
+				fmt.Sprintf("Streaming is not supported by provider '%s'", p.ID()), nil, &param)
+			return
+		}
+		slog.Debug("provider does not support streaming, simulating stream",
+			"request_id", requestID,
+			"provider", p.ID(),
+		)
+		simulateStream = true
+	}
+
+	streamOptions := req.StreamOptions
+	if simulateStream {
+		streamOptions = nil
 	}
 
 	// Build provider request (provider handles model normalization internally)
@@ -236,12 +423,13 @@ func (h *Handlers) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 		Messages:            req.Messages,
 		Tools:               req.Tools,
 		ToolChoice:          req.ToolChoice,
-		Stream:              req.Stream,
-		StreamOptions:       req.StreamOptions,
+		Stream:              req.Stream && !simulateStream,
+		StreamOptions:       streamOptions,
 		ReasoningEffort:     req.ReasoningEffort,
 		ReasoningSummary:    r.Header.Get("X-Reasoning-Summary"),
 		ReasoningCompat:     r.Header.Get("X-Reasoning-Compat"),
 		TextVerbosity:       r.Header.Get("X-Text-Verbosity"),
+		N:                   req.N,
 		Temperature:         req.Temperature,
 		TopP:                req.TopP,
 		MaxTokens:           req.MaxTokens,
@@ -249,29 +437,376 @@ func (h *Handlers) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 		Stop:                req.Stop,
 		PresencePenalty:     req.PresencePenalty,
 		FrequencyPenalty:    req.FrequencyPenalty,
+		Seed:                req.Seed,
+		Logprobs:            req.Logprobs,
+		TopLogprobs:         req.TopLogprobs,
 		ResponseFormat:      req.ResponseFormat,
 		ParallelToolCalls:   req.ParallelToolCalls,
+		Modalities:          req.Modalities,
+		AudioConfig:         req.AudioConfig,
+	}
+	if provider.SupportsParameter(p, "tool_resources") {
+		providerReq.ToolResources = req.ToolResources
+	}
+	providerReq.PassthroughHeaders = passthroughHeaders(r.Header, h.cfg.PassthroughHeaders)
+	providerReq.TraceContext = httputil.ExtractTraceContext(r)
+
+	// Let the provider do its pre-flight work
+	if preparer, ok := p.(provider.RequestPreparer); ok {
+		prepared, err := h.prepareRequest(r.Context(), preparer, providerReq)
+		if err != nil {
+			h.writeStreamError(w, err, "Failed to prepare request: ")
+			return
+		}
+		providerReq = prepared
+	}
+
+	// In race mode, every provider serving the model competes for the request
+	sender := h.registry.WithCircuitBreaker(p)
+	if h.race != nil && len(h.race.Competitors(modelID)) > 1 {
+		sender = h.race
 	}
 
 	// Send request to provider
-	stream, err := p.ChatCompletion(r.Context(), providerReq)
+	ctx := httputil.WithTraceContext(audit.WithRequestID(r.Context(), requestID), providerReq.TraceContext)
+	ctx, truncation := provider.WithTruncationReport(ctx)
+	stream, err := h.jsonMode.ChatCompletion(ctx, sender, providerReq)
 	if err != nil {
-		api.WriteServerError(w, "Failed to send request: "+err.Error())
+		h.writeStreamError(w, err, "Failed to send request: ")
 		return
 	}
 	defer func() { _ = stream.Close() }()
+	if dropped := truncation.Dropped(); dropped > 0 {
+		w.Header().Set(truncatedMessagesHeader, strconv.Itoa(dropped))
+	}
+	// Let the client join the upstream trace
+	for name, value := range httputil.UpstreamTraceContext(ctx) {
+		w.Header().Set(name, value)
+	}
+	if mr, ok := stream.(provider.MetadataReporter); ok {
+		if id := mr.Metadata()[provider.MetadataUpstreamRequestID]; id != "" {
+			w.Header().Set(upstreamRequestIDHeader, id)
+		}
+	}
 
-	// Handle streaming vs non-streaming
+	stream = h.wrap.Wrap(stream)
+
+	// Track streams so shutdown can let them finish
 	if req.Stream {
-		h.handleStreaming(w, stream)
-	} else {
-		h.handleNonStreaming(w, stream)
+		h.streams.Start()
+		defer h.streams.Done()
+	}
+
+	// Handle streaming vs non-streaming
+	var usage *api.Usage
+	var completed bool
+	switch {
+	case simulateStream:
+		includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+		usage, completed = h.handleSimulatedStreaming(r.Context(), w, stream, includeUsage)
+	case req.Stream:
+		if wt, ok := stream.(io.WriterTo); ok && h.passthroughAllowed(p) {
+			completed = h.handlePassthroughStreaming(w, wt)
+			break
+		}
+		usage, completed = h.handleStreaming(r.Context(), w, stream)
+	default:
+		usage, completed = h.handleNonStreaming(w, stream)
+	}
+
+	if completed {
+		h.reportUsage(r.Context(), p, provider.UsageEvent{
+			Provider:       p.ID(),
+			Model:          modelID,
+			User:           req.User,
+			ConversationID: r.Header.Get("X-Conversation-ID"),
+			RequestID:      requestID,
+			Latency:        time.Since(start),
+		}, usage)
 	}
 }
 
-func (h *Handlers) handleStreaming(w http.ResponseWriter, stream provider.Stream) {
+// reportUsage sends a usage event to the provider (if it implements
+// UsageReporter) and to the configured webhook. Failures are logged only.
+func (h *Handlers) reportUsage(ctx context.Context, p provider.Provider, event provider.UsageEvent, usage *api.Usage) {
+	if usage != nil {
+		event.PromptTokens = usage.PromptTokens
+		event.CompletionTokens = usage.CompletionTokens
+	}
+	event.LatencyMs = event.Latency.Milliseconds()
+	event.Timestamp = time.Now().UTC()
+
+	// The response is already written; don't let client disconnects cancel reporting
+	ctx = context.WithoutCancel(ctx)
+
+	if ur, ok := p.(provider.UsageReporter); ok {
+		if err := ur.ReportUsage(ctx, event); err != nil {
+			slog.Warn("failed to report usage", "request_id", event.RequestID, "provider", event.Provider, "error", err)
+		}
+	}
+	if h.usageReporter != nil {
+		if err := h.usageReporter.ReportUsage(ctx, event); err != nil {
+			slog.Warn("failed to report usage", "request_id", event.RequestID, "sink", "webhook", "error", err)
+		}
+	}
+}
+
+// TokensCount handles POST /v1/tokens/count
+func (h *Handlers) TokensCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		api.WriteMethodNotAllowed(w)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+
+	var req api.ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if strings.Contains(err.Error(), "http: request body too large") {
+			api.WriteBadRequest(w, "Request body too large (max 10MB)")
+			return
+		}
+		api.WriteBadRequest(w, "Invalid JSON: "+err.Error())
+		return
+	}
+
+	if req.Model == "" {
+		api.WriteBadRequestWithParam(w, "model is required", "model")
+		return
+	}
+	if len(req.Messages) == 0 {
+		api.WriteBadRequestWithParam(w, "messages is required", "messages")
+		return
+	}
+
+	p, modelID, err := h.registry.GetProvider(req.Model)
+	if err != nil {
+		if strings.Contains(err.Error(), "requires login") {
+			api.WriteError(w, http.StatusUnauthorized, api.ErrorTypeAuthentication, err.Error(), nil, nil)
+			return
+		}
+		if strings.Contains(err.Error(), "must include provider prefix") {
+			api.WriteBadRequestWithParam(w, err.Error(), "model")
+			return
+		}
+		api.WriteModelNotFound(w, req.Model)
+		return
+	}
+
+	providerReq := &provider.ChatCompletionRequest{
+		Model:      modelID,
+		Messages:   req.Messages,
+		Tools:      req.Tools,
+		ToolChoice: req.ToolChoice,
+	}
+
+	// Prefer the provider's own counter, falling back to a local estimate
+	var counter provider.TokenCounter = provider.DefaultTokenEstimator
+	if tc, ok := p.(provider.TokenCounter); ok {
+		counter = tc
+	}
+
+	count, err := counter.CountTokens(r.Context(), providerReq)
+	if err != nil {
+		h.writeStreamError(w, err, "Failed to count tokens: ")
+		return
+	}
+	count.Model = req.Model
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(count)
+}
+
+// Embeddings handles POST /v1/embeddings
+func (h *Handlers) Embeddings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		api.WriteMethodNotAllowed(w)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+
+	var req api.EmbeddingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if strings.Contains(err.Error(), "http: request body too large") {
+			api.WriteBadRequest(w, "Request body too large (max 10MB)")
+			return
+		}
+		api.WriteBadRequest(w, "Invalid JSON: "+err.Error())
+		return
+	}
+
+	if req.Model == "" {
+		api.WriteBadRequestWithParam(w, "model is required", "model")
+		return
+	}
+	if len(req.Input) == 0 || string(req.Input) == "null" {
+		api.WriteBadRequestWithParam(w, "input is required", "input")
+		return
+	}
+
+	p, modelID, err := h.registry.GetProvider(req.Model)
+	if err != nil {
+		if strings.Contains(err.Error(), "requires login") {
+			api.WriteError(w, http.StatusUnauthorized, api.ErrorTypeAuthentication, err.Error(), nil, nil)
+			return
+		}
+		if strings.Contains(err.Error(), "must include provider prefix") {
+			api.WriteBadRequestWithParam(w, err.Error(), "model")
+			return
+		}
+		api.WriteModelNotFound(w, req.Model)
+		return
+	}
+
+	// Embedding models are usually missing from chat model catalogs, so
+	// the model is left for the upstream to validate
+	embedder, ok := p.(provider.Embedder)
+	if !ok {
+		api.WriteBadRequestWithParam(w, fmt.Sprintf("Provider '%s' does not support embeddings", p.ID()), "model")
+		return
+	}
+
+	resp, err := embedder.Embeddings(r.Context(), &provider.EmbeddingsRequest{
+		Model:          modelID,
+		Input:          req.Input,
+		EncodingFormat: req.EncodingFormat,
+		Dimensions:     req.Dimensions,
+		User:           req.User,
+	})
+	if err != nil {
+		if errors.Is(err, provider.ErrCapabilityNotSupported) {
+			api.WriteBadRequestWithParam(w, err.Error(), "model")
+			return
+		}
+		h.writeStreamError(w, err, "Embeddings request failed: ")
+		return
+	}
+	resp.Model = req.Model
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// filterToolTypes removes tools whose type the provider doesn't support.
+func filterToolTypes(requestID string, p provider.Provider, tools []api.Tool) []api.Tool {
+	tc, ok := p.(provider.ToolTypeCapability)
+	if !ok || len(tools) == 0 {
+		return tools
+	}
+
+	supported := tc.SupportedToolTypes()
+	filtered := make([]api.Tool, 0, len(tools))
+	var dropped []string
+	for _, tool := range tools {
+		if slices.Contains(supported, tool.Type) {
+			filtered = append(filtered, tool)
+		} else {
+			dropped = append(dropped, tool.Type)
+		}
+	}
+
+	if len(dropped) > 0 {
+		slog.Warn("dropping unsupported tool types",
+			"request_id", requestID,
+			"provider", p.ID(),
+			"types", strings.Join(dropped, ", "),
+		)
+	}
+	return filtered
+}
+
+// streamingSupported reports whether a provider can stream responses.
+func streamingSupported(p provider.Provider) bool {
+	if sc, ok := p.(provider.StreamingCapability); ok {
+		return sc.StreamingSupported()
+	}
+	return true
+}
+
+// prepareRequest runs the provider's pre-flight step within PrepareTimeout.
+func (h *Handlers) prepareRequest(ctx context.Context, preparer provider.RequestPreparer, req *provider.ChatCompletionRequest) (*provider.ChatCompletionRequest, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(h.cfg.PrepareTimeout)*time.Second)
+	defer cancel()
+
+	prepared, err := preparer.PrepareRequest(ctx, req)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, api.NewUpstreamError(http.StatusGatewayTimeout,
+			fmt.Sprintf("request preparation timed out after %ds", h.cfg.PrepareTimeout))
+	}
+	return prepared, err
+}
+
+// chunkDelay waits OPENCOMPAT_STREAMING_CHUNK_DELAY (plus jitter) between
+// streamed chunks. It returns false if the client went away meanwhile.
+func (h *Handlers) chunkDelay(ctx context.Context) bool {
+	delay := h.cfg.StreamingChunkDelay
+	if h.cfg.StreamingChunkDelayJitter > 0 {
+		delay += rand.N(h.cfg.StreamingChunkDelayJitter)
+	}
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// passthroughAllowed reports whether streamed responses may be copied to the
+// client verbatim. Stream middleware (such as transforms), field stripping,
+// usage reporting, audit logging and chunk pacing need the decoded chunks.
+// (Stream wrappers such as JSON mode validation hide io.WriterTo themselves.)
+func (h *Handlers) passthroughAllowed(p provider.Provider) bool {
+	if len(h.wrap) > 0 || h.strip != nil || h.usageReporter != nil {
+		return false
+	}
+	if h.cfg.StreamingChunkDelay > 0 || h.cfg.StreamingChunkDelayJitter > 0 {
+		return false
+	}
+	if a, ok := p.(provider.Auditor); ok && a.AuditEnabled() {
+		return false
+	}
+	_, reportsUsage := p.(provider.UsageReporter)
+	return !reportsUsage
+}
+
+// handlePassthroughStreaming copies the provider's raw SSE stream to the client.
+func (h *Handlers) handlePassthroughStreaming(w http.ResponseWriter, wt io.WriterTo) bool {
+	sseWriter, err := NewSSEWriter(w)
+	if err != nil {
+		api.WriteServerError(w, err.Error())
+		return false
+	}
+
+	n, err := wt.WriteTo(sseWriter.w)
+	if err == nil {
+		return true
+	}
+
+	// Headers are only sent on the first write, so a proper error response
+	// is still possible if nothing was forwarded
+	if n == 0 {
+		h.writeStreamError(w, err, "Stream error: ")
+		return false
+	}
+	_ = sseWriter.WriteError(formatErrorForSSE(err, "Stream error"))
+	_ = sseWriter.WriteDone()
+	return false
+}
+
+// handleStreaming relays chunks as SSE. It returns the usage seen in the
+// stream (if any) and whether the stream completed without error.
+func (h *Handlers) handleStreaming(ctx context.Context, w http.ResponseWriter, stream provider.Stream) (*api.Usage, bool) {
 	var sseWriter *SSEWriter
 	var streamErr error
+	var usage *api.Usage
+	sent := 0
 
 	for {
 		chunk, err := stream.Next()
@@ -288,14 +823,23 @@ func (h *Handlers) handleStreaming(w http.ResponseWriter, stream provider.Stream
 			sseWriter, initErr = NewSSEWriter(w)
 			if initErr != nil {
 				api.WriteServerError(w, initErr.Error())
-				return
+				return nil, false
 			}
+			sseWriter.strip = h.strip
 		}
 
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+
+		if sent > 0 && !h.chunkDelay(ctx) {
+			return nil, false
+		}
 		if err := sseWriter.WriteChunk(chunk); err != nil {
 			// Client disconnected
-			return
+			return nil, false
 		}
+		sent++
 	}
 
 	// If no chunks were sent, we can still return a proper HTTP error
@@ -306,26 +850,83 @@ func (h *Handlers) handleStreaming(w http.ResponseWriter, stream provider.Stream
 			err = stream.Err()
 		}
 		if err != nil {
-			writeStreamError(w, err, "Stream error: ")
-			return
+			h.writeStreamError(w, err, "Stream error: ")
+			return nil, false
 		}
 		api.WriteServerError(w, "No response received from upstream")
-		return
+		return nil, false
 	}
 
 	// For errors after streaming started, write error to SSE stream.
 	// streamErr is set when Next() returns a non-EOF error.
 	// stream.Err() may return additional errors from SSE event processing (e.g., response.failed).
+	completed := true
 	if streamErr != nil {
 		_ = sseWriter.WriteError(formatErrorForSSE(streamErr, "Stream error"))
+		completed = false
 	} else if err := stream.Err(); err != nil {
 		_ = sseWriter.WriteError(formatErrorForSSE(err, "Upstream error"))
+		completed = false
 	}
 
 	_ = sseWriter.WriteDone()
+
+	// Fall back to the accumulated response when usage wasn't streamed
+	if usage == nil {
+		if resp := stream.Response(); resp != nil {
+			usage = resp.Usage
+		}
+	}
+	return usage, completed
 }
 
-func (h *Handlers) handleNonStreaming(w http.ResponseWriter, stream provider.Stream) {
+func (h *Handlers) handleNonStreaming(w http.ResponseWriter, stream provider.Stream) (*api.Usage, bool) {
+	response, ok := h.readResponse(w, stream)
+	if !ok {
+		return nil, false
+	}
+
+	data, err := h.strip.Marshal(response)
+	if err != nil {
+		api.WriteServerError(w, err.Error())
+		return nil, false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(append(data, '\n'))
+	return response.Usage, true
+}
+
+// handleSimulatedStreaming writes a buffered response as an SSE stream.
+func (h *Handlers) handleSimulatedStreaming(ctx context.Context, w http.ResponseWriter, stream provider.Stream, includeUsage bool) (*api.Usage, bool) {
+	response, ok := h.readResponse(w, stream)
+	if !ok {
+		return nil, false
+	}
+
+	sseWriter, err := NewSSEWriter(w)
+	if err != nil {
+		api.WriteServerError(w, err.Error())
+		return nil, false
+	}
+	sseWriter.strip = h.strip
+
+	for i, chunk := range api.ResponseToChunks(response, includeUsage) {
+		if i > 0 && !h.chunkDelay(ctx) {
+			return nil, false
+		}
+		if err := sseWriter.WriteChunk(&chunk); err != nil {
+			// Client disconnected
+			return nil, false
+		}
+	}
+
+	_ = sseWriter.WriteDone()
+	return response.Usage, true
+}
+
+// readResponse consumes a non-streaming stream and returns the accumulated response.
+// On failure it writes an error response and returns false.
+func (h *Handlers) readResponse(w http.ResponseWriter, stream provider.Stream) (*api.ChatCompletionResponse, bool) {
 	// Consume the stream to build the response
 	for {
 		_, err := stream.Next()
@@ -333,24 +934,38 @@ func (h *Handlers) handleNonStreaming(w http.ResponseWriter, stream provider.Str
 			if err == io.EOF {
 				break
 			}
-			writeStreamError(w, err, "Stream read error: ")
-			return
+			h.writeStreamError(w, err, "Stream read error: ")
+			return nil, false
 		}
 	}
 
 	// Check for stream error
 	if err := stream.Err(); err != nil {
-		writeStreamError(w, err, "Upstream error: ")
-		return
+		h.writeStreamError(w, err, "Upstream error: ")
+		return nil, false
 	}
 
 	// Get the accumulated response
 	response := stream.Response()
 	if response == nil || response.ID == "" {
 		api.WriteServerError(w, "No response received from upstream")
-		return
+		return nil, false
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(response)
+	return response, true
+}
+
+// passthroughHeaders returns the headers in allowlist present on the
+// incoming request, or nil when there are none.
+func passthroughHeaders(incoming http.Header, allowlist []string) http.Header {
+	var headers http.Header
+	for _, name := range allowlist {
+		if values := incoming.Values(name); len(values) > 0 {
+			if headers == nil {
+				headers = make(http.Header)
+			}
+			headers[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	return headers
 }
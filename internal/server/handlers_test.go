@@ -0,0 +1,554 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/config"
+	"github.com/edgard/opencompat/internal/httputil"
+	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/provider/copilot"
+	"github.com/edgard/opencompat/internal/provider/mock"
+)
+
+// auditingProvider is a provider with audit logging on or off.
+type auditingProvider struct {
+	provider.Provider
+	audit bool
+}
+
+func (p auditingProvider) AuditEnabled() bool { return p.audit }
+
+func TestPassthroughAllowed(t *testing.T) {
+	h := &Handlers{cfg: &config.Config{}}
+	tests := []struct {
+		name string
+		p    provider.Provider
+		want bool
+	}{
+		{name: "plain provider", p: auditingProvider{}, want: true},
+		{name: "audit logging on", p: auditingProvider{audit: true}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.passthroughAllowed(tt.p); got != tt.want {
+				t.Errorf("passthroughAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// postJSON posts body to the chat completions endpoint.
+func postJSON(t *testing.T, baseURL, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(baseURL+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+// postChat sends a chat completion request for model with stream set.
+func postChat(t *testing.T, baseURL, model string, stream bool) *http.Response {
+	t.Helper()
+	return postJSON(t, baseURL, `{"model":"mock/`+model+`","stream":`+strconv.FormatBool(stream)+
+		`,"messages":[{"role":"user","content":"hi"}]}`)
+}
+
+// completion returns a non-streaming response with a single message.
+func completion(model, content string) *api.ChatCompletionResponse {
+	stop := "stop"
+	message := api.AssistantMessage(content)
+	return &api.ChatCompletionResponse{
+		ID:      "1",
+		Object:  "chat.completion",
+		Model:   model,
+		Choices: []api.Choice{{Message: &message, FinishReason: &stop}},
+	}
+}
+
+// readBody reads the response body and fails unless the status is want.
+func readBody(t *testing.T, resp *http.Response, want int) string {
+	t.Helper()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != want {
+		t.Fatalf("status = %d, want %d; body: %s", resp.StatusCode, want, body)
+	}
+	return string(body)
+}
+
+func TestNonStreamingProvider(t *testing.T) {
+	tests := []struct {
+		name        string
+		disable     bool
+		wantStatus  int
+		wantBody    []string
+		wantInvoked bool
+	}{
+		{
+			name:        "simulate",
+			wantStatus:  http.StatusOK,
+			wantBody:    []string{`"content":"hello"`, "data: [DONE]"},
+			wantInvoked: true,
+		},
+		{
+			name:       "reject",
+			disable:    true,
+			wantStatus: http.StatusNotImplemented,
+			wantBody:   []string{`"message":"Streaming is not supported by provider 'mock'"`, `"param":"stream"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.New(mock.WithoutStreaming())
+			m.SetResponse("buffered", completion("buffered", "hello"))
+			cfg := config.Load()
+			cfg.DisableStreamingFallback = tt.disable
+			_, baseURL := newMockServer(t, m, cfg)
+
+			resp := postChat(t, baseURL, "buffered", true)
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("body does not contain %s:\n%s", want, body)
+				}
+			}
+
+			invocations := m.Invocations()
+			if !tt.wantInvoked {
+				if len(invocations) != 0 {
+					t.Errorf("provider called %d times, want 0", len(invocations))
+				}
+				return
+			}
+			if len(invocations) != 1 || invocations[0].Stream {
+				t.Errorf("invocations = %+v, want one buffered request", invocations)
+			}
+		})
+	}
+}
+
+func TestStreamingChunkDelay(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	m := mock.New()
+	slowStream(m, "paced", 4)
+	cfg := config.Load()
+	cfg.StreamingChunkDelay = delay
+	_, baseURL := newMockServer(t, m, cfg)
+
+	resp := postChat(t, baseURL, "paced", true)
+	reader := bufio.NewReader(resp.Body)
+	var arrivals []time.Time
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended without [DONE]: %v", err)
+		}
+		if strings.HasPrefix(line, "data: [DONE]") {
+			break
+		}
+		if strings.HasPrefix(line, "data: ") {
+			arrivals = append(arrivals, time.Now())
+		}
+	}
+
+	if len(arrivals) != 4 {
+		t.Fatalf("received %d chunks, want 4", len(arrivals))
+	}
+	for i := 1; i < len(arrivals); i++ {
+		// Allow for the previous chunk arriving late
+		if gap := arrivals[i].Sub(arrivals[i-1]); gap < delay*4/5 {
+			t.Errorf("chunk %d arrived %s after the previous one, want at least %s", i, gap, delay)
+		}
+	}
+}
+
+func TestChunkDelayStopsOnCancel(t *testing.T) {
+	h := &Handlers{cfg: &config.Config{StreamingChunkDelay: time.Hour}}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	if h.chunkDelay(ctx) {
+		t.Error("chunkDelay() = true after cancellation, want false")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("chunkDelay() returned %s after cancellation", elapsed)
+	}
+}
+
+func TestChunkDelayZero(t *testing.T) {
+	h := &Handlers{cfg: &config.Config{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if !h.chunkDelay(ctx) {
+		t.Error("chunkDelay() without a delay = false, want true")
+	}
+}
+
+func TestStreamingChunkDelayClientDisconnect(t *testing.T) {
+	m := mock.New()
+	slowStream(m, "paced", 3)
+	cfg := config.Load()
+	cfg.StreamingChunkDelay = time.Hour
+	s, baseURL := newMockServer(t, m, cfg)
+
+	resp := postChat(t, baseURL, "paced", true)
+	reader := bufio.NewReader(resp.Body)
+	if _, err := reader.ReadString('\n'); err != nil {
+		t.Fatalf("stream did not start: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	// The handler stops sleeping once the client is gone
+	deadline := time.Now().Add(2 * time.Second)
+	for s.handlers.streams.Active() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if active := s.handlers.streams.Active(); active != 0 {
+		t.Errorf("active streams = %d after the client disconnected, want 0", active)
+	}
+}
+
+func TestModalitiesValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		fields     string // extra request fields
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "text", fields: `"modalities":["text"]`, wantStatus: http.StatusOK},
+		{name: "audio with config", fields: `"modalities":["text","audio"],"audio":{"voice":"alloy","format":"wav"}`, wantStatus: http.StatusOK},
+		{
+			name:       "unknown modality",
+			fields:     `"modalities":["text","video"]`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"message":"Invalid modality 'video'. Must be one of: text, audio","type":"invalid_request_error","param":"modalities[1]"`,
+		},
+		{
+			name:       "audio without config",
+			fields:     `"modalities":["audio"]`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"message":"audio is required when modalities includes 'audio'","type":"invalid_request_error","param":"audio"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.New()
+			m.SetResponse("audio", completion("audio", "hello"))
+			_, baseURL := newMockServer(t, m, config.Load())
+
+			resp := postJSON(t, baseURL, `{"model":"mock/audio","messages":[{"role":"user","content":"hi"}],`+tt.fields+`}`)
+			body := readBody(t, resp, tt.wantStatus)
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body does not contain %s:\n%s", tt.wantBody, body)
+			}
+
+			invocations := m.Invocations()
+			if tt.wantStatus != http.StatusOK {
+				if len(invocations) != 0 {
+					t.Errorf("provider called %d times, want 0", len(invocations))
+				}
+				return
+			}
+			if len(invocations) != 1 || len(invocations[0].Modalities) == 0 {
+				t.Errorf("invocations = %+v, want one with modalities passed through", invocations)
+			}
+		})
+	}
+}
+
+// functionToolsProvider is a mock that accepts only function tools.
+type functionToolsProvider struct {
+	*mock.Provider
+}
+
+func (functionToolsProvider) SupportedToolTypes() []string { return []string{"function"} }
+
+// mixedTools are a function tool and the built-in OpenAI tool types.
+var mixedTools = []api.Tool{
+	{Type: "function", Function: api.Function{Name: "get_weather"}},
+	{Type: "code_interpreter"},
+	{Type: "web_search_preview"},
+}
+
+func TestFilterToolTypes(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cp, err := copilot.NewWithOptions(auth.NewStore(), nil)
+	if err != nil {
+		t.Fatalf("copilot.NewWithOptions() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		p    provider.Provider
+		want []string
+	}{
+		{name: "copilot", p: cp, want: []string{"function"}},
+		{name: "no capability", p: mock.New(), want: []string{"function", "code_interpreter", "web_search_preview"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterToolTypes("req", tt.p, mixedTools)
+			if types := toolTypes(got); !slices.Equal(types, tt.want) {
+				t.Errorf("filterToolTypes() types = %v, want %v", types, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnsupportedToolTypesStripped(t *testing.T) {
+	m := mock.New()
+	m.SetResponse("tools", completion("tools", "hello"))
+	_, baseURL := newProviderServer(t, functionToolsProvider{m}, config.Load())
+
+	resp := postJSON(t, baseURL, `{"model":"mock/tools","messages":[{"role":"user","content":"hi"}],"tools":[`+
+		`{"type":"function","function":{"name":"get_weather","parameters":{"type":"object"}}},`+
+		`{"type":"code_interpreter"}]}`)
+	readBody(t, resp, http.StatusOK)
+
+	invocations := m.Invocations()
+	if len(invocations) != 1 {
+		t.Fatalf("provider called %d times, want 1", len(invocations))
+	}
+	if types := toolTypes(invocations[0].Tools); !slices.Equal(types, []string{"function"}) {
+		t.Errorf("tools sent = %v, want [function]", types)
+	}
+}
+
+func toolTypes(tools []api.Tool) []string {
+	types := make([]string, len(tools))
+	for i, tool := range tools {
+		types[i] = tool.Type
+	}
+	return types
+}
+
+// parametersProvider is a mock that declares the optional parameters it
+// supports.
+type parametersProvider struct {
+	*mock.Provider
+	params []string
+}
+
+func (p parametersProvider) SupportedParameters() []string { return p.params }
+
+func TestToolResourcesPassthrough(t *testing.T) {
+	const resources = `{"file_search":{"vector_store_ids":["vs_1"]}}`
+	tests := []struct {
+		name   string
+		params []string
+		want   string
+	}{
+		{name: "supported", params: []string{"tool_resources"}, want: resources},
+		{name: "unsupported", params: []string{"temperature"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.New()
+			m.SetResponse("assistant", completion("assistant", "hello"))
+			_, baseURL := newProviderServer(t, parametersProvider{m, tt.params}, config.Load())
+
+			resp := postJSON(t, baseURL, `{"model":"mock/assistant","messages":[{"role":"user","content":"hi"}],`+
+				`"tool_resources":`+resources+`}`)
+			readBody(t, resp, http.StatusOK)
+
+			invocations := m.Invocations()
+			if len(invocations) != 1 {
+				t.Fatalf("provider called %d times, want 1", len(invocations))
+			}
+			if got := string(invocations[0].ToolResources); got != tt.want {
+				t.Errorf("tool_resources sent = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictFunctionSchema(t *testing.T) {
+	const invalid = `{"type":"object","properties":{"id":{"anyOf":[{"type":"string"},{"type":"integer"}]}}}`
+	tests := []struct {
+		name       string
+		strict     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "not strict", strict: "false", wantStatus: http.StatusOK},
+		{
+			name:       "strict",
+			strict:     "true",
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"error":{"message":"Invalid schema for strict function 'lookup': ` +
+				`parameters: additionalProperties must be false; ` +
+				`parameters.properties.id: anyOf may only combine a single schema with null",` +
+				`"type":"invalid_request_error","param":"tools[1].function.parameters","code":null}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.New()
+			m.SetResponse("strict", completion("strict", "hello"))
+			_, baseURL := newMockServer(t, m, config.Load())
+
+			resp := postJSON(t, baseURL, `{"model":"mock/strict","messages":[{"role":"user","content":"hi"}],"tools":[`+
+				`{"type":"function","function":{"name":"ok","parameters":{"type":"object"}}},`+
+				`{"type":"function","function":{"name":"lookup","strict":`+tt.strict+`,"parameters":`+invalid+`}}]}`)
+			body := readBody(t, resp, tt.wantStatus)
+			if tt.wantBody != "" && strings.TrimSpace(body) != tt.wantBody {
+				t.Errorf("body = %s\nwant %s", body, tt.wantBody)
+			}
+			if want := tt.wantStatus == http.StatusOK; (len(m.Invocations()) == 1) != want {
+				t.Errorf("provider called %d times, want called %v", len(m.Invocations()), want)
+			}
+		})
+	}
+}
+
+func TestContextLengthExceededSuggestsModels(t *testing.T) {
+	m := mock.New()
+	m.SetError("small", &api.ErrContextLengthExceeded{
+		Message:      "prompt token count of 150000 exceeds the limit of 128000",
+		PromptTokens: 150000,
+		ModelLimit:   128000,
+	})
+	// Listed out of order; suggestions are sorted by context window
+	m.SetModels([]api.Model{
+		{ID: "huge", ContextWindow: 1_000_000},
+		{ID: "small", ContextWindow: 128_000},
+		{ID: "large", ContextWindow: 200_000},
+		{ID: "tiny", ContextWindow: 8_000},
+		{ID: "medium", ContextWindow: 160_000},
+		{ID: "unknown"},
+	})
+	_, baseURL := newMockServer(t, m, config.Load())
+
+	resp := postChat(t, baseURL, "small", false)
+	body := readBody(t, resp, http.StatusBadRequest)
+
+	var got struct {
+		Error struct {
+			Code       string `json:"code"`
+			Param      string `json:"param"`
+			Extensions struct {
+				PromptTokens    int      `json:"prompt_tokens"`
+				ModelLimit      int      `json:"model_limit"`
+				SuggestedModels []string `json:"suggested_models"`
+			} `json:"extensions"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("invalid error body %s: %v", body, err)
+	}
+	if got.Error.Code != "context_length_exceeded" || got.Error.Param != "messages" {
+		t.Errorf("code, param = %q, %q, want context_length_exceeded, messages", got.Error.Code, got.Error.Param)
+	}
+	ext := got.Error.Extensions
+	if ext.PromptTokens != 150000 || ext.ModelLimit != 128000 {
+		t.Errorf("extensions tokens = (%d, %d), want (150000, 128000)", ext.PromptTokens, ext.ModelLimit)
+	}
+	if want := []string{"mock/medium", "mock/large", "mock/huge"}; !slices.Equal(ext.SuggestedModels, want) {
+		t.Errorf("suggested_models = %v, want %v", ext.SuggestedModels, want)
+	}
+}
+
+// tracingProvider is a mock whose upstream responds with a traceparent,
+// recording the trace context it is called with.
+type tracingProvider struct {
+	*mock.Provider
+	upstreamParent string
+
+	mu       sync.Mutex
+	outgoing map[string]string
+}
+
+func (p *tracingProvider) ChatCompletion(ctx context.Context, req *provider.ChatCompletionRequest) (provider.Stream, error) {
+	p.mu.Lock()
+	p.outgoing = httputil.TraceContextFrom(ctx)
+	p.mu.Unlock()
+	httputil.RecordUpstreamTraceContext(ctx, http.Header{"Traceparent": {p.upstreamParent}})
+	return p.Provider.ChatCompletion(ctx, req)
+}
+
+// lastOutgoing returns the trace context of the last request.
+func (p *tracingProvider) lastOutgoing() map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.outgoing
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	const (
+		traceParent    = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		upstreamParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-b7ad6b7169203331-01"
+	)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   map[string]string
+	}{
+		{
+			name:   "traceparent and tracestate",
+			header: http.Header{"Traceparent": {traceParent}, "Tracestate": {"congo=t61rcWkgMzE"}},
+			want:   map[string]string{"traceparent": traceParent, "tracestate": "congo=t61rcWkgMzE"},
+		},
+		{name: "invalid traceparent", header: http.Header{"Traceparent": {"garbage"}, "Tracestate": {"congo=t61rcWkgMzE"}}},
+		{name: "none", header: http.Header{}},
+	}
+	m := mock.New()
+	m.SetResponse("echo", completion("echo", "hi"))
+	m.SetChunks("echo", []*api.ChatCompletionChunk{{ID: "1", Model: "echo", Choices: []api.Choice{{Delta: &api.Delta{Content: "hi"}}}}})
+	p := &tracingProvider{Provider: m, upstreamParent: upstreamParent}
+	_, baseURL := newProviderServer(t, p, config.Load())
+
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			t.Run(tt.name+"/stream="+strconv.FormatBool(stream), func(t *testing.T) {
+				req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/chat/completions", strings.NewReader(
+					`{"model":"mock/echo","stream":`+strconv.FormatBool(stream)+`,"messages":[{"role":"user","content":"hi"}]}`))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header = tt.header.Clone()
+				req.Header.Set("Content-Type", "application/json")
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				readBody(t, resp, http.StatusOK)
+
+				if got := p.lastOutgoing(); !maps.Equal(got, tt.want) {
+					t.Errorf("provider trace context = %v, want %v", got, tt.want)
+				}
+				invocations := m.Invocations()
+				if got := invocations[len(invocations)-1].TraceContext; !maps.Equal(got, tt.want) {
+					t.Errorf("request TraceContext = %v, want %v", got, tt.want)
+				}
+				// The client can join the upstream trace
+				if got := resp.Header.Get("Traceparent"); got != upstreamParent {
+					t.Errorf("response traceparent = %q, want %q", got, upstreamParent)
+				}
+			})
+		}
+	}
+}
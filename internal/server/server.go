@@ -2,14 +2,24 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"expvar"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
 	"github.com/edgard/opencompat/internal/config"
+	"github.com/edgard/opencompat/internal/cors"
+	"github.com/edgard/opencompat/internal/httputil"
+	"github.com/edgard/opencompat/internal/metrics"
 	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/tokencount"
+	"github.com/edgard/opencompat/internal/wsstream"
 )
 
 // Server represents the HTTP server.
@@ -21,21 +31,31 @@ type Server struct {
 }
 
 // New creates a new server instance.
-func New(registry *provider.Registry, cfg *config.Config) *Server {
-	handlers := NewHandlers(registry, cfg)
+func New(registry *provider.Registry, cfg *config.Config) (*Server, error) {
+	handlers, err := NewHandlers(registry, cfg)
+	if err != nil {
+		return nil, err
+	}
+	tokencount.SetModels(registry.AllModels)
 
 	mux := http.NewServeMux()
 
 	// Register routes
 	mux.HandleFunc("/health", handlers.Health)
+	mux.HandleFunc("/healthz", handlers.Healthz)
 	mux.HandleFunc("/v1/models", handlers.Models)
 	mux.HandleFunc("/v1/chat/completions", handlers.ChatCompletions)
+	mux.Handle("/v1/chat/completions/ws", wsstream.Handler(registry))
+	mux.HandleFunc("/v1/tokens/count", handlers.TokensCount)
+	mux.HandleFunc("/v1/embeddings", handlers.Embeddings)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/metrics", metrics.Handler())
 
 	// Catch-all for unknown /v1/ endpoints - returns OpenAI-style 404
 	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
 		// Check if this path matches a known endpoint (exact match handled above)
 		path := r.URL.Path
-		if path == "/v1/models" || path == "/v1/chat/completions" {
+		if path == "/v1/models" || path == "/v1/chat/completions" || path == "/v1/chat/completions/ws" || path == "/v1/tokens/count" || path == "/v1/embeddings" {
 			// Shouldn't reach here due to exact match, but just in case
 			return
 		}
@@ -44,26 +64,61 @@ func New(registry *provider.Registry, cfg *config.Config) *Server {
 		api.WriteNotFound(w, fmt.Sprintf("Unknown endpoint: /v1/%s", endpoint))
 	})
 
-	// Apply middleware
+	proxyAuth, err := auth.NewStore().LoadProxyAuth(cfg.APIKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	corsConfig := cors.Config{
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   cfg.CORSAllowedMethods,
+		AllowedHeaders:   cfg.CORSAllowedHeaders,
+		ExposedHeaders:   []string{"x-request-id", truncatedMessagesHeader, upstreamRequestIDHeader, httputil.TraceParentHeader, httputil.TraceStateHeader},
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           cfg.CORSMaxAge,
+	}
+	if err := corsConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid CORS configuration: %w", err)
+	}
+
+	// Apply middleware; CORS preflights and health checks skip API key auth
 	handler := ChainMiddleware(
 		mux,
 		RecoveryMiddleware,
 		LoggingMiddleware,
 		RequestIDMiddleware,
-		CORSMiddleware,
+		cors.Middleware(corsConfig),
+		skipPaths(proxyAuth.Middleware, "/health", "/healthz"),
 	)
 
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 
+	var tlsConfig *tls.Config
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		tlsConfig, err = httputil.BuildTLSConfig(httputil.TLSConfig{
+			CertFile:       cfg.TLSCertFile,
+			KeyFile:        cfg.TLSKeyFile,
+			ClientCACert:   cfg.TLSClientCACert,
+			ClientAuth:     cfg.TLSClientAuth,
+			OCSPStapleFile: cfg.TLSOCSPStapleFile,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+		}
+	} else if cfg.TLSClientCACert != "" || (cfg.TLSClientAuth != "" && cfg.TLSClientAuth != httputil.ClientAuthNone) {
+		return nil, errors.New("client certificate authentication requires OPENCOMPAT_TLS_CERT_FILE and OPENCOMPAT_TLS_KEY_FILE")
+	}
+
 	return &Server{
 		httpServer: &http.Server{
-			Addr:    addr,
-			Handler: handler,
+			Addr:      addr,
+			Handler:   handler,
+			TLSConfig: tlsConfig,
 		},
 		handlers: handlers,
 		registry: registry,
 		cfg:      cfg,
-	}
+	}, nil
 }
 
 // PrefetchInstructions initializes all active providers.
@@ -98,19 +153,54 @@ func (s *Server) Start() error {
 		}
 	}
 
+	scheme := "http"
+	if s.httpServer.TLSConfig != nil {
+		scheme = "https"
+	}
 	slog.Info("server starting", "addr", s.httpServer.Addr)
-	slog.Info("OpenAI-compatible API available", "url", fmt.Sprintf("http://%s/v1", s.httpServer.Addr))
-
-	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	slog.Info("OpenAI-compatible API available", "url", fmt.Sprintf("%s://%s/v1", scheme, s.httpServer.Addr))
+
+	var err error
+	if s.httpServer.TLSConfig != nil {
+		// The certificate is already loaded into TLSConfig
+		err = s.httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = s.httpServer.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		return err
 	}
 	return nil
 }
 
 // Shutdown gracefully shuts down the server.
+// With stream draining enabled, active streaming responses are given up to
+// the drain timeout to finish after the listener has stopped.
 func (s *Server) Shutdown(ctx context.Context) error {
-	// Close all providers
-	s.registry.CloseAll()
+	err := s.httpServer.Shutdown(ctx)
+
+	if s.cfg.DrainStreams {
+		if active := s.handlers.streams.Active(); active > 0 {
+			slog.Info("waiting for active streams to finish", "streams", active, "timeout", time.Duration(s.cfg.DrainTimeout)*time.Second)
+		}
+		drainCtx, cancel := context.WithTimeout(context.Background(), time.Duration(s.cfg.DrainTimeout)*time.Second)
+		if drainErr := s.handlers.streams.Wait(drainCtx); drainErr != nil {
+			slog.Warn("streams still active after drain timeout", "streams", s.handlers.streams.Active())
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			// Shutdown timed out waiting on the streams we have now drained
+			err = nil
+		}
+		cancel()
+	}
+
+	// Let providers finish requests still in flight, e.g. after a drain
+	// timeout
+	if shutdownErr := s.registry.ShutdownAll(ctx); shutdownErr != nil {
+		slog.Warn("providers still had requests in flight at shutdown", "error", shutdownErr)
+	}
+
+	// Flush usage events after in-flight requests have finished
+	s.handlers.Close()
 
-	return s.httpServer.Shutdown(ctx)
+	return err
 }
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/config"
+	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/provider/mock"
+	"github.com/edgard/opencompat/internal/wsstream"
+)
+
+// newMockServer starts a server whose only provider is m, returning its
+// base URL.
+func newMockServer(t *testing.T, m *mock.Provider, cfg *config.Config) (*Server, string) {
+	t.Helper()
+	return newProviderServer(t, m, cfg)
+}
+
+// newProviderServer starts a server whose only provider is p, returning
+// its base URL.
+func newProviderServer(t *testing.T, p provider.Provider, cfg *config.Config) (*Server, string) {
+	t.Helper()
+	registry := provider.NewRegistry()
+	registry.RegisterMeta(provider.ProviderMeta{
+		ID:         p.ID(),
+		AuthMethod: auth.AuthMethodNone,
+		Factory:    func(*auth.Store) (provider.Provider, error) { return p, nil },
+	})
+	if err := registry.Initialize(nil); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(registry, cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.httpServer.Serve(ln) }()
+	t.Cleanup(func() { _ = s.httpServer.Close() })
+	return s, "http://" + ln.Addr().String()
+}
+
+// slowStream programs model to stream the given number of chunks, paced
+// by the delay of m.
+func slowStream(m *mock.Provider, model string, chunks int) {
+	programmed := make([]*api.ChatCompletionChunk, chunks)
+	for i := range programmed {
+		programmed[i] = &api.ChatCompletionChunk{ID: "1", Model: model, Choices: []api.Choice{{Delta: &api.Delta{Content: "x"}}}}
+	}
+	m.SetChunks(model, programmed)
+}
+
+func TestShutdownDrainsStreams(t *testing.T) {
+	tests := []struct {
+		name          string
+		drain         bool
+		wantCompleted bool
+	}{
+		{name: "drain", drain: true, wantCompleted: true},
+		{name: "no drain", drain: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 11 chunks 200ms apart: a 2 second stream
+			m := mock.New(mock.DelayBetweenChunks(200 * time.Millisecond))
+			slowStream(m, "slow", 11)
+			cfg := config.Load()
+			cfg.DrainStreams = tt.drain
+			cfg.DrainTimeout = 10
+			s, baseURL := newMockServer(t, m, cfg)
+
+			resp, err := http.Post(baseURL+"/v1/chat/completions", "application/json",
+				strings.NewReader(`{"model":"mock/slow","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			reader := bufio.NewReader(resp.Body)
+			if _, err := reader.ReadString('\n'); err != nil {
+				t.Fatalf("stream did not start: %v", err)
+			}
+
+			// Keep reading while the server shuts down
+			done := make(chan bool, 1)
+			go func() {
+				var body strings.Builder
+				for {
+					line, err := reader.ReadString('\n')
+					body.WriteString(line)
+					if err != nil {
+						done <- strings.Contains(body.String(), "data: [DONE]")
+						return
+					}
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			err = s.Shutdown(ctx)
+
+			if !tt.wantCompleted {
+				// Shutdown gives up on the stream, leaving it to the process exit
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("Shutdown() error = %v, want deadline exceeded", err)
+				}
+				if active := s.handlers.streams.Active(); active != 1 {
+					t.Errorf("active streams after Shutdown = %d, want 1", active)
+				}
+				<-done
+				return
+			}
+			if err != nil {
+				t.Errorf("Shutdown() error = %v", err)
+			}
+			if active := s.handlers.streams.Active(); active != 0 {
+				t.Errorf("active streams after Shutdown = %d, want 0", active)
+			}
+			if completed := <-done; !completed {
+				t.Error("stream ended without [DONE]")
+			}
+		})
+	}
+}
+
+func TestWebSocketEndpoint(t *testing.T) {
+	m := mock.New()
+	m.SetChunks("echo", []*api.ChatCompletionChunk{{ID: "1", Model: "echo", Choices: []api.Choice{{Delta: &api.Delta{Content: "hi"}}}}})
+	_, baseURL := newMockServer(t, m, config.Load())
+
+	// The upgrade goes through the middleware, which must let it hijack
+	// the connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(baseURL, "http")+"/v1/chat/completions/ws",
+		&websocket.DialOptions{Subprotocols: []string{wsstream.Subprotocol}})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer func() { _ = conn.CloseNow() }()
+
+	if err := conn.Write(ctx, websocket.MessageText, []byte(`{"model":"mock/echo","messages":[{"role":"user","content":"hi"}]}`)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	for _, want := range []string{`"content":"hi"`, wsstream.DoneMessage} {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("message = %s, want %s", data, want)
+		}
+	}
+}
+
+func TestCORSWithAPIKeyAuth(t *testing.T) {
+	const origin = "https://app.example.com"
+	m := mock.New()
+	m.SetResponse("echo", completion("echo", "hi"))
+	cfg := config.Load()
+	cfg.APIKeys = []string{"secret"}
+	cfg.CORSAllowedOrigins = []string{origin}
+	cfg.CORSAllowCredentials = true
+	_, baseURL := newMockServer(t, m, cfg)
+
+	tests := []struct {
+		name       string
+		method     string
+		key        string
+		wantStatus int
+	}{
+		{name: "preflight skips auth", method: http.MethodOptions, wantStatus: http.StatusNoContent},
+		{name: "unauthorized", method: http.MethodPost, wantStatus: http.StatusUnauthorized},
+		{name: "authorized", method: http.MethodPost, key: "secret", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, baseURL+"/v1/chat/completions",
+				strings.NewReader(`{"model":"mock/echo","messages":[{"role":"user","content":"hi"}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Origin", origin)
+			req.Header.Set("Content-Type", "application/json")
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			// Browsers can only read errors that carry CORS headers
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); got != origin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, origin)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+			}
+		})
+	}
+}
+
+func TestCORSWildcardWithCredentialsRejected(t *testing.T) {
+	cfg := config.Load()
+	cfg.CORSAllowCredentials = true
+	if _, err := New(provider.NewRegistry(), cfg); err == nil || !strings.Contains(err.Error(), "invalid CORS configuration") {
+		t.Errorf("New() error = %v, want invalid CORS configuration", err)
+	}
+}
@@ -6,55 +6,41 @@ import (
 	"net/http"
 
 	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/httputil"
+	"github.com/edgard/opencompat/internal/provider/middleware"
 )
 
 // SSEWriter helps write SSE events to the client.
 type SSEWriter struct {
-	w       http.ResponseWriter
-	flusher http.Flusher
+	w     *httputil.SSEResponseWriter
+	strip *middleware.ResponseFieldStripper // nil leaves chunks unchanged
 }
 
 // NewSSEWriter creates a new SSE writer.
 func NewSSEWriter(w http.ResponseWriter) (*SSEWriter, error) {
-	flusher, ok := w.(http.Flusher)
-	if !ok {
+	if _, ok := w.(http.Flusher); !ok {
 		return nil, fmt.Errorf("streaming not supported")
 	}
 
-	// Set SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
-
-	return &SSEWriter{w: w, flusher: flusher}, nil
+	// SSE headers are set and each event is flushed by the response writer
+	return &SSEWriter{w: httputil.NewSSEResponseWriter(w)}, nil
 }
 
 // WriteChunk writes a chat completion chunk as an SSE event.
 func (s *SSEWriter) WriteChunk(chunk *api.ChatCompletionChunk) error {
-	data, err := json.Marshal(chunk)
+	data, err := s.strip.Marshal(chunk)
 	if err != nil {
 		return err
 	}
 
 	_, err = fmt.Fprintf(s.w, "data: %s\n\n", data)
-	if err != nil {
-		return err
-	}
-
-	s.flusher.Flush()
-	return nil
+	return err
 }
 
 // WriteDone writes the [DONE] marker.
 func (s *SSEWriter) WriteDone() error {
 	_, err := fmt.Fprint(s.w, "data: [DONE]\n\n")
-	if err != nil {
-		return err
-	}
-
-	s.flusher.Flush()
-	return nil
+	return err
 }
 
 // WriteError writes an error as an SSE event.
@@ -72,10 +58,5 @@ func (s *SSEWriter) WriteError(message string) error {
 	}
 
 	_, err = fmt.Fprintf(s.w, "data: %s\n\n", data)
-	if err != nil {
-		return err
-	}
-
-	s.flusher.Flush()
-	return nil
+	return err
 }
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// StreamTracker counts in-flight streaming responses so shutdown can wait
+// for them to finish.
+type StreamTracker struct {
+	wg     sync.WaitGroup
+	active atomic.Int64
+}
+
+// Start records the beginning of a stream. Every call must be paired with Done.
+func (t *StreamTracker) Start() {
+	t.wg.Add(1)
+	t.active.Add(1)
+}
+
+// Done records the end of a stream.
+func (t *StreamTracker) Done() {
+	t.active.Add(-1)
+	t.wg.Done()
+}
+
+// Active returns the number of streams in flight.
+func (t *StreamTracker) Active() int64 {
+	return t.active.Load()
+}
+
+// Wait blocks until all streams have finished or ctx is done.
+func (t *StreamTracker) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
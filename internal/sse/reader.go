@@ -19,8 +19,10 @@ type Event struct {
 
 // Reader reads SSE events from an HTTP response.
 type Reader struct {
-	reader *bufio.Reader
-	done   bool
+	reader    *bufio.Reader
+	done      bool
+	comments  int
+	lastRetry int
 }
 
 // NewReader creates a new SSE reader.
@@ -30,7 +32,21 @@ func NewReader(r io.Reader) *Reader {
 	}
 }
 
-// ReadEvent reads the next SSE event.
+// Comments returns the number of comment lines (": ..."), typically
+// keep-alive heartbeats, skipped so far.
+func (r *Reader) Comments() int {
+	return r.comments
+}
+
+// LastRetryMs returns the reconnection delay from the most recent retry:
+// field in milliseconds, or 0 if the server hasn't sent one.
+func (r *Reader) LastRetryMs() int {
+	return r.lastRetry
+}
+
+// ReadEvent reads the next SSE event. Comment lines are counted and
+// skipped, and never produce an event. A final event cut off before its
+// empty line is still returned.
 func (r *Reader) ReadEvent() (*Event, error) {
 	if r.done {
 		return nil, io.EOF
@@ -41,32 +57,26 @@ func (r *Reader) ReadEvent() (*Event, error) {
 
 	for {
 		line, err := r.reader.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				r.done = true
-				if len(dataLines) > 0 {
-					// Process any remaining data
-					break
-				}
-			}
+		if err != nil && err != io.EOF {
 			return nil, err
 		}
+		atEOF := err == io.EOF
+		if atEOF {
+			r.done = true
+		}
 
 		line = strings.TrimSuffix(line, "\n")
 		line = strings.TrimSuffix(line, "\r")
 
-		// Empty line signals end of event
-		if line == "" {
-			if event.Event != "" || len(dataLines) > 0 {
-				break
-			}
-			continue
-		}
-
-		// Parse field
-		if strings.HasPrefix(line, "event:") {
+		switch {
+		case line == "":
+			// Empty line signals end of event
+		case strings.HasPrefix(line, ":"):
+			// Comments carry no event data; servers send them as heartbeats
+			r.comments++
+		case strings.HasPrefix(line, "event:"):
 			event.Event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
-		} else if strings.HasPrefix(line, "data:") {
+		case strings.HasPrefix(line, "data:"):
 			data := strings.TrimPrefix(line, "data:")
 			data = strings.TrimSpace(data)
 			if data == "[DONE]" {
@@ -74,14 +84,25 @@ func (r *Reader) ReadEvent() (*Event, error) {
 				return nil, io.EOF
 			}
 			dataLines = append(dataLines, data)
-		} else if strings.HasPrefix(line, "id:") {
+		case strings.HasPrefix(line, "id:"):
 			event.ID = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
-		} else if strings.HasPrefix(line, "retry:") {
+		case strings.HasPrefix(line, "retry:"):
 			if v, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "retry:"))); err == nil {
 				event.Retry = v
+				r.lastRetry = v
+			}
+		}
+
+		// An event ends at an empty line, or unterminated at the end of
+		// the stream
+		if line == "" || atEOF {
+			if event.Event != "" || len(dataLines) > 0 {
+				break
+			}
+			if atEOF {
+				return nil, io.EOF
 			}
 		}
-		// Ignore comments (lines starting with :)
 	}
 
 	// Combine data lines
@@ -0,0 +1,123 @@
+package sse
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readAll reads every event from input.
+func readAll(t *testing.T, input string) (*Reader, []Event) {
+	t.Helper()
+	r := NewReader(strings.NewReader(input))
+	var events []Event
+	for {
+		event, err := r.ReadEvent()
+		if errors.Is(err, io.EOF) {
+			return r, events
+		}
+		if err != nil {
+			t.Fatalf("ReadEvent() error = %v", err)
+		}
+		events = append(events, *event)
+	}
+}
+
+func TestReadEvent(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		want         []Event
+		wantComments int
+		wantRetry    int
+	}{
+		{
+			name:  "data events",
+			input: "data: {\"a\":1}\n\ndata: {\"b\":2}\n\n",
+			want:  []Event{{Data: []byte(`{"a":1}`)}, {Data: []byte(`{"b":2}`)}},
+		},
+		{
+			name:  "multi-line data and fields",
+			input: "event: message\nid: 7\ndata: {\"a\":\ndata: 1}\n\n",
+			want:  []Event{{Event: "message", ID: "7", Data: []byte("{\"a\":\n1}")}},
+		},
+		{
+			name:         "comment heartbeats",
+			input:        ": ping\n\n:keep-alive\ndata: {}\n\n: ping\n\n",
+			want:         []Event{{Data: []byte(`{}`)}},
+			wantComments: 3,
+		},
+		{
+			name:      "retry field",
+			input:     "retry: 3000\ndata: {}\n\n",
+			want:      []Event{{Data: []byte(`{}`), Retry: 3000}},
+			wantRetry: 3000,
+		},
+		{
+			name:      "retry before an event",
+			input:     "retry: 1500\n\ndata: {}\n\n",
+			want:      []Event{{Data: []byte(`{}`), Retry: 1500}},
+			wantRetry: 1500,
+		},
+		{
+			name:  "invalid retry ignored",
+			input: "retry: soon\ndata: {}\n\n",
+			want:  []Event{{Data: []byte(`{}`)}},
+		},
+		{
+			name:  "empty data",
+			input: "data:\n\ndata: {}\n\n",
+			want:  []Event{{Data: []byte{}}, {Data: []byte(`{}`)}},
+		},
+		{
+			name:  "named event without data",
+			input: "event: ping\n\n",
+			want:  []Event{{Event: "ping"}},
+		},
+		{
+			name:  "CRLF line endings",
+			input: "data: {}\r\n\r\n",
+			want:  []Event{{Data: []byte(`{}`)}},
+		},
+		{
+			name:  "DONE ends the stream",
+			input: "data: {}\n\ndata: [DONE]\n\ndata: {\"late\":true}\n\n",
+			want:  []Event{{Data: []byte(`{}`)}},
+		},
+		{
+			name:  "unterminated final event",
+			input: "data: {}",
+			want:  []Event{{Data: []byte(`{}`)}},
+		},
+		{
+			name:  "unterminated final line",
+			input: "data: {\"a\":1}\n\ndata: {\"b\":2}\n",
+			want:  []Event{{Data: []byte(`{"a":1}`)}, {Data: []byte(`{"b":2}`)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, events := readAll(t, tt.input)
+			if !reflect.DeepEqual(events, tt.want) {
+				t.Errorf("events = %+v, want %+v", events, tt.want)
+			}
+			if got := r.Comments(); got != tt.wantComments {
+				t.Errorf("Comments() = %d, want %d", got, tt.wantComments)
+			}
+			if got := r.LastRetryMs(); got != tt.wantRetry {
+				t.Errorf("LastRetryMs() = %d, want %d", got, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestReadEventAfterEOF(t *testing.T) {
+	r := NewReader(strings.NewReader("data: [DONE]\n\n"))
+	for range 2 {
+		if _, err := r.ReadEvent(); err != io.EOF {
+			t.Errorf("ReadEvent() error = %v, want io.EOF", err)
+		}
+	}
+}
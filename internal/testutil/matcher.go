@@ -0,0 +1,180 @@
+// Package testutil provides helpers for testing code that talks to upstream APIs.
+package testutil
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// RecordedRequest is a snapshot of an HTTP request received by a RequestMatcher.
+type RecordedRequest struct {
+	Method string
+	URL    string // Path and query, as received by the server
+	Header http.Header
+	Body   []byte
+}
+
+// RequestMatcher records HTTP requests and provides fluent assertions on them.
+// It implements http.Handler, so it can back an httptest.Server:
+//
+//	m := testutil.NewRequestMatcher(t).Respond(http.StatusOK, `{"ok":true}`)
+//	srv := httptest.NewServer(m)
+//	defer srv.Close()
+//	// ... exercise the client against srv.URL ...
+//	m.AssertMethod(http.MethodPost).
+//		AssertURL("/chat/completions").
+//		AssertHeader("Content-Type", "application/json").
+//		AssertBody("model", "gpt-4o")
+//
+// Assertions apply to the most recently recorded request.
+type RequestMatcher struct {
+	t testing.TB
+
+	mu       sync.Mutex
+	requests []RecordedRequest
+
+	status int
+	header http.Header
+	body   string
+}
+
+// NewRequestMatcher creates a matcher that reports failures to t.
+// By default it responds with 200 and an empty JSON object.
+func NewRequestMatcher(t testing.TB) *RequestMatcher {
+	return &RequestMatcher{
+		t:      t,
+		status: http.StatusOK,
+		header: http.Header{"Content-Type": []string{"application/json"}},
+		body:   "{}",
+	}
+}
+
+// Respond sets the status code and body returned for every request.
+func (m *RequestMatcher) Respond(status int, body string) *RequestMatcher {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status = status
+	m.body = body
+	return m
+}
+
+// RespondHeader sets a response header returned for every request.
+func (m *RequestMatcher) RespondHeader(key, value string) *RequestMatcher {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.header.Set(key, value)
+	return m
+}
+
+// ServeHTTP records the request and writes the configured response.
+func (m *RequestMatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		m.t.Errorf("testutil: failed to read request body: %v", err)
+	}
+
+	m.mu.Lock()
+	m.requests = append(m.requests, RecordedRequest{
+		Method: r.Method,
+		URL:    r.URL.RequestURI(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	status, respBody := m.status, m.body
+	for k, v := range m.header {
+		w.Header()[k] = v
+	}
+	m.mu.Unlock()
+
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, respBody)
+}
+
+// Requests returns a copy of all recorded requests in arrival order.
+func (m *RequestMatcher) Requests() []RecordedRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]RecordedRequest(nil), m.requests...)
+}
+
+// Last returns the most recently recorded request, failing the test if none was received.
+func (m *RequestMatcher) Last() RecordedRequest {
+	m.t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.requests) == 0 {
+		m.t.Fatalf("testutil: no requests recorded")
+	}
+	return m.requests[len(m.requests)-1]
+}
+
+// AssertMethod checks the HTTP method of the last request.
+func (m *RequestMatcher) AssertMethod(method string) *RequestMatcher {
+	m.t.Helper()
+	if got := m.Last().Method; got != method {
+		m.t.Errorf("method = %q, want %q", got, method)
+	}
+	return m
+}
+
+// AssertURL checks the path and query of the last request.
+func (m *RequestMatcher) AssertURL(u string) *RequestMatcher {
+	m.t.Helper()
+	if got := m.Last().URL; got != u {
+		m.t.Errorf("url = %q, want %q", got, u)
+	}
+	return m
+}
+
+// AssertHeader checks that the last request carried header key with the given value.
+func (m *RequestMatcher) AssertHeader(key, value string) *RequestMatcher {
+	m.t.Helper()
+	req := m.Last()
+	values, ok := req.Header[http.CanonicalHeaderKey(key)]
+	if !ok {
+		m.t.Errorf("header %q missing, want %q", key, value)
+		return m
+	}
+	if got := req.Header.Get(key); got != value {
+		m.t.Errorf("header %q = %q (all: %q), want %q", key, got, values, value)
+	}
+	return m
+}
+
+// AssertBody checks the JSON value at a gjson path in the last request body.
+// want is compared after a JSON round-trip, so Go values such as ints,
+// structs and maps match their decoded JSON equivalents.
+func (m *RequestMatcher) AssertBody(path string, want interface{}) *RequestMatcher {
+	m.t.Helper()
+	body := m.Last().Body
+	if !gjson.ValidBytes(body) {
+		m.t.Errorf("body is not valid JSON: %s", body)
+		return m
+	}
+
+	result := gjson.GetBytes(body, path)
+	if !result.Exists() {
+		m.t.Errorf("body path %q missing, want %v", path, want)
+		return m
+	}
+
+	wantJSON, err := json.Marshal(want)
+	if err != nil {
+		m.t.Fatalf("testutil: failed to marshal expected value: %v", err)
+	}
+	var wantValue interface{}
+	if err := json.Unmarshal(wantJSON, &wantValue); err != nil {
+		m.t.Fatalf("testutil: failed to normalize expected value: %v", err)
+	}
+
+	if got := result.Value(); !reflect.DeepEqual(got, wantValue) {
+		m.t.Errorf("body path %q = %s, want %s", path, result.Raw, wantJSON)
+	}
+	return m
+}
@@ -0,0 +1,161 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+// RecordedSpan is a snapshot of a span recorded by a SpanRecorder.
+type RecordedSpan struct {
+	Name       string
+	Parent     string // name of the parent span; empty for a root span
+	Attributes map[attribute.Key]attribute.Value
+	Events     []string
+	Status     codes.Code
+	Ended      bool
+}
+
+// SpanRecorder is a trace.TracerProvider that records spans in memory, so
+// tests can check instrumentation without an OpenTelemetry SDK:
+//
+//	rec := testutil.NewSpanRecorder()
+//	provider.SetTracerProvider(rec)
+//	// ... exercise the instrumented code ...
+//	span, ok := rec.Span("copilot.SendRequest")
+type SpanRecorder struct {
+	embedded.TracerProvider
+
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+// NewSpanRecorder creates an empty recorder.
+func NewSpanRecorder() *SpanRecorder {
+	return &SpanRecorder{}
+}
+
+// Tracer returns a tracer that records into r.
+func (r *SpanRecorder) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{recorder: r}
+}
+
+// Spans returns the spans started so far, in start order.
+func (r *SpanRecorder) Spans() []RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spans := make([]RecordedSpan, len(r.spans))
+	for i, s := range r.spans {
+		spans[i] = s.snapshot()
+	}
+	return spans
+}
+
+// Span returns the last started span with the given name.
+func (r *SpanRecorder) Span(name string) (RecordedSpan, bool) {
+	spans := r.Spans()
+	for i := len(spans) - 1; i >= 0; i-- {
+		if spans[i].Name == name {
+			return spans[i], true
+		}
+	}
+	return RecordedSpan{}, false
+}
+
+type recordingTracer struct {
+	embedded.Tracer
+	recorder *SpanRecorder
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	s := &recordingSpan{
+		recorder: t.recorder,
+		span:     RecordedSpan{Name: name, Attributes: map[attribute.Key]attribute.Value{}},
+	}
+	if parent, ok := trace.SpanFromContext(ctx).(*recordingSpan); ok && !cfg.NewRoot() {
+		s.span.Parent = parent.snapshot().Name
+	}
+	s.SetAttributes(cfg.Attributes()...)
+
+	t.recorder.mu.Lock()
+	t.recorder.spans = append(t.recorder.spans, s)
+	t.recorder.mu.Unlock()
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+type recordingSpan struct {
+	embedded.Span
+	recorder *SpanRecorder
+
+	mu   sync.Mutex
+	span RecordedSpan
+}
+
+func (s *recordingSpan) snapshot() RecordedSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	span := s.span
+	span.Attributes = make(map[attribute.Key]attribute.Value, len(s.span.Attributes))
+	for k, v := range s.span.Attributes {
+		span.Attributes[k] = v
+	}
+	span.Events = append([]string(nil), s.span.Events...)
+	return span
+}
+
+func (s *recordingSpan) End(...trace.SpanEndOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Ended = true
+}
+
+func (s *recordingSpan) AddEvent(name string, _ ...trace.EventOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Events = append(s.span.Events, name)
+}
+
+func (s *recordingSpan) AddLink(trace.Link) {}
+
+func (s *recordingSpan) IsRecording() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.span.Ended
+}
+
+func (s *recordingSpan) RecordError(error, ...trace.EventOption) {
+	s.AddEvent("exception")
+}
+
+func (s *recordingSpan) SpanContext() trace.SpanContext {
+	return trace.SpanContext{}
+}
+
+func (s *recordingSpan) SetStatus(code codes.Code, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Status = code
+}
+
+func (s *recordingSpan) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Name = name
+}
+
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attr := range kv {
+		s.span.Attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordingSpan) TracerProvider() trace.TracerProvider {
+	return s.recorder
+}
@@ -0,0 +1,142 @@
+// Package tokencount counts the prompt tokens of chat messages before they
+// are sent, using the model's tiktoken encoding when it is known.
+package tokencount
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+
+	"github.com/edgard/opencompat/internal/api"
+)
+
+// Per-message overhead of the chat format, from OpenAI's cookbook
+// ("How to count tokens with tiktoken").
+const (
+	tokensPerMessage = 3 // <|start|>{role}\n ... <|end|>\n
+	tokensPerName    = 1 // a name replaces the role
+	tokensPerReply   = 3 // every reply is primed with <|start|>assistant
+)
+
+// wordsPerToken converts word counts to tokens for models without a known
+// tokenizer: English prose averages about 0.75 words per token.
+const wordsPerToken = 0.75
+
+// ErrUnknownContextWindow is returned by MaxContextTokens for models whose
+// context window isn't known.
+var ErrUnknownContextWindow = errors.New("unknown context window")
+
+func init() {
+	// Encodings are embedded instead of being downloaded on first use
+	tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+}
+
+// encodings caches loaded encodings by name; loading one parses several MB.
+var encodings sync.Map // name -> *encodingOnce
+
+type encodingOnce struct {
+	once sync.Once
+	enc  *tiktoken.Tiktoken
+	err  error
+}
+
+// encoding returns the named encoding, loading it once.
+func encoding(name string) (*tiktoken.Tiktoken, error) {
+	v, _ := encodings.LoadOrStore(name, &encodingOnce{})
+	e := v.(*encodingOnce)
+	e.once.Do(func() {
+		e.enc, e.err = tiktoken.GetEncoding(name)
+	})
+	return e.enc, e.err
+}
+
+// encodingName returns the tiktoken encoding of model, or "" when the
+// model's tokenizer isn't known. Provider prefixes are ignored.
+func encodingName(model string) string {
+	model = model[strings.LastIndex(model, "/")+1:]
+	for _, prefix := range []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "o1", "o3", "o4"} {
+		if strings.HasPrefix(model, prefix) {
+			return tiktoken.MODEL_O200K_BASE
+		}
+	}
+	for _, prefix := range []string{"gpt-4", "gpt-3.5"} {
+		if strings.HasPrefix(model, prefix) {
+			return tiktoken.MODEL_CL100K_BASE
+		}
+	}
+	return ""
+}
+
+// Count returns the prompt tokens messages consume for model, including
+// the per-message overhead of the chat format. Models with a known
+// tiktoken encoding (GPT-3.5, GPT-4, GPT-4o and later GPT and o-series
+// models) are counted exactly. Other models, such as Claude or Gemini,
+// are approximated from word counts, which is typically within 20-30% for
+// English prose and less accurate for code or other languages.
+func Count(model string, messages []api.Message) (int, error) {
+	textTokens := approximate
+	if name := encodingName(model); name != "" {
+		enc, err := encoding(name)
+		if err != nil {
+			return 0, fmt.Errorf("failed to load %s encoding: %w", name, err)
+		}
+		textTokens = func(s string) int { return len(enc.EncodeOrdinary(s)) }
+	}
+
+	tokens := tokensPerReply
+	for _, msg := range messages {
+		tokens += tokensPerMessage
+		tokens += textTokens(msg.Role)
+		if msg.Name != "" {
+			tokens += tokensPerName + textTokens(msg.Name)
+		}
+		for _, part := range msg.GetContentParts() {
+			tokens += textTokens(part.Text)
+		}
+		for _, tc := range msg.ToolCalls {
+			tokens += textTokens(tc.Function.Name)
+			tokens += textTokens(tc.Function.Arguments)
+		}
+	}
+	return tokens, nil
+}
+
+// approximate estimates the tokens of s from its word count, rounding up.
+func approximate(s string) int {
+	words := len(strings.Fields(s))
+	if words == 0 {
+		return 0
+	}
+	return int(float64(words)/wordsPerToken + 0.999)
+}
+
+// models lists the models whose metadata MaxContextTokens consults.
+var models atomic.Pointer[func() []api.Model]
+
+// SetModels sets the source of model metadata for MaxContextTokens,
+// typically Registry.AllModels so cached provider model lists are used.
+func SetModels(list func() []api.Model) {
+	models.Store(&list)
+}
+
+// MaxContextTokens returns the context window of model from the model
+// metadata set with SetModels. model may be prefixed with its provider;
+// unprefixed IDs match the first provider that lists the model with a
+// known context window.
+func MaxContextTokens(model string) (int, error) {
+	list := models.Load()
+	if list == nil {
+		return 0, fmt.Errorf("%w for model %s: no model metadata", ErrUnknownContextWindow, model)
+	}
+	for _, m := range (*list)() {
+		if m.ContextWindow > 0 && (m.ID == model || strings.HasSuffix(m.ID, "/"+model)) {
+			return m.ContextWindow, nil
+		}
+	}
+	return 0, fmt.Errorf("%w for model %s", ErrUnknownContextWindow, model)
+}
@@ -0,0 +1,295 @@
+// Package wsstream serves chat completions over WebSocket, as a
+// bidirectional alternative to the SSE streaming endpoint.
+//
+// A client connects with the "opencompat-v1" subprotocol and sends chat
+// completion requests as JSON text messages, using the same body as
+// POST /v1/chat/completions. Each request is answered with one JSON text
+// message per api.ChatCompletionChunk, followed by a "[DONE]" message, as
+// on the SSE stream. Failed requests get a single api.ErrorResponse
+// message before "[DONE]"; the connection stays open for further requests.
+package wsstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/coder/websocket"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// Subprotocol is the WebSocket subprotocol clients must request.
+const Subprotocol = "opencompat-v1"
+
+// DoneMessage ends the response to a request.
+const DoneMessage = "[DONE]"
+
+// maxMessageSize matches the request body limit of the HTTP endpoint.
+const maxMessageSize = 10 * 1024 * 1024
+
+// Handler returns the WebSocket endpoint, serving requests with the
+// providers of registry. Requests are handled one at a time per
+// connection and always streamed.
+func Handler(registry *provider.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+			Subprotocols: []string{Subprotocol},
+		})
+		if err != nil {
+			// Accept has written the error response
+			slog.Debug("websocket upgrade failed", "error", err)
+			return
+		}
+		defer func() { _ = conn.CloseNow() }()
+
+		if conn.Subprotocol() != Subprotocol {
+			_ = conn.Close(websocket.StatusPolicyViolation, "subprotocol "+Subprotocol+" required")
+			return
+		}
+		conn.SetReadLimit(maxMessageSize)
+
+		s := &session{registry: registry, conn: conn}
+		ctx := r.Context()
+		for {
+			typ, data, err := conn.Read(ctx)
+			if err != nil {
+				if status := websocket.CloseStatus(err); status != websocket.StatusNormalClosure && status != websocket.StatusGoingAway {
+					slog.Debug("websocket read failed", "error", err)
+				}
+				return
+			}
+			if typ != websocket.MessageText {
+				err = s.writeError(ctx, badRequest("requests must be sent as text messages", ""))
+			} else {
+				err = s.serve(ctx, data)
+			}
+			if err != nil {
+				slog.Debug("websocket write failed", "error", err)
+				return
+			}
+		}
+	})
+}
+
+// session is one client connection.
+type session struct {
+	registry *provider.Registry
+	conn     *websocket.Conn
+}
+
+// serve answers one request message. It returns an error only when the
+// connection can no longer be written to.
+func (s *session) serve(ctx context.Context, data []byte) error {
+	req, errResp := s.parse(data)
+	if errResp != nil {
+		return s.writeError(ctx, errResp)
+	}
+
+	stream, err := s.registry.WithCircuitBreaker(req.provider).ChatCompletion(ctx, req.req)
+	if err != nil {
+		return s.writeError(ctx, errorResponse(err, "Failed to send request: "))
+	}
+	defer func() { _ = stream.Close() }()
+
+	sent := 0
+	for {
+		chunk, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return s.writeError(ctx, errorResponse(err, "Stream read error: "))
+		}
+		if err := s.writeJSON(ctx, chunk); err != nil {
+			return err
+		}
+		sent++
+	}
+	if err := stream.Err(); err != nil {
+		return s.writeError(ctx, errorResponse(err, "Upstream error: "))
+	}
+
+	// Providers that cannot stream only produce the accumulated response
+	if sent == 0 {
+		resp := stream.Response()
+		if resp == nil || resp.ID == "" {
+			return s.writeError(ctx, serverError("No response received from upstream"))
+		}
+		for _, chunk := range api.ResponseToChunks(resp, req.includeUsage) {
+			if err := s.writeJSON(ctx, &chunk); err != nil {
+				return err
+			}
+		}
+	}
+	return s.conn.Write(ctx, websocket.MessageText, []byte(DoneMessage))
+}
+
+// request is a validated request message.
+type request struct {
+	req          *provider.ChatCompletionRequest
+	provider     provider.Provider
+	includeUsage bool // client asked for a usage chunk
+}
+
+// parse validates a request message and resolves its provider, returning
+// the error to send back on failure.
+func (s *session) parse(data []byte) (*request, *api.ErrorResponse) {
+	var req api.ChatCompletionRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, badRequest("Invalid JSON: "+err.Error(), "")
+	}
+	if err := req.NormalizeFunctions(); err != nil {
+		return nil, badRequest(err.Error(), "")
+	}
+	if req.Model == "" {
+		return nil, badRequest("model is required", "model")
+	}
+
+	p, modelID, err := s.registry.GetProvider(req.Model)
+	if err != nil || !s.registry.IsModelSupported(req.Model) {
+		code := "model_not_found"
+		return nil, &api.ErrorResponse{Error: api.ErrorDetail{
+			Message: "The model `" + req.Model + "` does not exist or you do not have access to it.",
+			Type:    api.ErrorTypeNotFound,
+			Code:    &code,
+		}}
+	}
+
+	if len(req.Messages) == 0 {
+		return nil, badRequest("messages is required", "messages")
+	}
+	for i, msg := range req.Messages {
+		switch msg.Role {
+		case "system", "user", "assistant", "tool":
+		default:
+			return nil, badRequest(
+				fmt.Sprintf("Invalid role '%s'. Must be one of: system, user, assistant, tool", msg.Role),
+				fmt.Sprintf("messages[%d].role", i))
+		}
+		if msg.Role == "tool" && msg.ToolCallID == "" {
+			return nil, badRequest("Tool messages must include tool_call_id",
+				fmt.Sprintf("messages[%d].tool_call_id", i))
+		}
+	}
+
+	// Providers that cannot stream get a buffered request; its response is
+	// split into chunks by serve
+	stream := true
+	if sc, ok := p.(provider.StreamingCapability); ok {
+		stream = sc.StreamingSupported()
+	}
+	streamOptions := req.StreamOptions
+	if !stream {
+		streamOptions = nil
+	}
+
+	providerReq := &provider.ChatCompletionRequest{
+		Model:               modelID,
+		Messages:            req.Messages,
+		Tools:               req.Tools,
+		ToolChoice:          req.ToolChoice,
+		Stream:              stream,
+		StreamOptions:       streamOptions,
+		ReasoningEffort:     req.ReasoningEffort,
+		N:                   req.N,
+		Temperature:         req.Temperature,
+		TopP:                req.TopP,
+		MaxTokens:           req.MaxTokens,
+		MaxCompletionTokens: req.MaxCompletionTokens,
+		Stop:                req.Stop,
+		PresencePenalty:     req.PresencePenalty,
+		FrequencyPenalty:    req.FrequencyPenalty,
+		Seed:                req.Seed,
+		Logprobs:            req.Logprobs,
+		TopLogprobs:         req.TopLogprobs,
+		ResponseFormat:      req.ResponseFormat,
+		ParallelToolCalls:   req.ParallelToolCalls,
+		Modalities:          req.Modalities,
+		AudioConfig:         req.AudioConfig,
+	}
+	return &request{
+		req:          providerReq,
+		provider:     p,
+		includeUsage: req.StreamOptions != nil && req.StreamOptions.IncludeUsage,
+	}, nil
+}
+
+// writeJSON sends v as a text message.
+func (s *session) writeJSON(ctx context.Context, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.conn.Write(ctx, websocket.MessageText, data)
+}
+
+// writeError sends resp followed by DoneMessage.
+func (s *session) writeError(ctx context.Context, resp *api.ErrorResponse) error {
+	if err := s.writeJSON(ctx, resp); err != nil {
+		return err
+	}
+	return s.conn.Write(ctx, websocket.MessageText, []byte(DoneMessage))
+}
+
+func badRequest(message, param string) *api.ErrorResponse {
+	resp := &api.ErrorResponse{Error: api.ErrorDetail{Message: message, Type: api.ErrorTypeInvalidRequest}}
+	if param != "" {
+		resp.Error.Param = &param
+	}
+	return resp
+}
+
+func serverError(message string) *api.ErrorResponse {
+	return &api.ErrorResponse{Error: api.ErrorDetail{Message: message, Type: api.ErrorTypeServer}}
+}
+
+// errorResponse maps a provider error to the error type the HTTP endpoint
+// would respond with.
+func errorResponse(err error, prefix string) *api.ErrorResponse {
+	var ctxErr *api.ErrContextLengthExceeded
+	if errors.As(err, &ctxErr) {
+		code := "context_length_exceeded"
+		resp := badRequest(ctxErr.Message, "messages")
+		resp.Error.Code = &code
+		resp.Error.Extensions = map[string]any{
+			"prompt_tokens": ctxErr.PromptTokens,
+			"model_limit":   ctxErr.ModelLimit,
+		}
+		return resp
+	}
+	var upstreamErr *api.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		errType := api.ErrorTypeServer
+		switch upstreamErr.StatusCode {
+		case http.StatusBadRequest:
+			errType = api.ErrorTypeInvalidRequest
+		case http.StatusUnauthorized, http.StatusForbidden:
+			errType = api.ErrorTypeAuthentication
+		case http.StatusNotFound:
+			errType = api.ErrorTypeNotFound
+		case http.StatusTooManyRequests:
+			errType = api.ErrorTypeRateLimit
+		case http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusGatewayTimeout:
+			errType = api.ErrorTypeServiceUnavailable
+		}
+		return &api.ErrorResponse{Error: api.ErrorDetail{Message: upstreamErr.Message, Type: errType}}
+	}
+	var paramErr *provider.ParameterNotSupportedError
+	if errors.As(err, &paramErr) {
+		return badRequest(paramErr.Error(), paramErr.Param)
+	}
+	if errors.Is(err, provider.ErrCircuitOpen) {
+		return &api.ErrorResponse{Error: api.ErrorDetail{Message: err.Error(), Type: api.ErrorTypeServiceUnavailable}}
+	}
+	if errors.Is(err, auth.ErrCredentialExpired) {
+		return &api.ErrorResponse{Error: api.ErrorDetail{Message: err.Error(), Type: api.ErrorTypeAuthentication}}
+	}
+	return serverError(prefix + err.Error())
+}
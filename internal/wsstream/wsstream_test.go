@@ -0,0 +1,233 @@
+package wsstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/edgard/opencompat/internal/api"
+	"github.com/edgard/opencompat/internal/auth"
+	"github.com/edgard/opencompat/internal/provider"
+	"github.com/edgard/opencompat/internal/provider/mock"
+)
+
+// newTestServer serves Handler with m as the only provider and returns its
+// ws:// URL.
+func newTestServer(t *testing.T, m *mock.Provider) string {
+	t.Helper()
+	registry := provider.NewRegistry()
+	registry.RegisterMeta(provider.ProviderMeta{
+		ID:         m.ID(),
+		AuthMethod: auth.AuthMethodNone,
+		Factory:    func(*auth.Store) (provider.Provider, error) { return m, nil },
+	})
+	if err := registry.Initialize(nil); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(Handler(registry))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// dial connects to url with the given subprotocols.
+func dial(t *testing.T, url string, subprotocols ...string) *websocket.Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{Subprotocols: subprotocols})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { _ = conn.CloseNow() })
+	return conn
+}
+
+// exchange sends request and returns the messages received up to and
+// excluding DoneMessage.
+func exchange(t *testing.T, conn *websocket.Conn, request string) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := conn.Write(ctx, websocket.MessageText, []byte(request)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	var messages []string
+	for {
+		typ, data, err := conn.Read(ctx)
+		if err != nil {
+			t.Fatalf("Read() error = %v after %q", err, messages)
+		}
+		if typ != websocket.MessageText {
+			t.Fatalf("message type = %v, want text", typ)
+		}
+		if string(data) == DoneMessage {
+			return messages
+		}
+		messages = append(messages, string(data))
+	}
+}
+
+// decodeError decodes message as an error response.
+func decodeError(t *testing.T, message string) api.ErrorDetail {
+	t.Helper()
+	var resp api.ErrorResponse
+	if err := json.Unmarshal([]byte(message), &resp); err != nil || resp.Error.Message == "" {
+		t.Fatalf("message %s is not an error response (%v)", message, err)
+	}
+	return resp.Error
+}
+
+func chatRequest(model string) string {
+	return `{"model":"` + model + `","messages":[{"role":"user","content":"hi"}]}`
+}
+
+func TestStreamChunks(t *testing.T) {
+	m := mock.New()
+	m.SetChunks("echo", []*api.ChatCompletionChunk{
+		{ID: "1", Model: "echo", Choices: []api.Choice{{Delta: &api.Delta{Role: "assistant", Content: "he"}}}},
+		{ID: "1", Model: "echo", Choices: []api.Choice{{Delta: &api.Delta{Content: "llo"}}}},
+	})
+	conn := dial(t, newTestServer(t, m), Subprotocol)
+	if got := conn.Subprotocol(); got != Subprotocol {
+		t.Fatalf("Subprotocol() = %q, want %q", got, Subprotocol)
+	}
+
+	messages := exchange(t, conn, chatRequest("mock/echo"))
+	var content strings.Builder
+	for _, message := range messages {
+		var chunk api.ChatCompletionChunk
+		if err := json.Unmarshal([]byte(message), &chunk); err != nil {
+			t.Fatalf("message %s is not a chunk: %v", message, err)
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+	}
+	if len(messages) != 2 || content.String() != "hello" {
+		t.Errorf("messages = %q, want two chunks with content hello", messages)
+	}
+
+	invocations := m.Invocations()
+	if len(invocations) != 1 || !invocations[0].Stream || invocations[0].Model != "echo" {
+		t.Errorf("invocations = %+v, want one streaming request for echo", invocations)
+	}
+}
+
+func TestNonStreamingProvider(t *testing.T) {
+	stop := "stop"
+	message := api.AssistantMessage("hello")
+	m := mock.New(mock.WithoutStreaming())
+	m.SetResponse("echo", &api.ChatCompletionResponse{
+		ID:      "1",
+		Object:  "chat.completion",
+		Model:   "echo",
+		Choices: []api.Choice{{Message: &message, FinishReason: &stop}},
+		Usage:   &api.Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2},
+	})
+	conn := dial(t, newTestServer(t, m), Subprotocol)
+
+	messages := exchange(t, conn, `{"model":"mock/echo","messages":[{"role":"user","content":"hi"}],"stream_options":{"include_usage":true}}`)
+	if len(messages) != 3 {
+		t.Fatalf("messages = %q, want content, finish and usage chunks", messages)
+	}
+	if !strings.Contains(messages[0], `"content":"hello"`) || !strings.Contains(messages[2], `"total_tokens":2`) {
+		t.Errorf("messages = %q, want the response split into chunks", messages)
+	}
+	if invocations := m.Invocations(); len(invocations) != 1 || invocations[0].Stream || invocations[0].StreamOptions != nil {
+		t.Errorf("invocations = %+v, want one buffered request without stream options", invocations)
+	}
+}
+
+func TestSubprotocolRequired(t *testing.T) {
+	conn := dial(t, newTestServer(t, mock.New()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _, err := conn.Read(ctx)
+	if status := websocket.CloseStatus(err); status != websocket.StatusPolicyViolation {
+		t.Errorf("Read() error = %v, want close status %v", err, websocket.StatusPolicyViolation)
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	m := mock.New()
+	m.SetChunks("echo", []*api.ChatCompletionChunk{{ID: "1", Model: "echo", Choices: []api.Choice{{Delta: &api.Delta{Content: "ok"}}}}})
+	m.SetError("limited", api.NewUpstreamError(http.StatusTooManyRequests, "slow down"))
+
+	tests := []struct {
+		name     string
+		request  string
+		wantType string
+		wantText string
+	}{
+		{name: "invalid JSON", request: `{`, wantType: api.ErrorTypeInvalidRequest, wantText: "Invalid JSON"},
+		{name: "missing model", request: `{"messages":[{"role":"user","content":"hi"}]}`, wantType: api.ErrorTypeInvalidRequest, wantText: "model is required"},
+		{name: "unknown model", request: chatRequest("mock/missing"), wantType: api.ErrorTypeNotFound, wantText: "mock/missing"},
+		{name: "invalid role", request: `{"model":"mock/echo","messages":[{"role":"robot","content":"hi"}]}`, wantType: api.ErrorTypeInvalidRequest, wantText: "Invalid role 'robot'"},
+		{name: "upstream error", request: chatRequest("mock/limited"), wantType: api.ErrorTypeRateLimit, wantText: "slow down"},
+	}
+	conn := dial(t, newTestServer(t, m), Subprotocol)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := exchange(t, conn, tt.request)
+			if len(messages) != 1 {
+				t.Fatalf("messages = %q, want one error", messages)
+			}
+			detail := decodeError(t, messages[0])
+			if detail.Type != tt.wantType || !strings.Contains(detail.Message, tt.wantText) {
+				t.Errorf("error = %+v, want type %s containing %q", detail, tt.wantType, tt.wantText)
+			}
+		})
+	}
+
+	// Errors leave the connection usable
+	if messages := exchange(t, conn, chatRequest("mock/echo")); len(messages) != 1 || !strings.Contains(messages[0], `"content":"ok"`) {
+		t.Errorf("messages after errors = %q, want the echo chunk", messages)
+	}
+}
+
+func TestBinaryMessageRejected(t *testing.T) {
+	conn := dial(t, newTestServer(t, mock.New()), Subprotocol)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := conn.Write(ctx, websocket.MessageBinary, []byte(chatRequest("mock/echo"))); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	_, data, err := conn.Read(ctx)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if detail := decodeError(t, string(data)); !strings.Contains(detail.Message, "text messages") {
+		t.Errorf("error = %+v, want text messages required", detail)
+	}
+	if _, data, err := conn.Read(ctx); err != nil || string(data) != DoneMessage {
+		t.Errorf("Read() = %q, %v, want %s", data, err, DoneMessage)
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantType string
+	}{
+		{"unauthorized", api.NewUpstreamError(http.StatusUnauthorized, "no"), api.ErrorTypeAuthentication},
+		{"unavailable", api.NewUpstreamError(http.StatusBadGateway, "down"), api.ErrorTypeServiceUnavailable},
+		{"circuit open", provider.ErrCircuitOpen, api.ErrorTypeServiceUnavailable},
+		{"credential expired", auth.ErrCredentialExpired, api.ErrorTypeAuthentication},
+		{"other", errors.New("boom"), api.ErrorTypeServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorResponse(tt.err, "prefix: ").Error.Type; got != tt.wantType {
+				t.Errorf("errorResponse(%v) type = %s, want %s", tt.err, got, tt.wantType)
+			}
+		})
+	}
+}
@@ -7,12 +7,14 @@ package main
 import (
 	"bufio"
 	"context"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -21,9 +23,16 @@ import (
 	"github.com/edgard/opencompat/internal/auth"
 	"github.com/edgard/opencompat/internal/config"
 	"github.com/edgard/opencompat/internal/logging"
+	"github.com/edgard/opencompat/internal/migrate"
+	"github.com/edgard/opencompat/internal/modelalias"
 	"github.com/edgard/opencompat/internal/provider"
-	_ "github.com/edgard/opencompat/internal/provider/chatgpt" // Register chatgpt provider
-	_ "github.com/edgard/opencompat/internal/provider/copilot" // Register copilot provider
+	_ "github.com/edgard/opencompat/internal/provider/azureopenai" // Register azure provider
+	_ "github.com/edgard/opencompat/internal/provider/chatgpt"     // Register chatgpt provider
+	_ "github.com/edgard/opencompat/internal/provider/claude"      // Register claude provider
+	_ "github.com/edgard/opencompat/internal/provider/copilot"     // Register copilot provider
+	_ "github.com/edgard/opencompat/internal/provider/gemini"      // Register gemini provider
+	_ "github.com/edgard/opencompat/internal/provider/mistral"     // Register mistral provider
+	_ "github.com/edgard/opencompat/internal/provider/ollama"      // Register ollama provider
 	"github.com/edgard/opencompat/internal/server"
 )
 
@@ -39,12 +48,15 @@ Usage:
   opencompat [command]
 
 Commands:
-  login <provider>    Authenticate with a provider (e.g., chatgpt)
-  logout <provider>   Remove credentials for a provider
+  login <provider>    Authenticate with a provider (e.g., chatgpt); --account <alias> adds another account
+  logout <provider>   Remove credentials for a provider (--account <alias> for another account)
+  accounts <provider> List a provider's accounts; --use <alias> switches the active one
+  apikey add          Generate an API key clients must present to the server
   info                Show authentication status for all providers
   models              List all supported providers and models
+  migrate             Convert a model alias file to the current format (--input <file> --output <file>)
   serve               Start the API server (default)
-  version             Show version information
+  version             Show version, build and dependency information
   help                Show this help message
 `
 
@@ -68,6 +80,8 @@ func buildUsage() string {
 			authDesc = "API key"
 		case auth.AuthMethodDeviceFlow:
 			authDesc = "GitHub device login"
+		case auth.AuthMethodNone:
+			authDesc = "no login required"
 		}
 		sb.WriteString(fmt.Sprintf("  %-19s %s (%s)\n", meta.ID, meta.Name, authDesc))
 	}
@@ -78,6 +92,37 @@ func buildUsage() string {
 	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_PORT", "Server listen port", "8080"))
 	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_LOG_LEVEL", "Log level (debug, info, warn, error)", "info"))
 	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_LOG_FORMAT", "Log format (text, json)", "text"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_DISABLE_STREAMING_FALLBACK", "Fail streaming requests to non-streaming providers", "false"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_CLAMP_PARAMETERS", "Clamp sampling parameters to provider ranges", "true"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_REJECT_OUT_OF_BOUNDS_PARAMETERS", "Reject out-of-range sampling parameters", "false"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_USAGE_WEBHOOK_URL", "Webhook URL receiving per-request usage events", "none"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_RESPONSE_TRANSFORM_SCRIPT", "Jsonnet script applied to every response", "none"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_RESPONSE_STRIP_FIELDS", "Comma-separated JSON paths removed from responses", "none"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_JSON_MODE_ENFORCEMENT", "json_object validation (passthrough, strict, retry)", "passthrough"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_GRACEFUL_SHUTDOWN_DRAIN_STREAMS", "Wait for active streams on shutdown", "false"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_GRACEFUL_SHUTDOWN_DRAIN_TIMEOUT", "Stream drain timeout in seconds", "120"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_CONCURRENT_PROVIDERS_RACE", "Race all providers serving the model", "false"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_PREPARE_TIMEOUT", "Provider request preparation timeout in seconds", "10"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_ENABLED_PROVIDERS", "Comma-separated provider IDs to serve", "all"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_CIRCUIT_BREAKER_THRESHOLD", "Consecutive failures that pause a provider (0 disables)", "5"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_CIRCUIT_BREAKER_WINDOW", "Circuit breaker failure window in seconds", "60"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_CIRCUIT_BREAKER_COOLDOWN", "Circuit breaker cool-down in seconds", "30"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_STREAMING_CHUNK_DELAY", "Pause between streamed chunks, e.g. 50ms", "0"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_STREAMING_CHUNK_DELAY_JITTER", "Random extra pause between streamed chunks", "0"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_PASSTHROUGH_HEADERS", "Comma-separated request headers forwarded upstream", "none"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_MODEL_ALIASES_FILE", "YAML file mapping model aliases to provider/model", "built-in aliases"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_PROVIDER_CONFIG_FILE", "Env file of provider settings reloaded on change", "none"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_TLS_CERT_FILE", "PEM server certificate; enables HTTPS", "none"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_TLS_KEY_FILE", "PEM server private key", "none"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_TLS_CLIENT_CA_CERT", "PEM CA bundle for client certificates", "none"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_TLS_CLIENT_AUTH", "Client certificates (none, request, require, verify)", "none"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_TLS_OCSP_STAPLE_FILE", "DER OCSP response stapled to handshakes", "none"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_API_KEYS", "Comma-separated API keys required by the server", "none"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_CORS_ALLOWED_ORIGINS", "Comma-separated origins allowed by CORS", "*"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_CORS_ALLOWED_METHODS", "Methods announced in CORS preflights", "GET, POST, OPTIONS"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_CORS_ALLOWED_HEADERS", "Request headers announced in CORS preflights", "Content-Type, Authorization, Accept, OpenAI-Beta"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_CORS_ALLOW_CREDENTIALS", "Allow credentialed CORS requests (needs listed origins)", "false"))
+	sb.WriteString(fmt.Sprintf("  %-44s %s (default: %s)\n", "OPENCOMPAT_CORS_MAX_AGE", "CORS preflight cache lifetime in seconds", "86400"))
 
 	// Provider-specific environment variables
 	for _, meta := range metas {
@@ -146,14 +191,20 @@ func main() {
 		cmdLogin()
 	case "logout":
 		cmdLogout()
+	case "accounts":
+		cmdAccounts()
+	case "apikey":
+		cmdAPIKey()
 	case "info":
 		cmdInfo()
 	case "models":
 		cmdModels()
+	case "migrate":
+		cmdMigrate()
 	case "serve":
 		cmdServe()
 	case "version", "-v", "--version":
-		fmt.Printf("opencompat %s (commit: %s, built: %s)\n", version, commit, date)
+		cmdVersion()
 	case "help", "-h", "--help":
 		fmt.Print(buildUsage())
 	default:
@@ -175,6 +226,7 @@ func cmdLogin() {
 	}
 
 	providerID := strings.ToLower(os.Args[2])
+	alias := parseAccountFlag("login", os.Args[3:])
 	store := auth.NewStore()
 	registry := provider.NewRegistry()
 	provider.RegisterAll(registry)
@@ -189,15 +241,20 @@ func cmdLogin() {
 		os.Exit(1)
 	}
 
+	// Credentials are saved under the account's key
+	key := auth.AccountKey(providerID, alias)
+
 	// Perform login based on auth method
 	switch meta.AuthMethod {
+	case auth.AuthMethodNone:
+		fmt.Printf("%s does not require login.\n", providerID)
 	case auth.AuthMethodOAuth:
-		if err := auth.PerformOAuthLogin(store, providerID, meta.OAuthCfg); err != nil {
+		if err := auth.PerformOAuthLogin(store, key, meta.OAuthCfg); err != nil {
 			fmt.Fprintf(os.Stderr, "Login failed: %v\n", err)
 			os.Exit(1)
 		}
 	case auth.AuthMethodDeviceFlow:
-		if err := auth.PerformDeviceFlowLogin(store, providerID, meta.DeviceFlowCfg); err != nil {
+		if err := auth.PerformDeviceFlowLogin(store, key, meta.DeviceFlowCfg, deviceFlowCallbacks()); err != nil {
 			fmt.Fprintf(os.Stderr, "Login failed: %v\n", err)
 			os.Exit(1)
 		}
@@ -218,7 +275,7 @@ func cmdLogin() {
 			APIKey:    apiKey,
 			CreatedAt: time.Now(),
 		}
-		if err := store.SaveAPIKeyCredentials(providerID, creds); err != nil {
+		if err := store.SaveAPIKeyCredentials(key, creds); err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to save credentials: %v\n", err)
 			os.Exit(1)
 		}
@@ -227,6 +284,101 @@ func cmdLogin() {
 		fmt.Fprintf(os.Stderr, "Unsupported auth method for provider: %s\n", providerID)
 		os.Exit(1)
 	}
+
+	// The account just logged in to becomes the active one
+	if meta.AuthMethod != auth.AuthMethodNone && alias != store.GetActiveAccount(providerID) {
+		if err := store.SetActiveAccount(providerID, alias); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to activate account: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Active %s account: %s\n", providerID, alias)
+	}
+}
+
+// deviceFlowCallbacks returns device flow callbacks that show a spinner while
+// polling. Returns nil (plain output) when stdout is not a terminal.
+func deviceFlowCallbacks() *auth.DeviceFlowCallbacks {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil
+	}
+
+	s := &spinner{}
+	return &auth.DeviceFlowCallbacks{
+		OnCodeDisplayed: func(code, verificationURL string) {
+			fmt.Println()
+			fmt.Println("To authenticate, please:")
+			fmt.Printf("  1. Open: %s\n", verificationURL)
+			fmt.Printf("  2. Enter code: %s\n", code)
+			fmt.Println()
+		},
+		OnPolling: func(attempt int, nextPollIn time.Duration) {
+			s.Start(fmt.Sprintf("Waiting for authorization (check %d, every %s)...", attempt, nextPollIn))
+		},
+		OnSuccess: func() {
+			s.Stop()
+			fmt.Println("\033[32m✓\033[0m Login successful!")
+		},
+		OnError: func(error) {
+			s.Stop()
+			fmt.Println("\033[31m✗\033[0m Login failed")
+		},
+	}
+}
+
+// spinner renders an ANSI spinner with a message on the current line.
+type spinner struct {
+	mu      sync.Mutex
+	message string
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Start begins spinning, or updates the message if already running.
+func (s *spinner) Start(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.message = message
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+}
+
+// Stop halts the spinner and clears its line.
+func (s *spinner) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+func (s *spinner) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for frame := 0; ; frame++ {
+		s.mu.Lock()
+		message := s.message
+		s.mu.Unlock()
+		fmt.Printf("\r\033[K%s %s", spinnerFrames[frame%len(spinnerFrames)], message)
+
+		select {
+		case <-stop:
+			fmt.Print("\r\033[K")
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 func cmdLogout() {
@@ -241,6 +393,7 @@ func cmdLogout() {
 	}
 
 	providerID := strings.ToLower(os.Args[2])
+	alias := parseAccountFlag("logout", os.Args[3:])
 	store := auth.NewStore()
 	registry := provider.NewRegistry()
 	provider.RegisterAll(registry)
@@ -251,18 +404,123 @@ func cmdLogout() {
 		os.Exit(1)
 	}
 
-	if err := store.DeleteCredentials(providerID); err != nil {
+	if err := store.DeleteCredentials(auth.AccountKey(providerID, alias)); err != nil {
 		fmt.Fprintf(os.Stderr, "Logout failed: %v\n", err)
 		os.Exit(1)
 	}
 
+	if alias != auth.DefaultAccount {
+		fmt.Printf("Logged out of %s account %s successfully.\n", providerID, alias)
+		return
+	}
 	fmt.Printf("Logged out of %s successfully.\n", providerID)
 }
 
+// parseAccountFlag parses the --account flag of the login and logout
+// commands, defaulting to the default account.
+func parseAccountFlag(command string, args []string) string {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	alias := fs.String("account", auth.DefaultAccount, "Account alias")
+	_ = fs.Parse(args)
+	if err := auth.ValidateAccountAlias(*alias); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return *alias
+}
+
+func cmdAccounts() {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, "Error: provider argument required")
+		fmt.Fprintln(os.Stderr, "Usage: opencompat accounts <provider> [--use <alias>]")
+		os.Exit(1)
+	}
+
+	providerID := strings.ToLower(os.Args[2])
+	fs := flag.NewFlagSet("accounts", flag.ExitOnError)
+	use := fs.String("use", "", "Account alias to make active")
+	_ = fs.Parse(os.Args[3:])
+
+	store := auth.NewStore()
+	registry := provider.NewRegistry()
+	provider.RegisterAll(registry)
+	if _, ok := registry.GetMeta(providerID); !ok {
+		fmt.Fprintf(os.Stderr, "Unknown provider: %s\n", providerID)
+		os.Exit(1)
+	}
+
+	if *use != "" {
+		if err := store.SetActiveAccount(providerID, *use); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to switch account: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Active %s account: %s (restart the server to apply)\n", providerID, *use)
+		return
+	}
+
+	aliases, err := store.ListAccounts(providerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list accounts: %v\n", err)
+		os.Exit(1)
+	}
+	if len(aliases) == 0 {
+		fmt.Printf("No %s accounts. Run: opencompat login %s\n", providerID, providerID)
+		return
+	}
+	active := store.GetActiveAccount(providerID)
+	for _, alias := range aliases {
+		marker := " "
+		if alias == active {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, alias)
+	}
+}
+
+func cmdMigrate() {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := fs.String("from", "v1", "Version of the input file")
+	to := fs.String("to", "v2", "Version to convert to")
+	input := fs.String("input", "", "Configuration file to migrate")
+	output := fs.String("output", "", "File to write the migrated configuration to")
+	_ = fs.Parse(os.Args[2:])
+
+	if *input == "" || *output == "" {
+		fmt.Fprintln(os.Stderr, "Error: --input and --output are required")
+		fmt.Fprintln(os.Stderr, "Usage: opencompat migrate [--from v1] [--to v2] --input <file> --output <file>")
+		os.Exit(1)
+	}
+	if err := migrate.File(*input, *output, *from, *to); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to migrate %s: %v\n", *input, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Migrated %s from %s to %s: %s\n", *input, *from, *to, *output)
+}
+
+func cmdAPIKey() {
+	if len(os.Args) < 3 || os.Args[2] != "add" {
+		fmt.Fprintln(os.Stderr, "Usage: opencompat apikey add")
+		os.Exit(1)
+	}
+
+	key, err := auth.NewStore().AddAPIKey()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to add API key: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(key)
+	fmt.Fprintln(os.Stderr, "Store this key now, it is not shown again. Clients send it as 'Authorization: Bearer <key>'.")
+	fmt.Fprintln(os.Stderr, "Restart the server to apply.")
+}
+
 func cmdInfo() {
 	store := auth.NewStore()
 	registry := provider.NewRegistry()
 	provider.RegisterAll(registry)
+	if err := registry.SetEnabledProviders(config.Load().EnabledProviders); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid OPENCOMPAT_ENABLED_PROVIDERS: %v\n", err)
+		os.Exit(1)
+	}
 
 	fmt.Println("Provider Status:")
 	fmt.Println()
@@ -270,6 +528,18 @@ func cmdInfo() {
 	for _, meta := range registry.ListMetas() {
 		fmt.Printf("  %s (%s):\n", meta.Name, meta.ID)
 
+		if !registry.IsEnabled(meta.ID) {
+			fmt.Printf("    Status: Disabled (not in OPENCOMPAT_ENABLED_PROVIDERS)\n")
+			fmt.Println()
+			continue
+		}
+
+		if meta.AuthMethod == auth.AuthMethodNone {
+			fmt.Printf("    Status: Enabled (no login required)\n")
+			fmt.Println()
+			continue
+		}
+
 		if !store.IsLoggedIn(meta.ID) {
 			fmt.Printf("    Status: Not logged in\n")
 			fmt.Printf("    Login:  opencompat login %s\n", meta.ID)
@@ -314,6 +584,9 @@ func cmdInfo() {
 				fmt.Printf("    API Key: ****\n")
 			}
 			fmt.Printf("    Created: %s\n", creds.CreatedAt.Format("2006-01-02 15:04:05"))
+			if !creds.ExpiresAt.IsZero() {
+				fmt.Printf("    Expires: %s\n", creds.ExpiresAt.Format("2006-01-02 15:04:05"))
+			}
 
 		case auth.AuthMethodDeviceFlow:
 			// Device flow uses OAuth credentials (refresh token is the GitHub token)
@@ -330,6 +603,9 @@ func cmdInfo() {
 			} else {
 				fmt.Printf("    Token: ****\n")
 			}
+			if !creds.ExpiresAt.IsZero() {
+				fmt.Printf("    Expires: %s\n", creds.ExpiresAt.Format("2006-01-02 15:04:05"))
+			}
 		}
 		fmt.Println()
 	}
@@ -392,8 +668,23 @@ func cmdServe() {
 	store := auth.NewStore()
 	registry := provider.NewRegistry()
 	provider.RegisterAll(registry)
+	if err := registry.SetEnabledProviders(cfg.EnabledProviders); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid OPENCOMPAT_ENABLED_PROVIDERS: %v\n", err)
+		os.Exit(1)
+	}
+	registry.SetCircuitConfig(provider.CircuitConfig{
+		FailureThreshold: max(cfg.CircuitFailureThreshold, 0),
+		Window:           time.Duration(cfg.CircuitWindow) * time.Second,
+		Cooldown:         time.Duration(cfg.CircuitCooldown) * time.Second,
+	})
+	aliases, err := modelalias.LoadWithDefaults(cfg.ModelAliasesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid OPENCOMPAT_MODEL_ALIASES_FILE: %v\n", err)
+		os.Exit(1)
+	}
+	registry.SetAliases(aliases)
 
-	// Initialize providers (only those logged in will activate)
+	// Initialize providers (only enabled, logged-in ones will activate)
 	if err := registry.Initialize(store); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize providers: %v\n", err)
 		os.Exit(1)
@@ -402,13 +693,37 @@ func cmdServe() {
 	// Check if at least one provider is active
 	if !registry.HasProviders() {
 		fmt.Fprintln(os.Stderr, "No providers available. Please log in to at least one provider:")
-		for _, meta := range registry.ListMetas() {
-			fmt.Fprintf(os.Stderr, "  opencompat login %s\n", meta.ID)
+		for _, id := range registry.EnabledProviders() {
+			fmt.Fprintf(os.Stderr, "  opencompat login %s\n", id)
 		}
 		os.Exit(1)
 	}
 
-	srv := server.New(registry, cfg)
+	if cfg.ProviderConfigFile != "" {
+		reload := func() {
+			opts, err := config.ReadEnvFile(cfg.ProviderConfigFile)
+			if err != nil {
+				slog.Error("failed to read provider config file", "path", cfg.ProviderConfigFile, "error", err)
+				return
+			}
+			if err := registry.Reload(opts); err != nil {
+				slog.Error("failed to reload provider configuration", "error", err)
+			}
+		}
+		reload()
+		watcher, err := config.NewWatcher(cfg.ProviderConfigFile, reload)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid OPENCOMPAT_PROVIDER_CONFIG_FILE: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() { _ = watcher.Close() }()
+	}
+
+	srv, err := server.New(registry, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create server: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Prefetch instructions before starting server
 	// This ensures all model instructions are available
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// argsEnv makes the test binary run main with these space-separated
+// arguments instead of the tests, so commands can be checked end to end.
+const argsEnv = "OPENCOMPAT_TEST_MAIN_ARGS"
+
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv(argsEnv); ok {
+		os.Args = append([]string{"opencompat"}, strings.Fields(args)...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCommand runs opencompat with args and returns its stdout and exit code.
+func runCommand(t *testing.T, args string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), argsEnv+"="+args)
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(out), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("running %q: %v", args, err)
+	}
+	return string(out), 0
+}
+
+func TestVersionCommand(t *testing.T) {
+	for _, args := range []string{"version", "--version", "version --format text"} {
+		t.Run(args, func(t *testing.T) {
+			out, code := runCommand(t, args)
+			if code != 0 {
+				t.Fatalf("exit code = %d, want 0", code)
+			}
+			for _, want := range []string{"opencompat dev", "Go:", "Providers:", "copilot"} {
+				if !strings.Contains(out, want) {
+					t.Errorf("output missing %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestVersionCommandJSON(t *testing.T) {
+	out, code := runCommand(t, "version --format json")
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0", code)
+	}
+	var info versionInfo
+	if err := json.Unmarshal([]byte(out), &info); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if info.Version == "" || info.GoVersion == "" || info.Platform == "" {
+		t.Errorf("version info = %+v, want version, Go version and platform", info)
+	}
+	ids := make([]string, len(info.Providers))
+	for i, p := range info.Providers {
+		ids[i] = p.ID
+		if p.Version != info.Version {
+			t.Errorf("provider %s version = %q, want the binary's %q", p.ID, p.Version, info.Version)
+		}
+	}
+	if !strings.Contains(strings.Join(ids, ","), "copilot") {
+		t.Errorf("providers = %v, want copilot registered", ids)
+	}
+}
+
+func TestVersionCommandUnknownFormat(t *testing.T) {
+	if _, code := runCommand(t, "version --format xml"); code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+}
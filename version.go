@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/edgard/opencompat/internal/provider"
+)
+
+// versionInfo is the output of the version command.
+type versionInfo struct {
+	Version      string            `json:"version"`
+	Commit       string            `json:"commit"`
+	Date         string            `json:"date"`
+	GoVersion    string            `json:"go_version"`
+	Platform     string            `json:"platform"`
+	Dependencies []dependency      `json:"dependencies"`
+	Providers    []providerVersion `json:"providers"`
+}
+
+type dependency struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+}
+
+// providerVersion describes a registered provider. Providers are compiled
+// into the binary, so they share its version.
+type providerVersion struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func cmdVersion() {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	format := fs.String("format", "text", "Output format (text, json)")
+	_ = fs.Parse(os.Args[2:])
+
+	info := buildVersionInfo()
+	switch *format {
+	case "text":
+		printVersionText(info)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(info)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format: %s (use text or json)\n", *format)
+		os.Exit(1)
+	}
+}
+
+// buildVersionInfo combines the ldflags version variables with the build
+// info embedded by the Go toolchain. VCS settings fill in the commit and
+// date for binaries built without ldflags (e.g. go install).
+func buildVersionInfo() versionInfo {
+	info := versionInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "none":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.Date == "unknown":
+				info.Date = s.Value
+			}
+		}
+		for _, dep := range bi.Deps {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			info.Dependencies = append(info.Dependencies, dependency{Path: dep.Path, Version: dep.Version})
+		}
+	}
+
+	registry := provider.NewRegistry()
+	provider.RegisterAll(registry)
+	for _, meta := range registry.ListMetas() {
+		info.Providers = append(info.Providers, providerVersion{ID: meta.ID, Name: meta.Name, Version: info.Version})
+	}
+	return info
+}
+
+func printVersionText(info versionInfo) {
+	fmt.Printf("opencompat %s\n", info.Version)
+	fmt.Printf("  Commit:   %s\n", info.Commit)
+	fmt.Printf("  Built:    %s\n", info.Date)
+	fmt.Printf("  Go:       %s\n", info.GoVersion)
+	fmt.Printf("  Platform: %s\n", info.Platform)
+
+	fmt.Println()
+	fmt.Println("Providers:")
+	for _, p := range info.Providers {
+		fmt.Printf("  %-12s %s (%s)\n", p.ID, p.Name, p.Version)
+	}
+
+	if len(info.Dependencies) > 0 {
+		fmt.Println()
+		fmt.Println("Dependencies:")
+		for _, dep := range info.Dependencies {
+			fmt.Printf("  %s %s\n", dep.Path, dep.Version)
+		}
+	}
+}